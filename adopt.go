@@ -0,0 +1,244 @@
+package fsstore
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/igumus/go-objectstore-lib"
+	"github.com/ipfs/go-cid"
+	mh "github.com/multiformats/go-multihash"
+)
+
+// _mappingSuffix handles the suffix of adopted directory path its mapping file is written at by default
+const _mappingSuffix = ".cids"
+
+// WithIngestMapping returns an IngestOption that specifies path mapping of adopted files (see `AdoptDirectory`)
+// is written at. If not set, the default is path of adopted directory suffixed with `.cids`
+func WithIngestMapping(path string) IngestOption {
+	return func(ic *ingestConfig) {
+		if len(path) > 0 {
+			ic.mapping = path
+		}
+	}
+}
+
+// AdoptResult captures outcome of adopting directory, see `AdoptDirectory`
+type AdoptResult struct {
+	Files   int
+	Linked  int
+	Bytes   int64
+	Mapping string
+	Root    cid.Cid
+}
+
+// adoptMapping captures wire format of an adopted file in mapping file
+type adoptMapping struct {
+	Path string `json:"path"`
+	Cid  string `json:"cid"`
+	Size int64  `json:"size"`
+}
+
+// Adopter defines the functions clients need to migrate plain directories of files into store.
+type Adopter interface {
+	AdoptDirectory(context.Context, string, ...IngestOption) (AdoptResult, error)
+}
+
+var _ Adopter = (*fsObjectStoreService)(nil)
+
+// AdoptDirectory - migrates local directory at dir into store: every regular file is created as object, hard
+// linked into bucket when possible so adopting takes no extra space, and the mapping of file paths (`/`
+// separated, relative to dir) to cids is written as newline delimited json `{"path", "cid", "size"}` (see
+// `WithIngestMapping`). Tree manifest preserving structure of dir is built as with `WithIngestTree`. Linked files
+// share content with their objects, so adopted files must not be modified in place afterwards; their times are left
+// untouched, objects count as written when adopted. Mapping lists
+// files adopted before a failure too, so adoption can be retried.
+func (f *fsObjectStoreService) AdoptDirectory(ctx context.Context, dir string, opts ...IngestOption) (AdoptResult, error) {
+	if err := f.authorize(ctx, OpWrite, cid.Undef); err != nil {
+		return AdoptResult{}, err
+	}
+	// files are created (and admitted while store is in maintenance) one by one, see `linkFile`
+	f.writeDone(ctx)
+	cfg := &ingestConfig{concurrency: _defIngestConcurrency, link: true}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if len(cfg.mapping) == 0 {
+		cfg.mapping = filepath.Clean(dir) + _mappingSuffix
+	}
+	info, err := os.Stat(dir)
+	if err != nil || !info.IsDir() {
+		return AdoptResult{}, ErrNotDirectory
+	}
+
+	result := AdoptResult{Mapping: cfg.mapping}
+	mapping := []adoptMapping{}
+	mu := sync.Mutex{}
+	entries, dirs, ok := f.ingest(ctx, dir, cfg, func(event IngestEvent) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		if event.Error != nil {
+			log.Printf("err: adopting file failed: %s, %s, %v\n", dir, event.Path, event.Error)
+			return true
+		}
+		result.Files++
+		result.Bytes += event.Size
+		if event.Linked {
+			result.Linked++
+		}
+		mapping = append(mapping, adoptMapping{Path: event.Path, Cid: event.Cid.String(), Size: event.Size})
+		return true
+	})
+	if err := writeMapping(cfg.mapping, mapping); err != nil {
+		log.Printf("err: writing adopted directory mapping failed: %s, %v\n", cfg.mapping, err)
+		return result, objectstore.ErrObjectWritingFailed
+	}
+	if !ok {
+		if ctxErr := checkContextError(ctx, f.isDebug()); ctxErr != nil {
+			return result, ctxErr
+		}
+		return result, ErrIngestIncomplete
+	}
+	if cfg.tree {
+		root, err := f.buildTree(ctx, entries, dirs)
+		if err != nil {
+			return result, err
+		}
+		result.Root = root.cid
+	}
+	if f.isDebug() {
+		log.Printf("debug: adopted directory: %s, %d files, %d linked\n", dir, result.Files, result.Linked)
+	}
+	return result, nil
+}
+
+// writeMapping - writes mapping of adopted files sorted by path at path
+func writeMapping(path string, mapping []adoptMapping) error {
+	sort.Slice(mapping, func(i, j int) bool {
+		return mapping[i].Path < mapping[j].Path
+	})
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(file)
+	for _, m := range mapping {
+		if err := encoder.Encode(m); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	return file.Close()
+}
+
+// linkFile - creates file at path as object by hard linking it into bucket; falls back to copying for encrypted
+// stores, content stored escaped (see `escapePlain`), and files on other devices than bucket
+func (f *fsObjectStoreService) linkFile(ctx context.Context, path string) IngestEvent {
+	if f.keys != nil {
+		return f.ingestFile(ctx, path)
+	}
+	event, handled := f.tryLinkFile(ctx, path)
+	if !handled {
+		// copy is admitted on its own by `CreateObject`, once link attempt released its admission
+		return f.ingestFile(ctx, path)
+	}
+	return event
+}
+
+// tryLinkFile - creates file at path as object by hard linking it into bucket, admitted as a write while store is
+// in maintenance mode; reports whether file is handled, so caller copies it otherwise
+func (f *fsObjectStoreService) tryLinkFile(ctx context.Context, path string) (IngestEvent, bool) {
+	if ctxErr := checkContextError(ctx, f.isDebug()); ctxErr != nil {
+		return IngestEvent{Error: ctxErr}, true
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return IngestEvent{Error: err}, true
+	}
+	before, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return IngestEvent{Error: err}, true
+	}
+	head := make([]byte, len(_envelopeMagic))
+	n, _ := io.ReadFull(file, head)
+	if isEnveloped(head[:n]) {
+		file.Close()
+		return IngestEvent{}, false
+	}
+	file.Seek(0, io.SeekStart)
+	prefix := objectstore.DigestPrefix
+	hash, err := mh.SumStream(file, prefix.MhType, prefix.MhLength)
+	file.Close()
+	if err != nil {
+		log.Printf("err: digesting adopted file failed: %s, %v\n", path, err)
+		return IngestEvent{Error: ErrDataDigestionFailed}, true
+	}
+	digest := cid.NewCidV1(prefix.Codec, hash)
+	event := IngestEvent{Cid: digest, Size: before.Size()}
+	if err := f.checkBlocked(ctx, OpWrite, digest); err != nil {
+		f.audit(ctx, OpWrite, digest, err)
+		return IngestEvent{Error: err}, true
+	}
+	if f.has(digest) {
+		f.stats.deduplicated()
+		return event, true
+	}
+
+	objLink := f.objectPath(digest)
+	if same, err := sameDevice(filepath.Dir(path), f.stripeOf(objLink)); err != nil || !same {
+		return IngestEvent{}, false
+	}
+	err = f.scan(ctx, digest, event.Size, func() (io.ReadCloser, error) {
+		return os.Open(path)
+	})
+	if err != nil {
+		return IngestEvent{Error: err}, true
+	}
+	if err := f.admitWrite(ctx); err != nil {
+		return IngestEvent{Error: err}, true
+	}
+	defer f.writeDone(ctx)
+	if err := f.fds.acquire(ctx); err != nil {
+		return IngestEvent{Error: err}, true
+	}
+	defer f.fds.release()
+	staged, err := stage(f.tempFor(objLink))
+	if err != nil {
+		return IngestEvent{Error: err}, true
+	}
+	discard(staged)
+	if err := os.Link(path, staged.Name()); err != nil {
+		if f.isDebug() {
+			log.Printf("debug: linking adopted file failed, copying: %s, %v\n", path, err)
+		}
+		return IngestEvent{}, false
+	}
+	// file changed while being digested is not linked under a stale cid
+	after, err := os.Stat(staged.Name())
+	if err != nil || !os.SameFile(before, after) || after.Size() != before.Size() || !after.ModTime().Equal(before.ModTime()) {
+		os.Remove(staged.Name())
+		return IngestEvent{}, false
+	}
+	if err := placeFile(objLink, func() error { return os.Rename(staged.Name(), objLink) }); err != nil {
+		os.Remove(staged.Name())
+		log.Printf("err: committing object failed: %s, %v\n", objLink, err)
+		return IngestEvent{Error: objectstore.ErrObjectWritingFailed}, true
+	}
+	f.share(digest, objLink)
+	f.negative.remove(digest.String())
+	err = f.journaled(JournalCreate, digest, event.Size)
+	f.audit(ctx, OpWrite, digest, err)
+	if err != nil {
+		event.Error = err
+		return event, true
+	}
+	f.notifyCreated(ctx, digest, event.Size)
+	event.Linked = true
+	return event, true
+}
@@ -0,0 +1,148 @@
+package fsstore
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"path"
+	"strings"
+
+	"github.com/ipfs/go-cid"
+)
+
+// ErrUnsupportedArchive is return, when object is not a zip, tar or gzip compressed tar archive.
+var ErrUnsupportedArchive = errors.New("fsobjectstore: unsupported archive format")
+
+// ErrArchiveMemberNotExists is return, when archive does not contain requested member.
+var ErrArchiveMemberNotExists = errors.New("fsobjectstore: archive member not exists")
+
+// _tarMagicOffset handles the offset of `ustar` magic within a tar header block
+const _tarMagicOffset = 257
+
+// ArchiveExtractor defines the functions clients need to serve files out of archive objects.
+type ArchiveExtractor interface {
+	ExtractArchiveMember(context.Context, cid.Cid, string) (io.ReadCloser, error)
+}
+
+var _ ArchiveExtractor = (*fsObjectStoreService)(nil)
+
+// ExtractArchiveMember - treats object with specified cid as a zip, tar or gzip compressed tar archive, and
+// streams member at memberPath without reading whole object. Callers must close returned reader.
+func (f *fsObjectStoreService) ExtractArchiveMember(ctx context.Context, c cid.Cid, memberPath string) (io.ReadCloser, error) {
+	obj, err := f.OpenObject(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	member := cleanMember(memberPath)
+
+	header := make([]byte, _tarMagicOffset+8)
+	n, err := io.ReadFull(obj, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		obj.Close()
+		return nil, ErrUnsupportedArchive
+	}
+	header = header[:n]
+	if _, err := obj.Seek(0, io.SeekStart); err != nil {
+		obj.Close()
+		return nil, ErrUnsupportedArchive
+	}
+
+	var rc io.ReadCloser
+	switch {
+	case bytes.HasPrefix(header, []byte("PK\x03\x04")) || bytes.HasPrefix(header, []byte("PK\x05\x06")):
+		rc, err = extractZip(obj, member)
+	case bytes.HasPrefix(header, []byte{0x1f, 0x8b}):
+		var gz *gzip.Reader
+		if gz, err = gzip.NewReader(obj); err != nil {
+			err = ErrUnsupportedArchive
+			break
+		}
+		rc, err = extractTar(gz, []io.Closer{gz, obj}, member)
+	case len(header) > _tarMagicOffset+5 && bytes.Equal(header[_tarMagicOffset:_tarMagicOffset+5], []byte("ustar")):
+		rc, err = extractTar(obj, []io.Closer{obj}, member)
+	default:
+		err = ErrUnsupportedArchive
+	}
+	if err != nil {
+		obj.Close()
+		if f.isDebug() {
+			log.Printf("debug: extracting archive member failed: %s, %s, %v\n", c, member, err)
+		}
+		return nil, err
+	}
+	return rc, nil
+}
+
+// extractZip - locates member via zip central directory, and returns reader closing object with it
+func extractZip(obj io.ReadSeekCloser, member string) (io.ReadCloser, error) {
+	size, err := obj.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, ErrUnsupportedArchive
+	}
+	at, ok := obj.(io.ReaderAt)
+	if !ok {
+		return nil, ErrUnsupportedArchive
+	}
+	archive, err := zip.NewReader(at, size)
+	if err != nil {
+		return nil, ErrUnsupportedArchive
+	}
+	for _, entry := range archive.File {
+		if cleanMember(entry.Name) != member || entry.FileInfo().IsDir() {
+			continue
+		}
+		content, err := entry.Open()
+		if err != nil {
+			return nil, ErrUnsupportedArchive
+		}
+		return &memberReader{Reader: content, closers: []io.Closer{content, obj}}, nil
+	}
+	return nil, ErrArchiveMemberNotExists
+}
+
+// extractTar - scans tar headers of r until member, and returns reader running closers on close
+func extractTar(r io.Reader, closers []io.Closer, member string) (io.ReadCloser, error) {
+	archive := tar.NewReader(r)
+	for {
+		entry, err := archive.Next()
+		if errors.Is(err, io.EOF) {
+			return nil, ErrArchiveMemberNotExists
+		}
+		if err != nil {
+			return nil, ErrUnsupportedArchive
+		}
+		if entry.Typeflag != tar.TypeReg && entry.Typeflag != tar.TypeRegA {
+			continue
+		}
+		if cleanMember(entry.Name) == member {
+			return &memberReader{Reader: archive, closers: closers}, nil
+		}
+	}
+}
+
+// cleanMember - normalizes archive member path, so `./a/b` and `/a/b` both match `a/b`
+func cleanMember(name string) string {
+	return strings.TrimPrefix(path.Clean("/"+name), "/")
+}
+
+// memberReader streams archive member, closing archive readers and object on close
+type memberReader struct {
+	io.Reader
+	closers []io.Closer
+}
+
+// Close - closes archive readers and underlying object
+func (m *memberReader) Close() error {
+	var err error
+	for _, c := range m.closers {
+		if closeErr := c.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
@@ -0,0 +1,206 @@
+package fsstore
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-cid"
+)
+
+// ErrAuditDisabled is return, when audit log is verified but objectstore is not configured to keep one.
+var ErrAuditDisabled = errors.New("fsobjectstore: audit log disabled")
+
+// ErrAuditChainBroken is return, when an audit record does not chain to its predecessor, or its hash does not match.
+var ErrAuditChainBroken = errors.New("fsobjectstore: audit chain broken")
+
+// ErrAuditReadingFailed is return, when reading audit log failed.
+var ErrAuditReadingFailed = errors.New("fsobjectstore: reading audit log failed")
+
+// _auditFile handles the internal file name of audit log
+const _auditFile = "audit"
+
+// AuditRecord captures an audited operation: who performed what and when, with its result. Each record
+// carries hash of its predecessor, and its own hash over predecessor hash and its content.
+type AuditRecord struct {
+	Seq       uint64    `json:"seq"`
+	Time      time.Time `json:"time"`
+	Principal string    `json:"principal"`
+	Op        Operation `json:"op"`
+	Bucket    string    `json:"bucket"`
+	Cid       string    `json:"cid,omitempty"`
+	Result    string    `json:"result"`
+	RequestID string    `json:"requestId,omitempty"`
+	TraceID   string    `json:"traceId,omitempty"`
+	Prev      string    `json:"prev"`
+	Hash      string    `json:"hash"`
+}
+
+// Auditor defines the functions clients need to check integrity of audit log.
+type Auditor interface {
+	VerifyAuditLog(context.Context) (int, error)
+}
+
+var _ Auditor = (*fsObjectStoreService)(nil)
+
+// auditLog appends hash chained records to audit log file
+type auditLog struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+	seq  uint64
+	last string
+}
+
+// openAuditLog - opens audit log at path for appending, resuming chain from its last record
+func openAuditLog(path string) (*auditLog, error) {
+	a := &auditLog{path: path}
+	err := scanAuditLog(path, func(rec AuditRecord) error {
+		a.seq, a.last = rec.Seq, rec.Hash
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0666)
+	if err != nil {
+		log.Printf("err: opening audit log failed: %s, %v\n", path, err)
+		return nil, ErrAuditReadingFailed
+	}
+	a.file = file
+	return a, nil
+}
+
+// hashAuditRecord - returns hex sha256 of record content (without its hash), chained to its predecessor
+func hashAuditRecord(rec AuditRecord) string {
+	rec.Hash = ""
+	data, _ := json.Marshal(rec)
+	sum := sha256.Sum256(append([]byte(rec.Prev+"\n"), data...))
+	return hex.EncodeToString(sum[:])
+}
+
+// append - appends record chained to last record of audit log
+func (a *auditLog) append(rec AuditRecord) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	rec.Seq = a.seq + 1
+	rec.Prev = a.last
+	rec.Hash = hashAuditRecord(rec)
+	data, err := json.Marshal(rec)
+	if err == nil {
+		_, err = fmt.Fprintf(a.file, "%s\n", data)
+	}
+	if err != nil {
+		log.Printf("err: appending audit log failed: %s, %v\n", a.path, err)
+		return
+	}
+	a.seq, a.last = rec.Seq, rec.Hash
+}
+
+// flush - syncs audit log file, so records appended are durable once store is closed
+func (a *auditLog) flush() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := a.file.Sync(); err != nil {
+		log.Printf("err: syncing audit log failed: %s, %v\n", a.path, err)
+	}
+}
+
+// scanAuditLog - decodes audit records of path in order, until fn returns error
+func scanAuditLog(path string, fn func(AuditRecord) error) error {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		log.Printf("err: opening audit log failed: %s, %v\n", path, err)
+		return ErrAuditReadingFailed
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		rec := AuditRecord{}
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			log.Printf("err: decoding audit record failed: %s, %v\n", path, err)
+			return ErrAuditChainBroken
+		}
+		if err := fn(rec); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		log.Printf("err: reading audit log failed: %s, %v\n", path, err)
+		return ErrAuditReadingFailed
+	}
+	return nil
+}
+
+// audit - accounts operation performed on behalf of principal attached to ctx in metrics, and records it with
+// its result when audit log is enabled. Operations performed internally on behalf of an audited operation are
+// neither accounted nor recorded.
+func (f *fsObjectStoreService) audit(ctx context.Context, op Operation, c cid.Cid, err error) {
+	if system, _ := ctx.Value(systemKey{}).(bool); system {
+		return
+	}
+	f.metrics.op(op, err)
+	if f.auditLog == nil {
+		return
+	}
+	rec := AuditRecord{Time: f.now().UTC(), Op: op, Bucket: f.bucket, Result: "ok"}
+	if p, ok := PrincipalFromContext(ctx); ok {
+		rec.Principal = p.ID
+	}
+	if c.Defined() {
+		rec.Cid = c.String()
+	}
+	meta := RequestMetaFromContext(ctx)
+	rec.RequestID, rec.TraceID = meta.RequestID, meta.TraceID
+	if err != nil {
+		rec.Result = err.Error()
+	}
+	f.auditLog.append(rec)
+}
+
+// VerifyAuditLog - checks that every audit record chains to its predecessor and carries a matching hash,
+// returning count of verified records; ErrAuditChainBroken reports tampering with (or truncation of) log
+func (f *fsObjectStoreService) VerifyAuditLog(ctx context.Context) (int, error) {
+	if f.auditLog == nil {
+		return 0, ErrAuditDisabled
+	}
+	if err := f.authorize(ctx, OpAdmin, cid.Undef); err != nil {
+		return 0, err
+	}
+	f.auditLog.mu.Lock()
+	defer f.auditLog.mu.Unlock()
+
+	count, prev := 0, ""
+	err := scanAuditLog(f.auditLog.path, func(rec AuditRecord) error {
+		if ctxErr := checkContextError(ctx, f.isDebug()); ctxErr != nil {
+			return ctxErr
+		}
+		if rec.Seq != uint64(count+1) || rec.Prev != prev || rec.Hash != hashAuditRecord(rec) {
+			log.Printf("err: audit chain broken at record: %d\n", count+1)
+			return ErrAuditChainBroken
+		}
+		count++
+		prev = rec.Hash
+		return nil
+	})
+	if err != nil {
+		return count, err
+	}
+	if uint64(count) != f.auditLog.seq || prev != f.auditLog.last {
+		log.Printf("err: audit log truncated at record: %d\n", count)
+		return count, ErrAuditChainBroken
+	}
+	return count, nil
+}
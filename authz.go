@@ -0,0 +1,123 @@
+package fsstore
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/ipfs/go-cid"
+)
+
+// ErrAccessDenied is return, when authorizer denies an operation. Authorizers should return (or wrap) it,
+// so front ends can map denials to their protocol (e.g. HTTP 403).
+var ErrAccessDenied = errors.New("fsobjectstore: access denied")
+
+// Operation represents kind of operation subject to authorization
+type Operation string
+
+const (
+	// OpRead covers reading objects, their metadata and references, and existence checks
+	OpRead Operation = "read"
+	// OpList covers listing objects of bucket
+	OpList Operation = "list"
+	// OpWrite covers creating objects and setting their metadata
+	OpWrite Operation = "write"
+	// OpDelete covers deleting objects
+	OpDelete Operation = "delete"
+	// OpAdmin covers bucket wide maintenance, e.g. snapshots, garbage collection, verification and key rotation
+	OpAdmin Operation = "admin"
+)
+
+// Principal captures identity operations are performed on behalf of
+type Principal struct {
+	ID    string
+	Roles []string
+}
+
+// Authorizer decides whether principal attached to context (see `PrincipalFromContext`) may perform
+// operation on bucket. Cid is `cid.Undef` for operations not targeting a single object, and for creations
+// whose cid is not yet known.
+type Authorizer interface {
+	Authorize(ctx context.Context, op Operation, bucket string, c cid.Cid) error
+}
+
+// AuthorizerFunc adapts an ordinary function to Authorizer
+type AuthorizerFunc func(ctx context.Context, op Operation, bucket string, c cid.Cid) error
+
+// Authorize - calls fn
+func (fn AuthorizerFunc) Authorize(ctx context.Context, op Operation, bucket string, c cid.Cid) error {
+	return fn(ctx, op, bucket, c)
+}
+
+// principalKey is context key of attached principal
+type principalKey struct{}
+
+// systemKey is context key marking store internal operations
+type systemKey struct{}
+
+// ContextWithPrincipal returns copy of ctx carrying principal, for authorizer to inspect
+func ContextWithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFromContext returns principal attached to ctx, and whether one was attached
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
+
+// withSystem - marks ctx as store internal, so operations performed on behalf of an already authorized
+// (or background) operation are not authorized again
+func withSystem(ctx context.Context) context.Context {
+	return context.WithValue(ctx, systemKey{}, true)
+}
+
+// authorize - consults authorizer (if configured) whether operation may be performed, refuses reads and writes
+// of blocked objects (see `BlockCID`), and injects chaos (see `WithChaos`); writes of clients are rejected while
+// store is a standby, held back while store is in maintenance mode (see `EnterMaintenance`), and provision lazily
+// initialized store (see `WithLazyInit`) once allowed. Admitted writes finish via `writeDone`
+func (f *fsObjectStoreService) authorize(ctx context.Context, op Operation, c cid.Cid) error {
+	if system, _ := ctx.Value(systemKey{}).(bool); system {
+		return nil
+	}
+	if (op == OpWrite || op == OpDelete) && f.isStandby() {
+		return ErrStandbyReadOnly
+	}
+	if (op == OpWrite || op == OpDelete) && f.readOnly {
+		return ErrStoreReadOnly
+	}
+	if f.authorizer != nil {
+		if err := f.authorizer.Authorize(ctx, op, f.bucket, c); err != nil {
+			if f.isDebug() {
+				p, _ := PrincipalFromContext(ctx)
+				log.Printf("debug: operation denied: %s, %s, %s, %s, %v\n", op, f.bucket, c, p.ID, err)
+			}
+			f.audit(ctx, op, c, err)
+			return err
+		}
+	}
+	if err := f.checkBlocked(ctx, op, c); err != nil {
+		f.audit(ctx, op, c, err)
+		return err
+	}
+	if err := f.chaos.inject(ctx, op, f.isDebug()); err != nil {
+		f.audit(ctx, op, c, err)
+		return err
+	}
+	if op == OpWrite || op == OpDelete {
+		if err := f.admitWrite(ctx); err != nil {
+			f.audit(ctx, op, c, err)
+			return err
+		}
+		if err := f.ensureBucket(); err != nil {
+			f.writeDone(ctx)
+			return err
+		}
+	}
+	return nil
+}
+
+// has - checks whether object exists, without authorization
+func (f *fsObjectStoreService) has(c cid.Cid) bool {
+	return exists(f.objectPath(c))
+}
@@ -0,0 +1,182 @@
+package fsstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-cid"
+)
+
+// ErrObjectBlocked is return, when object being read or written is on deny-list of store (see `BlockCID`).
+var ErrObjectBlocked = errors.New("fsobjectstore: object blocked")
+
+// ErrInvalidBlockedCID is return, when undefined cid is being blocked or unblocked.
+var ErrInvalidBlockedCID = errors.New("fsobjectstore: invalid blocked cid")
+
+// ErrBlocklistWritingFailed is return, when persisting deny-list of store failed.
+var ErrBlocklistWritingFailed = errors.New("fsobjectstore: writing blocklist failed")
+
+// ErrBlocklistReadingFailed is return, when persisted deny-list of store can not be read.
+var ErrBlocklistReadingFailed = errors.New("fsobjectstore: reading blocklist failed")
+
+// _blocklistFile handles the internal file name of deny-list of blocked objects
+const _blocklistFile = "blocklist"
+
+// BlockedCID captures an object on deny-list of store, with reason and time it was blocked at
+type BlockedCID struct {
+	Cid     string    `json:"cid"`
+	Reason  string    `json:"reason,omitempty"`
+	Blocked time.Time `json:"blocked"`
+}
+
+// Blocklist defines the functions clients need to prevent specific content from being stored or served.
+type Blocklist interface {
+	BlockCID(ctx context.Context, c cid.Cid, reason string) error
+	UnblockCID(ctx context.Context, c cid.Cid) error
+	BlockedCIDs(ctx context.Context) ([]BlockedCID, error)
+}
+
+var _ Blocklist = (*fsObjectStoreService)(nil)
+
+// blocklist holds deny-list of store in memory, persisted to internal blocklist file on every change
+type blocklist struct {
+	mu      sync.RWMutex
+	entries map[string]BlockedCID
+}
+
+// blocked - checks whether object with cid is on deny-list
+func (b *blocklist) blocked(c cid.Cid) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	_, ok := b.entries[c.String()]
+	return ok
+}
+
+// BlockCID - puts object with cid on deny-list of store: reads and writes of it are refused with
+// `ErrObjectBlocked` (and audited, see `WithAuditLog`) until it is unblocked. Stored copy of a blocked object is
+// kept, so blocking is reversible; delete it to get rid of it. Deny-list is persisted, surviving restarts.
+func (f *fsObjectStoreService) BlockCID(ctx context.Context, c cid.Cid, reason string) error {
+	if err := f.authorize(ctx, OpAdmin, c); err != nil {
+		return err
+	}
+	err := f.updateBlocklist(c, func(entries map[string]BlockedCID) {
+		if _, ok := entries[c.String()]; !ok {
+			entries[c.String()] = BlockedCID{Cid: c.String(), Reason: reason, Blocked: f.now().UTC()}
+		}
+	})
+	f.audit(ctx, OpAdmin, c, err)
+	if err == nil {
+		log.Printf("warn: object blocked: %s, %s, %s\n", f.bucket, c, reason)
+	}
+	return err
+}
+
+// UnblockCID - removes object with cid from deny-list of store, so it is read and written again
+func (f *fsObjectStoreService) UnblockCID(ctx context.Context, c cid.Cid) error {
+	if err := f.authorize(ctx, OpAdmin, c); err != nil {
+		return err
+	}
+	err := f.updateBlocklist(c, func(entries map[string]BlockedCID) {
+		delete(entries, c.String())
+	})
+	f.audit(ctx, OpAdmin, c, err)
+	if err == nil && f.isDebug() {
+		log.Printf("debug: object unblocked: %s, %s\n", f.bucket, c)
+	}
+	return err
+}
+
+// BlockedCIDs - returns deny-list of store, ordered by time objects were blocked at
+func (f *fsObjectStoreService) BlockedCIDs(ctx context.Context) ([]BlockedCID, error) {
+	if err := f.authorize(ctx, OpAdmin, cid.Undef); err != nil {
+		return nil, err
+	}
+	f.blocklist.mu.RLock()
+	entries := make([]BlockedCID, 0, len(f.blocklist.entries))
+	for _, entry := range f.blocklist.entries {
+		entries = append(entries, entry)
+	}
+	f.blocklist.mu.RUnlock()
+	sort.Slice(entries, func(i, j int) bool {
+		if !entries[i].Blocked.Equal(entries[j].Blocked) {
+			return entries[i].Blocked.Before(entries[j].Blocked)
+		}
+		return entries[i].Cid < entries[j].Cid
+	})
+	f.audit(ctx, OpAdmin, cid.Undef, nil)
+	return entries, nil
+}
+
+// updateBlocklist - applies update to copy of deny-list, persisting it before it takes effect
+func (f *fsObjectStoreService) updateBlocklist(c cid.Cid, update func(map[string]BlockedCID)) error {
+	if !c.Defined() {
+		return ErrInvalidBlockedCID
+	}
+	if err := f.ensureBucket(); err != nil {
+		return err
+	}
+	f.blocklist.mu.Lock()
+	defer f.blocklist.mu.Unlock()
+	entries := make(map[string]BlockedCID, len(f.blocklist.entries)+1)
+	for key, entry := range f.blocklist.entries {
+		entries[key] = entry
+	}
+	update(entries)
+	list := make([]BlockedCID, 0, len(entries))
+	for _, entry := range entries {
+		list = append(list, entry)
+	}
+	data, err := json.Marshal(list)
+	if err == nil {
+		err = f.writeInternal(f.internalPath(_blocklistFile), data)
+	}
+	if err != nil {
+		log.Printf("err: writing blocklist failed: %s, %v\n", f.bucket, err)
+		return ErrBlocklistWritingFailed
+	}
+	f.blocklist.entries = entries
+	return nil
+}
+
+// loadBlocklist - loads persisted deny-list of store; store failing to read it refuses to open, rather than
+// serving blocked content
+func (f *fsObjectStoreService) loadBlocklist() error {
+	path := f.internalPath(_blocklistFile)
+	entries := map[string]BlockedCID{}
+	if exists(path) {
+		data, err := f.readInternal(path)
+		list := []BlockedCID{}
+		if err == nil {
+			err = json.Unmarshal(data, &list)
+		}
+		if err != nil {
+			log.Printf("err: reading blocklist failed: %s, %v\n", path, err)
+			return ErrBlocklistReadingFailed
+		}
+		for _, entry := range list {
+			entries[entry.Cid] = entry
+		}
+	}
+	f.blocklist.mu.Lock()
+	f.blocklist.entries = entries
+	f.blocklist.mu.Unlock()
+	return nil
+}
+
+// checkBlocked - refuses reads and writes of client of object with cid on deny-list, logging attempt
+func (f *fsObjectStoreService) checkBlocked(ctx context.Context, op Operation, c cid.Cid) error {
+	if system, _ := ctx.Value(systemKey{}).(bool); system {
+		return nil
+	}
+	if (op != OpRead && op != OpWrite) || !c.Defined() || !f.blocklist.blocked(c) {
+		return nil
+	}
+	p, _ := PrincipalFromContext(ctx)
+	log.Printf("warn: blocked object requested: %s, %s, %s, %s\n", op, f.bucket, c, p.ID)
+	return ErrObjectBlocked
+}
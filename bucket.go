@@ -0,0 +1,262 @@
+package fsstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ipfs/go-cid"
+)
+
+// ErrBucketInUse is return, when renamed bucket is open by this store or another registered store.
+var ErrBucketInUse = errors.New("fsobjectstore: bucket in use")
+
+// ErrBucketRenameFailed is return, when renaming bucket failed.
+var ErrBucketRenameFailed = errors.New("fsobjectstore: bucket rename failed")
+
+// _bucketFile handles the internal file name of bucket metadata
+const _bucketFile = "bucket"
+
+// BucketMetadata captures descriptive metadata of bucket. `Name`, `Created`, `Layouts` and `Format` are
+// maintained by store: bucket creation time is recorded when bucket is provisioned, name is updated when bucket
+// is renamed, and historical layouts objects may still be stored in are recorded as they are configured (see
+// `WithHistoricalLayout`). `Format` is recorded for buckets provisioned with internal files checksummed; internal
+// files of buckets without it are accepted unchecksummed, as they may predate checksums.
+type BucketMetadata struct {
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Owner       string    `json:"owner,omitempty"`
+	Created     time.Time `json:"created"`
+	Layouts     []string  `json:"layouts,omitempty"`
+	Format      int       `json:"format,omitempty"`
+}
+
+// BucketManager defines the functions clients need to rename buckets and manage their metadata.
+type BucketManager interface {
+	RenameBucket(ctx context.Context, old, new string) error
+	StatBucket(context.Context) (BucketMetadata, error)
+	SetBucketMetadata(context.Context, BucketMetadata) error
+}
+
+var _ BucketManager = (*fsObjectStoreService)(nil)
+
+// StatBucket - returns metadata of bucket of store
+func (f *fsObjectStoreService) StatBucket(ctx context.Context) (BucketMetadata, error) {
+	if err := f.authorize(ctx, OpRead, cid.Undef); err != nil {
+		return BucketMetadata{}, err
+	}
+	meta, err := f.statBucket()
+	f.audit(ctx, OpRead, cid.Undef, err)
+	return meta, err
+}
+
+// statBucket - reads recorded metadata of bucket; bucket not provisioned yet has only a name
+func (f *fsObjectStoreService) statBucket() (BucketMetadata, error) {
+	if !f.isProvisioned() {
+		return BucketMetadata{Name: f.bucket}, nil
+	}
+	return f.readBucketMetadata()
+}
+
+// readBucketMetadata - reads recorded metadata of bucket, defaulting to its name when none is recorded. Bucket
+// metadata records format of internal files, so it is accepted unchecksummed only when it records none.
+func (f *fsObjectStoreService) readBucketMetadata() (BucketMetadata, error) {
+	path := f.internalPath(_bucketFile)
+	if !exists(path) {
+		return BucketMetadata{Name: f.bucket}, nil
+	}
+	data, err := read(path)
+	if err != nil {
+		return BucketMetadata{}, err
+	}
+	content, err := unframe(data, true)
+	if err != nil {
+		log.Printf("err: internal file checksum mismatch: %s\n", path)
+		return BucketMetadata{}, err
+	}
+	meta := BucketMetadata{}
+	if err := json.Unmarshal(content, &meta); err != nil {
+		log.Printf("err: decoding bucket metadata failed: %s, %v\n", f.bucket, err)
+		return BucketMetadata{}, ErrInternalCorrupted
+	}
+	if meta.Format >= _framedFormat && len(content) == len(data) {
+		log.Printf("err: internal file checksum missing: %s\n", path)
+		return BucketMetadata{}, ErrInternalCorrupted
+	}
+	meta.Name = f.bucket
+	return meta, nil
+}
+
+// SetBucketMetadata - replaces description and owner of bucket of store with given ones; name and creation
+// time are maintained by store, so they are ignored
+func (f *fsObjectStoreService) SetBucketMetadata(ctx context.Context, meta BucketMetadata) error {
+	if err := f.authorize(ctx, OpAdmin, cid.Undef); err != nil {
+		return err
+	}
+	err := f.setBucketMetadata(meta)
+	f.audit(ctx, OpAdmin, cid.Undef, err)
+	return err
+}
+
+// setBucketMetadata - records description and owner of bucket, keeping its creation time
+func (f *fsObjectStoreService) setBucketMetadata(meta BucketMetadata) error {
+	if err := f.ensureBucket(); err != nil {
+		return err
+	}
+	current, err := f.statBucket()
+	if err != nil && !errors.Is(err, ErrInternalCorrupted) {
+		return err
+	}
+	current.Name = f.bucket
+	current.Description = meta.Description
+	current.Owner = meta.Owner
+	if current.Created.IsZero() {
+		current.Created = f.now().UTC()
+	}
+	return f.writeBucketMetadata(f.bucketDir(), current)
+}
+
+// recordBucket - records metadata of newly provisioned bucket, and whether its internal files may predate
+// checksums; buckets provisioned before metadata was recorded are stamped with time they are first opened, and
+// recorded with no format, since fresh buckets only have every internal file checksummed
+func (f *fsObjectStoreService) recordBucket(fresh bool) error {
+	if !exists(f.internalPath(_bucketFile)) {
+		meta := BucketMetadata{Name: f.bucket, Created: f.now().UTC()}
+		if fresh {
+			meta.Format = _framedFormat
+		}
+		if err := f.writeBucketMetadata(f.bucketDir(), meta); err != nil {
+			return err
+		}
+	}
+	return f.loadFormat(fresh)
+}
+
+// loadFormat - determines whether internal files of bucket may predate checksums, as bucket metadata records;
+// bucket with no metadata recorded is taken as fresh only when its internal directory is just created
+func (f *fsObjectStoreService) loadFormat(fresh bool) error {
+	if !exists(f.internalPath(_bucketFile)) {
+		f.legacyFrames = !fresh
+		return nil
+	}
+	meta, err := f.readBucketMetadata()
+	if err != nil {
+		return err
+	}
+	f.legacyFrames = meta.Format < _framedFormat
+	return nil
+}
+
+// writeBucketMetadata - writes metadata of bucket at directory dir, staged in internals of that bucket
+func (f *fsObjectStoreService) writeBucketMetadata(dir string, meta BucketMetadata) error {
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	return write(filepath.Join(dir, _internalDir, _tempDir), filepath.Join(dir, _internalDir, _bucketFile), frame(data))
+}
+
+// RenameBucket - renames bucket old under data directories of store to new. Journal, references, statistics
+// and snapshots of bucket are bucket relative, so they move along unchanged; recorded name of bucket is updated.
+// Bucket open by this store, or by another store registered in process (see `Register`), can not be renamed.
+// Bucket directory of every data directory is renamed, and renamed ones are moved back when one fails, so old
+// either is renamed as a whole or stays in place.
+func (f *fsObjectStoreService) RenameBucket(ctx context.Context, old, new string) error {
+	if err := f.authorize(ctx, OpAdmin, cid.Undef); err != nil {
+		return err
+	}
+	err := f.renameBucket(ctx, old, new)
+	f.audit(ctx, OpAdmin, cid.Undef, err)
+	return err
+}
+
+// renameBucket - renames directories of bucket old as new in every data directory, rolling back on failure
+func (f *fsObjectStoreService) renameBucket(ctx context.Context, old, new string) error {
+	if err := validateBucket(old); err != nil {
+		return err
+	}
+	if err := validateBucket(new); err != nil {
+		return err
+	}
+	if info, err := os.Stat(filepath.Join(f.dataDir, old)); err != nil || !info.IsDir() {
+		return ErrBucketNotExists
+	}
+	renames := map[string]string{}
+	for _, stripe := range f.stripeDirs() {
+		dataDir := filepath.Dir(stripe)
+		from, to := filepath.Join(dataDir, old), filepath.Join(dataDir, new)
+		if exists(to) {
+			return ErrBucketExists
+		}
+		if f.bucketOpen(from) {
+			return ErrBucketInUse
+		}
+		if exists(from) {
+			renames[from] = to
+		}
+	}
+	if ctxErr := checkContextError(ctx, f.isDebug()); ctxErr != nil {
+		return ctxErr
+	}
+
+	renamed := map[string]string{}
+	var err error
+	for from, to := range renames {
+		if err = os.Rename(from, to); err != nil {
+			break
+		}
+		renamed[from] = to
+	}
+	if err != nil {
+		for from, to := range renamed {
+			if rollbackErr := os.Rename(to, from); rollbackErr != nil {
+				log.Printf("err: rolling back bucket rename failed: %s, %v\n", to, rollbackErr)
+			}
+		}
+		log.Printf("err: renaming bucket failed: %s, %s, %v\n", old, new, err)
+		return ErrBucketRenameFailed
+	}
+
+	primary := filepath.Join(f.dataDir, new)
+	meta := BucketMetadata{Name: new}
+	if data, err := os.ReadFile(filepath.Join(primary, _internalDir, _bucketFile)); err == nil {
+		if content, err := unframe(data, true); err == nil {
+			json.Unmarshal(content, &meta)
+		}
+	}
+	meta.Name = new
+	if meta.Created.IsZero() {
+		meta.Created = f.now().UTC()
+	}
+	if err := f.writeBucketMetadata(primary, meta); err != nil {
+		log.Printf("warn: recording name of renamed bucket failed: %s, %v\n", new, err)
+	}
+	if f.isDebug() {
+		log.Printf("debug: renamed bucket: %s, %s, %d data directories\n", old, new, len(renamed))
+	}
+	return nil
+}
+
+// bucketOpen - checks whether bucket directory dir is a stripe of this store, or of a registered store
+func (f *fsObjectStoreService) bucketOpen(dir string) bool {
+	stores := []*fsObjectStoreService{f}
+	registry.mu.RLock()
+	for _, store := range registry.stores {
+		if s, ok := store.(*fsObjectStoreService); ok {
+			stores = append(stores, s)
+		}
+	}
+	registry.mu.RUnlock()
+	for _, s := range stores {
+		for _, stripe := range s.stripeDirs() {
+			if filepath.Clean(stripe) == filepath.Clean(dir) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,147 @@
+package fsstore
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/igumus/go-objectstore-lib"
+	"github.com/ipfs/go-cid"
+)
+
+// callConfigKey is context key of per call configuration
+type callConfigKey struct{}
+
+// Captures/Represents per call configuration information, overriding store wide configuration for a call
+type callConfig struct {
+	verify   bool
+	noVerify bool
+	timeout  time.Duration
+	ttl      time.Duration
+	metadata *Metadata
+	dryRun   bool
+	progress Progress
+	tolerant bool
+}
+
+// A CallOption sets options of a single call, such as content verification or metadata of created object.
+type CallOption func(*callConfig)
+
+// WithVerify returns a CallOption that specifies locally read content is verified against cid, as replica
+// answers are; results are cached while stored file is unchanged (see `WithVerifyCache`). Options not applying
+// to a call are ignored.
+func WithVerify() CallOption {
+	return func(cc *callConfig) {
+		cc.verify = true
+	}
+}
+
+// WithNoVerify returns a CallOption that specifies content read from replicas (see `WithReplica`) is trusted
+// without verifying it against cid.
+func WithNoVerify() CallOption {
+	return func(cc *callConfig) {
+		cc.noVerify = true
+	}
+}
+
+// WithTimeout returns a CallOption that specifies deadline of call, in place of store wide operation
+// timeout (see `WithOperationTimeout`).
+func WithTimeout(d time.Duration) CallOption {
+	return func(cc *callConfig) {
+		cc.timeout = d
+	}
+}
+
+// WithTTL returns a CallOption that specifies created object expires after given duration, recorded as
+// `Expires` of its metadata; expired objects are moved to trash by lifecycle runs (see `ApplyLifecycle`).
+func WithTTL(d time.Duration) CallOption {
+	return func(cc *callConfig) {
+		cc.ttl = d
+	}
+}
+
+// WithMetadata returns a CallOption that specifies metadata attached to created object.
+func WithMetadata(meta Metadata) CallOption {
+	return func(cc *callConfig) {
+		cc.metadata = &meta
+	}
+}
+
+// WithDryRun returns a CallOption that specifies destructive call (see `DryRunner`) only reports what it would
+// delete or move, without touching disk.
+func WithDryRun(d bool) CallOption {
+	return func(cc *callConfig) {
+		cc.dryRun = d
+	}
+}
+
+// Caller defines the functions clients need to tune behavior of a single read or create call.
+type Caller interface {
+	ReadObjectWith(context.Context, cid.Cid, ...CallOption) ([]byte, error)
+	CreateObjectWith(context.Context, io.Reader, ...CallOption) (cid.Cid, error)
+	ListObjectWith(context.Context, ...CallOption) <-chan objectstore.ListObjectEvent
+}
+
+var _ Caller = (*fsObjectStoreService)(nil)
+
+// newCallConfig - applies given options to an empty call configuration
+func newCallConfig(opts []CallOption) *callConfig {
+	cfg := &callConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+// callConfigFrom - returns per call configuration attached to context, empty configuration otherwise
+func callConfigFrom(ctx context.Context) *callConfig {
+	if cfg, ok := ctx.Value(callConfigKey{}).(*callConfig); ok {
+		return cfg
+	}
+	return &callConfig{}
+}
+
+// withCall - attaches per call configuration to context, applying its timeout
+func (cc *callConfig) withCall(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx = context.WithValue(ctx, callConfigKey{}, cc)
+	if cc.progress != nil {
+		ctx = ContextWithProgress(ctx, cc.progress)
+	}
+	if cc.timeout > 0 {
+		return context.WithTimeout(ctx, cc.timeout)
+	}
+	return ctx, func() {}
+}
+
+// ReadObjectWith - reads object with specified cid (aka content identifier) as `ReadObject` does, tuned by
+// given call options
+func (f *fsObjectStoreService) ReadObjectWith(ctx context.Context, c cid.Cid, opts ...CallOption) ([]byte, error) {
+	cfg := newCallConfig(opts)
+	ctx, cancel := cfg.withCall(ctx)
+	defer cancel()
+	if cfg.verify {
+		return f.verifyRead(func() ([]byte, error) { return f.ReadObject(ctx, c) }, c)
+	}
+	return f.ReadObject(ctx, c)
+}
+
+// CreateObjectWith - creates object with specified data (aka content) as `CreateObject` does, tuned by given
+// call options; metadata (and expiry) of options is attached once object is created. Objects deduplicated with
+// an existing one keep metadata of their creator.
+func (f *fsObjectStoreService) CreateObjectWith(ctx context.Context, reader io.Reader, opts ...CallOption) (cid.Cid, error) {
+	cfg := newCallConfig(opts)
+	ctx, cancel := cfg.withCall(ctx)
+	defer cancel()
+	digest, created, err := f.CreateObjectIfAbsent(ctx, reader)
+	if err != nil || !created || (cfg.metadata == nil && cfg.ttl <= 0) {
+		return digest, err
+	}
+	meta := Metadata{}
+	if cfg.metadata != nil {
+		meta = *cfg.metadata
+	}
+	if cfg.ttl > 0 {
+		meta.Expires = f.now().Add(cfg.ttl)
+	}
+	return digest, f.SetMetadata(ctx, digest, meta)
+}
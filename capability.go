@@ -0,0 +1,63 @@
+package fsstore
+
+// APIVersion handles the version of store API reported by `Capabilities`; it is incremented when behaviour
+// callers rely on changes incompatibly
+const APIVersion = 1
+
+// Capability represents an optional feature of store, generic callers may adapt to
+type Capability string
+
+const (
+	// CapDelete covers deleting objects (see `DeletableStore`); standby stores not yet promoted refuse deletes
+	CapDelete Capability = "delete"
+	// CapStreaming covers streaming objects in and out of store (see `StreamingStore`), and writing them in chunks
+	// (see `ResumableUploader`)
+	CapStreaming Capability = "streaming"
+	// CapRanges covers reading byte ranges of objects (see `RangeReader`), and seeking readers of `OpenObject`
+	CapRanges Capability = "ranges"
+	// CapMetadata covers attaching descriptive information to objects (see `MetadataStore`)
+	CapMetadata Capability = "metadata"
+	// CapTTL covers expiry of created objects (see `WithTTL`), recorded in their metadata and enforced by lifecycle
+	// runs
+	CapTTL Capability = "ttl"
+	// CapEncryption covers encrypting content of objects at rest (see `WithEncryptionKey` and `WithKeyProvider`)
+	CapEncryption Capability = "encryption"
+)
+
+// CapabilitySet captures API version of store and optional features store instance supports, given its
+// configuration
+type CapabilitySet struct {
+	Version      int          `json:"version"`
+	Capabilities []Capability `json:"capabilities"`
+}
+
+// Has - checks whether set contains capability c
+func (s CapabilitySet) Has(c Capability) bool {
+	for _, capability := range s.Capabilities {
+		if capability == c {
+			return true
+		}
+	}
+	return false
+}
+
+// CapabilityReporter defines the functions clients need to discover optional features of a store, rather than
+// type asserting every feature interface.
+type CapabilityReporter interface {
+	Capabilities() CapabilitySet
+}
+
+var _ CapabilityReporter = (*fsObjectStoreService)(nil)
+
+// Capabilities - returns API version and optional features store supports as currently configured; features
+// depending on state (e.g. deletes of standby stores) are reported as of call
+func (f *fsObjectStoreService) Capabilities() CapabilitySet {
+	caps := []Capability{CapStreaming, CapRanges, CapMetadata, CapTTL}
+	if !f.isStandby() {
+		caps = append([]Capability{CapDelete}, caps...)
+	}
+	if f.keys != nil {
+		caps = append(caps, CapEncryption)
+	}
+	return CapabilitySet{Version: APIVersion, Capabilities: caps}
+}
@@ -0,0 +1,380 @@
+package fsstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ipfs/go-cid"
+)
+
+// ErrCatalogDisabled is return, when catalog is queried but objectstore is not configured to maintain one.
+var ErrCatalogDisabled = errors.New("fsobjectstore: catalog disabled")
+
+// ErrCatalogFailed is return, when catalog database can not be read or written.
+var ErrCatalogFailed = errors.New("fsobjectstore: catalog failed")
+
+// ErrInvalidFilter is return, when catalog query filter can not be parsed.
+var ErrInvalidFilter = errors.New("fsobjectstore: invalid catalog filter")
+
+// _catalogSchema handles the statements creating catalog tables, shared by buckets cataloged in same database
+var _catalogSchema = []string{
+	`CREATE TABLE IF NOT EXISTS fsstore_objects (
+		bucket TEXT NOT NULL,
+		cid TEXT NOT NULL,
+		size INTEGER NOT NULL,
+		mtime INTEGER NOT NULL,
+		content_type TEXT NOT NULL DEFAULT '',
+		PRIMARY KEY (bucket, cid)
+	)`,
+	`CREATE TABLE IF NOT EXISTS fsstore_tags (
+		bucket TEXT NOT NULL,
+		cid TEXT NOT NULL,
+		tag TEXT NOT NULL,
+		PRIMARY KEY (bucket, cid, tag)
+	)`,
+	`CREATE INDEX IF NOT EXISTS fsstore_objects_size ON fsstore_objects (bucket, size)`,
+	`CREATE INDEX IF NOT EXISTS fsstore_objects_mtime ON fsstore_objects (bucket, mtime)`,
+	`CREATE INDEX IF NOT EXISTS fsstore_tags_tag ON fsstore_tags (bucket, tag)`,
+}
+
+// _filterClause handles the pattern of a catalog filter clause, `field op value`
+var _filterClause = regexp.MustCompile(`^\s*([A-Za-z-]+)\s*(<=|>=|!=|=|<|>)\s*(\S+)\s*$`)
+
+// _filterAnd handles the pattern separating clauses of catalog filter
+var _filterAnd = regexp.MustCompile(`(?i)\s+and\s+`)
+
+// _sizeUnits maps size suffixes accepted by catalog filters to their multipliers
+var _sizeUnits = map[string]int64{"": 1, "B": 1, "KB": 1 << 10, "MB": 1 << 20, "GB": 1 << 30, "TB": 1 << 40}
+
+// CatalogEntry captures an object of catalog
+type CatalogEntry struct {
+	Cid         cid.Cid
+	Size        int64
+	Modified    time.Time
+	ContentType string
+	Tags        []string
+}
+
+// Cataloger defines the functions clients need to query objects by their attributes.
+type Cataloger interface {
+	Query(context.Context, string) ([]CatalogEntry, error)
+	RebuildCatalog(context.Context) error
+}
+
+var _ Cataloger = (*fsObjectStoreService)(nil)
+
+// catalog maintains objects of bucket (size, modification time, content type and tags) in a SQLite database
+type catalog struct {
+	db     *sql.DB
+	bucket string
+	clock  Clock
+}
+
+// openCatalog - creates catalog tables in database unless they exist; objects are recorded at time of clock
+func openCatalog(db *sql.DB, bucket string, clock Clock) (*catalog, error) {
+	for _, stmt := range _catalogSchema {
+		if _, err := db.Exec(stmt); err != nil {
+			log.Printf("err: creating catalog tables failed: %s, %v\n", bucket, err)
+			return nil, ErrCatalogFailed
+		}
+	}
+	return &catalog{db: db, bucket: bucket, clock: clock}, nil
+}
+
+// record - accounts created or deleted object in catalog; catalog is secondary to bucket, so failures are
+// only logged, and repaired via `RebuildCatalog`
+func (c *catalog) record(op JournalOp, digest cid.Cid, size int64) {
+	if c == nil {
+		return
+	}
+	var err error
+	if op == JournalCreate {
+		_, err = c.db.Exec(`INSERT INTO fsstore_objects (bucket, cid, size, mtime) VALUES (?, ?, ?, ?)
+			ON CONFLICT (bucket, cid) DO UPDATE SET size = excluded.size, mtime = excluded.mtime`,
+			c.bucket, digest.String(), size, c.clock.Now().Unix())
+	} else {
+		_, err = c.db.Exec(`DELETE FROM fsstore_objects WHERE bucket = ? AND cid = ?`, c.bucket, digest.String())
+		if err == nil {
+			_, err = c.db.Exec(`DELETE FROM fsstore_tags WHERE bucket = ? AND cid = ?`, c.bucket, digest.String())
+		}
+	}
+	if err != nil {
+		log.Printf("warn: updating catalog failed: %s, %s, %v\n", op, digest, err)
+	}
+}
+
+// describe - records content type and tags of object metadata in catalog, see `record`
+func (c *catalog) describe(digest cid.Cid, meta Metadata) {
+	if c == nil {
+		return
+	}
+	err := c.withTx(context.Background(), func(tx *sql.Tx) error {
+		return describeTx(tx, c.bucket, digest.String(), meta)
+	})
+	if err != nil {
+		log.Printf("warn: updating catalog metadata failed: %s, %v\n", digest, err)
+	}
+}
+
+// describeTx - replaces content type and tags of object within transaction
+func describeTx(tx *sql.Tx, bucket, key string, meta Metadata) error {
+	if _, err := tx.Exec(`UPDATE fsstore_objects SET content_type = ? WHERE bucket = ? AND cid = ?`,
+		meta.ContentType, bucket, key); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM fsstore_tags WHERE bucket = ? AND cid = ?`, bucket, key); err != nil {
+		return err
+	}
+	for _, tag := range meta.Tags {
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO fsstore_tags (bucket, cid, tag) VALUES (?, ?, ?)`,
+			bucket, key, tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// withTx - runs fn within transaction, committed when fn succeeds
+func (c *catalog) withTx(ctx context.Context, fn func(*sql.Tx) error) error {
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// Query - returns cataloged objects of bucket matching filter (see `WithCatalog`), in cid order. Filter is
+// `and` separated clauses of `field op value`, an empty filter matching every object:
+//
+//	size > 100MB and age > 30d and tag = tmp
+//
+// Fields are `size` (bytes, with optional `KB`, `MB`, `GB` or `TB` suffix), `age` (duration since object was
+// created, with optional `d` suffix for days), `mtime` (RFC 3339 time), `contentType` and `tag`; ops are
+// `=`, `!=`, `<`, `<=`, `>` and `>=`, where `contentType` and `tag` only support `=` and `!=`.
+func (f *fsObjectStoreService) Query(ctx context.Context, filter string) ([]CatalogEntry, error) {
+	if err := f.authorize(ctx, OpList, cid.Undef); err != nil {
+		return nil, err
+	}
+	entries, err := f.query(ctx, filter)
+	f.audit(ctx, OpList, cid.Undef, err)
+	return entries, err
+}
+
+// query - translates filter into catalog query, and decodes matching objects
+func (f *fsObjectStoreService) query(ctx context.Context, filter string) ([]CatalogEntry, error) {
+	if f.catalog == nil {
+		return nil, ErrCatalogDisabled
+	}
+	where, args, err := parseFilter(filter, f.now())
+	if err != nil {
+		return nil, err
+	}
+	stmt := `SELECT o.cid, o.size, o.mtime, o.content_type,
+		(SELECT group_concat(t.tag, char(31)) FROM fsstore_tags t WHERE t.bucket = o.bucket AND t.cid = o.cid)
+		FROM fsstore_objects o WHERE o.bucket = ?` + where + ` ORDER BY o.cid`
+	rows, err := f.catalog.db.QueryContext(ctx, stmt, append([]interface{}{f.bucket}, args...)...)
+	if err != nil {
+		if ctxErr := checkContextError(ctx, f.isDebug()); ctxErr != nil {
+			return nil, ctxErr
+		}
+		log.Printf("err: querying catalog failed: %s, %v\n", f.bucket, err)
+		return nil, ErrCatalogFailed
+	}
+	defer rows.Close()
+	ret := []CatalogEntry{}
+	for rows.Next() {
+		var key string
+		var mtime int64
+		var tags sql.NullString
+		entry := CatalogEntry{}
+		if err := rows.Scan(&key, &entry.Size, &mtime, &entry.ContentType, &tags); err != nil {
+			log.Printf("err: decoding catalog row failed: %s, %v\n", f.bucket, err)
+			return nil, ErrCatalogFailed
+		}
+		if entry.Cid, err = cid.Decode(key); err != nil {
+			log.Printf("err: decoding catalog cid failed: %s, %s\n", f.bucket, key)
+			return nil, ErrCatalogFailed
+		}
+		entry.Modified = time.Unix(mtime, 0)
+		if tags.Valid && len(tags.String) > 0 {
+			entry.Tags = strings.Split(tags.String, "\x1f")
+			sort.Strings(entry.Tags)
+		}
+		ret = append(ret, entry)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("err: reading catalog failed: %s, %v\n", f.bucket, err)
+		return nil, ErrCatalogFailed
+	}
+	if f.isDebug() {
+		log.Printf("debug: queried catalog: %s, %q, %d objects\n", f.bucket, filter, len(ret))
+	}
+	return ret, nil
+}
+
+// parseFilter - translates catalog filter into where clause (continuing a where clause of objects table `o`)
+// and its arguments, evaluating ages against now
+func parseFilter(filter string, now time.Time) (string, []interface{}, error) {
+	if len(strings.TrimSpace(filter)) == 0 {
+		return "", nil, nil
+	}
+	where := ""
+	args := []interface{}{}
+	for _, clause := range _filterAnd.Split(strings.TrimSpace(filter), -1) {
+		m := _filterClause.FindStringSubmatch(clause)
+		if m == nil {
+			return "", nil, fmt.Errorf("%w: %q", ErrInvalidFilter, clause)
+		}
+		field, op, value := strings.ToLower(m[1]), m[2], m[3]
+		switch field {
+		case "size":
+			size, err := parseSize(value)
+			if err != nil {
+				return "", nil, fmt.Errorf("%w: %q", ErrInvalidFilter, clause)
+			}
+			where += " AND o.size " + op + " ?"
+			args = append(args, size)
+		case "age":
+			age, err := parseAge(value)
+			if err != nil {
+				return "", nil, fmt.Errorf("%w: %q", ErrInvalidFilter, clause)
+			}
+			// older objects have smaller modification times, so comparison is flipped
+			where += " AND o.mtime " + flip(op) + " ?"
+			args = append(args, now.Add(-age).Unix())
+		case "mtime":
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return "", nil, fmt.Errorf("%w: %q", ErrInvalidFilter, clause)
+			}
+			where += " AND o.mtime " + op + " ?"
+			args = append(args, t.Unix())
+		case "contenttype", "content-type":
+			if op != "=" && op != "!=" {
+				return "", nil, fmt.Errorf("%w: %q", ErrInvalidFilter, clause)
+			}
+			where += " AND o.content_type " + op + " ?"
+			args = append(args, value)
+		case "tag":
+			exists := "EXISTS"
+			switch op {
+			case "=":
+			case "!=":
+				exists = "NOT EXISTS"
+			default:
+				return "", nil, fmt.Errorf("%w: %q", ErrInvalidFilter, clause)
+			}
+			where += " AND " + exists + " (SELECT 1 FROM fsstore_tags t WHERE t.bucket = o.bucket AND t.cid = o.cid AND t.tag = ?)"
+			args = append(args, value)
+		default:
+			return "", nil, fmt.Errorf("%w: unknown field %q", ErrInvalidFilter, m[1])
+		}
+	}
+	return where, args, nil
+}
+
+// parseSize - parses size with optional unit suffix
+func parseSize(value string) (int64, error) {
+	upper := strings.ToUpper(value)
+	digits := strings.TrimRight(upper, "KMGTB")
+	unit, ok := _sizeUnits[upper[len(digits):]]
+	if !ok {
+		return 0, ErrInvalidFilter
+	}
+	n, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return n * unit, nil
+}
+
+// parseAge - parses duration, accepting `d` suffix for days
+func parseAge(value string) (time.Duration, error) {
+	if strings.HasSuffix(value, "d") {
+		days, err := strconv.ParseInt(strings.TrimSuffix(value, "d"), 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(value)
+}
+
+// flip - returns comparison operator with operands swapped
+func flip(op string) string {
+	switch op {
+	case "<":
+		return ">"
+	case "<=":
+		return ">="
+	case ">":
+		return "<"
+	case ">=":
+		return "<="
+	}
+	return op
+}
+
+// RebuildCatalog - replaces catalog of bucket with objects (and their metadata) found walking bucket, repairing
+// catalog after failed updates, or cataloging objects created before catalog was configured
+func (f *fsObjectStoreService) RebuildCatalog(ctx context.Context) error {
+	if err := f.authorize(ctx, OpAdmin, cid.Undef); err != nil {
+		return err
+	}
+	err := f.rebuildCatalog(withSystem(ctx))
+	f.audit(ctx, OpAdmin, cid.Undef, err)
+	return err
+}
+
+// rebuildCatalog - walks bucket into catalog within a single transaction
+func (f *fsObjectStoreService) rebuildCatalog(ctx context.Context) error {
+	if f.catalog == nil {
+		return ErrCatalogDisabled
+	}
+	cataloged := 0
+	err := f.catalog.withTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`DELETE FROM fsstore_objects WHERE bucket = ?`, f.bucket); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`DELETE FROM fsstore_tags WHERE bucket = ?`, f.bucket); err != nil {
+			return err
+		}
+		return f.walkObjects(ctx, func(c cid.Cid, path string, info os.FileInfo) error {
+			key := c.String()
+			if _, err := tx.Exec(`INSERT INTO fsstore_objects (bucket, cid, size, mtime) VALUES (?, ?, ?, ?)`,
+				f.bucket, key, f.objectSize(path, info.Size()), info.ModTime().Unix()); err != nil {
+				return err
+			}
+			cataloged++
+			meta, err := f.GetMetadata(ctx, c)
+			if err != nil || (len(meta.ContentType) == 0 && len(meta.Tags) == 0) {
+				return nil
+			}
+			return describeTx(tx, f.bucket, key, *meta)
+		})
+	})
+	if err != nil {
+		if ctxErr := checkContextError(ctx, f.isDebug()); ctxErr != nil {
+			return ctxErr
+		}
+		log.Printf("err: rebuilding catalog failed: %s, %v\n", f.bucket, err)
+		return ErrCatalogFailed
+	}
+	if f.isDebug() {
+		log.Printf("debug: rebuilt catalog: %s, %d objects\n", f.bucket, cataloged)
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package fsstore
+
+import (
+	"errors"
+	"math/bits"
+)
+
+// ErrInvalidChunkSizes is return, when content defined chunking sizes are not ordered as min < avg < max.
+var ErrInvalidChunkSizes = errors.New("fsobjectstore: invalid chunk sizes")
+
+const (
+	// _defChunkMin handles the default minimum chunk size of content defined chunking
+	_defChunkMin = 16 << 10
+	// _defChunkAvg handles the default average chunk size of content defined chunking
+	_defChunkAvg = 64 << 10
+	// _defChunkMax handles the default maximum chunk size of content defined chunking
+	_defChunkMax = 256 << 10
+)
+
+// _gear handles random values mixed into rolling hash per input byte. Table is derived from a fixed
+// seed, since changing it would move every cut point and defeat deduplication with existing chunks.
+var _gear = func() (table [256]uint64) {
+	seed := uint64(0x6673_7374_6f72_6521)
+	for i := range table {
+		// splitmix64
+		seed += 0x9e3779b97f4a7c15
+		z := seed
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		table[i] = z ^ (z >> 31)
+	}
+	return table
+}()
+
+// cdc finds FastCDC cut points; a stricter mask is used below average size and a looser one above it,
+// normalizing chunk sizes around average
+type cdc struct {
+	min, avg, max int
+	maskS, maskL  uint64
+}
+
+// newCDC - creates FastCDC chunker with given sizes
+func newCDC(min, avg, max int) (*cdc, error) {
+	if min <= 0 || min >= avg || avg >= max {
+		return nil, ErrInvalidChunkSizes
+	}
+	n := bits.Len(uint(avg)) - 1
+	return &cdc{
+		min:   min,
+		avg:   avg,
+		max:   max,
+		maskS: highBits(n + 2),
+		maskL: highBits(n - 2),
+	}, nil
+}
+
+// highBits - returns mask of n most significant bits; gear hash shifts left, so high bits mix most input
+func highBits(n int) uint64 {
+	if n <= 0 {
+		return 0
+	}
+	return ^uint64(0) << uint(64-n)
+}
+
+// cut - returns length of first chunk of data, data is expected at most max long
+func (c *cdc) cut(data []byte) int {
+	size := len(data)
+	if size <= c.min {
+		return size
+	}
+	normal := c.avg
+	if size < normal {
+		normal = size
+	}
+	var hash uint64
+	i := c.min
+	for ; i < normal; i++ {
+		hash = (hash << 1) + _gear[data[i]]
+		if hash&c.maskS == 0 {
+			return i + 1
+		}
+	}
+	for ; i < size; i++ {
+		hash = (hash << 1) + _gear[data[i]]
+		if hash&c.maskL == 0 {
+			return i + 1
+		}
+	}
+	return size
+}
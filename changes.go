@@ -0,0 +1,125 @@
+package fsstore
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/ipfs/go-cid"
+)
+
+// ErrJournalBehind is return, when changes are listed since a sequence journal has not reached, e.g. one of
+// journal of another (or recreated) bucket.
+var ErrJournalBehind = errors.New("fsobjectstore: journal behind requested sequence")
+
+// ChangeEvent reports an object created or deleted since listed sequence, in journal sequence of its last change.
+// Error is set (and listing ends) when journal can not be read.
+type ChangeEvent struct {
+	Seq     uint64
+	Cid     cid.Cid
+	Deleted bool
+	Size    int64
+	Time    time.Time
+	Error   error
+}
+
+// ChangeLister defines the functions clients need to pick up objects changed since a journal sequence.
+type ChangeLister interface {
+	ListChangesSince(ctx context.Context, seq uint64) (<-chan ChangeEvent, error)
+}
+
+var _ ChangeLister = (*fsObjectStoreService)(nil)
+
+// ListChangesSince - lists objects created or deleted by operations journaled after seq (see `WithJournal`),
+// one event per object carrying its last change: an object created and deleted again since seq is reported
+// deleted. Seq of last event is the sequence consumers resume from; `0` lists changes since journal started.
+func (f *fsObjectStoreService) ListChangesSince(ctx context.Context, seq uint64) (<-chan ChangeEvent, error) {
+	if err := f.authorize(ctx, OpList, cid.Undef); err != nil {
+		return nil, err
+	}
+	err := f.checkChangesSince(seq)
+	f.audit(ctx, OpList, cid.Undef, err)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan ChangeEvent, f.listBuffer)
+	go func() {
+		defer close(ch)
+		send := func(event ChangeEvent) bool {
+			select {
+			case ch <- event:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		changes, err := f.changesSince(ctx, seq)
+		if err != nil {
+			send(ChangeEvent{Error: err})
+			return
+		}
+		for _, change := range changes {
+			if !send(change) {
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// checkChangesSince - checks that changes since seq can be listed from journal
+func (f *fsObjectStoreService) checkChangesSince(seq uint64) error {
+	if f.journal == nil {
+		return ErrJournalDisabled
+	}
+	f.journal.mu.Lock()
+	current := f.journal.seq
+	f.journal.mu.Unlock()
+	if seq > current {
+		log.Printf("err: changes listed since sequence ahead of journal: %s, %d > %d\n", f.bucket, seq, current)
+		return ErrJournalBehind
+	}
+	return nil
+}
+
+// changesSince - coalesces journal entries after seq into last change of every object, in sequence order
+func (f *fsObjectStoreService) changesSince(ctx context.Context, seq uint64) ([]ChangeEvent, error) {
+	last := map[string]ChangeEvent{}
+	var scanErr error
+	err := scanJournal(f.journal.path, f.journal.legacy, func(rec journalRecord) bool {
+		if rec.Seq <= seq {
+			return true
+		}
+		if scanErr = checkContextError(ctx, f.isDebug()); scanErr != nil {
+			return false
+		}
+		c, err := cid.Decode(rec.Cid)
+		if err != nil {
+			log.Printf("err: decoding journal entry cid failed: %d, %s\n", rec.Seq, rec.Cid)
+			scanErr = ErrJournalReadingFailed
+			return false
+		}
+		last[rec.Cid] = ChangeEvent{Seq: rec.Seq, Cid: c, Deleted: rec.Op != JournalCreate, Size: rec.Size, Time: rec.Time}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if scanErr != nil {
+		return nil, scanErr
+	}
+	changes := make([]ChangeEvent, 0, len(last))
+	for _, change := range last {
+		changes = append(changes, change)
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Seq < changes[j].Seq
+	})
+	if f.isDebug() {
+		log.Printf("debug: listed changes: %s, since %d, %d objects\n", f.bucket, seq, len(changes))
+	}
+	return changes, nil
+}
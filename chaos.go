@@ -0,0 +1,87 @@
+package fsstore
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// ErrChaosInjected is return, when chaos profile (see `WithChaos`) fails an operation on purpose.
+var ErrChaosInjected = errors.New("fsobjectstore: chaos injected failure")
+
+// ChaosProfile captures degradation injected into operations of clients, to rehearse behavior of embedding systems
+// against slow or failing storage: every operation is delayed by `Latency`, plus a random jitter up to `Jitter`,
+// and fails with `Err` (`ErrChaosInjected` when nil) at `ErrorRate` (0 to 1). `Ops` limits injection to operation
+// kinds, every kind when empty; `Seed` makes injection reproducible, zero seeding from clock.
+type ChaosProfile struct {
+	Latency   time.Duration
+	Jitter    time.Duration
+	ErrorRate float64
+	Err       error
+	Ops       []Operation
+	Seed      int64
+}
+
+// chaos injects degradation of profile into operations
+type chaos struct {
+	profile ChaosProfile
+	ops     map[Operation]bool
+	mu      sync.Mutex
+	rnd     *rand.Rand
+}
+
+// newChaos - creates injector of profile, or returns nil when store is not built with chaos support (the
+// `chaos` build tag), so production builds never degrade
+func newChaos(profile ChaosProfile) *chaos {
+	if !_chaosBuild {
+		log.Println("warn: chaos profile ignored, store is built without chaos tag")
+		return nil
+	}
+	seed := profile.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	if profile.Err == nil {
+		profile.Err = ErrChaosInjected
+	}
+	ops := make(map[Operation]bool, len(profile.Ops))
+	for _, op := range profile.Ops {
+		ops[op] = true
+	}
+	log.Printf("warn: chaos enabled: latency=%s jitter=%s errorRate=%g\n", profile.Latency, profile.Jitter, profile.ErrorRate)
+	return &chaos{profile: profile, ops: ops, rnd: rand.New(rand.NewSource(seed))}
+}
+
+// inject - delays operation of kind op by latency of profile, unless ctx is done first, and fails it at error rate
+func (c *chaos) inject(ctx context.Context, op Operation, debug bool) error {
+	if c == nil || (len(c.ops) > 0 && !c.ops[op]) {
+		return nil
+	}
+	c.mu.Lock()
+	delay := c.profile.Latency
+	if c.profile.Jitter > 0 {
+		delay += time.Duration(c.rnd.Int63n(int64(c.profile.Jitter)))
+	}
+	fail := c.profile.ErrorRate > 0 && c.rnd.Float64() < c.profile.ErrorRate
+	c.mu.Unlock()
+
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return checkContextError(ctx, debug)
+		}
+	}
+	if fail {
+		if debug {
+			log.Printf("debug: chaos failed operation: %s, %v\n", op, c.profile.Err)
+		}
+		return c.profile.Err
+	}
+	return nil
+}
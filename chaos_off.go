@@ -0,0 +1,7 @@
+//go:build !chaos
+
+package fsstore
+
+// _chaosBuild handles whether store is built with chaos support; without `chaos` build tag, profile of
+// `WithChaos` is ignored
+const _chaosBuild = false
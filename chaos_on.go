@@ -0,0 +1,6 @@
+//go:build chaos
+
+package fsstore
+
+// _chaosBuild handles whether store is built with chaos support, injecting profile of `WithChaos`
+const _chaosBuild = true
@@ -0,0 +1,189 @@
+package fsstore
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/igumus/go-objectstore-lib"
+	"github.com/ipfs/go-cid"
+)
+
+// ErrUnsupportedChecksumFormat is return, when checksum manifest is requested in an unknown format.
+var ErrUnsupportedChecksumFormat = errors.New("fsobjectstore: unsupported checksum manifest format")
+
+// ChecksumFormat names format of checksum manifests, see `ExportChecksumManifest`
+type ChecksumFormat string
+
+const (
+	// ChecksumSHA256SUMS formats manifest as `sha256sum` output, lines of hex digest and cid, checkable by
+	// `sha256sum -c` against objects saved as files named by their cid
+	ChecksumSHA256SUMS ChecksumFormat = "sha256sums"
+	// ChecksumCSV formats manifest as csv records of cid, size and hex digest, after a header record
+	ChecksumCSV ChecksumFormat = "csv"
+	// ChecksumJSON formats manifest as newline delimited json entries, see `ChecksumEntry`
+	ChecksumJSON ChecksumFormat = "json"
+)
+
+// _signatureComment handles the marker of signature line ending signed checksum manifests of text formats
+const _signatureComment = "#signature "
+
+// ChecksumEntry captures an object of checksum manifest, with size and SHA-256 digest of its content
+type ChecksumEntry struct {
+	Cid    string `json:"cid"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// checksumSignature captures wire format of signature line ending signed json checksum manifests
+type checksumSignature struct {
+	Signature string `json:"signature"`
+}
+
+// ChecksumExporter defines the functions clients need to hand contents of store to external auditors.
+type ChecksumExporter interface {
+	ExportChecksumManifest(ctx context.Context, w io.Writer, format ChecksumFormat) (int, error)
+}
+
+var _ ChecksumExporter = (*fsObjectStoreService)(nil)
+
+// ExportChecksumManifest - writes manifest of every object of bucket, with size and SHA-256 digest of its
+// content, to w in given format, so third parties can audit store contents without access to it. Manifests of
+// stores signing them (see `WithSigner`) end with a signature line, see `VerifyChecksumManifest`.
+// Returns number of objects in manifest.
+func (f *fsObjectStoreService) ExportChecksumManifest(ctx context.Context, w io.Writer, format ChecksumFormat) (int, error) {
+	if err := f.authorize(ctx, OpRead, cid.Undef); err != nil {
+		return 0, err
+	}
+	exported, err := f.exportChecksumManifest(withSystem(ctx), w, format)
+	f.audit(ctx, OpRead, cid.Undef, err)
+	return exported, err
+}
+
+// exportChecksumManifest - hashes every object into manifest written to w, signing manifest when configured
+func (f *fsObjectStoreService) exportChecksumManifest(ctx context.Context, w io.Writer, format ChecksumFormat) (int, error) {
+	switch format {
+	case ChecksumSHA256SUMS, ChecksumCSV, ChecksumJSON:
+	default:
+		return 0, ErrUnsupportedChecksumFormat
+	}
+	digest := sha256.New()
+	out := bufio.NewWriter(io.MultiWriter(w, digest))
+	if format == ChecksumCSV {
+		out.WriteString("cid,size,sha256\n")
+	}
+	exported := 0
+	err := f.walkObjects(ctx, func(c cid.Cid, path string, info os.FileInfo) error {
+		entry, err := f.checksumObject(ctx, c)
+		if errors.Is(err, objectstore.ErrObjectNotExists) {
+			// object deleted since it was walked
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := writeChecksumEntry(out, format, entry); err != nil {
+			log.Printf("err: writing checksum manifest failed: %s, %v\n", f.bucket, err)
+			return ErrExportFailed
+		}
+		exported++
+		return nil
+	})
+	if err != nil {
+		if ctxErr := checkContextError(ctx, f.isDebug()); ctxErr != nil {
+			return exported, ctxErr
+		}
+		return exported, err
+	}
+	if err := out.Flush(); err != nil {
+		log.Printf("err: writing checksum manifest failed: %s, %v\n", f.bucket, err)
+		return exported, ErrExportFailed
+	}
+	signature, err := f.sign(ctx, digest.Sum(nil))
+	if err != nil {
+		return exported, err
+	}
+	if len(signature) > 0 {
+		if err := writeChecksumSignature(w, format, signature); err != nil {
+			log.Printf("err: writing checksum manifest failed: %s, %v\n", f.bucket, err)
+			return exported, ErrExportFailed
+		}
+	}
+	if f.isDebug() {
+		log.Printf("debug: exported checksum manifest: %s, %s, %d\n", f.bucket, format, exported)
+	}
+	return exported, nil
+}
+
+// checksumObject - hashes content of object
+func (f *fsObjectStoreService) checksumObject(ctx context.Context, c cid.Cid) (ChecksumEntry, error) {
+	obj, err := f.openObject(ctx, c)
+	if err != nil {
+		return ChecksumEntry{}, err
+	}
+	defer obj.Close()
+	digest := sha256.New()
+	size, err := io.Copy(digest, obj)
+	if err != nil {
+		if ctxErr := checkContextError(ctx, f.isDebug()); ctxErr != nil {
+			return ChecksumEntry{}, ctxErr
+		}
+		log.Printf("err: hashing object failed: %s, %v\n", c, err)
+		return ChecksumEntry{}, objectstore.ErrObjectReadingFailed
+	}
+	return ChecksumEntry{Cid: c.String(), Size: size, SHA256: hex.EncodeToString(digest.Sum(nil))}, nil
+}
+
+// writeChecksumEntry - writes entry as line of manifest in format
+func writeChecksumEntry(w io.Writer, format ChecksumFormat, entry ChecksumEntry) error {
+	var err error
+	switch format {
+	case ChecksumSHA256SUMS:
+		_, err = fmt.Fprintf(w, "%s  %s\n", entry.SHA256, entry.Cid)
+	case ChecksumCSV:
+		_, err = fmt.Fprintf(w, "%s,%d,%s\n", entry.Cid, entry.Size, entry.SHA256)
+	case ChecksumJSON:
+		err = json.NewEncoder(w).Encode(entry)
+	}
+	return err
+}
+
+// writeChecksumSignature - writes signature line of manifest: a comment line for text formats, and a json entry
+// for json manifests
+func writeChecksumSignature(w io.Writer, format ChecksumFormat, signature string) error {
+	if format == ChecksumJSON {
+		return json.NewEncoder(w).Encode(checksumSignature{Signature: signature})
+	}
+	_, err := fmt.Fprintln(w, _signatureComment+signature)
+	return err
+}
+
+// VerifyChecksumManifest - checks whether manifest (see `ExportChecksumManifest`) ends with a valid signature of
+// SHA-256 digest of manifest preceding it, so auditors can authenticate manifests handed to them
+func VerifyChecksumManifest(ctx context.Context, manifest []byte, v SignatureVerifier) error {
+	body := bytes.TrimSuffix(manifest, []byte("\n"))
+	i := bytes.LastIndexByte(body, '\n') + 1
+	line := string(body[i:])
+	switch {
+	case strings.HasPrefix(line, "{"):
+		wire := checksumSignature{}
+		if err := json.Unmarshal(body[i:], &wire); err != nil {
+			return ErrSignatureInvalid
+		}
+		line = wire.Signature
+	case strings.HasPrefix(line, _signatureComment):
+		line = strings.TrimPrefix(line, _signatureComment)
+	default:
+		return ErrSignatureInvalid
+	}
+	return verifySignature(ctx, v, digestOf(manifest[:i]), line)
+}
@@ -0,0 +1,302 @@
+package fsstore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"sync"
+
+	"github.com/igumus/go-objectstore-lib"
+	"github.com/ipfs/go-cid"
+	"github.com/ipld/go-ipld-prime"
+	"github.com/ipld/go-ipld-prime/datamodel"
+	"github.com/ipld/go-ipld-prime/fluent/qp"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipld/go-ipld-prime/node/basicnode"
+	"github.com/multiformats/go-multicodec"
+)
+
+// _defChunkWorkers handles the default number of chunks decoded in parallel when chunked object is read in full
+const _defChunkWorkers = 4
+
+// ErrNotChunkManifest is return, when object read as chunked content is not a chunk manifest.
+var ErrNotChunkManifest = errors.New("fsobjectstore: not a chunk manifest")
+
+// Chunker defines the functions clients need to store large streams deduplicated by content defined chunks.
+type Chunker interface {
+	CreateChunked(context.Context, io.Reader) (cid.Cid, error)
+	ReadChunked(context.Context, cid.Cid) (io.ReadCloser, error)
+	ReadChunkedAll(context.Context, cid.Cid) ([]byte, error)
+}
+
+var _ Chunker = (*fsObjectStoreService)(nil)
+
+// chunkEntry captures a chunk listed in chunk manifest
+type chunkEntry struct {
+	cid  cid.Cid
+	size int64
+}
+
+// CreateChunked - splits stream into FastCDC chunks (sized via `WithChunkSizes`), creates each chunk as
+// object, and returns cid of dag-cbor manifest `{size, chunks: [{cid, size}]}` listing them in order.
+// Slightly different versions of large content share most chunks, so only changed chunks are written.
+func (f *fsObjectStoreService) CreateChunked(ctx context.Context, reader io.Reader) (cid.Cid, error) {
+	if err := f.authorize(ctx, OpWrite, cid.Undef); err != nil {
+		return cid.Undef, err
+	}
+	defer f.writeDone(ctx)
+	// chunks are created internally, while chunks and manifest are checked against deny-list of client, and
+	// content is scanned as a whole once every chunk is written
+	client := ctx
+	ctx = withoutScan(withSystem(ctx))
+	buf := make([]byte, f.chunker.max)
+	entries := []chunkEntry{}
+	var total int64
+	n, eof := 0, false
+	for {
+		for !eof && n < len(buf) {
+			read, err := reader.Read(buf[n:])
+			n += read
+			if errors.Is(err, io.EOF) {
+				eof = true
+			} else if err != nil {
+				return cid.Undef, err
+			}
+		}
+		if n == 0 {
+			break
+		}
+		if ctxErr := checkContextError(ctx, f.isDebug()); ctxErr != nil {
+			return cid.Undef, ctxErr
+		}
+		size := f.chunker.cut(buf[:n])
+		digest, err := objectstore.DigestPrefix.Sum(buf[:size])
+		if err != nil {
+			return cid.Undef, ErrDataDigestionFailed
+		}
+		if err := f.checkBlocked(client, OpWrite, digest); err != nil {
+			return cid.Undef, err
+		}
+		digest, err = f.CreateObject(ctx, bytes.NewReader(buf[:size]))
+		if err != nil {
+			return cid.Undef, err
+		}
+		entries = append(entries, chunkEntry{cid: digest, size: int64(size)})
+		total += int64(size)
+		n = copy(buf, buf[size:n])
+	}
+
+	manifest, err := qp.BuildMap(basicnode.Prototype.Any, 2, func(ma datamodel.MapAssembler) {
+		qp.MapEntry(ma, "size", qp.Int(total))
+		qp.MapEntry(ma, "chunks", qp.List(int64(len(entries)), func(la datamodel.ListAssembler) {
+			for _, entry := range entries {
+				qp.ListEntry(la, qp.Map(2, func(ma datamodel.MapAssembler) {
+					qp.MapEntry(ma, "cid", qp.Link(cidlink.Link{Cid: entry.cid}))
+					qp.MapEntry(ma, "size", qp.Int(entry.size))
+				}))
+			}
+		}))
+	})
+	if err != nil {
+		log.Printf("err: building chunk manifest failed: %v\n", err)
+		return cid.Undef, ErrNodeEncodingFailed
+	}
+	source := &scanSource{size: total, open: func() (io.ReadCloser, error) {
+		return &chunkReader{f: f, ctx: ctx, entries: entries}, nil
+	}}
+	digest, err := f.createNode(client, manifest, multicodec.DagCbor, source)
+	if err != nil {
+		return cid.Undef, err
+	}
+	if f.isDebug() {
+		log.Printf("debug: created chunked object: %s, %d chunks, %d bytes\n", digest, len(entries), total)
+	}
+	return digest, nil
+}
+
+// ReadChunked - reads chunk manifest with specified cid, and returns reader streaming its chunks in order
+func (f *fsObjectStoreService) ReadChunked(ctx context.Context, manifest cid.Cid) (io.ReadCloser, error) {
+	if err := f.authorize(ctx, OpRead, manifest); err != nil {
+		return nil, err
+	}
+	r, err := f.readChunked(withSystem(ctx), manifest)
+	f.audit(ctx, OpRead, manifest, err)
+	return r, err
+}
+
+// readChunked - decodes chunk manifest, and returns reader streaming its chunks
+func (f *fsObjectStoreService) readChunked(ctx context.Context, manifest cid.Cid) (io.ReadCloser, error) {
+	node, err := f.ReadNode(ctx, manifest)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := chunkEntries(node)
+	if err != nil {
+		if f.isDebug() {
+			log.Printf("debug: reading chunk manifest failed: %s, %v\n", manifest, err)
+		}
+		return nil, ErrNotChunkManifest
+	}
+	return &chunkReader{f: f, ctx: ctx, entries: entries}, nil
+}
+
+// ReadChunkedAll - reads chunk manifest with specified cid, and returns its content in full. Unlike `ReadChunked`,
+// chunks are read and decoded (decrypted, resolved from deltas) by a bounded pool of workers (sized via
+// `WithChunkDecodeWorkers`), so reading large objects uses multiple cores.
+func (f *fsObjectStoreService) ReadChunkedAll(ctx context.Context, manifest cid.Cid) ([]byte, error) {
+	if err := f.authorize(ctx, OpRead, manifest); err != nil {
+		return nil, err
+	}
+	data, err := f.readChunkedAll(withSystem(ctx), manifest)
+	f.audit(ctx, OpRead, manifest, err)
+	return data, err
+}
+
+// readChunkedAll - decodes chunk manifest, and reads its chunks in parallel, assembling them in order
+func (f *fsObjectStoreService) readChunkedAll(ctx context.Context, manifest cid.Cid) ([]byte, error) {
+	node, err := f.ReadNode(ctx, manifest)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := chunkEntries(node)
+	if err != nil {
+		if f.isDebug() {
+			log.Printf("debug: reading chunk manifest failed: %s, %v\n", manifest, err)
+		}
+		return nil, ErrNotChunkManifest
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	chunks := make([][]byte, len(entries))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	var once sync.Once
+	var failure error
+	workers := f.chunkWorkers
+	if workers > len(entries) {
+		workers = len(entries)
+	}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				data, err := f.ReadObject(ctx, entries[index].cid)
+				if err != nil {
+					once.Do(func() {
+						failure = err
+						cancel()
+					})
+					continue
+				}
+				chunks[index] = data
+			}
+		}()
+	}
+feed:
+	for index := range entries {
+		select {
+		case indexes <- index:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(indexes)
+	wg.Wait()
+	if failure != nil {
+		return nil, failure
+	}
+	if ctxErr := checkContextError(ctx, f.isDebug()); ctxErr != nil {
+		return nil, ctxErr
+	}
+
+	var total int
+	for _, chunk := range chunks {
+		total += len(chunk)
+	}
+	ret := make([]byte, 0, total)
+	for _, chunk := range chunks {
+		ret = append(ret, chunk...)
+	}
+	if f.isDebug() {
+		log.Printf("debug: read chunked object in full: %s, %d chunks, %d workers, %d bytes\n", manifest, len(entries), workers, total)
+	}
+	return ret, nil
+}
+
+// chunkEntries - decodes chunk list of manifest node
+func chunkEntries(node ipld.Node) ([]chunkEntry, error) {
+	chunks, err := node.LookupByString("chunks")
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]chunkEntry, 0, chunks.Length())
+	it := chunks.ListIterator()
+	if it == nil {
+		return nil, ErrNotChunkManifest
+	}
+	for !it.Done() {
+		_, entry, err := it.Next()
+		if err != nil {
+			return nil, err
+		}
+		linkNode, err := entry.LookupByString("cid")
+		if err != nil {
+			return nil, err
+		}
+		link, err := linkNode.AsLink()
+		if err != nil {
+			return nil, err
+		}
+		cl, ok := link.(cidlink.Link)
+		if !ok {
+			return nil, ErrNotChunkManifest
+		}
+		sizeNode, err := entry.LookupByString("size")
+		if err != nil {
+			return nil, err
+		}
+		size, err := sizeNode.AsInt()
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, chunkEntry{cid: cl.Cid, size: size})
+	}
+	return entries, nil
+}
+
+// chunkReader streams chunks of manifest, reading one chunk object at a time
+type chunkReader struct {
+	f       *fsObjectStoreService
+	ctx     context.Context
+	entries []chunkEntry
+	current []byte
+}
+
+// Read - reads from current chunk, advancing to next chunk once current one is consumed
+func (r *chunkReader) Read(p []byte) (int, error) {
+	for len(r.current) == 0 {
+		if len(r.entries) == 0 {
+			return 0, io.EOF
+		}
+		data, err := r.f.ReadObject(r.ctx, r.entries[0].cid)
+		if err != nil {
+			return 0, err
+		}
+		r.current = data
+		r.entries = r.entries[1:]
+	}
+	n := copy(p, r.current)
+	r.current = r.current[n:]
+	return n, nil
+}
+
+// Close - releases reader
+func (r *chunkReader) Close() error {
+	r.entries = nil
+	r.current = nil
+	return nil
+}
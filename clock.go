@@ -0,0 +1,98 @@
+package fsstore
+
+import (
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-cid"
+)
+
+// Clock tells current time to store (see `WithClock`): recorded times (e.g. of journal entries, audit records,
+// snapshots and metadata expiry) and ages (e.g. of trash, lifecycle rules and garbage collection retention) are
+// derived from it, so tests can advance time deterministically. Object files are stamped with it as they are
+// created, so ages derived from file times agree. Background intervals (e.g. maintenance schedule and
+// checkpoints), operation durations and short lived caches keep using wall clock.
+type Clock interface {
+	Now() time.Time
+}
+
+// systemClock tells wall clock time
+type systemClock struct{}
+
+// Now - returns current wall clock time
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// ManualClock is a Clock standing still until it is advanced, for deterministic tests. It is safe for concurrent use.
+type ManualClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewManualClock - creates manual clock telling start
+func NewManualClock(start time.Time) *ManualClock {
+	return &ManualClock{now: start}
+}
+
+// Now - returns time clock is set to
+func (c *ManualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance - moves clock forward by d
+func (c *ManualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+}
+
+// Set - sets clock to t
+func (c *ManualClock) Set(t time.Time) {
+	c.mu.Lock()
+	c.now = t
+	c.mu.Unlock()
+}
+
+// now - returns current time of clock of store
+func (f *fsObjectStoreService) now() time.Time {
+	return f.clock.Now()
+}
+
+// stamp - marks object file with cid as written now by store clock, so ages derived from it (e.g. by garbage
+// collection and lifecycle rules) agree with store clock; under wall clock, file system stamps files it writes
+// already, see `touch`
+func (f *fsObjectStoreService) stamp(c cid.Cid) {
+	if path := f.objectPath(c); path != "" {
+		f.touch(c.String(), path, false)
+	}
+}
+
+// touch - marks bucket entry at path with placement key as placed now by store clock, so it counts as written now
+// even when renamed into place with an older modification time (e.g. once restored from trash); unless forced,
+// entries are left as stamped by file system under wall clock. Entries sharing their inode (e.g. adopted files
+// and pooled objects) are never stamped, since that changes times of every name of it, their placement time is
+// recorded instead (see `placedAt`)
+func (f *fsObjectStoreService) touch(key, path string, force bool) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		log.Printf("warn: stamping object failed: %s, %v\n", path, err)
+		return
+	}
+	now := f.now()
+	// entries of unknown link count are treated as shared, so times of other names are never changed
+	if links, err := linkCount(path, info); err != nil || links > 1 {
+		f.placed.record(key, now)
+		return
+	}
+	if _, ok := f.clock.(systemClock); ok && !force {
+		return
+	}
+	if err := os.Chtimes(path, now, now); err != nil {
+		log.Printf("warn: stamping object failed: %s, %v\n", path, err)
+	}
+}
@@ -0,0 +1,209 @@
+package fsstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/igumus/go-objectstore-lib"
+	"github.com/ipfs/go-cid"
+)
+
+// ErrBucketNotExists is return, when cloned bucket does not exist.
+var ErrBucketNotExists = errors.New("fsobjectstore: bucket not exists")
+
+// ErrBucketExists is return, when target bucket of clone already exists.
+var ErrBucketExists = errors.New("fsobjectstore: bucket already exists")
+
+// ErrCloneFailed is return, when cloning bucket failed.
+var ErrCloneFailed = errors.New("fsobjectstore: bucket clone failed")
+
+// _cloneInternals maps internal entries carried over to cloned bucket, to whether they are hard linked (files
+// only ever replaced by rename) or copied (files appended in place). Entries not listed (staging area, pending
+// uploads, trash, journal and audit log) belong to history of source bucket, and are not carried over.
+var _cloneInternals = map[string]bool{
+	_refsDir:       true,
+	_metaDir:       true,
+	_keyringFile:   true,
+	_snapshotsFile: false,
+}
+
+// BucketCloner defines the functions clients need to fork buckets cheaply.
+type BucketCloner interface {
+	CloneBucket(ctx context.Context, src, dst string) error
+}
+
+var _ BucketCloner = (*fsObjectStoreService)(nil)
+
+// CloneBucket - creates bucket dst under data directories of store, sharing objects of bucket src via hard links.
+// Since objects are immutable, buckets diverge at object level afterwards: writes to either bucket never show
+// up in the other. Clone is built aside and renamed into place, so dst either appears complete or not at all.
+func (f *fsObjectStoreService) CloneBucket(ctx context.Context, src, dst string) error {
+	if err := f.authorize(ctx, OpAdmin, cid.Undef); err != nil {
+		return err
+	}
+	err := f.cloneBucket(ctx, src, dst)
+	f.audit(ctx, OpAdmin, cid.Undef, err)
+	return err
+}
+
+// cloneBucket - links (or copies) files of bucket src into staging directories, one per data directory,
+// and renames them as bucket dst once every stripe is staged; renamed ones are moved back when one fails
+func (f *fsObjectStoreService) cloneBucket(ctx context.Context, src, dst string) error {
+	if err := validateBucket(src); err != nil {
+		return err
+	}
+	if err := validateBucket(dst); err != nil {
+		return err
+	}
+	if info, err := os.Stat(filepath.Join(f.dataDir, src)); err != nil || !info.IsDir() {
+		return ErrBucketNotExists
+	}
+	dataDirs := []string{}
+	for _, stripe := range f.stripeDirs() {
+		dataDir := filepath.Dir(stripe)
+		if exists(filepath.Join(dataDir, dst)) {
+			return ErrBucketExists
+		}
+		dataDirs = append(dataDirs, dataDir)
+	}
+
+	// references of source are kept consistent while they are linked
+	f.refMu.Lock()
+	defer f.refMu.Unlock()
+
+	staged := map[string]string{}
+	linked, copied := 0, 0
+	var err error
+	for _, dataDir := range dataDirs {
+		srcDir := filepath.Join(dataDir, src)
+		if !exists(srcDir) {
+			continue
+		}
+		var stagingDir string
+		if stagingDir, err = os.MkdirTemp(dataDir, "."+filepath.Base(dst)+".clone-"); err != nil {
+			break
+		}
+		staged[stagingDir] = filepath.Join(dataDir, dst)
+		var l, c int
+		l, c, err = f.cloneDir(ctx, srcDir, stagingDir)
+		linked, copied = linked+l, copied+c
+		if err != nil {
+			break
+		}
+	}
+	renamed := map[string]string{}
+	for stagingDir, dstDir := range staged {
+		if err != nil {
+			break
+		}
+		if err = os.Rename(stagingDir, dstDir); err == nil {
+			renamed[stagingDir] = dstDir
+		}
+	}
+	if err != nil {
+		// directories already renamed are moved back, so dst either is cloned as a whole or not at all
+		for stagingDir, dstDir := range renamed {
+			if rollbackErr := os.Rename(dstDir, stagingDir); rollbackErr != nil {
+				log.Printf("err: rolling back bucket clone failed: %s, %v\n", dstDir, rollbackErr)
+				os.RemoveAll(dstDir)
+			}
+		}
+		for stagingDir, dstDir := range staged {
+			os.RemoveAll(stagingDir)
+			if f.isDebug() {
+				log.Printf("debug: discarding partial clone: %s\n", dstDir)
+			}
+		}
+		if errors.Is(err, objectstore.ErrOperationCancelled) || errors.Is(err, objectstore.ErrOperationDeadlineExceeded) {
+			return err
+		}
+		log.Printf("err: cloning bucket failed: %s, %s, %v\n", src, dst, err)
+		return ErrCloneFailed
+	}
+	if f.isDebug() {
+		log.Printf("debug: cloned bucket: %s, %s, %d linked, %d copied\n", src, dst, linked, copied)
+	}
+	return nil
+}
+
+// cloneDir - links (or copies) files of bucket directory srcDir into dstDir, returning linked and copied counts
+func (f *fsObjectStoreService) cloneDir(ctx context.Context, srcDir, dstDir string) (int, int, error) {
+	linked, copied := 0, 0
+	err := filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
+		if ctxErr := checkContextError(ctx, f.isDebug()); ctxErr != nil {
+			return ctxErr
+		}
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(srcDir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		link := true
+		if parts := strings.Split(filepath.ToSlash(rel), "/"); parts[0] == _internalDir && len(parts) > 1 {
+			var ok bool
+			if link, ok = _cloneInternals[parts[1]]; !ok {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+		}
+		target := filepath.Join(dstDir, rel)
+		switch {
+		case info.IsDir():
+			return os.MkdirAll(target, 0777)
+		case !info.Mode().IsRegular():
+			return nil
+		case link && os.Link(path, target) == nil:
+			linked++
+			return nil
+		default:
+			// file systems lacking hard links fall back to copying
+			copied++
+			return copyFile(path, target)
+		}
+	})
+	return linked, copied, err
+}
+
+// breakLink - replaces file at path with a private copy when it is shared with a cloned bucket, so in-place
+// changes (e.g. extended attributes) do not leak across buckets
+func (f *fsObjectStoreService) breakLink(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if links, err := linkCount(path, info); err != nil || links < 2 {
+		return err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return write(f.tempDir, path, data)
+}
+
+// copyFile - copies content of file at src into newly created file at dst
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,387 @@
+// Command fsstorectl troubleshoots file system backed objectstores.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	fsstore "github.com/igumus/go-objectstore-fs"
+	"github.com/igumus/go-objectstore-fs/fusefs"
+	"github.com/igumus/go-objectstore-fs/gitimport"
+	"github.com/igumus/go-objectstore-fs/httpstore"
+	"github.com/igumus/go-objectstore-lib"
+	"github.com/ipfs/go-cid"
+)
+
+const usage = `usage: fsstorectl [flags] <command> [args]
+
+commands:
+  adopt [-tree] [-mapping <file>] <dir>
+                   migrates directory of files into objectstore, hard linking files when possible, and
+                   writes mapping of file paths to cids (defaults to <dir>.cids); with -tree, prints
+                   cid of tree manifest preserving structure of directory
+  config -url <gateway> [-token <token>] [setting=value ...]
+                   prints runtime configuration of gateway store, applying given settings first:
+                   debug, scrubRate, rebalanceRate, ioBytesPerSec, ioOpsPerSec, ioIdle,
+                   negativeCacheSize, maintenanceSchedule, gcKeepLast, gcKeepWithin;
+                   token defaults to FSSTORE_TOKEN environment variable
+  import-git <repo>
+                   imports loose and packed objects of git repository, printing refs and cids of
+                   objects they point at
+  inspect <cid>    prints on-disk details of object
+  mount <dir>      mounts objectstore as read-only file system until interrupted
+  reconcile        repairs cid index, statistics and catalog differing from objects of bucket,
+                   printing differences; exits with status 3 when anything is repaired
+  verify [-format text|jsonl|csv] [-progress]
+                   verifies every object, printing corrupt, missing and extra files with
+                   remediation hints; exits with status 3 when anything is found
+
+flags:
+`
+
+// _exitFindings handles the exit status of verification (or reconciliation) finding problems, distinct from
+// failing to run
+const _exitFindings = 3
+
+// _writing handles the commands writing to store, which is opened read only for other commands
+var _writing = map[string]bool{"adopt": true, "import-git": true, "reconcile": true}
+
+// opened handles the store opened by command, closed before exiting so it is not reconciled when opened next
+var opened io.Closer
+
+func main() {
+	dir := flag.String("dir", "/data", "data directory of objectstore")
+	bucket := flag.String("bucket", "store", "bucket of objectstore")
+	debug := flag.Bool("debug", false, "enables debug mode")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if flag.Arg(0) == "config" {
+		// runtime configuration belongs to a running store, so it is tuned via its gateway
+		flags := flag.NewFlagSet("config", flag.ExitOnError)
+		url := flags.String("url", "", "base url of gateway serving store")
+		token := flags.String("token", os.Getenv("FSSTORE_TOKEN"), "bearer token authenticating to gateway")
+		flags.Parse(flag.Args()[1:])
+		if len(*url) == 0 {
+			flag.Usage()
+			os.Exit(2)
+		}
+		client := httpstore.NewClient(*url, httpstore.WithBearerToken(*token))
+		config(context.Background(), client.(httpstore.ConfigClient), flags.Args())
+		return
+	}
+
+	// only commands writing to store provision and lock it, others read it as is, even while a live store holds it
+	store, err := fsstore.NewFileSystemObjectStore(
+		fsstore.WithDataDir(*dir),
+		fsstore.WithBucket(*bucket),
+		fsstore.WithDebugMode(*debug),
+		fsstore.WithReadOnly(!_writing[flag.Arg(0)]),
+	)
+	if err != nil {
+		fail(err)
+	}
+	opened = store.(io.Closer)
+
+	ctx := context.Background()
+	switch flag.Arg(0) {
+	case "adopt":
+		flags := flag.NewFlagSet("adopt", flag.ExitOnError)
+		tree := flags.Bool("tree", false, "builds tree manifest of directory")
+		mapping := flags.String("mapping", "", "path of mapping file")
+		flags.Parse(flag.Args()[1:])
+		if flags.NArg() != 1 {
+			flag.Usage()
+			exit(2)
+		}
+		adopt(ctx, store.(fsstore.Adopter), flags.Arg(0), fsstore.WithIngestTree(*tree), fsstore.WithIngestMapping(*mapping))
+	case "import-git":
+		if flag.NArg() != 2 {
+			flag.Usage()
+			exit(2)
+		}
+		importGit(ctx, gitimport.NewImporter(store, gitimport.WithDebugMode(*debug)), flag.Arg(1))
+	case "inspect":
+		if flag.NArg() != 2 {
+			flag.Usage()
+			exit(2)
+		}
+		inspect(ctx, store.(fsstore.Inspector), flag.Arg(1))
+	case "mount":
+		if flag.NArg() != 2 {
+			flag.Usage()
+			exit(2)
+		}
+		mount(store, flag.Arg(1), *debug)
+	case "verify":
+		flags := flag.NewFlagSet("verify", flag.ExitOnError)
+		format := flags.String("format", "text", "report format: text, jsonl or csv")
+		progress := flags.Bool("progress", false, "prints progress to stderr")
+		flags.Parse(flag.Args()[1:])
+		if flags.NArg() != 0 {
+			flag.Usage()
+			exit(2)
+		}
+		if *progress {
+			ctx = fsstore.ContextWithProgress(ctx, fsstore.ProgressFunc(printProgress))
+		}
+		verify(ctx, store.(fsstore.Verifier), *format)
+	case "reconcile":
+		if flag.NArg() != 1 {
+			flag.Usage()
+			exit(2)
+		}
+		reconcile(ctx, store.(fsstore.Reconciler))
+	default:
+		flag.Usage()
+		exit(2)
+	}
+	exit(0)
+}
+
+// config - applies settings to runtime configuration of gateway store, and prints resulting configuration
+func config(ctx context.Context, updater httpstore.ConfigClient, settings []string) {
+	changes := fsstore.ConfigChanges{}
+	if len(settings) > 0 {
+		// io budget and gc retention are replaced as a whole, so unset parts keep current values
+		current, err := updater.UpdateConfig(ctx, changes)
+		if err != nil {
+			fail(err)
+		}
+		if changes, err = parseSettings(current, settings); err != nil {
+			fail(err)
+		}
+	}
+	current, err := updater.UpdateConfig(ctx, changes)
+	if err != nil {
+		fail(err)
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(current); err != nil {
+		fail(err)
+	}
+}
+
+// parseSettings - parses `setting=value` arguments into configuration changes on top of current configuration
+func parseSettings(current fsstore.RuntimeConfig, settings []string) (fsstore.ConfigChanges, error) {
+	changes := fsstore.ConfigChanges{}
+	budget := current.IOBudget
+	retention := fsstore.PolicyRetention{}
+	if current.GCRetention != nil {
+		retention = *current.GCRetention
+	}
+	for _, setting := range settings {
+		kv := strings.SplitN(setting, "=", 2)
+		if len(kv) != 2 {
+			return changes, fmt.Errorf("invalid setting: %s", setting)
+		}
+		key, value := kv[0], kv[1]
+		var err error
+		switch key {
+		case "debug":
+			var v bool
+			v, err = strconv.ParseBool(value)
+			changes.Debug = &v
+		case "scrubRate", "rebalanceRate":
+			var v int64
+			v, err = strconv.ParseInt(value, 10, 64)
+			if key == "scrubRate" {
+				changes.ScrubRate = &v
+			} else {
+				changes.RebalanceRate = &v
+			}
+		case "ioBytesPerSec":
+			budget.BytesPerSec, err = strconv.ParseInt(value, 10, 64)
+			changes.IOBudget = &budget
+		case "ioOpsPerSec":
+			budget.OpsPerSec, err = strconv.ParseInt(value, 10, 64)
+			changes.IOBudget = &budget
+		case "ioIdle":
+			budget.IdlePriority, err = strconv.ParseBool(value)
+			changes.IOBudget = &budget
+		case "negativeCacheSize":
+			var v int
+			v, err = strconv.Atoi(value)
+			changes.NegativeCacheSize = &v
+		case "maintenanceSchedule":
+			changes.MaintenanceSchedule = &value
+		case "gcKeepLast":
+			retention.KeepLast, err = strconv.Atoi(value)
+			changes.GCRetention = &retention
+		case "gcKeepWithin":
+			retention.KeepWithin = value
+			changes.GCRetention = &retention
+		default:
+			return changes, fmt.Errorf("unknown setting: %s", key)
+		}
+		if err != nil {
+			return changes, fmt.Errorf("invalid setting: %s, %v", setting, err)
+		}
+	}
+	return changes, nil
+}
+
+// inspect - prints on-disk details of object with given cid
+func inspect(ctx context.Context, inspector fsstore.Inspector, value string) {
+	c, err := cid.Decode(value)
+	if err != nil {
+		fail(err)
+	}
+	info, err := inspector.Inspect(ctx, c)
+	if err != nil {
+		fail(err)
+	}
+	if _, err := info.WriteTo(os.Stdout); err != nil {
+		fail(err)
+	}
+}
+
+// verify - verifies objects of store, printing report in given format; exits with findings status when
+// report is not clean
+func verify(ctx context.Context, verifier fsstore.Verifier, format string) {
+	var write func(*fsstore.VerifyReport, io.Writer) error
+	switch format {
+	case "text":
+		write = writeText
+	case "jsonl":
+		write = (*fsstore.VerifyReport).WriteJSONLines
+	case "csv":
+		write = (*fsstore.VerifyReport).WriteCSV
+	default:
+		fail(fmt.Errorf("unknown report format: %s", format))
+	}
+	report, err := verifier.Verify(ctx)
+	if err != nil {
+		fail(err)
+	}
+	if err := write(report, os.Stdout); err != nil {
+		fail(err)
+	}
+	if len(report.Findings) > 0 {
+		exit(_exitFindings)
+	}
+}
+
+// adopt - migrates directory into store, and prints outcome
+func adopt(ctx context.Context, adopter fsstore.Adopter, dir string, opts ...fsstore.IngestOption) {
+	result, err := adopter.AdoptDirectory(ctx, dir, opts...)
+	fmt.Printf("adopted %d files (%d bytes), %d linked, mapping written to %s\n", result.Files, result.Bytes, result.Linked, result.Mapping)
+	if err != nil {
+		fail(err)
+	}
+	if result.Root.Defined() {
+		fmt.Printf("tree %s\n", result.Root)
+	}
+}
+
+// importGit - imports git repository at path, printing its refs and failed objects
+func importGit(ctx context.Context, importer *gitimport.Importer, path string) {
+	report, err := importer.ImportRepository(ctx, path)
+	if report != nil {
+		names := make([]string, 0, len(report.Refs))
+		for name := range report.Refs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Printf("%s %s\n", report.Refs[name], name)
+		}
+		for id, failure := range report.Failed {
+			fmt.Printf("failed %s: %v\n", id, failure)
+		}
+		fmt.Printf("imported %d objects (%d blobs, %d trees, %d commits, %d tags)\n", report.Imported,
+			report.Types["blob"], report.Types["tree"], report.Types["commit"], report.Types["tag"])
+	}
+	if err != nil {
+		fail(err)
+	}
+}
+
+// reconcile - reconciles indexes of store with bucket, printing differences repaired; exits with findings status
+// when indexes were not consistent
+func reconcile(ctx context.Context, reconciler fsstore.Reconciler) {
+	report, err := reconciler.Reconcile(ctx)
+	if err != nil {
+		fail(err)
+	}
+	for _, group := range []struct {
+		kind string
+		cids []cid.Cid
+	}{{"missing", report.Missing}, {"orphan", report.Orphans}, {"size", report.SizeMismatches}} {
+		for _, c := range group.cids {
+			fmt.Printf("%-8s %s\n", group.kind, c)
+		}
+	}
+	fmt.Printf("checked %d objects, stats off by %d objects (%d bytes)\n", report.Checked, report.ObjectsDrift, report.BytesDrift)
+	if !report.Consistent() {
+		exit(_exitFindings)
+	}
+}
+
+// writeText - prints report in human readable form
+func writeText(report *fsstore.VerifyReport, w io.Writer) error {
+	for _, finding := range report.Findings {
+		if _, err := fmt.Fprintf(w, "%-8s %s\n         %s\n", finding.Kind, finding.Path, finding.Hint); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "checked %d objects (%d bytes), %d findings\n", report.Checked, report.Bytes, len(report.Findings))
+	return err
+}
+
+// printProgress - prints progress of operation to stderr, on a single line rewritten by each update
+func printProgress(p fsstore.ProgressUpdate) {
+	fmt.Fprintf(os.Stderr, "\r%s: %d/%d objects, %d bytes, eta %s ", p.Operation, p.Done, p.Total, p.Bytes, p.ETA().Round(time.Second))
+	if p.Finished {
+		fmt.Fprintln(os.Stderr)
+	}
+}
+
+// mount - mounts objectstore on dir, and unmounts it when interrupted
+func mount(store objectstore.ObjectStore, dir string, debug bool) {
+	mp, err := fusefs.Mount(dir, store, fusefs.WithDebugMode(debug))
+	if err != nil {
+		fail(err)
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		if err := mp.Unmount(); err != nil {
+			fmt.Fprintf(os.Stderr, "fsstorectl: %v\n", err)
+		}
+	}()
+	mp.Wait()
+}
+
+// fail - prints error and exits with failure status
+func fail(err error) {
+	fmt.Fprintf(os.Stderr, "fsstorectl: %v\n", err)
+	exit(1)
+}
+
+// exit - closes opened store, then exits with status
+func exit(code int) {
+	if opened != nil {
+		opened.Close()
+	}
+	os.Exit(code)
+}
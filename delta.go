@@ -0,0 +1,281 @@
+package fsstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+	"io"
+	"io/ioutil"
+	"log"
+
+	"github.com/igumus/go-objectstore-lib"
+	"github.com/ipfs/go-cid"
+)
+
+// ErrDeltaCorrupted is return, when stored delta could not be applied to its base.
+var ErrDeltaCorrupted = errors.New("fsobjectstore: delta corrupted")
+
+// _defMaxDeltaDepth handles the default maximum length of delta chains
+const _defMaxDeltaDepth = 4
+
+// _deltaBlock handles the size of base blocks indexed while computing delta
+const _deltaBlock = 16
+
+const (
+	// deltaInsert op carries literal bytes
+	deltaInsert byte = iota
+	// deltaCopy op copies a range of base
+	deltaCopy
+)
+
+// DeltaCreator defines the functions clients need to store new versions of objects as deltas.
+type DeltaCreator interface {
+	CreateDelta(context.Context, cid.Cid, io.Reader) (cid.Cid, error)
+}
+
+var _ DeltaCreator = (*fsObjectStoreService)(nil)
+
+// CreateDelta - creates object with specified data (aka content), storing it as binary delta against base
+// object when delta is smaller than content. Cid is computed over content, and reads reconstruct content
+// transparently. Content is stored in full when delta chain of base already reached maximum depth
+// (see `WithMaxDeltaDepth`). Base is referenced by delta, so it is kept as long as delta exists.
+func (f *fsObjectStoreService) CreateDelta(ctx context.Context, base cid.Cid, reader io.Reader) (cid.Cid, error) {
+	if err := f.authorize(ctx, OpWrite, cid.Undef); err != nil {
+		return cid.Undef, err
+	}
+	defer f.writeDone(ctx)
+	digest, err := f.createDelta(ctx, base, reader)
+	f.audit(ctx, OpWrite, digest, err)
+	return digest, err
+}
+
+// createDelta - creates object from reader, as delta against base when smaller
+func (f *fsObjectStoreService) createDelta(ctx context.Context, base cid.Cid, reader io.Reader) (cid.Cid, error) {
+	data, readerErr := ioutil.ReadAll(reader)
+	if readerErr != nil {
+		return cid.Undef, readerErr
+	}
+	digest, err := objectstore.DigestPrefix.Sum(data)
+	if err != nil {
+		log.Printf("err: digesting object failed: %s\n", err.Error())
+		return cid.Undef, ErrDataDigestionFailed
+	}
+	if err := f.checkBlocked(ctx, OpWrite, digest); err != nil {
+		return digest, err
+	}
+	if f.has(digest) {
+		f.stats.deduplicated()
+		return digest, nil
+	}
+	if ctxErr := checkContextError(ctx, f.isDebug()); ctxErr != nil {
+		return cid.Undef, ctxErr
+	}
+	err = f.scan(ctx, digest, int64(len(data)), func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(data)), nil
+	})
+	if err != nil {
+		return digest, err
+	}
+
+	stored, err := f.readStored(ctx, f.objectPath(base))
+	if err != nil {
+		return cid.Undef, err
+	}
+	inner, _, err := f.decrypt(stored)
+	if err != nil {
+		return cid.Undef, err
+	}
+	depth := deltaDepth(inner)
+	baseData, err := f.resolve(ctx, inner)
+	if err != nil {
+		return cid.Undef, err
+	}
+
+	content := escapePlain(data)
+	if depth < f.maxDeltaDepth && !digest.Equals(base) {
+		header := appendUvarint(nil, uint64(depth+1))
+		header = append(header, base.Bytes()...)
+		sealed := sealEnvelope(envelopeDelta, header, makeDelta(baseData, data))
+		if len(sealed) < len(content) {
+			if err := f.addRefs(ctx, digest, []cid.Cid{base}); err != nil {
+				return cid.Undef, err
+			}
+			content = sealed
+		}
+	}
+	if f.isDebug() {
+		log.Printf("debug: created delta object: %s, base %s, %d of %d bytes\n", digest, base, len(content), len(data))
+	}
+
+	stored, err = f.encrypt(content)
+	if err != nil {
+		return cid.Undef, objectstore.ErrObjectWritingFailed
+	}
+	objLink := f.objectPath(digest)
+	if err := f.fds.acquire(ctx); err != nil {
+		return digest, err
+	}
+	defer f.fds.release()
+	err = f.bounded(ctx, func() error { return f.writeObject(digest, objLink, stored) })
+	if err != nil {
+		return digest, err
+	}
+	f.negative.remove(digest.String())
+	if err := f.journaled(JournalCreate, digest, int64(len(data))); err != nil {
+		return digest, err
+	}
+	f.notifyCreated(ctx, digest, int64(len(data)))
+	return digest, nil
+}
+
+// deltaDepth - returns length of delta chain ending with decrypted stored bytes, zero for full content
+func deltaDepth(inner []byte) int {
+	depth, _ := deltaHeader(inner)
+	return depth
+}
+
+// deltaHeader - returns length of delta chain ending with decrypted stored bytes and base of delta, zero
+// (and undefined base) for full content
+func deltaHeader(inner []byte) (int, cid.Cid) {
+	if !isEnveloped(inner) {
+		return 0, cid.Undef
+	}
+	kind, header, _, err := openEnvelope(inner)
+	if err != nil || kind != envelopeDelta {
+		return 0, cid.Undef
+	}
+	depth, n := binary.Uvarint(header)
+	if n <= 0 {
+		return 0, cid.Undef
+	}
+	_, base, err := cid.CidFromBytes(header[n:])
+	if err != nil {
+		return int(depth), cid.Undef
+	}
+	return int(depth), base
+}
+
+// resolveDelta - reconstructs content from delta envelope header (depth, base cid) and payload
+func (f *fsObjectStoreService) resolveDelta(ctx context.Context, header, payload []byte) ([]byte, error) {
+	_, n := binary.Uvarint(header)
+	if n <= 0 {
+		return nil, ErrEnvelopeCorrupted
+	}
+	_, base, err := cid.CidFromBytes(header[n:])
+	if err != nil {
+		return nil, ErrEnvelopeCorrupted
+	}
+	baseData, err := f.load(ctx, f.objectPath(base))
+	if err != nil {
+		log.Printf("err: reading delta base failed: %s, %v\n", base, err)
+		return nil, ErrDeltaCorrupted
+	}
+	return applyDelta(baseData, payload)
+}
+
+// makeDelta - encodes target as target length followed by insert/copy ops against base blocks
+func makeDelta(base, target []byte) []byte {
+	index := make(map[uint64]int, len(base)/_deltaBlock)
+	for off := 0; off+_deltaBlock <= len(base); off += _deltaBlock {
+		h := blockHash(base[off : off+_deltaBlock])
+		if _, ok := index[h]; !ok {
+			index[h] = off
+		}
+	}
+
+	out := appendUvarint(nil, uint64(len(target)))
+	pending := 0
+	flush := func(end int) {
+		if end > pending {
+			out = append(out, deltaInsert)
+			out = appendUvarint(out, uint64(end-pending))
+			out = append(out, target[pending:end]...)
+		}
+	}
+	for i := 0; i+_deltaBlock <= len(target); {
+		off, ok := index[blockHash(target[i:i+_deltaBlock])]
+		if !ok || !bytes.Equal(base[off:off+_deltaBlock], target[i:i+_deltaBlock]) {
+			i++
+			continue
+		}
+		n := _deltaBlock
+		for off+n < len(base) && i+n < len(target) && base[off+n] == target[i+n] {
+			n++
+		}
+		for i > pending && off > 0 && base[off-1] == target[i-1] {
+			i, off, n = i-1, off-1, n+1
+		}
+		flush(i)
+		out = append(out, deltaCopy)
+		out = appendUvarint(out, uint64(off))
+		out = appendUvarint(out, uint64(n))
+		i += n
+		pending = i
+	}
+	flush(len(target))
+	return out
+}
+
+// applyDelta - reconstructs target from base and delta encoded via `makeDelta`. Target length recorded by delta
+// is checked against what its ops could produce (inserts carry their bytes, copies take at least 3 bytes for at
+// most whole base), so a corrupted length never allocates beyond base and delta.
+func applyDelta(base, delta []byte) ([]byte, error) {
+	size, n := binary.Uvarint(delta)
+	if n <= 0 {
+		return nil, ErrDeltaCorrupted
+	}
+	delta = delta[n:]
+	if size > uint64(len(delta))+uint64(len(delta)/3)*uint64(len(base)) {
+		return nil, ErrDeltaCorrupted
+	}
+	hint := size
+	if limit := uint64(len(base) + len(delta)); hint > limit {
+		hint = limit
+	}
+	out := make([]byte, 0, hint)
+	for len(delta) > 0 {
+		op := delta[0]
+		delta = delta[1:]
+		switch op {
+		case deltaInsert:
+			length, n := binary.Uvarint(delta)
+			if n <= 0 || uint64(len(delta)-n) < length {
+				return nil, ErrDeltaCorrupted
+			}
+			out = append(out, delta[n:n+int(length)]...)
+			delta = delta[n+int(length):]
+		case deltaCopy:
+			off, n1 := binary.Uvarint(delta)
+			if n1 <= 0 {
+				return nil, ErrDeltaCorrupted
+			}
+			length, n2 := binary.Uvarint(delta[n1:])
+			if n2 <= 0 || off > uint64(len(base)) || length > uint64(len(base))-off {
+				return nil, ErrDeltaCorrupted
+			}
+			out = append(out, base[off:off+length]...)
+			delta = delta[n1+n2:]
+		default:
+			return nil, ErrDeltaCorrupted
+		}
+	}
+	if uint64(len(out)) != size {
+		return nil, ErrDeltaCorrupted
+	}
+	return out, nil
+}
+
+// blockHash - hashes a base/target block for delta index lookups
+func blockHash(block []byte) uint64 {
+	h := fnv.New64a()
+	h.Write(block)
+	return h.Sum64()
+}
+
+// appendUvarint - appends uvarint encoding of x to buf
+func appendUvarint(buf []byte, x uint64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	return append(buf, tmp[:binary.PutUvarint(tmp[:], x)]...)
+}
@@ -0,0 +1,34 @@
+//go:build !windows
+
+package fsstore
+
+import (
+	"os"
+	"syscall"
+)
+
+// sameDevice - checks whether given paths reside on same file system device
+func sameDevice(a, b string) (bool, error) {
+	aInfo, err := os.Stat(a)
+	if err != nil {
+		return false, err
+	}
+	bInfo, err := os.Stat(b)
+	if err != nil {
+		return false, err
+	}
+	aStat, aOk := aInfo.Sys().(*syscall.Stat_t)
+	bStat, bOk := bInfo.Sys().(*syscall.Stat_t)
+	if !aOk || !bOk {
+		return true, nil
+	}
+	return aStat.Dev == bStat.Dev, nil
+}
+
+// linkCount - returns number of hard links of file at path with given info
+func linkCount(path string, info os.FileInfo) (uint64, error) {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(stat.Nlink), nil
+	}
+	return 1, nil
+}
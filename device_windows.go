@@ -0,0 +1,37 @@
+//go:build windows
+
+package fsstore
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// sameDevice - checks whether given paths reside on same volume
+func sameDevice(a, b string) (bool, error) {
+	aAbs, err := filepath.Abs(a)
+	if err != nil {
+		return false, err
+	}
+	bAbs, err := filepath.Abs(b)
+	if err != nil {
+		return false, err
+	}
+	return filepath.VolumeName(aAbs) == filepath.VolumeName(bAbs), nil
+}
+
+// linkCount - returns number of hard links of file at path with given info; file information of windows does not
+// carry it, so it is queried from file handle
+func linkCount(path string, info os.FileInfo) (uint64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	var data syscall.ByHandleFileInformation
+	if err := syscall.GetFileInformationByHandle(syscall.Handle(file.Fd()), &data); err != nil {
+		return 0, err
+	}
+	return uint64(data.NumberOfLinks), nil
+}
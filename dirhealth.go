@@ -0,0 +1,177 @@
+package fsstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// errFSInfoUnsupported is return, when file system details are not detected on platform
+var errFSInfoUnsupported = errors.New("fsobjectstore: file system details not supported")
+
+// _dataDirCheckInterval handles the interval data directory health is checked at while store is open
+const _dataDirCheckInterval = 5 * time.Minute
+
+// _minInodeHeadroom handles the share of free inodes below which data directory is reported low on inodes
+const _minInodeHeadroom = 0.05
+
+// _xattrProbe handles the extended attribute name written to probe file system support
+const _xattrProbe = "user.fsstore.probe"
+
+// fsInfo captures file system details of a data directory, as detected by platform
+type fsInfo struct {
+	Type       string
+	ReadOnly   bool
+	NoAtime    bool
+	Inodes     uint64
+	FreeInodes uint64
+	Bytes      uint64
+	FreeBytes  uint64
+}
+
+// DataDirHealth captures file system details of a data directory (stripe of bucket, see `WithDataDirs`): file
+// system type (e.g. `ext4`, `xfs`, `nfs`, `tmpfs`; `unknown` when not detected), mount flags, capacity and inode
+// headroom, along with warnings of settings not suited to store. Xattrs reports support of extended attributes,
+// probed only when metadata is kept in them.
+type DataDirHealth struct {
+	Dir        string
+	FSType     string
+	ReadOnly   bool
+	NoAtime    bool
+	Inodes     uint64
+	FreeInodes uint64
+	Bytes      uint64
+	FreeBytes  uint64
+	Xattrs     bool
+	Warnings   []string
+	Checked    time.Time
+}
+
+// Healthy - checks whether data directory accepts writes, neither mounted read-only nor low on inodes
+func (h DataDirHealth) Healthy() bool {
+	return !h.ReadOnly && !h.lowOnInodes()
+}
+
+// lowOnInodes - checks whether share of free inodes is below headroom; file systems without fixed inode tables
+// (e.g. btrfs) report no inodes
+func (h DataDirHealth) lowOnInodes() bool {
+	return h.Inodes > 0 && float64(h.FreeInodes) < _minInodeHeadroom*float64(h.Inodes)
+}
+
+// DataDirMonitor defines the functions clients need to observe health of data directories, e.g. for health checks.
+type DataDirMonitor interface {
+	DataDirHealth() []DataDirHealth
+}
+
+var _ DataDirMonitor = (*fsObjectStoreService)(nil)
+
+// dirHealth keeps outcome of last data directory health check
+type dirHealth struct {
+	mu   sync.Mutex
+	dirs []DataDirHealth
+}
+
+// DataDirHealth - returns outcome of last health check of data directories, checked when store is opened and
+// periodically afterwards
+func (f *fsObjectStoreService) DataDirHealth() []DataDirHealth {
+	f.dirHealth.mu.Lock()
+	defer f.dirHealth.mu.Unlock()
+	ret := make([]DataDirHealth, len(f.dirHealth.dirs))
+	copy(ret, f.dirHealth.dirs)
+	return ret
+}
+
+// checkDataDirs - checks health of every data directory, logging warnings not reported by previous check
+func (f *fsObjectStoreService) checkDataDirs() {
+	f.dirHealth.mu.Lock()
+	previous := map[string]struct{}{}
+	for _, dir := range f.dirHealth.dirs {
+		for _, warning := range dir.Warnings {
+			previous[dir.Dir+"\x00"+warning] = struct{}{}
+		}
+	}
+	f.dirHealth.mu.Unlock()
+
+	dirs := []DataDirHealth{}
+	for _, dir := range f.stripeDirs() {
+		health := f.checkDataDir(dir)
+		for _, warning := range health.Warnings {
+			if _, ok := previous[health.Dir+"\x00"+warning]; !ok {
+				log.Printf("warn: data directory %s: %s\n", warning, health.Dir)
+			}
+		}
+		dirs = append(dirs, health)
+	}
+	f.dirHealth.mu.Lock()
+	f.dirHealth.dirs = dirs
+	f.dirHealth.mu.Unlock()
+}
+
+// checkDataDir - detects file system details of data directory, probing extended attributes when metadata is
+// kept in them (see `WithXattrMetadata`); not yet provisioned directories are checked via closest existing parent
+func (f *fsObjectStoreService) checkDataDir(dir string) DataDirHealth {
+	health := DataDirHealth{Dir: filepath.Dir(dir), FSType: "unknown", Checked: f.now()}
+	path := dir
+	for !exists(path) && filepath.Dir(path) != path {
+		path = filepath.Dir(path)
+	}
+	info, err := statFS(path)
+	switch {
+	case errors.Is(err, errFSInfoUnsupported):
+	case err != nil:
+		health.Warnings = append(health.Warnings, fmt.Sprintf("file system not detected (%v)", err))
+	default:
+		health.FSType, health.ReadOnly, health.NoAtime = info.Type, info.ReadOnly, info.NoAtime
+		health.Inodes, health.FreeInodes, health.Bytes, health.FreeBytes = info.Inodes, info.FreeInodes, info.Bytes, info.FreeBytes
+	}
+	if health.ReadOnly {
+		health.Warnings = append(health.Warnings, "mounted read-only, writes fail")
+	}
+	if health.lowOnInodes() {
+		health.Warnings = append(health.Warnings, fmt.Sprintf("low on inodes, %d of %d free", health.FreeInodes, health.Inodes))
+	}
+	switch health.FSType {
+	case "tmpfs":
+		health.Warnings = append(health.Warnings, "on tmpfs, objects do not survive reboots")
+	case "nfs", "cifs", "smb2":
+		health.Warnings = append(health.Warnings, "on network file system, durability of renames depends on server")
+	}
+	if f.useXattrs() && f.isProvisioned() && !health.ReadOnly {
+		health.Xattrs = f.probeXattrs(dir)
+		if !health.Xattrs {
+			health.Warnings = append(health.Warnings, "extended attributes configured but not supported")
+		}
+	}
+	return health
+}
+
+// probeXattrs - checks whether file system of stripe dir supports extended attributes, by setting one on a
+// file staged in its internal temp directory
+func (f *fsObjectStoreService) probeXattrs(dir string) bool {
+	file, err := stage(filepath.Join(dir, _internalDir, _tempDir))
+	if err != nil {
+		return false
+	}
+	defer discard(file)
+	return setXattr(file.Name(), _xattrProbe, []byte{1}) == nil
+}
+
+// startDataDirChecks - checks health of data directories in background every interval, until store is closed
+func (f *fsObjectStoreService) startDataDirChecks(interval time.Duration) {
+	f.background(func(ctx context.Context) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				f.checkDataDirs()
+			case <-ctx.Done():
+				return
+			}
+		}
+	})
+}
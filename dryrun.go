@@ -0,0 +1,59 @@
+package fsstore
+
+import (
+	"context"
+
+	"github.com/ipfs/go-cid"
+)
+
+// _dryRunSample handles the number of affected object cids reports of destructive operations sample
+const _dryRunSample = 16
+
+// DryRunner defines the functions clients need to preview destructive operations via `WithDryRun`, before
+// running them for real.
+type DryRunner interface {
+	CollectGarbageWith(context.Context, RetentionPolicy, ...CallOption) (*GCReport, error)
+	EmptyTrashWith(context.Context, ...CallOption) (*PurgeReport, error)
+	RebalanceWith(context.Context, ...CallOption) (*RebalanceReport, error)
+}
+
+var _ DryRunner = (*fsObjectStoreService)(nil)
+
+// CollectGarbageWith - collects garbage as `CollectGarbage` does, tuned by given call options
+func (f *fsObjectStoreService) CollectGarbageWith(ctx context.Context, policy RetentionPolicy, opts ...CallOption) (*GCReport, error) {
+	ctx, cancel := newCallConfig(opts).withCall(ctx)
+	defer cancel()
+	return f.CollectGarbage(ctx, policy)
+}
+
+// EmptyTrashWith - purges every deleted object as `EmptyTrash` does, tuned by given call options
+func (f *fsObjectStoreService) EmptyTrashWith(ctx context.Context, opts ...CallOption) (*PurgeReport, error) {
+	ctx, cancel := newCallConfig(opts).withCall(ctx)
+	defer cancel()
+	if err := f.authorize(ctx, OpAdmin, cid.Undef); err != nil {
+		return nil, err
+	}
+	report, err := f.purgeTrash(ctx, f.now())
+	f.audit(ctx, OpAdmin, cid.Undef, err)
+	return report, err
+}
+
+// RebalanceWith - migrates objects as `Rebalance` does, tuned by given call options
+func (f *fsObjectStoreService) RebalanceWith(ctx context.Context, opts ...CallOption) (*RebalanceReport, error) {
+	ctx, cancel := newCallConfig(opts).withCall(ctx)
+	defer cancel()
+	return f.Rebalance(ctx)
+}
+
+// isDryRun - checks whether call of context only reports effects of destructive operation
+func isDryRun(ctx context.Context) bool {
+	return callConfigFrom(ctx).dryRun
+}
+
+// sample - appends cid of affected object to sample, until sample is full
+func sample(cids []cid.Cid, c cid.Cid) []cid.Cid {
+	if len(cids) >= _dryRunSample {
+		return cids
+	}
+	return append(cids, c)
+}
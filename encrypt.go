@@ -0,0 +1,299 @@
+package fsstore
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/ipfs/go-cid"
+)
+
+// ErrEncryptionDisabled is return, when key rotation is requested from a store without encryption keys.
+var ErrEncryptionDisabled = errors.New("fsobjectstore: encryption disabled")
+
+// ErrUnknownEncryptionKey is return, when an object or rotation refers to a key id key provider could not resolve.
+var ErrUnknownEncryptionKey = errors.New("fsobjectstore: unknown encryption key")
+
+// ErrInvalidEncryptionKey is return, when configured encryption key is not an AES-128/192/256 key.
+var ErrInvalidEncryptionKey = errors.New("fsobjectstore: invalid encryption key")
+
+// ErrDecryptionFailed is return, when stored object could not be authenticated with its key.
+var ErrDecryptionFailed = errors.New("fsobjectstore: decryption failed")
+
+// _keyringFile handles the internal file name recording active encryption key id
+const _keyringFile = "keyring"
+
+// KeyRotator defines the functions clients need to rotate at-rest encryption keys.
+type KeyRotator interface {
+	RotateKey(context.Context, string) error
+	ActiveKey() string
+}
+
+var _ KeyRotator = (*fsObjectStoreService)(nil)
+
+// keyring resolves encryption keys by id via key provider, caching their ciphers, and holds id of key
+// new objects are encrypted with
+type keyring struct {
+	provider KeyProvider
+	mu       sync.RWMutex
+	ciphers  map[string]cipher.AEAD
+	active   string
+}
+
+// newKeyring - creates keyring resolving keys via provider, active key is given id
+func newKeyring(provider KeyProvider, active string) (*keyring, error) {
+	k := &keyring{provider: provider, ciphers: map[string]cipher.AEAD{}, active: active}
+	if _, err := k.lookup(active); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// current - returns active key id and cipher
+func (k *keyring) current() (string, cipher.AEAD, error) {
+	k.mu.RLock()
+	id := k.active
+	k.mu.RUnlock()
+	aead, err := k.lookup(id)
+	return id, aead, err
+}
+
+// activate - makes key with given id active
+func (k *keyring) activate(id string) {
+	k.mu.Lock()
+	k.active = id
+	k.mu.Unlock()
+}
+
+// lookup - returns AES-GCM cipher of key id, resolving key via provider on first use
+func (k *keyring) lookup(id string) (cipher.AEAD, error) {
+	k.mu.RLock()
+	aead, ok := k.ciphers[id]
+	k.mu.RUnlock()
+	if ok {
+		return aead, nil
+	}
+	key, err := k.provider.Key(context.Background(), id)
+	if err != nil {
+		log.Printf("err: resolving encryption key failed: %s, %v\n", id, err)
+		return nil, ErrUnknownEncryptionKey
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, ErrInvalidEncryptionKey
+	}
+	if aead, err = cipher.NewGCM(block); err != nil {
+		return nil, ErrInvalidEncryptionKey
+	}
+	k.mu.Lock()
+	k.ciphers[id] = aead
+	k.mu.Unlock()
+	return aead, nil
+}
+
+// loadActiveKey - restores active key id recorded by an earlier rotation, when that key is still configured
+func (f *fsObjectStoreService) loadActiveKey() {
+	data, err := f.readInternal(f.internalPath(_keyringFile))
+	if err != nil {
+		return
+	}
+	id := strings.TrimSpace(string(data))
+	if _, err := f.keys.lookup(id); err != nil {
+		log.Printf("err: recorded active encryption key not available, keeping default: %s\n", id)
+		return
+	}
+	f.keys.activate(id)
+}
+
+// encrypt - returns stored bytes of inner (escaped plain or delta) bytes, encrypted with active key when
+// encryption is enabled. Envelope header carries key id and nonce, and is authenticated with ciphertext.
+func (f *fsObjectStoreService) encrypt(inner []byte) ([]byte, error) {
+	if f.keys == nil {
+		return inner, nil
+	}
+	id, aead, err := f.keys.current()
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	header := appendUvarint(nil, uint64(len(id)))
+	header = append(header, id...)
+	header = append(header, nonce...)
+	return sealEnvelope(envelopeEncrypted, header, aead.Seal(nil, nonce, inner, header)), nil
+}
+
+// decrypt - returns inner bytes of stored bytes and id of key they were encrypted with, empty id when
+// stored bytes are not encrypted
+func (f *fsObjectStoreService) decrypt(stored []byte) ([]byte, string, error) {
+	if !isEnveloped(stored) {
+		return stored, "", nil
+	}
+	kind, header, payload, err := openEnvelope(stored)
+	if err != nil {
+		return nil, "", err
+	}
+	if kind != envelopeEncrypted {
+		return stored, "", nil
+	}
+	size, n := binary.Uvarint(header)
+	if n <= 0 || uint64(len(header)-n) < size {
+		return nil, "", ErrEnvelopeCorrupted
+	}
+	id := string(header[n : n+int(size)])
+	if f.keys == nil {
+		return nil, id, ErrUnknownEncryptionKey
+	}
+	aead, err := f.keys.lookup(id)
+	if err != nil {
+		return nil, id, err
+	}
+	nonce := header[n+int(size):]
+	if len(nonce) != aead.NonceSize() {
+		return nil, id, ErrEnvelopeCorrupted
+	}
+	inner, err := aead.Open(nil, nonce, payload, header)
+	if err != nil {
+		return nil, id, ErrDecryptionFailed
+	}
+	return inner, id, nil
+}
+
+// rekey - re-encrypts object file at objLink with active key, when it is encrypted with another key (or not
+// at all); inner bytes are kept as is, so deltas stay deltas. Object is left for a later read when no file may
+// be opened within budget before ctx is done.
+func (f *fsObjectStoreService) rekey(ctx context.Context, objLink string, inner []byte, keyID string) {
+	if f.keys == nil {
+		return
+	}
+	if active, _, _ := f.keys.current(); active == keyID {
+		return
+	}
+	if err := f.fds.acquire(ctx); err != nil {
+		return
+	}
+	defer f.fds.release()
+	stored, err := f.encrypt(inner)
+	if err == nil && exists(objLink) {
+		err = write(f.tempFor(objLink), objLink, stored)
+	}
+	if err != nil {
+		log.Printf("err: re-encrypting object failed: %s, %v\n", objLink, err)
+		return
+	}
+	if f.isDebug() {
+		log.Printf("debug: re-encrypted object: %s\n", objLink)
+	}
+}
+
+// RotateKey - makes configured key with given id active, so new objects are encrypted with it. Objects
+// encrypted with other keys are re-encrypted as they are read, and eagerly by a background job when
+// `WithEagerKeyRotation` is set; old keys must stay resolvable until every object is re-encrypted.
+func (f *fsObjectStoreService) RotateKey(ctx context.Context, id string) error {
+	if err := f.authorize(ctx, OpAdmin, cid.Undef); err != nil {
+		return err
+	}
+	err := f.rotateKey(ctx, id)
+	f.audit(ctx, OpAdmin, cid.Undef, err)
+	return err
+}
+
+// rotateKey - activates key with id, and records it as active key
+func (f *fsObjectStoreService) rotateKey(ctx context.Context, id string) error {
+	if f.keys == nil {
+		return ErrEncryptionDisabled
+	}
+	if ctxErr := checkContextError(ctx, f.isDebug()); ctxErr != nil {
+		return ctxErr
+	}
+	if err := f.ensureBucket(); err != nil {
+		return err
+	}
+	if _, err := f.keys.lookup(id); err != nil {
+		return err
+	}
+	if err := f.writeInternal(f.internalPath(_keyringFile), []byte(id+"\n")); err != nil {
+		return err
+	}
+	f.keys.activate(id)
+	if f.isDebug() {
+		log.Printf("debug: rotated encryption key: %s, %s\n", f.bucket, id)
+	}
+	if f.eagerRotation {
+		if _, err := f.startJob(withSystem(ctx), JobReencrypt); err != nil {
+			log.Printf("warn: re-encrypting bucket not started: %s, %v\n", f.bucket, err)
+		}
+	}
+	return nil
+}
+
+// ActiveKey - returns id of key new objects are encrypted with, empty when encryption disabled
+func (f *fsObjectStoreService) ActiveKey() string {
+	if f.keys == nil {
+		return ""
+	}
+	f.keys.mu.RLock()
+	defer f.keys.mu.RUnlock()
+	return f.keys.active
+}
+
+// reencryptState captures checkpoint of re-encryption job, resumed when store is reopened
+type reencryptState struct {
+	Cursor  walkCursor `json:"cursor"`
+	Checked int        `json:"checked"`
+}
+
+// reencrypt - re-encrypts every object not encrypted with active key as built-in job, resuming from checkpointed
+// state unless nil, and reports count of objects checked
+func (f *fsObjectStoreService) reencrypt(ctx context.Context, state json.RawMessage, checkpoint func(interface{})) (interface{}, error) {
+	if f.keys == nil {
+		return nil, ErrEncryptionDisabled
+	}
+	from := reencryptState{}
+	if state != nil {
+		if err := json.Unmarshal(state, &from); err != nil {
+			return nil, err
+		}
+	}
+	count := from.Checked
+	err := f.walkObjectsFrom(ctx, from.Cursor, func(c cid.Cid, path string, info os.FileInfo, at walkCursor) error {
+		if err := f.io.wait(ctx, 0, info.Size()); err != nil {
+			return err
+		}
+		// cursor passes object once handled, objects failing to decrypt are skipped on resume as well
+		defer func() { checkpoint(reencryptState{Cursor: at, Checked: count}) }()
+		stored, err := f.readStored(ctx, path)
+		if err != nil {
+			return nil
+		}
+		inner, keyID, err := f.decrypt(stored)
+		if err != nil {
+			log.Printf("err: decrypting object failed: %s, %v\n", c, err)
+			return nil
+		}
+		f.rekey(ctx, path, inner, keyID)
+		count++
+		return nil
+	})
+	if err != nil {
+		if ctxErr := checkContextError(ctx, f.isDebug()); ctxErr != nil {
+			return nil, ctxErr
+		}
+		log.Printf("err: re-encrypting bucket failed: %s, %v\n", f.bucket, err)
+		return nil, err
+	}
+	if f.isDebug() {
+		log.Printf("debug: re-encrypted bucket: %s, %d objects checked\n", f.bucket, count)
+	}
+	return map[string]int{"checked": count}, nil
+}
@@ -0,0 +1,210 @@
+package fsstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"log"
+	"os"
+
+	"github.com/igumus/go-objectstore-lib"
+)
+
+// ErrEnvelopeCorrupted is return, when stored object envelope could not be decoded.
+var ErrEnvelopeCorrupted = errors.New("fsobjectstore: object envelope corrupted")
+
+// _envelopeMagic handles the leading bytes marking a stored object file as an envelope rather than plain content
+const _envelopeMagic = "FSOE\x01"
+
+const (
+	// envelopePlain wraps plain content that itself begins with envelope magic
+	envelopePlain byte = iota
+	// envelopeDelta holds content as delta against a base object
+	envelopeDelta
+	// envelopeEncrypted holds encrypted plain (or delta) envelope
+	envelopeEncrypted
+)
+
+// isEnveloped - checks whether stored bytes begin with envelope magic
+func isEnveloped(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(_envelopeMagic))
+}
+
+// sealEnvelope - encodes envelope as magic, kind, uvarint length prefixed header, and payload
+func sealEnvelope(kind byte, header, payload []byte) []byte {
+	buf := bytes.Buffer{}
+	buf.WriteString(_envelopeMagic)
+	buf.WriteByte(kind)
+	buf.Write(appendUvarint(nil, uint64(len(header))))
+	buf.Write(header)
+	buf.Write(payload)
+	return buf.Bytes()
+}
+
+// openEnvelope - decodes envelope sealed via `sealEnvelope`
+func openEnvelope(data []byte) (byte, []byte, []byte, error) {
+	rest := data[len(_envelopeMagic):]
+	if len(rest) < 1 {
+		return 0, nil, nil, ErrEnvelopeCorrupted
+	}
+	kind := rest[0]
+	size, n := binary.Uvarint(rest[1:])
+	if n <= 0 || uint64(len(rest)-1-n) < size {
+		return 0, nil, nil, ErrEnvelopeCorrupted
+	}
+	header := rest[1+n : 1+n+int(size)]
+	return kind, header, rest[1+n+int(size):], nil
+}
+
+// escapePlain - returns bytes to store for plain content, wrapping content beginning with envelope magic
+func escapePlain(data []byte) []byte {
+	if isEnveloped(data) {
+		return sealEnvelope(envelopePlain, nil, data)
+	}
+	return data
+}
+
+// sealFile - rewrites plain content staged at path into bytes to store; content beginning with envelope
+// magic is wrapped in an envelope, and content is encrypted when encryption is enabled
+func (f *fsObjectStoreService) sealFile(path string) error {
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return objectstore.ErrObjectWritingFailed
+	}
+	defer file.Close()
+	if f.keys == nil {
+		head := make([]byte, len(_envelopeMagic))
+		if n, err := file.ReadAt(head, 0); err != nil && !errors.Is(err, io.EOF) || !isEnveloped(head[:n]) {
+			return nil
+		}
+	}
+	data, err := io.ReadAll(file)
+	var stored []byte
+	if err == nil {
+		stored, err = f.encrypt(escapePlain(data))
+	}
+	if err == nil {
+		_, err = file.WriteAt(stored, 0)
+	}
+	if err == nil {
+		err = file.Truncate(int64(len(stored)))
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if err != nil {
+		log.Printf("err: sealing staged object failed: %s, %v\n", path, err)
+		return objectstore.ErrObjectWritingFailed
+	}
+	return nil
+}
+
+// _envelopePeek handles the count of leading bytes read to resolve content size of enveloped object files
+const _envelopePeek = 256
+
+// objectSize - returns content size of object file at path with given stored size, resolving plain and
+// delta envelopes from their leading bytes only; encrypted objects are decrypted to resolve their size
+func (f *fsObjectStoreService) objectSize(path string, stored int64) int64 {
+	if stored < int64(len(_envelopeMagic)) {
+		return stored
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return stored
+	}
+	defer file.Close()
+	head := make([]byte, _envelopePeek)
+	n, _ := io.ReadFull(file, head)
+	head = head[:n]
+	if !isEnveloped(head) {
+		return stored
+	}
+	kind, _, payload, err := openEnvelope(head)
+	if err != nil {
+		return stored
+	}
+	switch kind {
+	case envelopePlain:
+		return stored - int64(n-len(payload))
+	case envelopeDelta:
+		if size, m := binary.Uvarint(payload); m > 0 {
+			return int64(size)
+		}
+	case envelopeEncrypted:
+		if data, err := f.load(context.Background(), path); err == nil {
+			return int64(len(data))
+		}
+	}
+	return stored
+}
+
+// load - reads object file at objLink, and returns its content with envelope (if any) resolved. Object
+// not encrypted with active key is re-encrypted on the way.
+func (f *fsObjectStoreService) load(ctx context.Context, objLink string) ([]byte, error) {
+	stored, err := f.readStored(ctx, objLink)
+	if err != nil {
+		return nil, err
+	}
+	inner, keyID, err := f.decrypt(stored)
+	if err != nil {
+		log.Printf("err: decrypting object failed: %s, %v\n", objLink, err)
+		return nil, err
+	}
+	f.rekey(ctx, objLink, inner, keyID)
+	return f.resolve(ctx, inner)
+}
+
+// readStored - reads stored bytes of object file at objLink as they are, guarding path against symbolic links
+// and holding a slot of open file budget (see `WithMaxOpenFiles`) meanwhile
+func (f *fsObjectStoreService) readStored(ctx context.Context, objLink string) ([]byte, error) {
+	if err := f.fds.acquire(ctx); err != nil {
+		return nil, err
+	}
+	defer f.fds.release()
+	file, err := f.openObjectFile(objLink)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return readFile(file, objLink)
+}
+
+// unseal - resolves envelope of stored bytes into object content, decrypting and reconstructing deltas from their base
+func (f *fsObjectStoreService) unseal(ctx context.Context, stored []byte) ([]byte, error) {
+	inner, _, err := f.decrypt(stored)
+	if err != nil {
+		return nil, err
+	}
+	return f.resolve(ctx, inner)
+}
+
+// resolve - resolves plain and delta envelopes of decrypted bytes into object content
+func (f *fsObjectStoreService) resolve(ctx context.Context, data []byte) ([]byte, error) {
+	if !isEnveloped(data) {
+		return data, nil
+	}
+	kind, header, payload, err := openEnvelope(data)
+	if err != nil {
+		return nil, err
+	}
+	switch kind {
+	case envelopePlain:
+		return payload, nil
+	case envelopeDelta:
+		return f.resolveDelta(ctx, header, payload)
+	default:
+		return nil, ErrEnvelopeCorrupted
+	}
+}
+
+// bytesObject serves reconstructed object content as a seekable reader
+type bytesObject struct {
+	*bytes.Reader
+}
+
+// Close - releases reader
+func (bytesObject) Close() error {
+	return nil
+}
@@ -0,0 +1,198 @@
+package events
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	fsstore "github.com/igumus/go-objectstore-fs"
+)
+
+// _defPollInterval handles the default interval journal is polled for new entries
+const _defPollInterval = time.Second
+
+// _defBatchSize handles the default count of journal entries read per poll
+const _defBatchSize = 256
+
+// _defRetryBackoff handles the default initial backoff of retrying failed publishes, doubled up to a minute
+const _defRetryBackoff = time.Second
+
+// Captures/Represents event bus configuration information
+type busConfig struct {
+	bucket    string
+	cursorDir string
+	interval  time.Duration
+	batch     int
+	backoff   time.Duration
+	debug     bool
+}
+
+// A BusOption sets options such as bucket name and cursor directory.
+type BusOption func(*busConfig)
+
+// WithBucket returns a BusOption that specifies bucket name attached to events.
+func WithBucket(b string) BusOption {
+	return func(bc *busConfig) {
+		bc.bucket = b
+	}
+}
+
+// WithCursorDir returns a BusOption that specifies directory persisting delivery position of each sink.
+// If not set, positions are kept in memory only and delivery restarts from journal beginning after restart.
+func WithCursorDir(d string) BusOption {
+	return func(bc *busConfig) {
+		bc.cursorDir = strings.TrimSpace(d)
+	}
+}
+
+// WithPollInterval returns a BusOption that specifies how often journal is polled for new entries.
+// If not set, the default is `1s`
+func WithPollInterval(d time.Duration) BusOption {
+	return func(bc *busConfig) {
+		if d > 0 {
+			bc.interval = d
+		}
+	}
+}
+
+// WithDebugMode returns a BusOption that specifies debug mode.
+// If not set, the default is `false`
+func WithDebugMode(dm bool) BusOption {
+	return func(bc *busConfig) {
+		bc.debug = dm
+	}
+}
+
+// Bus delivers journaled objectstore operations to sinks
+type Bus struct {
+	journal fsstore.JournalReader
+	sinks   []Sink
+	cfg     *busConfig
+}
+
+// NewBus creates Bus delivering operations journaled by given reader to sinks via configuration options.
+func NewBus(journal fsstore.JournalReader, sinks []Sink, opts ...BusOption) *Bus {
+	cfg := &busConfig{interval: _defPollInterval, batch: _defBatchSize, backoff: _defRetryBackoff}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return &Bus{journal: journal, sinks: sinks, cfg: cfg}
+}
+
+// Run - delivers events to every sink until context is done. Each sink progresses independently,
+// so a failing sink never blocks the others.
+func (b *Bus) Run(ctx context.Context) error {
+	wg := sync.WaitGroup{}
+	for _, sink := range b.sinks {
+		wg.Add(1)
+		go func(sink Sink) {
+			defer wg.Done()
+			b.deliver(ctx, sink)
+		}(sink)
+	}
+	wg.Wait()
+	return ctx.Err()
+}
+
+// deliver - follows journal from persisted position of sink, publishing each entry until acknowledged
+func (b *Bus) deliver(ctx context.Context, sink Sink) {
+	cursor := b.loadCursor(sink)
+	for {
+		entries, err := b.journal.ReadJournal(ctx, cursor, b.cfg.batch)
+		if err != nil {
+			log.Printf("err: reading journal for sink failed: %s, %v\n", sink.Name(), err)
+		}
+		for _, entry := range entries {
+			if !b.publish(ctx, sink, entry) {
+				return
+			}
+			cursor = entry.Seq
+			if err := b.storeCursor(sink, cursor); err != nil {
+				log.Printf("err: persisting sink cursor failed: %s, %v\n", sink.Name(), err)
+			}
+		}
+		if len(entries) == b.cfg.batch {
+			continue
+		}
+		select {
+		case <-time.After(b.cfg.interval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// publish - publishes journal entry to sink, retrying with backoff until it succeeds or context is done
+func (b *Bus) publish(ctx context.Context, sink Sink, entry fsstore.JournalEntry) bool {
+	event := Event{
+		Seq:    entry.Seq,
+		Type:   string(entry.Op),
+		Cid:    entry.Cid.String(),
+		Size:   entry.Size,
+		Bucket: b.cfg.bucket,
+		Time:   entry.Time,
+	}
+	backoff := b.cfg.backoff
+	for {
+		err := sink.Publish(ctx, event)
+		if err == nil {
+			if b.cfg.debug {
+				log.Printf("debug: published event: %s, %d\n", sink.Name(), event.Seq)
+			}
+			return true
+		}
+		log.Printf("err: publishing event failed: %s, %d, %v\n", sink.Name(), event.Seq, err)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return false
+		}
+		if backoff *= 2; backoff > time.Minute {
+			backoff = time.Minute
+		}
+	}
+}
+
+// cursorPath - returns file path persisting delivery position of sink
+func (b *Bus) cursorPath(sink Sink) string {
+	return filepath.Join(b.cfg.cursorDir, sink.Name()+".cursor")
+}
+
+// loadCursor - returns persisted delivery position of sink, zero when none persisted
+func (b *Bus) loadCursor(sink Sink) uint64 {
+	if len(b.cfg.cursorDir) == 0 {
+		return 0
+	}
+	data, err := ioutil.ReadFile(b.cursorPath(sink))
+	if err != nil {
+		return 0
+	}
+	cursor, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		log.Printf("err: decoding sink cursor failed: %s, %v\n", sink.Name(), err)
+		return 0
+	}
+	return cursor
+}
+
+// storeCursor - persists delivery position of sink atomically
+func (b *Bus) storeCursor(sink Sink, cursor uint64) error {
+	if len(b.cfg.cursorDir) == 0 {
+		return nil
+	}
+	if err := os.MkdirAll(b.cfg.cursorDir, 0777); err != nil {
+		return err
+	}
+	path := b.cursorPath(sink)
+	staged := path + ".tmp"
+	if err := ioutil.WriteFile(staged, []byte(strconv.FormatUint(cursor, 10)), 0666); err != nil {
+		return err
+	}
+	return os.Rename(staged, path)
+}
@@ -0,0 +1,30 @@
+// Package events publishes objectstore operations (create/delete/gc) to pluggable sinks such as
+// webhooks, NATS subjects and Kafka topics. Delivery is at-least-once: events are read from the
+// objectstore journal, and each sink's position is persisted only after the sink acknowledged them.
+package events
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrPublishFailed is return, when sink could not publish an event.
+var ErrPublishFailed = errors.New("events: publishing event failed")
+
+// Event captures a published objectstore operation
+type Event struct {
+	Seq    uint64    `json:"seq"`
+	Type   string    `json:"type"`
+	Cid    string    `json:"cid"`
+	Size   int64     `json:"size"`
+	Bucket string    `json:"bucket"`
+	Time   time.Time `json:"time"`
+}
+
+// Sink defines the functions clients need to provide for delivering events to a destination.
+// Sink name identifies persisted delivery position, so it must be stable across restarts.
+type Sink interface {
+	Name() string
+	Publish(context.Context, Event) error
+}
@@ -0,0 +1,119 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// webhookSink POSTs events as json to an url
+type webhookSink struct {
+	name   string
+	url    string
+	client *http.Client
+}
+
+// NewWebhookSink creates Sink POSTing events as json to url, succeeding on 2xx responses.
+// Nil client falls back to http.DefaultClient.
+func NewWebhookSink(name, url string, client *http.Client) Sink {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &webhookSink{name: name, url: url, client: client}
+}
+
+// Name - returns name of sink
+func (w *webhookSink) Name() string {
+	return w.name
+}
+
+// Publish - POSTs event to webhook url
+func (w *webhookSink) Publish(ctx context.Context, event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := w.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%w: %s responded %s", ErrPublishFailed, w.url, resp.Status)
+	}
+	return nil
+}
+
+// NATSPublisher defines the publishing function of a NATS connection, which *nats.Conn implements.
+type NATSPublisher interface {
+	Publish(subject string, data []byte) error
+}
+
+// natsSink publishes events as json to a NATS subject
+type natsSink struct {
+	name    string
+	conn    NATSPublisher
+	subject string
+}
+
+// NewNATSSink creates Sink publishing events as json to subject via conn.
+func NewNATSSink(name string, conn NATSPublisher, subject string) Sink {
+	return &natsSink{name: name, conn: conn, subject: subject}
+}
+
+// Name - returns name of sink
+func (n *natsSink) Name() string {
+	return n.name
+}
+
+// Publish - publishes event to NATS subject
+func (n *natsSink) Publish(ctx context.Context, event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return n.conn.Publish(n.subject, data)
+}
+
+// KafkaProducer defines the producing function clients need to adapt their Kafka client
+// (e.g. sarama, segmentio/kafka-go, confluent-kafka-go) to.
+type KafkaProducer interface {
+	Produce(ctx context.Context, topic string, key, value []byte) error
+}
+
+// kafkaSink produces events as json to a Kafka topic keyed by cid
+type kafkaSink struct {
+	name     string
+	producer KafkaProducer
+	topic    string
+}
+
+// NewKafkaSink creates Sink producing events as json to topic via producer, keyed by cid so events
+// of same object stay ordered in one partition.
+func NewKafkaSink(name string, producer KafkaProducer, topic string) Sink {
+	return &kafkaSink{name: name, producer: producer, topic: topic}
+}
+
+// Name - returns name of sink
+func (k *kafkaSink) Name() string {
+	return k.name
+}
+
+// Publish - produces event to Kafka topic
+func (k *kafkaSink) Publish(ctx context.Context, event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return k.producer.Produce(ctx, k.topic, []byte(event.Cid), data)
+}
@@ -0,0 +1,132 @@
+package fsstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"time"
+
+	"github.com/igumus/go-objectstore-lib"
+	"github.com/ipfs/go-cid"
+)
+
+// ErrInvalidRange is return, when byte range being read starts before object or past its end.
+var ErrInvalidRange = errors.New("fsobjectstore: invalid range")
+
+// Focused extension interfaces of `objectstore.ObjectStore`: consumers depend only on features they need, and
+// other backends implement subsets of them. `MetadataStore` completes the set.
+
+// StreamingStore defines the functions clients need to stream objects in and out of store, without holding them
+// fully in memory.
+type StreamingStore interface {
+	ObjectOpener
+	Putter
+}
+
+// DeletableStore defines the functions clients need to delete objects.
+type DeletableStore interface {
+	DeleteObject(context.Context, cid.Cid) error
+}
+
+// RangeReader defines the functions clients need to read byte ranges of objects.
+type RangeReader interface {
+	ReadRange(ctx context.Context, c cid.Cid, offset, length int64) ([]byte, error)
+}
+
+// ObjectStat captures content size of an object, and time it was last modified at
+type ObjectStat struct {
+	Cid     cid.Cid
+	Size    int64
+	ModTime time.Time
+}
+
+// StatStore defines the functions clients need to learn size of objects without reading them.
+type StatStore interface {
+	StatObject(context.Context, cid.Cid) (ObjectStat, error)
+}
+
+var _ StreamingStore = (*fsObjectStoreService)(nil)
+var _ DeletableStore = (*fsObjectStoreService)(nil)
+var _ RangeReader = (*fsObjectStoreService)(nil)
+var _ StatStore = (*fsObjectStoreService)(nil)
+
+// ReadRange - reads length bytes of object with specified cid (aka content identifier) from offset; negative
+// length reads until end of object, and ranges past end of object are cut short. Like `OpenObject`, content is
+// not verified against cid.
+func (f *fsObjectStoreService) ReadRange(ctx context.Context, c cid.Cid, offset, length int64) ([]byte, error) {
+	if err := f.authorize(ctx, OpRead, c); err != nil {
+		return nil, err
+	}
+	data, err := f.readRange(ctx, c, offset, length)
+	if err == nil {
+		f.popularity.record(c)
+	}
+	f.audit(ctx, OpRead, c, err)
+	return data, err
+}
+
+// readRange - reads byte range of object via seekable reader of `openObject`
+func (f *fsObjectStoreService) readRange(ctx context.Context, c cid.Cid, offset, length int64) ([]byte, error) {
+	if offset < 0 {
+		return nil, ErrInvalidRange
+	}
+	obj, err := f.openObject(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	defer obj.Close()
+	size, err := obj.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, objectstore.ErrObjectReadingFailed
+	}
+	if offset > size {
+		return nil, ErrInvalidRange
+	}
+	if length < 0 || length > size-offset {
+		length = size - offset
+	}
+	if _, err := obj.Seek(offset, io.SeekStart); err != nil {
+		return nil, objectstore.ErrObjectReadingFailed
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(obj, data); err != nil {
+		log.Printf("err: reading object range failed: %s, %d, %d, %v\n", c, offset, length, err)
+		return nil, objectstore.ErrObjectReadingFailed
+	}
+	if f.isDebug() {
+		log.Printf("debug: read object range: %s, %d, %d\n", c, offset, length)
+	}
+	return data, nil
+}
+
+// StatObject - returns content size and modification time of object with specified cid (aka content identifier),
+// reading only leading bytes of enveloped objects (and all of encrypted ones) to resolve their size
+func (f *fsObjectStoreService) StatObject(ctx context.Context, c cid.Cid) (ObjectStat, error) {
+	if err := f.authorize(ctx, OpRead, c); err != nil {
+		return ObjectStat{}, err
+	}
+	stat, err := f.statObject(ctx, c)
+	f.audit(ctx, OpRead, c, err)
+	return stat, err
+}
+
+// statObject - stats object file of cid, resolving content size of enveloped objects
+func (f *fsObjectStoreService) statObject(ctx context.Context, c cid.Cid) (ObjectStat, error) {
+	key := c.String()
+	if f.negative.contains(key) {
+		return ObjectStat{}, objectstore.ErrObjectNotExists
+	}
+	if ctxErr := checkContextError(ctx, f.isDebug()); ctxErr != nil {
+		return ObjectStat{}, ctxErr
+	}
+	objLink := f.objectPath(c)
+	info, err := f.statObjectFile(objLink)
+	if err != nil {
+		if errors.Is(err, objectstore.ErrObjectNotExists) {
+			f.rememberAbsent(key)
+		}
+		return ObjectStat{}, err
+	}
+	return ObjectStat{Cid: c, Size: f.objectSize(objLink, info.Size()), ModTime: f.placedAt(key, info)}, nil
+}
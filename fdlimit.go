@@ -0,0 +1,113 @@
+package fsstore
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+)
+
+// OpenFilesStats captures usage of open file budget (see `WithMaxOpenFiles`). Waits counts opens which had to
+// wait for budget, WaitTime and MaxWait their total and longest wait.
+type OpenFilesStats struct {
+	Limit    int
+	InUse    int
+	Acquired int64
+	Waits    int64
+	WaitTime time.Duration
+	MaxWait  time.Duration
+}
+
+// OpenFilesReporter defines the functions clients need to observe file descriptor usage of objectstore.
+type OpenFilesReporter interface {
+	OpenFiles() OpenFilesStats
+}
+
+var _ OpenFilesReporter = (*fsObjectStoreService)(nil)
+
+// fdBudget bounds number of object files open at once via a semaphore
+type fdBudget struct {
+	slots chan struct{}
+	mu    sync.Mutex
+	stats OpenFilesStats
+}
+
+// newFDBudget - creates budget of n open files, nil when unlimited
+func newFDBudget(n int) *fdBudget {
+	if n <= 0 {
+		return nil
+	}
+	return &fdBudget{slots: make(chan struct{}, n), stats: OpenFilesStats{Limit: n}}
+}
+
+// acquire - blocks until a file may be opened within budget, or context is done; no slot is taken once context
+// is done
+func (b *fdBudget) acquire(ctx context.Context) error {
+	if b == nil {
+		return nil
+	}
+	if ctx.Err() != nil {
+		return checkContextError(ctx, false)
+	}
+	select {
+	case b.slots <- struct{}{}:
+		b.record(0, false)
+		return nil
+	default:
+	}
+	start := time.Now()
+	select {
+	case b.slots <- struct{}{}:
+		b.record(time.Since(start), true)
+		return nil
+	case <-ctx.Done():
+		return checkContextError(ctx, false)
+	}
+}
+
+// record - accounts an acquired slot, and its wait when it had to wait
+func (b *fdBudget) record(wait time.Duration, waited bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.stats.Acquired++
+	if waited {
+		b.stats.Waits++
+		b.stats.WaitTime += wait
+		if wait > b.stats.MaxWait {
+			b.stats.MaxWait = wait
+		}
+	}
+}
+
+// release - returns slot of a closed file to budget
+func (b *fdBudget) release() {
+	if b != nil {
+		<-b.slots
+	}
+}
+
+// budgetedFile releases its budget slot once closed
+type budgetedFile struct {
+	*os.File
+	budget *fdBudget
+	once   sync.Once
+}
+
+// Close - closes file and releases its budget slot
+func (b *budgetedFile) Close() error {
+	err := b.File.Close()
+	b.once.Do(b.budget.release)
+	return err
+}
+
+// OpenFiles - returns usage of open file budget; zero stats are returned when budget is unlimited
+func (f *fsObjectStoreService) OpenFiles() OpenFilesStats {
+	if f.fds == nil {
+		return OpenFilesStats{}
+	}
+	f.fds.mu.Lock()
+	defer f.fds.mu.Unlock()
+	stats := f.fds.stats
+	stats.InUse = len(f.fds.slots)
+	return stats
+}
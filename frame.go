@@ -0,0 +1,111 @@
+package fsstore
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"hash/crc32"
+	"log"
+)
+
+// ErrInternalCorrupted is return, when an internal file (or record of it) does not match its checksum.
+var ErrInternalCorrupted = errors.New("fsobjectstore: internal file corrupted")
+
+// _frameMagic handles the marker of checksum trailer appended to internal files
+const _frameMagic = "\x00fsc"
+
+// _frameSize handles the length of checksum trailer: marker followed by big endian CRC32C of content
+const _frameSize = len(_frameMagic) + 4
+
+// _lineFrameSize handles the length of checksum suffix of internal records: space followed by hex CRC32C
+const _lineFrameSize = 1 + 8
+
+// _crcTable handles the CRC32C (Castagnoli) table internal files are checksummed with
+var _crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// frame - returns data with checksum trailer appended
+func frame(data []byte) []byte {
+	ret := make([]byte, 0, len(data)+_frameSize)
+	ret = append(ret, data...)
+	ret = append(ret, _frameMagic...)
+	sum := make([]byte, 4)
+	binary.BigEndian.PutUint32(sum, crc32.Checksum(data, _crcTable))
+	return append(ret, sum...)
+}
+
+// _framedFormat handles the format version of buckets whose internal files are all checksummed (see
+// `BucketMetadata`); buckets recorded without it may hold files written before internal files were checksummed
+const _framedFormat = 1
+
+// unframe - returns content of framed data, verifying its checksum trailer. Data without trailer is accepted as
+// is only when legacy, i.e. it may be written before internal files were checksummed.
+func unframe(data []byte, legacy bool) ([]byte, error) {
+	n := len(data) - _frameSize
+	if n < 0 || string(data[n:n+len(_frameMagic)]) != _frameMagic {
+		if legacy {
+			return data, nil
+		}
+		return nil, ErrInternalCorrupted
+	}
+	if binary.BigEndian.Uint32(data[n+len(_frameMagic):]) != crc32.Checksum(data[:n], _crcTable) {
+		return nil, ErrInternalCorrupted
+	}
+	return data[:n], nil
+}
+
+// frameLine - returns record with checksum suffix appended, for line oriented internal files
+func frameLine(line []byte) []byte {
+	sum := make([]byte, 4)
+	binary.BigEndian.PutUint32(sum, crc32.Checksum(line, _crcTable))
+	ret := make([]byte, 0, len(line)+_lineFrameSize)
+	ret = append(ret, line...)
+	ret = append(ret, ' ')
+	return append(ret, hex.EncodeToString(sum)...)
+}
+
+// unframeLine - returns record of framed line, verifying its checksum suffix. Lines without suffix are accepted
+// as is only when legacy, as `unframe` does.
+func unframeLine(line []byte, legacy bool) ([]byte, error) {
+	n := len(line) - _lineFrameSize
+	var sum []byte
+	var err error
+	if n >= 0 && line[n] == ' ' {
+		sum, err = hex.DecodeString(string(line[n+1:]))
+	}
+	if sum == nil || err != nil {
+		if legacy {
+			return line, nil
+		}
+		return nil, ErrInternalCorrupted
+	}
+	if binary.BigEndian.Uint32(sum) != crc32.Checksum(line[:n], _crcTable) {
+		return nil, ErrInternalCorrupted
+	}
+	return line[:n], nil
+}
+
+// readInternal - reads internal file at path, verifying its checksum
+func (f *fsObjectStoreService) readInternal(path string) ([]byte, error) {
+	data, err := read(path)
+	if err != nil {
+		return nil, err
+	}
+	content, err := unframe(data, f.legacyFrames)
+	if err != nil {
+		log.Printf("err: internal file checksum mismatch: %s\n", path)
+		return nil, err
+	}
+	return content, nil
+}
+
+// writeInternal - writes internal file at path atomically, with checksum trailer
+func (f *fsObjectStoreService) writeInternal(path string, data []byte) error {
+	return write(f.tempDir, path, frame(data))
+}
+
+// framedEqual - checks whether internal file at path holds exactly framed data, so unchanged files are not rewritten
+func framedEqual(path string, data []byte) bool {
+	current, err := read(path)
+	return err == nil && bytes.Equal(current, frame(data))
+}
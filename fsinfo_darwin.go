@@ -0,0 +1,28 @@
+package fsstore
+
+import (
+	"bytes"
+
+	"golang.org/x/sys/unix"
+)
+
+// statFS - reports file system type, mount flags and capacity of file system holding path
+func statFS(path string) (fsInfo, error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(path, &st); err != nil {
+		return fsInfo{}, err
+	}
+	name := st.Fstypename[:]
+	if i := bytes.IndexByte(name, 0); i >= 0 {
+		name = name[:i]
+	}
+	return fsInfo{
+		Type:       string(name),
+		ReadOnly:   st.Flags&unix.MNT_RDONLY != 0,
+		NoAtime:    st.Flags&unix.MNT_NOATIME != 0,
+		Inodes:     st.Files,
+		FreeInodes: st.Ffree,
+		Bytes:      st.Blocks * uint64(st.Bsize),
+		FreeBytes:  st.Bavail * uint64(st.Bsize),
+	}, nil
+}
@@ -0,0 +1,42 @@
+package fsstore
+
+import "golang.org/x/sys/unix"
+
+// _zfsMagic handles the file system magic of zfs, not defined by unix package
+const _zfsMagic = 0x2fc12fc1
+
+// _fsTypes handles file system names of known statfs magic numbers
+var _fsTypes = map[int64]string{
+	unix.EXT4_SUPER_MAGIC:      "ext4",
+	unix.XFS_SUPER_MAGIC:       "xfs",
+	unix.BTRFS_SUPER_MAGIC:     "btrfs",
+	unix.F2FS_SUPER_MAGIC:      "f2fs",
+	_zfsMagic:                  "zfs",
+	unix.NFS_SUPER_MAGIC:       "nfs",
+	unix.CIFS_SUPER_MAGIC:      "cifs",
+	unix.SMB2_SUPER_MAGIC:      "smb2",
+	unix.TMPFS_MAGIC:           "tmpfs",
+	unix.OVERLAYFS_SUPER_MAGIC: "overlay",
+	unix.FUSE_SUPER_MAGIC:      "fuse",
+}
+
+// statFS - reports file system type, mount flags and capacity of file system holding path
+func statFS(path string) (fsInfo, error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(path, &st); err != nil {
+		return fsInfo{}, err
+	}
+	fsType, ok := _fsTypes[int64(st.Type)]
+	if !ok {
+		fsType = "unknown"
+	}
+	return fsInfo{
+		Type:       fsType,
+		ReadOnly:   st.Flags&unix.ST_RDONLY != 0,
+		NoAtime:    st.Flags&unix.ST_NOATIME != 0,
+		Inodes:     st.Files,
+		FreeInodes: st.Ffree,
+		Bytes:      st.Blocks * uint64(st.Bsize),
+		FreeBytes:  st.Bavail * uint64(st.Bsize),
+	}, nil
+}
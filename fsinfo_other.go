@@ -0,0 +1,8 @@
+//go:build !linux && !darwin
+
+package fsstore
+
+// statFS - returns errFSInfoUnsupported, since file system details are not detected on this platform
+func statFS(path string) (fsInfo, error) {
+	return fsInfo{}, errFSInfoUnsupported
+}
@@ -1,6 +1,7 @@
 package fsstore
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -9,6 +10,8 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"sync"
+	"time"
 
 	"github.com/igumus/go-objectstore-lib"
 	"github.com/ipfs/go-cid"
@@ -17,11 +20,88 @@ import (
 // ErrDataDigestionFailed is return, when file system objectstore's object digestion failed.
 var ErrDataDigestionFailed = errors.New("fsobjectstore: object digestion failed")
 
+// ConditionalCreator defines the functions clients need to know whether created
+// content is newly written or deduplicated with an existing object.
+type ConditionalCreator interface {
+	CreateObjectIfAbsent(context.Context, io.Reader) (cid.Cid, bool, error)
+}
+
+var _ ConditionalCreator = (*fsObjectStoreService)(nil)
+
 // Captures/Represents filesystem backed objectstore service information
 type fsObjectStoreService struct {
-	debug   bool
-	dataDir string
-	bucket  string
+	debug          int32
+	dataDir        string
+	stripes        []string
+	active         int
+	ring           *ring
+	rebalancing    int32
+	rebalanceLimit *rateLimiter
+	bucket         string
+	tempDir        string
+	xattrs         int32
+	fds            *fdBudget
+	symlinks       SymlinkPolicy
+	negative       *negativeCache
+	verified       *verifyCache
+	listBuffer     int
+	listStall      time.Duration
+	consistentList bool
+	journal        *journal
+	stats          *stats
+	metrics        *metrics
+	views          views
+	popularity     *popularity
+	usage          *usage
+	chaos          *chaos
+	clock          Clock
+	negPersist     bool
+	layouts        []layoutProbe
+	layoutRewrite  bool
+	legacyFrames   bool
+	webhook        *webhook
+	scrubLimit     *rateLimiter
+	opTimeout      time.Duration
+	slowOp         time.Duration
+	chunker        *cdc
+	chunkWorkers   int
+	pool           bool
+	gate           writeGate
+	policy         *BucketPolicy
+	catalog        *catalog
+	lifecycle      []LifecycleRule
+	transitioner   Transitioner
+	io             ioThrottle
+	liveMu         sync.Mutex
+	maxDeltaDepth  int
+	replicas       []objectstore.ObjectStore
+	hedgeDelay     time.Duration
+	maint          *maintenance
+	jobs           *jobs
+	dirHealth      dirHealth
+	standby        *standby
+	pending        *fsObjectStoreConfig
+	provisioned    int32
+	unclean        bool
+	provisionMu    sync.Mutex
+	keys           *keyring
+	authorizer     Authorizer
+	auditLog       *auditLog
+	trashRetention time.Duration
+	uploadTTL      time.Duration
+	eagerRotation  bool
+	bgCtx          context.Context
+	bgCancel       context.CancelFunc
+	bgWG           sync.WaitGroup
+	closeOnce      sync.Once
+	refMu          sync.Mutex
+	snapMu         sync.Mutex
+	signer         Signer
+	scanners       []ContentScanner
+	blocklist      blocklist
+	placed         *placements
+	readOnly       bool
+	lock           *os.File
 }
 
 // NewFileSystemObjectStore creates file system backed ObjectStore instance via given configuration options.
@@ -34,106 +114,445 @@ func NewFileSystemObjectStore(opts ...FSObjectstoreConfigOption) (objectstore.Ob
 	if err := cfg.validate(); err != nil {
 		return nil, err
 	}
+	chunker, err := newCDC(cfg.chunkMin, cfg.chunkAvg, cfg.chunkMax)
+	if err != nil {
+		return nil, err
+	}
 	srv := &fsObjectStoreService{
-		debug:   cfg.debug,
-		dataDir: cfg.dir,
-		bucket:  cfg.bucket,
+		dataDir:        cfg.dir,
+		bucket:         cfg.bucket,
+		tempDir:        cfg.tempDir,
+		symlinks:       cfg.symlinks,
+		negative:       newNegativeCache(cfg.negCacheSize, cfg.negCacheTTL),
+		verified:       newVerifyCache(cfg.verifyCacheSize, cfg.verifyCacheTTL),
+		stats:          newStats(cfg.cidIndex || cfg.watchExternal),
+		metrics:        newMetrics(),
+		views:          views{open: map[*snapshotView]struct{}{}},
+		listBuffer:     cfg.listBuffer,
+		listStall:      cfg.listStall,
+		consistentList: cfg.consistentList,
+		scrubLimit:     &rateLimiter{rate: cfg.scrubRate},
+		fds:            newFDBudget(cfg.maxOpenFiles),
+		rebalanceLimit: &rateLimiter{rate: cfg.rebalanceRate},
+		opTimeout:      cfg.opTimeout,
+		slowOp:         cfg.slowOp,
+		chunker:        chunker,
+		chunkWorkers:   cfg.chunkWorkers,
+		pool:           cfg.pool,
+		gate:           writeGate{bound: cfg.maintQueue},
+		policy:         newBucketPolicy(cfg),
+		lifecycle:      cfg.lifecycle,
+		transitioner:   cfg.transitioner,
+		maxDeltaDepth:  cfg.maxDeltaDepth,
+		replicas:       cfg.replicas,
+		hedgeDelay:     cfg.hedgeDelay,
+		eagerRotation:  cfg.eagerRotation,
+		authorizer:     cfg.authorizer,
+		trashRetention: cfg.trashRetention,
+		uploadTTL:      cfg.uploadTTL,
+		jobs:           newJobs(cfg.jobConcurrency, cfg.jobHistory),
+		signer:         cfg.signer,
+		scanners:       cfg.scanners,
+		clock:          cfg.clock,
+		negPersist:     cfg.negPersist,
+		layoutRewrite:  cfg.layoutRewrite,
+		readOnly:       cfg.readOnly,
+	}
+	srv.setDebug(cfg.debug)
+	srv.io.set(cfg.ioBudget)
+	srv.bgCtx, srv.bgCancel = context.WithCancel(withSystem(context.Background()))
+	if cfg.xattrs {
+		srv.xattrs = 1
+	}
+	if cfg.topCapacity > 0 {
+		srv.popularity = newPopularity(cfg.topCapacity)
+	}
+	if cfg.usageRetention > 0 {
+		srv.usage = newUsage(cfg.usageRetention)
+	}
+	if cfg.chaos != nil {
+		srv.chaos = newChaos(*cfg.chaos)
+	}
+	if cfg.keyProvider != nil {
+		keys, err := newKeyring(cfg.keyProvider, cfg.activeKey)
+		if err != nil {
+			return nil, err
+		}
+		srv.keys = keys
+	}
+	if len(srv.tempDir) == 0 {
+		srv.tempDir = srv.internalPath(_tempDir)
+	}
+	if len(cfg.extraDirs) > 0 || len(cfg.retiredDirs) > 0 {
+		srv.stripes = []string{srv.bucketDir()}
+		for _, dir := range cfg.extraDirs {
+			srv.stripes = append(srv.stripes, filepath.Join(dir, srv.bucket))
+		}
+		srv.active = len(srv.stripes)
+		for _, dir := range cfg.retiredDirs {
+			srv.stripes = append(srv.stripes, filepath.Join(dir, srv.bucket))
+		}
 	}
 
-	dir := fmt.Sprintf("%s/%s", srv.dataDir, srv.bucket)
-	if !exists(dir) {
-		if err := os.MkdirAll(dir, 0777); err != nil {
+	if err := srv.validatePaths(); err != nil {
+		return nil, err
+	}
+	if srv.readOnly {
+		if err := srv.openReadOnly(cfg); err != nil {
+			return nil, err
+		}
+	} else if cfg.lazyInit && cfg.standbySource == nil && !srv.provisionedOnDisk() {
+		// provisioning is deferred to first write (or `EnsureBucket`), so opening store modifies nothing
+		srv.deferProvision(cfg)
+	} else if err := srv.provision(cfg); err != nil {
+		return nil, err
+	} else {
+		srv.provisioned = 1
+	}
+	if srv.keys != nil {
+		srv.loadActiveKey()
+	}
+	if srv.readOnly {
+		// read only stores follow nothing, and run neither maintenance nor checkpoints in background
+		return srv, nil
+	}
+	if len(cfg.webhookURL) > 0 {
+		srv.webhook = newWebhook(cfg.webhookURL, cfg.webhookSecret, srv.isDebug())
+	}
+
+	if cfg.standbySource != nil {
+		if err := srv.startStandby(cfg.standbySource, cfg.standbyPoll); err != nil {
 			return nil, err
 		}
 	}
+	if cfg.schedule != nil {
+		srv.startMaintenance(cfg.schedule, cfg.retention)
+	}
+	if cfg.statsInterval > 0 {
+		srv.startStatsCheckpoint(cfg.statsInterval)
+	}
+	if cfg.watchExternal {
+		srv.startExternalWatch()
+	}
+	srv.checkDataDirs()
+	srv.startDataDirChecks(_dataDirCheckInterval)
+	if srv.isProvisioned() {
+		srv.resumeJobs()
+	}
+	if srv.unclean {
+		log.Printf("warn: store not closed cleanly, reconciling indexes: %s\n", srv.bucket)
+		if _, err := srv.startJob(withSystem(context.Background()), JobReconcile); err != nil {
+			log.Printf("err: starting reconciliation failed: %s, %v\n", srv.bucket, err)
+		}
+	}
 
 	return srv, nil
 }
 
+// provision - creates bucket, internal and temp directories of store, loads placement ring, migrates legacy
+// layout, records bucket metadata, opens journal, audit log and catalog when configured, recovers checkpointed
+// statistics and placements (see `placements`) and marks store open. Bucket is locked for store as soon as its
+// internal directory exists (see `lockBucket`), and unlocked when provisioning fails
+func (f *fsObjectStoreService) provision(cfg *fsObjectStoreConfig) (err error) {
+	dir := f.bucketDir()
+	if !exists(dir) {
+		if err := os.MkdirAll(dir, 0777); err != nil {
+			return err
+		}
+	}
+	// internal files of bucket opened first by this version are all checksummed
+	fresh := !exists(f.internalPath()) && !f.hasLegacyInternals()
+	if err := os.MkdirAll(f.internalPath(), 0777); err != nil {
+		return err
+	}
+	if err := f.lockBucket(); err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			f.unlockBucket()
+		}
+	}()
+	if err := f.loadFormat(fresh); err != nil {
+		return err
+	}
+	if len(f.stripes) > 0 || exists(f.internalPath(_ringFile)) {
+		if err := f.loadRing(); err != nil {
+			return err
+		}
+	}
+	for _, stripe := range f.stripeDirs()[1:] {
+		if err := os.MkdirAll(f.tempFor(stripe), 0777); err != nil {
+			return err
+		}
+	}
+	if err := f.migrateLayout(); err != nil {
+		return err
+	}
+	if err := f.validateTempDir(); err != nil {
+		return err
+	}
+	if err := f.recordBucket(fresh); err != nil {
+		return err
+	}
+	if err := f.recordLayouts(cfg.layouts); err != nil {
+		return err
+	}
+	if cfg.journal {
+		j, err := openJournal(f.internalPath(_journalFile), f.clock, f.legacyFrames)
+		if errors.Is(err, ErrJournalCorrupted) {
+			j, err = f.rebuildJournal(f.internalPath(_journalFile))
+		}
+		if err != nil {
+			return err
+		}
+		f.journal = j
+	}
+	if cfg.audit {
+		a, err := openAuditLog(f.internalPath(_auditFile))
+		if err != nil {
+			return err
+		}
+		f.auditLog = a
+	}
+	if cfg.catalogDB != nil {
+		c, err := openCatalog(cfg.catalogDB, f.bucket, f.clock)
+		if err != nil {
+			return err
+		}
+		f.catalog = c
+	}
+	if err := f.loadBlocklist(); err != nil {
+		return err
+	}
+	f.loadStats()
+	f.loadUsage()
+	f.loadNegativeCache()
+	placed, err := openPlacements(f.internalPath(_placedFile), f.tempDir, f.legacyFrames)
+	if err != nil {
+		return err
+	}
+	f.placed = placed
+	f.unclean = f.markOpen()
+	return nil
+}
+
+// bucketDir - returns file system path of bucket directory
+func (f *fsObjectStoreService) bucketDir() string {
+	return fmt.Sprintf("%s/%s", f.dataDir, f.bucket)
+}
+
 // path - returns file system path of given object link
 func (f *fsObjectStoreService) path(objLink string) string {
-	return fmt.Sprintf("%s/%s/%s", f.dataDir, f.bucket, objLink)
+	return fmt.Sprintf("%s/%s", f.bucketDir(), objLink)
 }
 
-// HasObject - checks whether object exists on file system with specified cid (aka content identifier)
+// HasObject - checks whether object exists on file system with specified cid (aka content identifier), in current
+// layout or a historical one (see `WithHistoricalLayout`); absence is remembered for a while (see `WithNegativeCache`) to spare repeated misses
 func (f *fsObjectStoreService) HasObject(ctx context.Context, cid cid.Cid) bool {
-	objLink := f.path(objectstore.DefaultLinkFunc(cid.String()))
-	ret := exists(objLink)
-	if f.debug {
+	if f.authorize(ctx, OpRead, cid) != nil {
+		return false
+	}
+	defer f.observe(ctx, "has", time.Now(), cid, 0)
+	key := cid.String()
+	hit := f.negative.contains(key)
+	f.metrics.negativeLookup(hit)
+	objLink := f.objectPath(cid)
+	ret := !hit && exists(objLink)
+	if !hit && !ret && len(f.layouts) > 0 {
+		_, legacy := f.legacyPath(cid)
+		ret = len(legacy) > 0
+	}
+	if !hit && !ret {
+		f.rememberAbsent(key)
+	}
+	if f.isDebug() {
 		log.Printf("debug: has object: %s, %t\n", objLink, ret)
 	}
+	f.audit(ctx, OpRead, cid, nil)
 	return ret
 }
 
-// ReadObject - reads object on file system with specified cid (aka content identifier)
-func (f *fsObjectStoreService) ReadObject(ctx context.Context, cid cid.Cid) ([]byte, error) {
-	if !f.HasObject(ctx, cid) {
-		return nil, objectstore.ErrObjectNotExists
+// ReadObject - reads object on file system with specified cid (aka content identifier). Object file is
+// opened directly, and absence is remembered for a short while to spare repeated misses. When replicas
+// are configured (see `WithReplica`), read is hedged against them.
+func (f *fsObjectStoreService) ReadObject(ctx context.Context, cid cid.Cid) (data []byte, err error) {
+	start := time.Now()
+	defer func() { f.observe(ctx, "read", start, cid, int64(len(data))) }()
+	if err := f.authorize(ctx, OpRead, cid); err != nil {
+		return nil, err
+	}
+	defer func(ctx context.Context) { f.audit(ctx, OpRead, cid, err) }(ctx)
+	ctx, cancel := f.withDeadline(ctx)
+	defer cancel()
+
+	if len(f.replicas) > 0 {
+		data, err = f.hedgedRead(ctx, cid)
+	} else {
+		data, err = f.readLocal(ctx, cid)
 	}
-	objLink := f.path(objectstore.DefaultLinkFunc(cid.String()))
-	if f.debug {
-		log.Printf("debug: check object existence: %s\n", objLink)
+	if err == nil {
+		f.popularity.record(cid)
 	}
-	if ctxErr := checkContextError(ctx, f.debug); ctxErr != nil {
+	return data, err
+}
+
+// readLocal - reads object with specified cid from file system of store
+func (f *fsObjectStoreService) readLocal(ctx context.Context, cid cid.Cid) (data []byte, err error) {
+	key := cid.String()
+	hit := f.negative.contains(key)
+	f.metrics.negativeLookup(hit)
+	if hit {
+		if f.isDebug() {
+			log.Printf("debug: read object negative cache hit: %s\n", key)
+		}
+		return nil, objectstore.ErrObjectNotExists
+	}
+	if ctxErr := checkContextError(ctx, f.isDebug()); ctxErr != nil {
 		return nil, ctxErr
 	}
-	if f.debug {
-		log.Printf("debug: check context error: %s\n", objLink)
+	objLink := f.objectPath(cid)
+	var content []byte
+	err = f.bounded(ctx, func() error {
+		var readErr error
+		content, readErr = f.load(ctx, objLink)
+		if errors.Is(readErr, objectstore.ErrObjectNotExists) && f.isRebalancing() {
+			// object may have been moved to another stripe after it was located
+			content, readErr = f.load(ctx, f.objectPath(cid))
+		}
+		if errors.Is(readErr, objectstore.ErrObjectNotExists) && len(f.layouts) > 0 {
+			content, readErr = f.readLegacy(ctx, cid)
+		}
+		return readErr
+	})
+	if err == nil {
+		data = content
 	}
-	return read(objLink)
+	if errors.Is(err, objectstore.ErrObjectNotExists) {
+		f.rememberAbsent(key)
+	}
+	if f.isDebug() {
+		log.Printf("debug: read object: %s, %v\n", objLink, err)
+	}
+	return data, err
 }
 
 // CreateObject - creates object to file system with specified data (aka content)
 func (f *fsObjectStoreService) CreateObject(ctx context.Context, reader io.Reader) (cid.Cid, error) {
+	digest, _, err := f.CreateObjectIfAbsent(ctx, reader)
+	return digest, err
+}
+
+// CreateObjectIfAbsent - creates object to file system with specified data (aka content), and reports
+// whether object is newly written or deduplicated with an existing one
+func (f *fsObjectStoreService) CreateObjectIfAbsent(ctx context.Context, reader io.Reader) (cid.Cid, bool, error) {
+	if err := f.authorize(ctx, OpWrite, cid.Undef); err != nil {
+		return cid.Undef, false, err
+	}
+	defer f.writeDone(ctx)
+	digest, created, err := f.createObject(ctx, reader)
+	f.audit(ctx, OpWrite, digest, err)
+	return digest, created, err
+}
+
+// createObject - creates object from reader, reporting whether it is newly written
+func (f *fsObjectStoreService) createObject(ctx context.Context, reader io.Reader) (cid.Cid, bool, error) {
+	ctx, cancel := f.withDeadline(ctx)
+	defer cancel()
+
 	data, readerErr := ioutil.ReadAll(reader)
 	if readerErr != nil {
-		return cid.Undef, readerErr
+		return cid.Undef, false, readerErr
 	}
 
 	digest, err := objectstore.DigestPrefix.Sum(data)
 	if err != nil {
 		log.Printf("err: digesting object failed: %s\n", err.Error())
-		return cid.Undef, ErrDataDigestionFailed
+		return cid.Undef, false, ErrDataDigestionFailed
 	}
-	if f.debug {
+	if f.isDebug() {
 		log.Printf("debug: created object cid: %s\n", digest)
 	}
+	defer f.observe(ctx, "create", time.Now(), digest, int64(len(data)))
 
-	if f.HasObject(ctx, digest) {
-		return digest, nil
+	if err := f.checkBlocked(ctx, OpWrite, digest); err != nil {
+		return digest, false, err
+	}
+	if f.has(digest) {
+		f.stats.deduplicated()
+		f.notifyCreated(ctx, digest, int64(len(data)))
+		return digest, false, nil
 	}
 
-	objLink := f.path(objectstore.DefaultLinkFunc(digest.String()))
-	if err := write(objLink, data); err != nil {
-		return digest, err
+	err = f.scan(ctx, digest, int64(len(data)), func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(data)), nil
+	})
+	if err != nil {
+		return digest, false, err
+	}
+	objLink := f.objectPath(digest)
+	stored, err := f.encrypt(escapePlain(data))
+	if err != nil {
+		return digest, false, objectstore.ErrObjectWritingFailed
+	}
+	if err := f.fds.acquire(ctx); err != nil {
+		return digest, false, err
+	}
+	defer f.fds.release()
+	err = f.bounded(ctx, func() error { return f.writeObject(digest, objLink, stored) })
+	if err != nil {
+		return digest, false, err
+	}
+	f.negative.remove(digest.String())
+	if err := f.journaled(JournalCreate, digest, int64(len(data))); err != nil {
+		return digest, true, err
 	}
-	return digest, nil
+	f.notifyCreated(ctx, digest, int64(len(data)))
+	return digest, true, nil
 }
 
+// ListObject - lists objects of bucket. Consumers must drain returned channel or cancel context; walk
+// is blocked while channel (buffered via `WithListBuffer`) is full, unless a stall timeout is configured
+// via `WithListStallTimeout`, in which case remaining walk is spilled to disk once consumer stalls. Consistent
+// listing (see `WithConsistentListing`) is served from a point in time copy of cid index instead.
+// Walk failures are reported as `*ListError` carrying offending path, aborting listing unless listed via
+// `ListObjectWith` continuing on errors.
 func (f *fsObjectStoreService) ListObject(ctx context.Context) <-chan objectstore.ListObjectEvent {
-	dir := fmt.Sprintf("%s/%s", f.dataDir, f.bucket)
-	ch := make(chan objectstore.ListObjectEvent)
+	return f.listObject(ctx, func() {})
+}
+
+// listObject - lists objects of bucket in background, calling done once listing finishes
+func (f *fsObjectStoreService) listObject(ctx context.Context, done func()) <-chan objectstore.ListObjectEvent {
+	ch := make(chan objectstore.ListObjectEvent, f.listBuffer)
 
 	go func() {
+		defer done()
 		defer close(ch)
+		if err := f.authorize(ctx, OpList, cid.Undef); err != nil {
+			select {
+			case ch <- objectstore.ListObjectEvent{Object: "", Error: err}:
+			case <-ctx.Done():
+			}
+			return
+		}
+		f.audit(ctx, OpList, cid.Undef, nil)
 
-		err := filepath.Walk(dir,
-			func(path string, info os.FileInfo, err error) error {
-				if ctx.Err() != nil {
-					return ctx.Err()
-				}
-				if err != nil {
-					return err
-				}
-				if info.Mode().IsRegular() {
-					ch <- objectstore.ListObjectEvent{Object: info.Name(), Error: nil}
-				}
-				return nil
-			})
-		if err != nil {
-			ch <- objectstore.ListObjectEvent{Object: "", Error: err}
+		l := &lister{f: f, ctx: ctx, ch: ch, tolerant: callConfigFrom(ctx).tolerant}
+		defer func(start time.Time) { f.observe(ctx, "list", start, cid.Undef, l.count) }(time.Now())
+		if f.consistentList {
+			l.finish(f.listIndexed(ctx, l))
 			return
 		}
+		var err error
+		for _, dir := range f.stripeDirs() {
+			err = f.walkFilesWith(ctx, dir, func(path string, info os.FileInfo) error {
+				return l.emit(filepath.Base(path))
+			}, l.fail)
+			if err != nil {
+				break
+			}
+		}
+		l.finish(err)
 	}()
 	return ch
 }
@@ -0,0 +1,54 @@
+package fsstore_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	fsstore "github.com/igumus/go-objectstore-fs"
+	"github.com/igumus/go-objectstore-fs/objectstoretest"
+	"github.com/igumus/go-objectstore-lib"
+)
+
+// newStore - opens store on a fresh data directory with given options, closed once test finishes
+func newStore(t *testing.T, opts ...fsstore.FSObjectstoreConfigOption) objectstore.ObjectStore {
+	t.Helper()
+	opts = append([]fsstore.FSObjectstoreConfigOption{fsstore.WithDataDir(t.TempDir())}, opts...)
+	store, err := fsstore.NewFileSystemObjectStore(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		store.(io.Closer).Close()
+	})
+	return store
+}
+
+func TestConformance(t *testing.T) {
+	objectstoretest.Run(t, func(t *testing.T) objectstore.ObjectStore {
+		return newStore(t)
+	})
+}
+
+func TestOpenLockedBucket(t *testing.T) {
+	dir := t.TempDir()
+	store := newStore(t, fsstore.WithDataDir(dir))
+	if _, err := fsstore.NewFileSystemObjectStore(fsstore.WithDataDir(dir)); !errors.Is(err, fsstore.ErrStoreLocked) {
+		t.Fatalf("opening locked bucket: %v, want %v", err, fsstore.ErrStoreLocked)
+	}
+
+	ctx := context.Background()
+	c, err := store.CreateObject(ctx, bytes.NewReader([]byte("locked")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	readOnly := newStore(t, fsstore.WithDataDir(dir), fsstore.WithReadOnly(true))
+	if data, err := readOnly.ReadObject(ctx, c); err != nil || string(data) != "locked" {
+		t.Fatalf("reading via read only store: %q, %v", data, err)
+	}
+	if _, err := readOnly.CreateObject(ctx, bytes.NewReader([]byte("written"))); !errors.Is(err, fsstore.ErrStoreReadOnly) {
+		t.Fatalf("writing via read only store: %v, want %v", err, fsstore.ErrStoreReadOnly)
+	}
+}
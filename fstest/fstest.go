@@ -0,0 +1,150 @@
+// Package fstest populates objectstores with deterministic fixtures, and asserts store invariants against
+// their manifest, so downstream projects write integration tests against fsstore (or any objectstore) without
+// hand crafting objects. Same spec always yields same objects, hence same cids.
+package fstest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/igumus/go-objectstore-lib"
+	"github.com/ipfs/go-cid"
+)
+
+// ErrInvariantViolated is return, when store content does not match manifest of populated fixtures.
+var ErrInvariantViolated = errors.New("fstest: store invariant violated")
+
+// _defCount handles the default number of generated objects
+const _defCount = 16
+
+// _defMaxSize handles the default upper bound of generated object sizes
+const _defMaxSize = 64 << 10
+
+// Spec captures how fixtures are generated. Object sizes are drawn uniformly from [MinSize, MaxSize], both
+// sizes and content seeded by Seed.
+type Spec struct {
+	Count   int
+	MinSize int
+	MaxSize int
+	Seed    int64
+}
+
+// Fixture captures a generated object and the cid store is expected to return for it
+type Fixture struct {
+	Index int
+	Size  int
+	Cid   cid.Cid
+}
+
+// Manifest captures fixtures populated to a store, in generation order
+type Manifest struct {
+	Spec    Spec
+	Objects []Fixture
+}
+
+// normalize - returns spec with defaults filled in for unset fields
+func (s Spec) normalize() Spec {
+	if s.Count <= 0 {
+		s.Count = _defCount
+	}
+	if s.MaxSize <= 0 {
+		s.MaxSize = _defMaxSize
+	}
+	if s.MinSize < 0 || s.MinSize > s.MaxSize {
+		s.MinSize = 0
+	}
+	return s
+}
+
+// Content - returns content of i-th object of spec; content is derived only from seed and index, so fixtures
+// are regenerated on demand instead of kept in memory
+func (s Spec) Content(i int) []byte {
+	s = s.normalize()
+	r := rand.New(rand.NewSource(s.Seed*1000003 + int64(i)))
+	data := make([]byte, s.MinSize+r.Intn(s.MaxSize-s.MinSize+1))
+	r.Read(data)
+	// index prefix keeps objects distinct, even when sizes are too small for random content to differ
+	copy(data, fmt.Sprintf("%d:", i))
+	return data
+}
+
+// Generate - returns manifest of objects spec yields, without touching any store
+func Generate(spec Spec) (*Manifest, error) {
+	spec = spec.normalize()
+	manifest := &Manifest{Spec: spec, Objects: make([]Fixture, 0, spec.Count)}
+	for i := 0; i < spec.Count; i++ {
+		data := spec.Content(i)
+		c, err := objectstore.DigestPrefix.Sum(data)
+		if err != nil {
+			return nil, err
+		}
+		manifest.Objects = append(manifest.Objects, Fixture{Index: i, Size: len(data), Cid: c})
+	}
+	return manifest, nil
+}
+
+// Populate - creates objects of spec in store, returning their manifest. Error returns when store fails to
+// create an object, or returns another cid than expected.
+func Populate(ctx context.Context, store objectstore.ObjectStore, spec Spec) (*Manifest, error) {
+	manifest, err := Generate(spec)
+	if err != nil {
+		return nil, err
+	}
+	for _, fixture := range manifest.Objects {
+		c, err := store.CreateObject(ctx, bytes.NewReader(manifest.Spec.Content(fixture.Index)))
+		if err != nil {
+			return nil, err
+		}
+		if !c.Equals(fixture.Cid) {
+			return nil, fmt.Errorf("%w: object %d created as %s, expected %s", ErrInvariantViolated, fixture.Index, c, fixture.Cid)
+		}
+	}
+	return manifest, nil
+}
+
+// Check - asserts store holds every object of manifest with its exact content, and lists each of them exactly
+// once. Store may hold objects beyond manifest.
+func Check(ctx context.Context, store objectstore.ObjectStore, manifest *Manifest) error {
+	for _, fixture := range manifest.Objects {
+		if !store.HasObject(ctx, fixture.Cid) {
+			return fmt.Errorf("%w: object %d missing: %s", ErrInvariantViolated, fixture.Index, fixture.Cid)
+		}
+		data, err := store.ReadObject(ctx, fixture.Cid)
+		if err != nil {
+			return fmt.Errorf("%w: object %d unreadable: %s, %v", ErrInvariantViolated, fixture.Index, fixture.Cid, err)
+		}
+		if !bytes.Equal(data, manifest.Spec.Content(fixture.Index)) {
+			return fmt.Errorf("%w: object %d content differs: %s", ErrInvariantViolated, fixture.Index, fixture.Cid)
+		}
+	}
+
+	listed := map[string]int{}
+	for event := range store.ListObject(ctx) {
+		if event.Error != nil {
+			return fmt.Errorf("%w: listing failed: %v", ErrInvariantViolated, event.Error)
+		}
+		listed[event.Object]++
+	}
+	for _, fixture := range manifest.Objects {
+		switch listed[fixture.Cid.String()] {
+		case 1:
+		case 0:
+			return fmt.Errorf("%w: object %d not listed: %s", ErrInvariantViolated, fixture.Index, fixture.Cid)
+		default:
+			return fmt.Errorf("%w: object %d listed more than once: %s", ErrInvariantViolated, fixture.Index, fixture.Cid)
+		}
+	}
+	return nil
+}
+
+// Assert - fails test, when store does not satisfy invariants of `Check` against manifest
+func Assert(t testing.TB, store objectstore.ObjectStore, manifest *Manifest) {
+	t.Helper()
+	if err := Check(context.Background(), store, manifest); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,50 @@
+// Package fusefs exposes an objectstore as a read-only FUSE file system. Objects appear as files named
+// after their cid, and ipld encoded objects (e.g. manifests) appear as directories mirroring their
+// data model, whose links resolve to the linked objects.
+package fusefs
+
+import "errors"
+
+// ErrUnsupportedPlatform is return, when mounting is requested on a platform without FUSE support.
+var ErrUnsupportedPlatform = errors.New("fusefs: platform not supported")
+
+// Captures/Represents mount configuration information
+type mountConfig struct {
+	debug      bool
+	allowOther bool
+}
+
+// A MountOption sets options such as debug mode and access of other users.
+type MountOption func(*mountConfig)
+
+// WithDebugMode returns a MountOption that specifies debug mode, logging FUSE requests.
+// If not set, the default is `false`
+func WithDebugMode(dm bool) MountOption {
+	return func(mc *mountConfig) {
+		mc.debug = dm
+	}
+}
+
+// WithAllowOther returns a MountOption that specifies whether users other than mounting one can access mount.
+// If not set, the default is `false`
+func WithAllowOther(ao bool) MountOption {
+	return func(mc *mountConfig) {
+		mc.allowOther = ao
+	}
+}
+
+// MountPoint captures a mounted objectstore
+type MountPoint struct {
+	unmount func() error
+	wait    func()
+}
+
+// Unmount - unmounts objectstore file system
+func (m *MountPoint) Unmount() error {
+	return m.unmount()
+}
+
+// Wait - blocks until objectstore file system is unmounted
+func (m *MountPoint) Wait() {
+	m.wait()
+}
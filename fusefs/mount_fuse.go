@@ -0,0 +1,346 @@
+//go:build linux || darwin
+
+package fusefs
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"syscall"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+	fsstore "github.com/igumus/go-objectstore-fs"
+	"github.com/igumus/go-objectstore-lib"
+	"github.com/ipfs/go-cid"
+	"github.com/ipld/go-ipld-prime"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+)
+
+// Mount - mounts objectstore as read-only file system on dir. Returned mount must be unmounted by caller.
+func Mount(dir string, store objectstore.ObjectStore, opts ...MountOption) (*MountPoint, error) {
+	cfg := &mountConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	root := &rootNode{store: store}
+	server, err := fs.Mount(dir, root, &fs.Options{
+		MountOptions: fuse.MountOptions{
+			AllowOther: cfg.allowOther,
+			Debug:      cfg.debug,
+			FsName:     "fsstore",
+			Name:       "fsstore",
+			Options:    []string{"ro"},
+		},
+	})
+	if err != nil {
+		log.Printf("err: mounting objectstore failed: %s, %v\n", dir, err)
+		return nil, err
+	}
+	return &MountPoint{unmount: server.Unmount, wait: server.Wait}, nil
+}
+
+// rootNode lists every object of objectstore
+type rootNode struct {
+	fs.Inode
+	store objectstore.ObjectStore
+}
+
+var _ fs.NodeReaddirer = (*rootNode)(nil)
+var _ fs.NodeLookuper = (*rootNode)(nil)
+
+// Readdir - lists objects of objectstore
+func (r *rootNode) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	entries := []fuse.DirEntry{}
+	for event := range r.store.ListObject(ctx) {
+		if event.Error != nil {
+			log.Printf("err: listing objects failed: %v\n", event.Error)
+			return nil, syscall.EIO
+		}
+		c, err := cid.Decode(event.Object)
+		if err != nil {
+			continue
+		}
+		entries = append(entries, fuse.DirEntry{Name: event.Object, Mode: objectMode(r.store, c)})
+	}
+	return fs.NewListDirStream(entries), 0
+}
+
+// Lookup - resolves object with cid as name
+func (r *rootNode) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	c, err := cid.Decode(name)
+	if err != nil {
+		return nil, syscall.ENOENT
+	}
+	if !r.store.HasObject(ctx, c) {
+		return nil, syscall.ENOENT
+	}
+	return newObjectInode(ctx, &r.Inode, r.store, c, out)
+}
+
+// objectMode - returns file mode of object, ipld encoded objects are directories when objectstore can decode them
+func objectMode(store objectstore.ObjectStore, c cid.Cid) uint32 {
+	if _, ok := store.(fsstore.NodeStore); ok && c.Prefix().Codec != cid.Raw {
+		return fuse.S_IFDIR
+	}
+	return fuse.S_IFREG
+}
+
+// newObjectInode - creates child inode of parent representing object
+func newObjectInode(ctx context.Context, parent *fs.Inode, store objectstore.ObjectStore, c cid.Cid, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	if objectMode(store, c) == fuse.S_IFDIR {
+		node, err := store.(fsstore.NodeStore).ReadNode(ctx, c)
+		if err == nil {
+			out.Mode = fuse.S_IFDIR | 0555
+			return parent.NewInode(ctx, &ipldNode{store: store, node: node}, fs.StableAttr{Mode: fuse.S_IFDIR}), 0
+		}
+	}
+	file := objectFile(store, c)
+	if errno := file.fill(ctx, &out.Attr); errno != 0 {
+		return nil, errno
+	}
+	return parent.NewInode(ctx, file, fs.StableAttr{Mode: fuse.S_IFREG}), 0
+}
+
+// ipldNode exposes map/list of ipld data model as directory
+type ipldNode struct {
+	fs.Inode
+	store objectstore.ObjectStore
+	node  ipld.Node
+}
+
+var _ fs.NodeReaddirer = (*ipldNode)(nil)
+var _ fs.NodeLookuper = (*ipldNode)(nil)
+
+// Readdir - lists map keys or list indexes of node
+func (n *ipldNode) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	entries := []fuse.DirEntry{}
+	err := n.each(func(name string, value ipld.Node) error {
+		entries = append(entries, fuse.DirEntry{Name: name, Mode: n.mode(value)})
+		return nil
+	})
+	if err != nil {
+		return nil, syscall.EIO
+	}
+	return fs.NewListDirStream(entries), 0
+}
+
+// Lookup - resolves map key or list index of node
+func (n *ipldNode) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	var child ipld.Node
+	var err error
+	switch n.node.Kind() {
+	case ipld.Kind_Map:
+		child, err = n.node.LookupByString(name)
+	case ipld.Kind_List:
+		idx, convErr := strconv.ParseInt(name, 10, 64)
+		if convErr != nil {
+			return nil, syscall.ENOENT
+		}
+		child, err = n.node.LookupByIndex(idx)
+	default:
+		return nil, syscall.ENOENT
+	}
+	if err != nil {
+		return nil, syscall.ENOENT
+	}
+
+	switch child.Kind() {
+	case ipld.Kind_Link:
+		link, err := child.AsLink()
+		if err != nil {
+			return nil, syscall.EIO
+		}
+		cl, ok := link.(cidlink.Link)
+		if !ok || !n.store.HasObject(ctx, cl.Cid) {
+			return nil, syscall.ENOENT
+		}
+		return newObjectInode(ctx, &n.Inode, n.store, cl.Cid, out)
+	case ipld.Kind_Map, ipld.Kind_List:
+		out.Mode = fuse.S_IFDIR | 0555
+		return n.NewInode(ctx, &ipldNode{store: n.store, node: child}, fs.StableAttr{Mode: fuse.S_IFDIR}), 0
+	default:
+		data, err := scalarBytes(child)
+		if err != nil {
+			return nil, syscall.EIO
+		}
+		file := bytesFile(data)
+		if errno := file.fill(ctx, &out.Attr); errno != 0 {
+			return nil, errno
+		}
+		return n.NewInode(ctx, file, fs.StableAttr{Mode: fuse.S_IFREG}), 0
+	}
+}
+
+// each - iterates entries of map/list node with their directory entry names
+func (n *ipldNode) each(fn func(string, ipld.Node) error) error {
+	switch n.node.Kind() {
+	case ipld.Kind_Map:
+		it := n.node.MapIterator()
+		for !it.Done() {
+			key, value, err := it.Next()
+			if err != nil {
+				return err
+			}
+			name, err := key.AsString()
+			if err != nil {
+				return err
+			}
+			if err := fn(name, value); err != nil {
+				return err
+			}
+		}
+	case ipld.Kind_List:
+		it := n.node.ListIterator()
+		for !it.Done() {
+			idx, value, err := it.Next()
+			if err != nil {
+				return err
+			}
+			if err := fn(strconv.FormatInt(idx, 10), value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// mode - returns file mode of child node
+func (n *ipldNode) mode(value ipld.Node) uint32 {
+	switch value.Kind() {
+	case ipld.Kind_Map, ipld.Kind_List:
+		return fuse.S_IFDIR
+	case ipld.Kind_Link:
+		link, err := value.AsLink()
+		if err != nil {
+			return fuse.S_IFREG
+		}
+		if cl, ok := link.(cidlink.Link); ok {
+			return objectMode(n.store, cl.Cid)
+		}
+	}
+	return fuse.S_IFREG
+}
+
+// scalarBytes - returns file content of scalar ipld node
+func scalarBytes(node ipld.Node) ([]byte, error) {
+	switch node.Kind() {
+	case ipld.Kind_Bytes:
+		return node.AsBytes()
+	case ipld.Kind_String:
+		s, err := node.AsString()
+		return []byte(s), err
+	case ipld.Kind_Int:
+		i, err := node.AsInt()
+		return []byte(strconv.FormatInt(i, 10)), err
+	case ipld.Kind_Float:
+		f, err := node.AsFloat()
+		return []byte(strconv.FormatFloat(f, 'g', -1, 64)), err
+	case ipld.Kind_Bool:
+		b, err := node.AsBool()
+		return []byte(strconv.FormatBool(b)), err
+	default:
+		return nil, nil
+	}
+}
+
+// fileNode exposes content as read-only file. Content is sized and read on demand, range by range, so large
+// objects are never held in memory; failures are not remembered, so later requests retry them.
+type fileNode struct {
+	fs.Inode
+	size func(context.Context) (int64, error)
+	read func(ctx context.Context, off, length int64) ([]byte, error)
+}
+
+var _ fs.NodeGetattrer = (*fileNode)(nil)
+var _ fs.NodeOpener = (*fileNode)(nil)
+var _ fs.NodeReader = (*fileNode)(nil)
+
+// objectFile - creates file of object, sized via `fsstore.StatStore` and read via `fsstore.RangeReader` when
+// objectstore supports them, otherwise by reading object as a whole
+func objectFile(store objectstore.ObjectStore, c cid.Cid) *fileNode {
+	return &fileNode{
+		size: func(ctx context.Context) (int64, error) {
+			if stater, ok := store.(fsstore.StatStore); ok {
+				stat, err := stater.StatObject(ctx, c)
+				return stat.Size, err
+			}
+			data, err := store.ReadObject(ctx, c)
+			return int64(len(data)), err
+		},
+		read: func(ctx context.Context, off, length int64) ([]byte, error) {
+			if ranger, ok := store.(fsstore.RangeReader); ok {
+				data, err := ranger.ReadRange(ctx, c, off, length)
+				if errors.Is(err, fsstore.ErrInvalidRange) {
+					return nil, nil
+				}
+				return data, err
+			}
+			data, err := store.ReadObject(ctx, c)
+			if err != nil {
+				return nil, err
+			}
+			return cut(data, off, length), nil
+		},
+	}
+}
+
+// bytesFile - creates file of in memory content
+func bytesFile(data []byte) *fileNode {
+	return &fileNode{
+		size: func(context.Context) (int64, error) {
+			return int64(len(data)), nil
+		},
+		read: func(_ context.Context, off, length int64) ([]byte, error) {
+			return cut(data, off, length), nil
+		},
+	}
+}
+
+// cut - returns up to length bytes of data from offset, nothing past its end
+func cut(data []byte, off, length int64) []byte {
+	if off >= int64(len(data)) {
+		return nil
+	}
+	end := off + length
+	if end > int64(len(data)) {
+		end = int64(len(data))
+	}
+	return data[off:end]
+}
+
+// fill - fills attributes of file
+func (f *fileNode) fill(ctx context.Context, attr *fuse.Attr) syscall.Errno {
+	size, err := f.size(ctx)
+	if err != nil {
+		log.Printf("err: sizing file content failed: %v\n", err)
+		return syscall.EIO
+	}
+	attr.Mode = fuse.S_IFREG | 0444
+	attr.Size = uint64(size)
+	return 0
+}
+
+// Getattr - returns attributes of file
+func (f *fileNode) Getattr(ctx context.Context, fh fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	return f.fill(ctx, &out.Attr)
+}
+
+// Open - opens file for reading only
+func (f *fileNode) Open(ctx context.Context, flags uint32) (fs.FileHandle, uint32, syscall.Errno) {
+	if flags&(syscall.O_WRONLY|syscall.O_RDWR) != 0 {
+		return nil, 0, syscall.EROFS
+	}
+	return nil, fuse.FOPEN_KEEP_CACHE, 0
+}
+
+// Read - reads file content at offset
+func (f *fileNode) Read(ctx context.Context, fh fs.FileHandle, dest []byte, off int64) (fuse.ReadResult, syscall.Errno) {
+	data, err := f.read(ctx, off, int64(len(dest)))
+	if err != nil {
+		log.Printf("err: reading file content failed: %d, %v\n", off, err)
+		return nil, syscall.EIO
+	}
+	return fuse.ReadResultData(data), 0
+}
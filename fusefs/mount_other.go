@@ -0,0 +1,10 @@
+//go:build !linux && !darwin
+
+package fusefs
+
+import "github.com/igumus/go-objectstore-lib"
+
+// Mount - returns ErrUnsupportedPlatform, since FUSE is not available on this platform
+func Mount(dir string, store objectstore.ObjectStore, opts ...MountOption) (*MountPoint, error) {
+	return nil, ErrUnsupportedPlatform
+}
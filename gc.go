@@ -0,0 +1,251 @@
+package fsstore
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"time"
+
+	"github.com/ipfs/go-cid"
+)
+
+// ErrNoRetainedSnapshot is return, when retention policy retains no bucket snapshot, so garbage collection would delete every object.
+var ErrNoRetainedSnapshot = errors.New("fsobjectstore: retention policy retains no snapshot")
+
+// ErrGarbageCollectionFailed is return, when garbage collection of bucket failed.
+var ErrGarbageCollectionFailed = errors.New("fsobjectstore: garbage collection failed")
+
+// RetentionPolicy captures snapshot based retention of garbage collection. A snapshot is retained when
+// it is one of last `KeepLast` snapshots, or it is younger than `KeepWithin`.
+type RetentionPolicy struct {
+	KeepLast   int
+	KeepWithin time.Duration
+}
+
+// GCReport captures outcome of garbage collection
+type GCReport struct {
+	RetainedSnapshots  int
+	ExpiredSnapshots   int
+	Kept               int
+	Deleted            int
+	DeletedBytes       int64
+	PurgedTrash        int
+	PurgedTrashBytes   int64
+	ExpiredUploads     int
+	PoolReclaimed      int
+	PoolReclaimedBytes int64
+	PrunedDirs         int
+	Sample             []cid.Cid
+	DryRun             bool
+}
+
+// GarbageCollector defines the functions clients need to reclaim objects no longer retained.
+type GarbageCollector interface {
+	CollectGarbage(context.Context, RetentionPolicy) (*GCReport, error)
+}
+
+var _ GarbageCollector = (*fsObjectStoreService)(nil)
+
+// gcCandidate captures an object visited during garbage collection
+type gcCandidate struct {
+	cid  cid.Cid
+	path string
+	size int64
+}
+
+// CollectGarbage - keeps every object reachable from snapshots retained by policy, and deletes everything else.
+// Objects modified after newest retained snapshot are not covered by any snapshot yet, so they (and objects
+// reachable from them) are kept too. Deleted objects whose trash retention passed are purged and pending uploads
+// left idle (see `WithUploadTTL`) are expired beforehand, and pooled objects no bucket links anymore (see
+// `WithSharedPool`) are reclaimed and empty shard directories pruned (see `PruneEmptyDirs`) afterwards.
+func (f *fsObjectStoreService) CollectGarbage(ctx context.Context, policy RetentionPolicy) (*GCReport, error) {
+	if err := f.authorize(ctx, OpAdmin, cid.Undef); err != nil {
+		return nil, err
+	}
+	report, err := f.collectGarbage(withSystem(ctx), policy)
+	if report != nil && !report.DryRun && (err == nil || errors.Is(err, ErrNoRetainedSnapshot)) {
+		// empty shard directories are left behind by objects deleted and purged
+		if pruned, pruneErr := f.pruneEmptyDirs(withSystem(ctx)); pruneErr == nil {
+			report.PrunedDirs = pruned
+		} else {
+			log.Printf("warn: pruning empty directories after gc failed: %s, %v\n", f.bucket, pruneErr)
+		}
+	}
+	f.metrics.gc(report, f.now())
+	f.audit(ctx, OpAdmin, cid.Undef, err)
+	return report, err
+}
+
+// collectGarbage - expires snapshots by policy, and deletes objects unreachable from retained ones; on dry run
+// (see `WithDryRun`) objects and snapshots are only reported
+func (f *fsObjectStoreService) collectGarbage(ctx context.Context, policy RetentionPolicy) (*GCReport, error) {
+	f.snapMu.Lock()
+	defer f.snapMu.Unlock()
+
+	purged, err := f.purgeTrash(ctx, f.now().Add(-f.trashRetention))
+	if err != nil {
+		return nil, err
+	}
+	dryRun := purged.DryRun
+	expiredUploads, err := f.expireUploads(ctx, dryRun)
+	if err != nil {
+		return nil, err
+	}
+	snaps, err := f.readSnapshots()
+	if err != nil {
+		return nil, err
+	}
+	retained, expired := policy.split(snaps, f.now())
+	if len(retained) == 0 {
+		report := &GCReport{PurgedTrash: purged.Purged, PurgedTrashBytes: purged.PurgedBytes, ExpiredUploads: expiredUploads,
+			DryRun: dryRun}
+		// pooled objects whose last link was purged from trash are reclaimed still
+		if report.PoolReclaimed, report.PoolReclaimedBytes, err = f.sweepPool(ctx, dryRun); err != nil {
+			return report, err
+		}
+		return report, ErrNoRetainedSnapshot
+	}
+	newest := retained[len(retained)-1].Created
+
+	roots := make([]cid.Cid, 0, len(retained))
+	for _, snap := range retained {
+		roots = append(roots, snap.Cid)
+	}
+	t := NewProgressTracker(ctx, "gc")
+	defer t.Finish()
+	candidates := []gcCandidate{}
+	err = f.walkObjects(ctx, func(c cid.Cid, path string, info os.FileInfo) error {
+		if f.placedAt(c.String(), info).After(newest) {
+			roots = append(roots, c)
+			return nil
+		}
+		candidates = append(candidates, gcCandidate{cid: c, path: path, size: info.Size()})
+		return nil
+	})
+	if err != nil {
+		if ctxErr := checkContextError(ctx, f.isDebug()); ctxErr != nil {
+			return nil, ctxErr
+		}
+		log.Printf("err: walking bucket for gc failed: %s, %v\n", f.bucket, err)
+		return nil, ErrGarbageCollectionFailed
+	}
+
+	reachable, err := f.reachable(ctx, roots)
+	if err != nil {
+		return nil, err
+	}
+
+	t.Expect(int64(len(candidates)))
+	report := &GCReport{RetainedSnapshots: len(retained), ExpiredSnapshots: len(expired), PurgedTrash: purged.Purged,
+		PurgedTrashBytes: purged.PurgedBytes, ExpiredUploads: expiredUploads, DryRun: dryRun}
+	for _, candidate := range candidates {
+		t.Add(1, candidate.size)
+		if _, ok := reachable[candidate.cid.String()]; ok || f.pinned(candidate.cid) {
+			report.Kept++
+			continue
+		}
+		if ctxErr := checkContextError(ctx, f.isDebug()); ctxErr != nil {
+			return report, ctxErr
+		}
+		if !dryRun {
+			if err := f.collect(ctx, candidate); err != nil {
+				return report, err
+			}
+		}
+		report.Sample = sample(report.Sample, candidate.cid)
+		report.Deleted++
+		report.DeletedBytes += candidate.size
+	}
+	report.Kept += len(roots) - len(retained)
+
+	if len(expired) > 0 && !dryRun {
+		if err := f.writeSnapshots(retained); err != nil {
+			return report, err
+		}
+	}
+	if report.PoolReclaimed, report.PoolReclaimedBytes, err = f.sweepPool(ctx, dryRun); err != nil {
+		return report, err
+	}
+	if !dryRun {
+		if err := f.compactPlacements(); err != nil {
+			log.Printf("warn: compacting placements after gc failed: %s, %v\n", f.bucket, err)
+		}
+	}
+	if f.isDebug() {
+		log.Printf("debug: collected garbage: %s, %+v\n", f.bucket, *report)
+	}
+	return report, nil
+}
+
+// split - partitions snapshots (oldest first) into retained and expired ones
+func (p RetentionPolicy) split(snaps []Snapshot, now time.Time) ([]Snapshot, []Snapshot) {
+	retained, expired := []Snapshot{}, []Snapshot{}
+	for i, snap := range snaps {
+		last := p.KeepLast > 0 && i >= len(snaps)-p.KeepLast
+		young := p.KeepWithin > 0 && now.Sub(snap.Created) < p.KeepWithin
+		if last || young {
+			retained = append(retained, snap)
+		} else {
+			expired = append(expired, snap)
+		}
+	}
+	return retained, expired
+}
+
+// reachable - returns set of cids reachable from given roots via recorded references
+func (f *fsObjectStoreService) reachable(ctx context.Context, roots []cid.Cid) (map[string]struct{}, error) {
+	f.refMu.Lock()
+	defer f.refMu.Unlock()
+
+	seen := make(map[string]struct{}, len(roots))
+	queue := append([]cid.Cid{}, roots...)
+	for len(queue) > 0 {
+		if ctxErr := checkContextError(ctx, f.isDebug()); ctxErr != nil {
+			return nil, ctxErr
+		}
+		current := queue[0]
+		queue = queue[1:]
+		if _, ok := seen[current.String()]; ok {
+			continue
+		}
+		seen[current.String()] = struct{}{}
+		children, err := f.readRefs(current)
+		if err != nil {
+			return nil, err
+		}
+		queue = append(queue, children...)
+	}
+	return seen, nil
+}
+
+// collect - deletes object file of garbage collection candidate, and drops references it records
+func (f *fsObjectStoreService) collect(ctx context.Context, candidate gcCandidate) error {
+	// object is removed before its references, so an interrupted collection only leaves
+	// over-counted children behind.
+	size := f.objectSize(candidate.path, candidate.size)
+	if err := os.Remove(candidate.path); err != nil && !os.IsNotExist(err) {
+		log.Printf("err: deleting object failed: %s, %v\n", candidate.path, err)
+		return ErrGarbageCollectionFailed
+	}
+	os.Remove(f.metaPath(candidate.cid))
+	if err := f.journaled(JournalGC, candidate.cid, size); err != nil {
+		return err
+	}
+	if f.isDebug() {
+		log.Printf("debug: deleted object: %s\n", candidate.cid)
+	}
+	return f.removeRefs(ctx, candidate.cid)
+}
+
+// writeSnapshots - replaces recorded snapshots with given ones, caller must hold snapMu
+func (f *fsObjectStoreService) writeSnapshots(snaps []Snapshot) error {
+	data := []byte{}
+	for _, snap := range snaps {
+		data = append(data, snapshotRecord(snap)...)
+	}
+	if err := write(f.tempDir, f.internalPath(_snapshotsFile), data); err != nil {
+		return ErrSnapshotFailed
+	}
+	return nil
+}
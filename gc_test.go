@@ -0,0 +1,181 @@
+package fsstore_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	fsstore "github.com/igumus/go-objectstore-fs"
+	"github.com/igumus/go-objectstore-lib"
+	"github.com/ipfs/go-cid"
+)
+
+// create - creates object of data in store, failing test otherwise
+func create(t *testing.T, store objectstore.ObjectStore, data string) cid.Cid {
+	t.Helper()
+	c, err := store.CreateObject(context.Background(), bytes.NewReader([]byte(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// snapshot - snapshots bucket of store, failing test otherwise
+func snapshot(t *testing.T, store objectstore.ObjectStore) {
+	t.Helper()
+	if _, err := store.(fsstore.BucketSnapshotter).SnapshotBucket(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// collect - collects garbage of store keeping newest snapshot, failing test otherwise
+func collect(t *testing.T, store objectstore.ObjectStore) *fsstore.GCReport {
+	t.Helper()
+	report, err := store.(fsstore.GarbageCollector).CollectGarbage(context.Background(), fsstore.RetentionPolicy{KeepLast: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return report
+}
+
+func TestCollectGarbageKeepsRestoredObject(t *testing.T) {
+	ctx := context.Background()
+	store := newStore(t)
+	deleter := store.(fsstore.Deleter)
+
+	// under wall clock, files keep times file system stamped unless store stamps them
+	c := create(t, store, "restored")
+	if err := deleter.DeleteObject(ctx, c); err != nil {
+		t.Fatal(err)
+	}
+	tick()
+	snapshot(t, store)
+	if err := deleter.RestoreObject(ctx, c); err != nil {
+		t.Fatal(err)
+	}
+
+	if report := collect(t, store); report.Deleted != 0 {
+		t.Fatalf("restored object collected: %+v", report)
+	}
+	if !store.HasObject(ctx, c) {
+		t.Fatal("restored object missing after gc")
+	}
+}
+
+func TestCollectGarbageKeepsAdoptedObject(t *testing.T) {
+	ctx := context.Background()
+	clock := fsstore.NewManualClock(time.Now())
+	store := newStore(t, fsstore.WithClock(clock))
+	create(t, store, "root")
+	snapshot(t, store)
+	clock.Advance(time.Hour)
+
+	dir := filepath.Join(t.TempDir(), "adopted")
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, []byte("adopted"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	written := time.Now().Add(-48 * time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(file, written, written); err != nil {
+		t.Fatal(err)
+	}
+	result, err := store.(fsstore.Adopter).AdoptDirectory(ctx, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Linked != 1 {
+		t.Skipf("file not hard linked into bucket: %+v", result)
+	}
+
+	info, err := os.Stat(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(written) {
+		t.Fatalf("adopted file modification time changed: %s, want %s", info.ModTime(), written)
+	}
+	if report := collect(t, store); report.Deleted != 0 {
+		t.Fatalf("adopted object collected: %+v", report)
+	}
+	data, err := store.ReadObject(ctx, adoptedCid(t, result.Mapping))
+	if err != nil || string(data) != "adopted" {
+		t.Fatalf("adopted object unreadable after gc: %q, %v", data, err)
+	}
+}
+
+func TestSweepPoolKeepsLinkedObjects(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	open := func(bucket string) objectstore.ObjectStore {
+		store, err := fsstore.NewFileSystemObjectStore(fsstore.WithDataDir(dir), fsstore.WithBucket(bucket),
+			fsstore.WithSharedPool(true))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() {
+			store.(io.Closer).Close()
+		})
+		return store
+	}
+	first, second := open("first"), open("second")
+
+	c := create(t, first, "pooled")
+	create(t, second, "root")
+	tick()
+	snapshot(t, second)
+	// second bucket links copy pooled by first one, and must not inherit its age
+	create(t, second, "pooled")
+	if report := collect(t, second); report.Deleted != 0 || report.PoolReclaimed != 0 {
+		t.Fatalf("pooled object collected: %+v", report)
+	}
+
+	for _, store := range []objectstore.ObjectStore{first, second} {
+		deleter := store.(fsstore.Deleter)
+		if err := deleter.DeleteObject(ctx, c); err != nil {
+			t.Fatal(err)
+		}
+		if report := collect(t, second); report.PoolReclaimed != 0 {
+			t.Fatalf("pooled object reclaimed while linked: %+v", report)
+		}
+		if _, err := deleter.EmptyTrash(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if report := collect(t, second); report.PoolReclaimed != 1 {
+		t.Fatalf("unlinked pooled object not reclaimed: %+v", report)
+	}
+}
+
+// tick - waits for next second of wall clock, so files written before are older than snapshots taken afterwards,
+// which are recorded in seconds
+func tick() {
+	time.Sleep(time.Until(time.Now().Truncate(time.Second).Add(time.Second + 10*time.Millisecond)))
+}
+
+// adoptedCid - decodes cid of single file listed in mapping file of adopted directory
+func adoptedCid(t *testing.T, mapping string) cid.Cid {
+	t.Helper()
+	data, err := os.ReadFile(mapping)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entry struct {
+		Cid string `json:"cid"`
+	}
+	if err := json.Unmarshal(bytes.TrimSpace(data), &entry); err != nil {
+		t.Fatal(err)
+	}
+	c, err := cid.Decode(entry.Cid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
@@ -0,0 +1,78 @@
+package gitimport
+
+// applyDelta - reconstructs object from content of base object and git delta: sizes of base and target, followed
+// by instructions copying ranges of base, or inserting data of delta
+func applyDelta(base, delta []byte) ([]byte, error) {
+	pos := 0
+	varint := func() (int, bool) {
+		value, shift := 0, uint(0)
+		for pos < len(delta) && shift < 64 {
+			b := delta[pos]
+			pos++
+			value |= int(b&0x7f) << shift
+			if b&0x80 == 0 {
+				return value, true
+			}
+			shift += 7
+		}
+		return 0, false
+	}
+	baseSize, ok := varint()
+	if !ok || baseSize != len(base) {
+		return nil, ErrCorruptObject
+	}
+	targetSize, ok := varint()
+	if !ok || targetSize < 0 {
+		return nil, ErrCorruptObject
+	}
+
+	hint := targetSize
+	if hint > _maxSizeHint {
+		hint = _maxSizeHint
+	}
+	target := make([]byte, 0, hint)
+	for pos < len(delta) {
+		cmd := delta[pos]
+		pos++
+		switch {
+		case cmd&0x80 != 0:
+			// copy: bits 0-3 flag bytes of base offset, bits 4-6 flag bytes of size
+			offset, size := 0, 0
+			for bit := uint(0); bit < 7; bit++ {
+				if cmd&(1<<bit) == 0 {
+					continue
+				}
+				if pos >= len(delta) {
+					return nil, ErrCorruptObject
+				}
+				if bit < 4 {
+					offset |= int(delta[pos]) << (8 * bit)
+				} else {
+					size |= int(delta[pos]) << (8 * (bit - 4))
+				}
+				pos++
+			}
+			if size == 0 {
+				size = 0x10000
+			}
+			if offset+size > len(base) || len(target)+size > targetSize {
+				return nil, ErrCorruptObject
+			}
+			target = append(target, base[offset:offset+size]...)
+		case cmd != 0:
+			// insert: cmd bytes of delta
+			size := int(cmd)
+			if pos+size > len(delta) || len(target)+size > targetSize {
+				return nil, ErrCorruptObject
+			}
+			target = append(target, delta[pos:pos+size]...)
+			pos += size
+		default:
+			return nil, ErrCorruptObject
+		}
+	}
+	if len(target) != targetSize {
+		return nil, ErrCorruptObject
+	}
+	return target, nil
+}
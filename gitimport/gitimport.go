@@ -0,0 +1,219 @@
+// Package gitimport imports object databases of git repositories into objectstores, so git data can be served
+// from them. Every git object (blob, tree, commit or tag) is stored as git hashes it, header included
+// (`<type> <size>\x00<content>`), under a cid of `git-raw` codec and SHA-1 multihash, so cids map one to one onto
+// git object ids, see `Cid` and `ObjectID`. Stores need to support putting objects of known cid
+// (see `fsstore.Putter`), which verifies every object against its git object id.
+package gitimport
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	fsstore "github.com/igumus/go-objectstore-fs"
+	"github.com/igumus/go-objectstore-lib"
+	"github.com/ipfs/go-cid"
+	mh "github.com/multiformats/go-multihash"
+)
+
+// ErrNotRepository is return, when imported path is neither a git repository nor its git directory.
+var ErrNotRepository = errors.New("gitimport: not a git repository")
+
+// ErrPutUnsupported is return, when store does not support putting objects of known cid.
+var ErrPutUnsupported = errors.New("gitimport: store does not support putting objects by cid")
+
+// ErrInvalidObjectID is return, when git object id is not 40 hex digits, or cid is not of a git object.
+var ErrInvalidObjectID = errors.New("gitimport: invalid git object id")
+
+// ErrCorruptObject is return, when git object (or delta of packfile) can not be decoded.
+var ErrCorruptObject = errors.New("gitimport: corrupt git object")
+
+// ErrCorruptPack is return, when packfile can not be decoded, so rest of its objects can not be imported.
+var ErrCorruptPack = errors.New("gitimport: corrupt packfile")
+
+// ErrMissingBase is return, when base object of a delta is neither in packfile nor in store.
+var ErrMissingBase = errors.New("gitimport: delta base object missing")
+
+// ErrImportIncomplete is return, when some objects could not be imported; report lists failed objects.
+var ErrImportIncomplete = errors.New("gitimport: some objects failed to import")
+
+// Captures/Represents importer configuration information
+type importerConfig struct {
+	debug bool
+}
+
+// An ImporterOption sets options such as debug mode.
+type ImporterOption func(*importerConfig)
+
+// WithDebugMode returns an ImporterOption that specifies debug mode.
+// If not set, the default is `false`
+func WithDebugMode(dm bool) ImporterOption {
+	return func(ic *importerConfig) {
+		ic.debug = dm
+	}
+}
+
+// ImportReport captures outcome of importing git repository: refs (`HEAD`, `refs/heads/main`, ...) mapped to
+// cids of objects they point at, count of imported objects by git type, and objects (by git object id, or
+// packfiles by path) failed to import
+type ImportReport struct {
+	Refs     map[string]cid.Cid
+	Imported int
+	Types    map[string]int
+	Failed   map[string]error
+}
+
+// imported - accounts imported object of git type
+func (r *ImportReport) imported(kind string) {
+	r.Imported++
+	r.Types[kind]++
+}
+
+// Importer imports git object databases into an objectstore
+type Importer struct {
+	store objectstore.ObjectStore
+	cfg   *importerConfig
+}
+
+// NewImporter creates Importer instance importing into given store via configuration options.
+func NewImporter(store objectstore.ObjectStore, opts ...ImporterOption) *Importer {
+	cfg := &importerConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return &Importer{store: store, cfg: cfg}
+}
+
+// ImportRepository - imports every loose and packed object of git repository at path (a work tree, or a bare
+// repository / git directory) into store, and resolves its refs. Objects already in store are skipped, so
+// repositories are imported incrementally, and thin packs resolve deltas against objects imported before.
+// Progress is reported to observer of ctx (see `fsstore.ContextWithProgress`) as operation `git-import`.
+func (i *Importer) ImportRepository(ctx context.Context, path string) (*ImportReport, error) {
+	putter, ok := i.store.(fsstore.Putter)
+	if !ok {
+		return nil, ErrPutUnsupported
+	}
+	gitDir, err := gitDirOf(path)
+	if err != nil {
+		return nil, err
+	}
+
+	t := fsstore.NewProgressTracker(ctx, "git-import")
+	defer t.Finish()
+	report := &ImportReport{Refs: map[string]cid.Cid{}, Types: map[string]int{}, Failed: map[string]error{}}
+	objects := filepath.Join(gitDir, "objects")
+	if err := i.importLoose(ctx, putter, objects, report, t); err != nil {
+		return report, err
+	}
+	packs, err := filepath.Glob(filepath.Join(objects, "pack", "*.pack"))
+	if err != nil {
+		return report, err
+	}
+	for _, pack := range packs {
+		if err := i.importPack(ctx, putter, pack, report, t); err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return report, ctxErr
+			}
+			log.Printf("err: importing packfile failed: %s, %v\n", pack, err)
+			report.Failed[pack] = err
+		}
+	}
+	for name, id := range readRefs(gitDir) {
+		if c, err := Cid(id); err == nil {
+			report.Refs[name] = c
+		}
+	}
+
+	if i.cfg.debug {
+		log.Printf("debug: imported git repository: %s, %d imported, %d refs, %d failed\n", gitDir, report.Imported, len(report.Refs), len(report.Failed))
+	}
+	if len(report.Failed) > 0 {
+		return report, ErrImportIncomplete
+	}
+	return report, nil
+}
+
+// gitDirOf - returns git directory of repository at path: `.git` of work trees (following `gitdir:` files of
+// linked work trees and submodules), or path itself for bare repositories
+func gitDirOf(path string) (string, error) {
+	dotGit := filepath.Join(path, ".git")
+	if info, err := os.Stat(dotGit); err == nil {
+		if info.IsDir() {
+			return dotGit, nil
+		}
+		data, err := os.ReadFile(dotGit)
+		if err != nil || !bytes.HasPrefix(data, []byte("gitdir:")) {
+			return "", ErrNotRepository
+		}
+		gitDir := strings.TrimSpace(string(data[len("gitdir:"):]))
+		if !filepath.IsAbs(gitDir) {
+			gitDir = filepath.Join(path, gitDir)
+		}
+		path = gitDir
+	}
+	if info, err := os.Stat(filepath.Join(path, "objects")); err != nil || !info.IsDir() {
+		return "", ErrNotRepository
+	}
+	if _, err := os.Stat(filepath.Join(path, "HEAD")); err != nil {
+		return "", ErrNotRepository
+	}
+	return path, nil
+}
+
+// Cid - returns cid of git object with given (hex, SHA-1) object id
+func Cid(id string) (cid.Cid, error) {
+	digest, err := hex.DecodeString(id)
+	if err != nil || len(digest) != 20 {
+		return cid.Undef, ErrInvalidObjectID
+	}
+	hash, err := mh.Encode(digest, mh.SHA1)
+	if err != nil {
+		return cid.Undef, ErrInvalidObjectID
+	}
+	return cid.NewCidV1(cid.GitRaw, hash), nil
+}
+
+// ObjectID - returns git object id of git object with given cid
+func ObjectID(c cid.Cid) (string, error) {
+	if !c.Defined() || c.Prefix().Codec != cid.GitRaw {
+		return "", ErrInvalidObjectID
+	}
+	decoded, err := mh.Decode(c.Hash())
+	if err != nil || decoded.Code != mh.SHA1 {
+		return "", ErrInvalidObjectID
+	}
+	return hex.EncodeToString(decoded.Digest), nil
+}
+
+// ParseObject - splits stored git object into its type (`blob`, `tree`, `commit` or `tag`) and content
+func ParseObject(data []byte) (string, []byte, error) {
+	i := bytes.IndexByte(data, 0)
+	if i < 0 {
+		return "", nil, ErrCorruptObject
+	}
+	header := strings.SplitN(string(data[:i]), " ", 2)
+	if len(header) != 2 {
+		return "", nil, ErrCorruptObject
+	}
+	size, err := strconv.Atoi(header[1])
+	if err != nil || size != len(data)-i-1 {
+		return "", nil, ErrCorruptObject
+	}
+	switch header[0] {
+	case "blob", "tree", "commit", "tag":
+		return header[0], data[i+1:], nil
+	default:
+		return "", nil, ErrCorruptObject
+	}
+}
+
+// objectHeader - returns header git prefixes content of type and size with, before hashing it
+func objectHeader(kind string, size int) []byte {
+	return []byte(kind + " " + strconv.Itoa(size) + "\x00")
+}
@@ -0,0 +1,99 @@
+package gitimport
+
+import (
+	"bufio"
+	"compress/zlib"
+	"context"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	fsstore "github.com/igumus/go-objectstore-fs"
+)
+
+// importLoose - imports zlib compressed loose objects of object directory, stored as `<xx>/<38 hex digits>`
+// named after their object id
+func (i *Importer) importLoose(ctx context.Context, putter fsstore.Putter, objects string, report *ImportReport, t *fsstore.ProgressTracker) error {
+	dirs, err := os.ReadDir(objects)
+	if err != nil {
+		return ErrNotRepository
+	}
+	for _, dir := range dirs {
+		if !dir.IsDir() || len(dir.Name()) != 2 {
+			// `pack` and `info` directories
+			continue
+		}
+		entries, err := os.ReadDir(filepath.Join(objects, dir.Name()))
+		if err != nil {
+			log.Printf("err: listing loose objects failed: %s, %v\n", dir.Name(), err)
+			report.Failed[filepath.Join(objects, dir.Name())] = err
+			continue
+		}
+		t.Expect(int64(len(entries)))
+		for _, entry := range entries {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			id := dir.Name() + entry.Name()
+			kind, size, err := i.importLooseObject(ctx, putter, filepath.Join(objects, dir.Name(), entry.Name()), id)
+			if err != nil {
+				if ctxErr := ctx.Err(); ctxErr != nil {
+					return ctxErr
+				}
+				log.Printf("err: importing loose object failed: %s, %v\n", id, err)
+				report.Failed[id] = err
+			} else {
+				report.imported(kind)
+			}
+			t.Add(1, size)
+		}
+	}
+	return nil
+}
+
+// importLooseObject - inflates loose object at path into store, returning its git type and size
+func (i *Importer) importLooseObject(ctx context.Context, putter fsstore.Putter, path, id string) (string, int64, error) {
+	c, err := Cid(id)
+	if err != nil {
+		return "", 0, err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer file.Close()
+	zr, err := zlib.NewReader(bufio.NewReader(file))
+	if err != nil {
+		return "", 0, ErrCorruptObject
+	}
+	defer zr.Close()
+	body := bufio.NewReader(zr)
+	header, err := body.ReadString(0)
+	if err != nil {
+		return "", 0, ErrCorruptObject
+	}
+	kind := strings.SplitN(header, " ", 2)[0]
+	counter := &countingReader{r: io.MultiReader(strings.NewReader(header), body)}
+	if err := putter.PutObject(ctx, c, counter); err != nil {
+		return "", 0, err
+	}
+	if i.cfg.debug {
+		log.Printf("debug: imported loose git object: %s, %s\n", id, c)
+	}
+	return kind, counter.n, nil
+}
+
+// countingReader counts bytes read through from underlying reader
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+// Read - reads p from underlying reader, counting read bytes
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,298 @@
+package gitimport
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"context"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"log"
+	"os"
+
+	fsstore "github.com/igumus/go-objectstore-fs"
+)
+
+// _packSignature handles the magic packfiles start with
+const _packSignature = "PACK"
+
+// packfile object types, delta types store their object as delta against a base object, see `applyDelta`
+const (
+	_packCommit   = 1
+	_packTree     = 2
+	_packBlob     = 3
+	_packTag      = 4
+	_packOfsDelta = 6
+	_packRefDelta = 7
+)
+
+// _maxSizeHint handles the largest size buffers of packfile entries and delta targets are preallocated for, so
+// sizes claimed by corrupt packfiles can not exhaust memory up front; larger objects grow as they are decoded
+const _maxSizeHint = 16 << 20
+
+// _packTypes handles the git types of packfile object types
+var _packTypes = map[int]string{_packCommit: "commit", _packTree: "tree", _packBlob: "blob", _packTag: "tag"}
+
+// packReader reads packfile sequentially, tracking offset of read bytes; as a byte reader, zlib streams of
+// entries are inflated without reading past their end
+type packReader struct {
+	r   *bufio.Reader
+	off int64
+}
+
+// Read - reads p from packfile
+func (p *packReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.off += int64(n)
+	return n, err
+}
+
+// ReadByte - reads next byte of packfile
+func (p *packReader) ReadByte() (byte, error) {
+	b, err := p.r.ReadByte()
+	if err == nil {
+		p.off++
+	}
+	return b, err
+}
+
+// packDelta captures a delta of packfile waiting for its base object
+type packDelta struct {
+	base  string
+	delta []byte
+}
+
+// importPack - imports every object of packfile at path: whole objects as they are read, deltas against base
+// objects preceding them (by offset) as they are read, and deltas against base objects of any other position (by
+// object id) once their base is in store
+func (i *Importer) importPack(ctx context.Context, putter fsstore.Putter, path string, report *ImportReport, t *fsstore.ProgressTracker) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	pr := &packReader{r: bufio.NewReader(file)}
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(pr, header); err != nil || string(header[:4]) != _packSignature {
+		return ErrCorruptPack
+	}
+	if version := binary.BigEndian.Uint32(header[4:8]); version != 2 && version != 3 {
+		return ErrCorruptPack
+	}
+	count := binary.BigEndian.Uint32(header[8:12])
+	t.Expect(int64(count))
+
+	ids := map[int64]string{}
+	pending := []packDelta{}
+	for n := uint32(0); n < count; n++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		offset := pr.off
+		kind, size, err := readEntryHeader(pr)
+		if err != nil {
+			return ErrCorruptPack
+		}
+		base := ""
+		baseOffset := int64(-1)
+		switch kind {
+		case _packOfsDelta:
+			distance, err := readOffset(pr)
+			if err != nil || distance > offset {
+				return ErrCorruptPack
+			}
+			baseOffset = offset - distance
+		case _packRefDelta:
+			digest := make([]byte, sha1.Size)
+			if _, err := io.ReadFull(pr, digest); err != nil {
+				return ErrCorruptPack
+			}
+			base = hex.EncodeToString(digest)
+		}
+		data, err := inflate(pr, size)
+		if err != nil {
+			return ErrCorruptPack
+		}
+
+		switch kind {
+		case _packCommit, _packTree, _packBlob, _packTag:
+			raw := append(objectHeader(_packTypes[kind], len(data)), data...)
+			id, err := i.putObject(ctx, putter, raw)
+			if err != nil {
+				report.Failed[id] = err
+			} else {
+				ids[offset] = id
+				report.imported(_packTypes[kind])
+			}
+		case _packOfsDelta:
+			id, ok := ids[baseOffset]
+			if !ok {
+				// base object failed to import, or offset is not an object of pack
+				log.Printf("err: resolving packed delta failed: %s, offset %d, %v\n", path, offset, ErrMissingBase)
+				report.Failed[path] = ErrMissingBase
+				break
+			}
+			if id, kind, err := i.resolveDelta(ctx, putter, id, data); err != nil {
+				report.Failed[id] = err
+			} else {
+				ids[offset] = id
+				report.imported(kind)
+			}
+		case _packRefDelta:
+			if !i.has(ctx, base) {
+				pending = append(pending, packDelta{base: base, delta: data})
+				break
+			}
+			if id, kind, err := i.resolveDelta(ctx, putter, base, data); err != nil {
+				report.Failed[id] = err
+			} else {
+				ids[offset] = id
+				report.imported(kind)
+			}
+		default:
+			return ErrCorruptPack
+		}
+		t.Add(1, int64(len(data)))
+	}
+
+	// deltas of objects resolved later in pack
+	for len(pending) > 0 {
+		waiting := []packDelta{}
+		for _, d := range pending {
+			if !i.has(ctx, d.base) {
+				waiting = append(waiting, d)
+				continue
+			}
+			if id, kind, err := i.resolveDelta(ctx, putter, d.base, d.delta); err != nil {
+				report.Failed[id] = err
+			} else {
+				report.imported(kind)
+			}
+		}
+		if len(waiting) == len(pending) {
+			for _, d := range waiting {
+				log.Printf("err: resolving packed delta failed: %s, base %s, %v\n", path, d.base, ErrMissingBase)
+				report.Failed[d.base] = ErrMissingBase
+			}
+			break
+		}
+		pending = waiting
+	}
+	if i.cfg.debug {
+		log.Printf("debug: imported git packfile: %s, %d objects\n", path, count)
+	}
+	return nil
+}
+
+// readEntryHeader - reads type and (inflated) size of packfile entry
+func readEntryHeader(r io.ByteReader) (int, int64, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return 0, 0, err
+	}
+	kind := int(b>>4) & 0x07
+	size := int64(b & 0x0f)
+	for shift := uint(4); b&0x80 != 0; shift += 7 {
+		if shift > 56 {
+			return 0, 0, ErrCorruptPack
+		}
+		if b, err = r.ReadByte(); err != nil {
+			return 0, 0, err
+		}
+		size |= int64(b&0x7f) << shift
+	}
+	if size < 0 {
+		return 0, 0, ErrCorruptPack
+	}
+	return kind, size, nil
+}
+
+// readOffset - reads distance of offset delta to its base object, encoded big endian with an offset added for
+// every continued byte
+func readOffset(r io.ByteReader) (int64, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	distance := int64(b & 0x7f)
+	for b&0x80 != 0 {
+		if distance >= 1<<55 {
+			return 0, ErrCorruptPack
+		}
+		if b, err = r.ReadByte(); err != nil {
+			return 0, err
+		}
+		distance = (distance+1)<<7 | int64(b&0x7f)
+	}
+	return distance, nil
+}
+
+// inflate - reads zlib stream of packfile entry of size, up to end of its checksum
+func inflate(pr *packReader, size int64) ([]byte, error) {
+	zr, err := zlib.NewReader(pr)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	hint := size
+	if hint > _maxSizeHint {
+		hint = _maxSizeHint
+	}
+	buf := bytes.NewBuffer(make([]byte, 0, hint))
+	// entries inflating past their size are corrupt, so inflating stops one byte past it
+	if _, err := io.Copy(buf, io.LimitReader(zr, size+1)); err != nil {
+		return nil, err
+	}
+	if int64(buf.Len()) != size {
+		return nil, ErrCorruptPack
+	}
+	return buf.Bytes(), nil
+}
+
+// has - checks whether git object of id is in store
+func (i *Importer) has(ctx context.Context, id string) bool {
+	c, err := Cid(id)
+	return err == nil && i.store.HasObject(ctx, c)
+}
+
+// putObject - stores git object (header included), returning its object id
+func (i *Importer) putObject(ctx context.Context, putter fsstore.Putter, raw []byte) (string, error) {
+	sum := sha1.Sum(raw)
+	id := hex.EncodeToString(sum[:])
+	c, err := Cid(id)
+	if err != nil {
+		return id, err
+	}
+	if err := putter.PutObject(ctx, c, bytes.NewReader(raw)); err != nil {
+		log.Printf("err: importing packed git object failed: %s, %v\n", id, err)
+		return id, err
+	}
+	return id, nil
+}
+
+// resolveDelta - applies delta to base object of id in store, and stores resulting git object, returning its
+// object id and type; base id is returned when delta can not be resolved
+func (i *Importer) resolveDelta(ctx context.Context, putter fsstore.Putter, base string, delta []byte) (string, string, error) {
+	c, err := Cid(base)
+	if err != nil {
+		return base, "", err
+	}
+	data, err := i.store.ReadObject(ctx, c)
+	if err != nil {
+		log.Printf("err: reading delta base object failed: %s, %v\n", base, err)
+		return base, "", err
+	}
+	kind, content, err := ParseObject(data)
+	if err != nil {
+		return base, "", err
+	}
+	target, err := applyDelta(content, delta)
+	if err != nil {
+		log.Printf("err: applying delta failed: %s, %v\n", base, err)
+		return base, "", err
+	}
+	id, err := i.putObject(ctx, putter, append(objectHeader(kind, len(target)), target...))
+	return id, kind, err
+}
@@ -0,0 +1,72 @@
+package gitimport
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// _symrefPrefix handles the prefix of symbolic refs, naming ref they point at
+const _symrefPrefix = "ref: "
+
+// _maxSymrefDepth handles the maximum count of symbolic refs followed resolving a ref
+const _maxSymrefDepth = 5
+
+// readRefs - returns object ids of refs of git directory: packed refs, loose refs under `refs/` overriding them,
+// and `HEAD`; symbolic refs are resolved, and refs not resolving to an object id are left out
+func readRefs(gitDir string) map[string]string {
+	raw := map[string]string{}
+	if file, err := os.Open(filepath.Join(gitDir, "packed-refs")); err == nil {
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			line := scanner.Text()
+			// comments, and peeled object ids of annotated tags
+			if strings.HasPrefix(line, "#") || strings.HasPrefix(line, "^") {
+				continue
+			}
+			if fields := strings.Fields(line); len(fields) == 2 {
+				raw[fields[1]] = fields[0]
+			}
+		}
+		file.Close()
+	}
+	filepath.Walk(filepath.Join(gitDir, "refs"), func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return nil
+		}
+		if data, err := os.ReadFile(path); err == nil {
+			if name, err := filepath.Rel(gitDir, path); err == nil {
+				raw[filepath.ToSlash(name)] = strings.TrimSpace(string(data))
+			}
+		}
+		return nil
+	})
+	if data, err := os.ReadFile(filepath.Join(gitDir, "HEAD")); err == nil {
+		raw["HEAD"] = strings.TrimSpace(string(data))
+	}
+
+	refs := map[string]string{}
+	for name := range raw {
+		if id, ok := resolveRef(raw, name); ok {
+			refs[name] = id
+		}
+	}
+	return refs
+}
+
+// resolveRef - follows symbolic refs starting at name to object id they point at
+func resolveRef(raw map[string]string, name string) (string, bool) {
+	for depth := 0; depth <= _maxSymrefDepth; depth++ {
+		value, ok := raw[name]
+		if !ok {
+			return "", false
+		}
+		if !strings.HasPrefix(value, _symrefPrefix) {
+			_, err := Cid(value)
+			return value, err == nil
+		}
+		name = strings.TrimSpace(strings.TrimPrefix(value, _symrefPrefix))
+	}
+	return "", false
+}
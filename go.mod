@@ -3,8 +3,13 @@ module github.com/igumus/go-objectstore-fs
 go 1.17
 
 require (
+	github.com/hanwen/go-fuse/v2 v2.1.0
 	github.com/igumus/go-objectstore-lib v1.1.3
 	github.com/ipfs/go-cid v0.2.0
+	github.com/ipld/go-ipld-prime v0.17.0
+	github.com/multiformats/go-multicodec v0.5.0
+	github.com/multiformats/go-multihash v0.2.0
+	golang.org/x/sys v0.0.0-20220622161953-175b2fd9d664
 )
 
 require (
@@ -14,10 +19,9 @@ require (
 	github.com/multiformats/go-base32 v0.0.4 // indirect
 	github.com/multiformats/go-base36 v0.1.0 // indirect
 	github.com/multiformats/go-multibase v0.1.1 // indirect
-	github.com/multiformats/go-multihash v0.2.0 // indirect
 	github.com/multiformats/go-varint v0.0.6 // indirect
+	github.com/polydawn/refmt v0.0.0-20201211092308-30ac6d18308e // indirect
 	github.com/spaolacci/murmur3 v1.1.0 // indirect
 	golang.org/x/crypto v0.0.0-20220622213112-05595931fe9d // indirect
-	golang.org/x/sys v0.0.0-20220622161953-175b2fd9d664 // indirect
 	lukechampine.com/blake3 v1.1.7 // indirect
 )
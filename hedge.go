@@ -0,0 +1,104 @@
+package fsstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/igumus/go-objectstore-lib"
+	"github.com/ipfs/go-cid"
+)
+
+// _defHedgeDelay handles the default delay after which a read is also issued to next replica
+const _defHedgeDelay = 50 * time.Millisecond
+
+// hedgeResult captures outcome of a hedged read attempt
+type hedgeResult struct {
+	source string
+	data   []byte
+	err    error
+}
+
+// hedgedRead - reads object locally, and issues read to next replica each time hedge delay passes (or an
+// earlier attempt failed) without an answer. First successful answer wins; replica answers are verified
+// against cid, unless call opted out via `WithNoVerify`. Error of local read is returned when every attempt fails;
+// objects every attempt missed are remembered as absent (see `WithNegativeCache`).
+func (f *fsObjectStoreService) hedgedRead(ctx context.Context, c cid.Cid) ([]byte, error) {
+	key := c.String()
+	if f.negative.contains(key) {
+		f.metrics.negativeLookup(true)
+		return nil, objectstore.ErrObjectNotExists
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	trusted := callConfigFrom(ctx).noVerify
+	results := make(chan hedgeResult, len(f.replicas)+1)
+	go func() {
+		data, err := f.readLocal(ctx, c)
+		results <- hedgeResult{source: "local", data: data, err: err}
+	}()
+	launched, pending := 0, 1
+	launch := func() {
+		replica := f.replicas[launched]
+		source := fmt.Sprintf("replica %d", launched)
+		launched++
+		pending++
+		go func() {
+			data, err := replica.ReadObject(ctx, c)
+			if err == nil && !trusted {
+				err = verifyContent(c, data)
+			}
+			results <- hedgeResult{source: source, data: data, err: err}
+		}()
+	}
+
+	timer := time.NewTimer(f.hedgeDelay)
+	defer timer.Stop()
+	var firstErr error
+	absent := true
+	for pending > 0 {
+		select {
+		case result := <-results:
+			pending--
+			if result.err == nil {
+				if f.isDebug() {
+					log.Printf("debug: hedged read answered: %s, %s\n", c, result.source)
+				}
+				return result.data, nil
+			}
+			absent = absent && errors.Is(result.err, objectstore.ErrObjectNotExists)
+			if result.source == "local" || firstErr == nil {
+				firstErr = result.err
+			}
+			if launched < len(f.replicas) {
+				launch()
+			}
+		case <-timer.C:
+			if launched < len(f.replicas) {
+				launch()
+				timer.Reset(f.hedgeDelay)
+			}
+		case <-ctx.Done():
+			return nil, checkContextError(ctx, f.isDebug())
+		}
+	}
+	if absent {
+		f.negative.add(key)
+	}
+	return nil, firstErr
+}
+
+// verifyContent - checks whether data matches cid, guarding against corrupted replica answers
+func verifyContent(c cid.Cid, data []byte) error {
+	digest, err := c.Prefix().Sum(data)
+	if err != nil {
+		return ErrDataDigestionFailed
+	}
+	if !digest.Equals(c) {
+		return objectstore.ErrObjectReadingFailed
+	}
+	return nil
+}
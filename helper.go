@@ -3,6 +3,8 @@ package fsstore
 import (
 	"bytes"
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"log"
 	"os"
@@ -11,6 +13,9 @@ import (
 	"github.com/igumus/go-objectstore-lib"
 )
 
+// _tempPrefix handles the file name prefix of staged writes
+const _tempPrefix = "write-"
+
 // checkContextError - check given context has an error
 func checkContextError(ctx context.Context, debug bool) error {
 	switch ctx.Err() {
@@ -38,16 +43,25 @@ func exists(path string) bool {
 	return !errors.Is(err, os.ErrNotExist)
 }
 
-// read - reads objLink value as binary
+// read - reads objLink value as binary, ErrObjectNotExists is returned when objLink is absent
 func read(objLink string) ([]byte, error) {
 	file, err := os.Open(objLink)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, objectstore.ErrObjectNotExists
+	}
 	if err != nil {
 		log.Printf("err: opening object failed: %s, %v\n", objLink, err)
 		return nil, objectstore.ErrObjectReadingFailed
 	}
 
+	defer file.Close()
+	return readFile(file, objLink)
+}
+
+// readFile - reads content of opened file of objLink as binary
+func readFile(file *os.File, objLink string) ([]byte, error) {
 	binData := bytes.Buffer{}
-	_, err = binData.ReadFrom(file)
+	_, err := binData.ReadFrom(file)
 	if err != nil {
 		log.Printf("err: reading object failed: %s, %v\n", objLink, err)
 		return nil, objectstore.ErrObjectReadingFailed
@@ -56,21 +70,73 @@ func read(objLink string) ([]byte, error) {
 	return binData.Bytes(), nil
 }
 
-func write(objLink string, data []byte) error {
-	os.MkdirAll(filepath.Dir(objLink), 0777)
-	file, err := os.Create(objLink)
+// write - writes data to objLink atomically, by staging it in tmpDir and renaming into place
+func write(tmpDir, objLink string, data []byte) error {
+	file, err := stage(tmpDir)
 	if err != nil {
-		log.Printf("err: creating object failed: %s, %v\n", objLink, err)
-		return objectstore.ErrObjectWritingFailed
+		return err
 	}
 
 	binData := bytes.Buffer{}
 	binData.Write(data)
 
-	_, err = binData.WriteTo(file)
-	if err != nil {
+	if _, err = binData.WriteTo(file); err != nil {
+		discard(file)
 		log.Printf("err: writing object failed: %s, %v\n", objLink, err)
 		return objectstore.ErrObjectWritingFailed
 	}
+	return commit(file, objLink)
+}
+
+// stage - creates staging file in tmpDir to be committed or discarded later
+func stage(tmpDir string) (*os.File, error) {
+	if err := os.MkdirAll(tmpDir, 0777); err != nil {
+		log.Printf("err: creating temp directory failed: %s, %v\n", tmpDir, err)
+		return nil, objectstore.ErrObjectWritingFailed
+	}
+	file, err := createTemp(tmpDir)
+	if err != nil {
+		log.Printf("err: creating staged object failed: %s, %v\n", tmpDir, err)
+		return nil, objectstore.ErrObjectWritingFailed
+	}
+	return file, nil
+}
+
+// commit - flushes staging file and renames it into objLink
+func commit(file *os.File, objLink string) error {
+	err := file.Sync()
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = placeFile(objLink, func() error { return os.Rename(file.Name(), objLink) })
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		log.Printf("err: committing object failed: %s, %v\n", objLink, err)
+		return objectstore.ErrObjectWritingFailed
+	}
 	return nil
 }
+
+// discard - closes and removes staging file
+func discard(file *os.File) {
+	file.Close()
+	os.Remove(file.Name())
+}
+
+// createTemp - creates uniquely named staging file in dir, honoring process umask like os.Create does
+func createTemp(dir string) (*os.File, error) {
+	suffix := make([]byte, 8)
+	for {
+		if _, err := rand.Read(suffix); err != nil {
+			return nil, err
+		}
+		name := filepath.Join(dir, _tempPrefix+hex.EncodeToString(suffix))
+		file, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		return file, err
+	}
+}
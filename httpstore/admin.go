@@ -0,0 +1,326 @@
+package httpstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	fsstore "github.com/igumus/go-objectstore-fs"
+)
+
+// Job captures wire format of an admin job (see `fsstore.JobStatus`), with progress reported by operation and its
+// result once succeeded: `fsstore.GCReport` of `gc`, `fsstore.VerifyReport` of `verify`, `fsstore.RebalanceReport`
+// of `rebalance`, `fsstore.ReconcileReport` of `reconcile`, dropped journal entries of `compact`, and snapshot cid of `snapshot`.
+type Job struct {
+	ID        string                  `json:"id"`
+	Kind      string                  `json:"kind"`
+	Owner     string                  `json:"owner,omitempty"`
+	State     fsstore.JobState        `json:"state"`
+	Submitted time.Time               `json:"submitted"`
+	Started   time.Time               `json:"started"`
+	Finished  time.Time               `json:"finished"`
+	Progress  *fsstore.ProgressUpdate `json:"progress,omitempty"`
+	Result    json.RawMessage         `json:"result,omitempty"`
+	Error     string                  `json:"error,omitempty"`
+}
+
+// gcRequest captures wire format of garbage collection job request
+type gcRequest struct {
+	KeepLast   int    `json:"keepLast,omitempty"`
+	KeepWithin string `json:"keepWithin,omitempty"`
+	DryRun     bool   `json:"dryRun,omitempty"`
+}
+
+// admin - serves admin job routes, starting operation named by path in background
+func (h *handler) admin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.authenticated(w, r) {
+		return
+	}
+	manager, ok := h.store.(fsstore.JobManager)
+	if !ok {
+		http.Error(w, "jobs not supported", http.StatusNotImplemented)
+		return
+	}
+	op := strings.TrimPrefix(r.URL.Path, _adminPath+"/")
+	var run fsstore.JobFunc
+	switch op {
+	case "gc":
+		gc, ok := h.store.(fsstore.DryRunner)
+		if !ok {
+			http.Error(w, "garbage collection not supported", http.StatusNotImplemented)
+			return
+		}
+		req := gcRequest{}
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "invalid gc request", http.StatusBadRequest)
+				return
+			}
+		}
+		policy := fsstore.RetentionPolicy{KeepLast: req.KeepLast}
+		if len(req.KeepWithin) > 0 {
+			within, err := time.ParseDuration(req.KeepWithin)
+			if err != nil {
+				http.Error(w, "invalid keepWithin", http.StatusBadRequest)
+				return
+			}
+			policy.KeepWithin = within
+		}
+		run = func(ctx context.Context) (interface{}, error) {
+			return gc.CollectGarbageWith(ctx, policy, fsstore.WithDryRun(req.DryRun))
+		}
+	case fsstore.JobVerify, fsstore.JobRebalance, fsstore.JobReconcile:
+		// built-in jobs are run by store itself, so rebalancing resumes after gateway restarts
+	case "compact":
+		compactor, ok := h.store.(fsstore.JournalCompactor)
+		if !ok {
+			http.Error(w, "journal compaction not supported", http.StatusNotImplemented)
+			return
+		}
+		run = func(ctx context.Context) (interface{}, error) {
+			dropped, err := compactor.CompactJournal(ctx)
+			return map[string]int{"dropped": dropped}, err
+		}
+	case "prune":
+		pruner, ok := h.store.(fsstore.DirPruner)
+		if !ok {
+			http.Error(w, "pruning not supported", http.StatusNotImplemented)
+			return
+		}
+		run = func(ctx context.Context) (interface{}, error) {
+			pruned, err := pruner.PruneEmptyDirs(ctx)
+			return map[string]int{"pruned": pruned}, err
+		}
+	case "snapshot":
+		snapshotter, ok := h.store.(fsstore.BucketSnapshotter)
+		if !ok {
+			http.Error(w, "snapshots not supported", http.StatusNotImplemented)
+			return
+		}
+		run = func(ctx context.Context) (interface{}, error) {
+			c, err := snapshotter.SnapshotBucket(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return map[string]string{"cid": c.String()}, nil
+		}
+	default:
+		http.NotFound(w, r)
+		return
+	}
+
+	var status fsstore.JobStatus
+	var err error
+	if run == nil {
+		status, err = manager.StartJob(r.Context(), op)
+	} else {
+		status, err = manager.SubmitJob(r.Context(), op, run)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), statusOf(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", _jobsPath+"/"+status.ID)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(jobOf(status))
+}
+
+// jobs - lists queued, running and recently finished jobs of store, including steps of scheduled maintenance
+func (h *handler) jobs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.authenticated(w, r) {
+		return
+	}
+	manager, ok := h.store.(fsstore.JobManager)
+	if !ok {
+		http.Error(w, "jobs not supported", http.StatusNotImplemented)
+		return
+	}
+	statuses, err := manager.Jobs(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), statusOf(err))
+		return
+	}
+	ret := make([]Job, 0, len(statuses))
+	for _, status := range statuses {
+		ret = append(ret, jobOf(status))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ret)
+}
+
+// job - reports state of admin job, or cancels it
+func (h *handler) job(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodDelete {
+		w.Header().Set("Allow", "GET, DELETE")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.authenticated(w, r) {
+		return
+	}
+	manager, ok := h.store.(fsstore.JobManager)
+	if !ok {
+		http.Error(w, "jobs not supported", http.StatusNotImplemented)
+		return
+	}
+	id := strings.TrimPrefix(r.URL.Path, _jobsPath+"/")
+	if r.Method == http.MethodDelete {
+		if err := manager.CancelJob(r.Context(), id); err != nil {
+			http.Error(w, err.Error(), statusOf(err))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	status, err := manager.Job(r.Context(), id)
+	if err != nil {
+		http.Error(w, err.Error(), statusOf(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(jobOf(status))
+}
+
+// jobOf - returns wire format of job status
+func jobOf(status fsstore.JobStatus) Job {
+	job := Job{ID: status.ID, Kind: status.Kind, Owner: status.Owner, State: status.State, Submitted: status.Submitted,
+		Started: status.Started, Finished: status.Finished, Progress: status.Progress, Error: status.Err}
+	if status.Result != nil {
+		if encoded, err := json.Marshal(status.Result); err == nil {
+			job.Result = encoded
+		} else {
+			log.Printf("warn: encoding job result failed: %s, %v\n", status.ID, err)
+		}
+	}
+	return job
+}
+
+// authenticated - checks that admin request carries a principal (see `WithPrincipalFunc`), responding
+// unauthorized otherwise; authorizing principal is left to store
+func (h *handler) authenticated(w http.ResponseWriter, r *http.Request) bool {
+	if _, ok := fsstore.PrincipalFromContext(r.Context()); ok {
+		return true
+	}
+	http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+	return false
+}
+
+// AdminClient defines the functions clients need to run maintenance operations of gateway store as admin jobs.
+// Clients created via `NewClient` implement it.
+type AdminClient interface {
+	StartJob(ctx context.Context, op string, body interface{}) (Job, error)
+	Job(ctx context.Context, id string) (Job, error)
+	Jobs(ctx context.Context) ([]Job, error)
+	CancelJob(ctx context.Context, id string) error
+}
+
+var _ AdminClient = (*client)(nil)
+
+// jobURL - returns gateway url of admin job
+func (c *client) jobURL(id string) string {
+	return c.baseURL + _jobsPath + "/" + id
+}
+
+// StartJob - starts admin job running operation (`gc`, `verify`, `rebalance`, `reconcile`, `compact`, `prune` or `snapshot`) on gateway store,
+// with json encoded body as its request (e.g. retention of `gc`) unless nil
+func (c *client) StartJob(ctx context.Context, op string, body interface{}) (Job, error) {
+	var payload []byte
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return Job{}, err
+		}
+		payload = encoded
+	}
+	resp, err := c.do(ctx, func() (*http.Request, error) {
+		return http.NewRequest(http.MethodPost, c.baseURL+_adminPath+"/"+op, bytes.NewReader(payload))
+	}, false)
+	if err != nil {
+		return Job{}, requestError(ctx, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		return Job{}, adminError(resp)
+	}
+	job := Job{}
+	err = json.NewDecoder(resp.Body).Decode(&job)
+	return job, err
+}
+
+// Job - returns state of admin job with id on gateway
+func (c *client) Job(ctx context.Context, id string) (Job, error) {
+	resp, err := c.do(ctx, func() (*http.Request, error) {
+		return http.NewRequest(http.MethodGet, c.jobURL(id), nil)
+	}, true)
+	if err != nil {
+		return Job{}, requestError(ctx, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Job{}, adminError(resp)
+	}
+	job := Job{}
+	err = json.NewDecoder(resp.Body).Decode(&job)
+	return job, err
+}
+
+// Jobs - lists queued, running and recently finished jobs of gateway store
+func (c *client) Jobs(ctx context.Context) ([]Job, error) {
+	resp, err := c.do(ctx, func() (*http.Request, error) {
+		return http.NewRequest(http.MethodGet, c.baseURL+_jobsPath, nil)
+	}, true)
+	if err != nil {
+		return nil, requestError(ctx, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, adminError(resp)
+	}
+	ret := []Job{}
+	err = json.NewDecoder(resp.Body).Decode(&ret)
+	return ret, err
+}
+
+// CancelJob - cancels admin job with id on gateway; canceling a finished job has no effect
+func (c *client) CancelJob(ctx context.Context, id string) error {
+	resp, err := c.do(ctx, func() (*http.Request, error) {
+		return http.NewRequest(http.MethodDelete, c.jobURL(id), nil)
+	}, true)
+	if err != nil {
+		return requestError(ctx, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		return adminError(resp)
+	}
+	return nil
+}
+
+// adminError - maps unexpected admin route responses to errors
+func adminError(resp *http.Response) error {
+	switch resp.StatusCode {
+	case http.StatusUnauthorized:
+		return ErrUnauthenticated
+	case http.StatusNotFound:
+		return fsstore.ErrJobNotExists
+	case http.StatusNotImplemented:
+		return ErrJobUnsupported
+	default:
+		return responseError(resp)
+	}
+}
@@ -0,0 +1,77 @@
+package httpstore
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	fsstore "github.com/igumus/go-objectstore-fs"
+	"github.com/ipfs/go-cid"
+)
+
+// blockRequest captures wire format of blocking request
+type blockRequest struct {
+	Reason string `json:"reason,omitempty"`
+}
+
+// blocklist - serves listing deny-list of store
+func (h *handler) blocklist(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.authenticated(w, r) {
+		return
+	}
+	list, ok := h.store.(fsstore.Blocklist)
+	if !ok {
+		http.Error(w, "blocklist not supported", http.StatusNotImplemented)
+		return
+	}
+	blocked, err := list.BlockedCIDs(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), statusOf(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(blocked)
+}
+
+// blocked - serves blocking and unblocking object of deny-list of store
+func (h *handler) blocked(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut && r.Method != http.MethodDelete {
+		w.Header().Set("Allow", "PUT, DELETE")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.authenticated(w, r) {
+		return
+	}
+	list, ok := h.store.(fsstore.Blocklist)
+	if !ok {
+		http.Error(w, "blocklist not supported", http.StatusNotImplemented)
+		return
+	}
+	c, err := cid.Decode(strings.TrimPrefix(r.URL.Path, _blocklistPath+"/"))
+	if err != nil {
+		http.Error(w, "invalid cid", http.StatusBadRequest)
+		return
+	}
+	if r.Method == http.MethodDelete {
+		err = list.UnblockCID(r.Context(), c)
+	} else {
+		req := blockRequest{}
+		if err := json.NewDecoder(io.LimitReader(r.Body, 1<<16)).Decode(&req); err != nil && err != io.EOF {
+			http.Error(w, "invalid block request", http.StatusBadRequest)
+			return
+		}
+		err = list.BlockCID(r.Context(), c, req.Reason)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), statusOf(err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
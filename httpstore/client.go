@@ -0,0 +1,403 @@
+package httpstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	fsstore "github.com/igumus/go-objectstore-fs"
+	"github.com/igumus/go-objectstore-lib"
+	"github.com/ipfs/go-cid"
+)
+
+// _defRetries handles the default count of retries of failed requests
+const _defRetries = 3
+
+// _defRetryBackoff handles the default initial backoff between retries, doubled on each retry
+const _defRetryBackoff = 100 * time.Millisecond
+
+// Captures/Represents gateway client configuration information
+type clientConfig struct {
+	httpClient *http.Client
+	retries    int
+	backoff    time.Duration
+	debug      bool
+	token      string
+}
+
+// A ClientOption sets options such as retries and underlying http client.
+type ClientOption func(*clientConfig)
+
+// WithHTTPClient returns a ClientOption that specifies http client used for requests.
+// If not set, a client with pooled keep-alive connections is used
+func WithHTTPClient(hc *http.Client) ClientOption {
+	return func(cc *clientConfig) {
+		cc.httpClient = hc
+	}
+}
+
+// WithRetries returns a ClientOption that specifies count of retries, and initial backoff between them.
+// If not set, the default is `3` retries starting with `100ms` backoff
+func WithRetries(n int, backoff time.Duration) ClientOption {
+	return func(cc *clientConfig) {
+		cc.retries = n
+		cc.backoff = backoff
+	}
+}
+
+// WithBearerToken returns a ClientOption that specifies token sent as `Authorization: Bearer` header of every
+// request, for gateway principal function (see `WithPrincipalFunc`) to authenticate. If not set, requests are anonymous
+func WithBearerToken(token string) ClientOption {
+	return func(cc *clientConfig) {
+		cc.token = token
+	}
+}
+
+// WithClientDebugMode returns a ClientOption that specifies debug mode.
+// If not set, the default is `false`
+func WithClientDebugMode(dm bool) ClientOption {
+	return func(cc *clientConfig) {
+		cc.debug = dm
+	}
+}
+
+// client implements objectstore.ObjectStore against HTTP gateway
+type client struct {
+	baseURL string
+	cfg     *clientConfig
+}
+
+var _ fsstore.StandbySource = (*client)(nil)
+
+// ConfigClient defines the functions clients need to tune gateway store at runtime, see `fsstore.ConfigUpdater`.
+// Clients created via `NewClient` implement it.
+type ConfigClient interface {
+	UpdateConfig(context.Context, fsstore.ConfigChanges) (fsstore.RuntimeConfig, error)
+}
+
+var _ ConfigClient = (*client)(nil)
+
+var _ fsstore.NameResolver = (*client)(nil)
+
+// NewClient creates objectstore.ObjectStore instance talking to gateway at baseURL via configuration options.
+func NewClient(baseURL string, opts ...ClientOption) objectstore.ObjectStore {
+	cfg := &clientConfig{retries: _defRetries, backoff: _defRetryBackoff}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if cfg.httpClient == nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.MaxIdleConnsPerHost = 32
+		cfg.httpClient = &http.Client{Transport: transport}
+	}
+	return &client{baseURL: strings.TrimRight(baseURL, "/"), cfg: cfg}
+}
+
+// do - sends request built by build, retrying on transport errors and server failures.
+// Request bodies are replayed only when they are seekable, otherwise request is not retried.
+func (c *client) do(ctx context.Context, build func() (*http.Request, error), replayable bool) (*http.Response, error) {
+	backoff := c.cfg.backoff
+	for attempt := 0; ; attempt++ {
+		req, err := build()
+		if err != nil {
+			return nil, err
+		}
+		meta := fsstore.RequestMetaFromContext(ctx)
+		if len(meta.RequestID) > 0 {
+			req.Header.Set(fsstore.RequestIDHeader, meta.RequestID)
+		}
+		if len(meta.TraceID) > 0 {
+			req.Header.Set(fsstore.TraceIDHeader, meta.TraceID)
+		}
+		if len(c.cfg.token) > 0 {
+			req.Header.Set("Authorization", "Bearer "+c.cfg.token)
+		}
+		resp, err := c.cfg.httpClient.Do(req.WithContext(ctx))
+		// routes the gateway store does not support are not transient failures
+		retry := err != nil || (resp.StatusCode >= http.StatusInternalServerError && resp.StatusCode != http.StatusNotImplemented)
+		if !retry || !replayable || attempt >= c.cfg.retries {
+			return resp, err
+		}
+		if resp != nil {
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		if c.cfg.debug {
+			log.Printf("debug: retrying gateway request: %s %s, attempt %d\n", req.Method, req.URL, attempt+1)
+		}
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil, contextError(ctx)
+		}
+		backoff *= 2
+	}
+}
+
+// CreateObject - creates object on gateway with specified data (aka content); seekable readers are replayed from
+// offset they are handed at
+func (c *client) CreateObject(ctx context.Context, reader io.Reader) (cid.Cid, error) {
+	seeker, replayable := reader.(io.Seeker)
+	var start int64
+	if replayable {
+		offset, err := seeker.Seek(0, io.SeekCurrent)
+		start, replayable = offset, err == nil
+	}
+	resp, err := c.do(ctx, func() (*http.Request, error) {
+		if replayable {
+			if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+				return nil, err
+			}
+		}
+		return http.NewRequest(http.MethodPost, c.baseURL+_objectsPath, ioutil.NopCloser(reader))
+	}, replayable)
+	if err != nil {
+		return cid.Undef, requestError(ctx, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return cid.Undef, responseError(resp)
+	}
+	created := createResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		return cid.Undef, err
+	}
+	return cid.Decode(created.Cid)
+}
+
+// ReadObject - reads object on gateway with specified cid (aka content identifier)
+func (c *client) ReadObject(ctx context.Context, id cid.Cid) ([]byte, error) {
+	resp, err := c.do(ctx, func() (*http.Request, error) {
+		return http.NewRequest(http.MethodGet, c.objectURL(id), nil)
+	}, true)
+	if err != nil {
+		return nil, requestError(ctx, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, responseError(resp)
+	}
+	buf := bytes.Buffer{}
+	if _, err := buf.ReadFrom(resp.Body); err != nil {
+		return nil, objectstore.ErrObjectReadingFailed
+	}
+	return buf.Bytes(), nil
+}
+
+// HasObject - checks whether object exists on gateway with specified cid (aka content identifier)
+func (c *client) HasObject(ctx context.Context, id cid.Cid) bool {
+	resp, err := c.do(ctx, func() (*http.Request, error) {
+		return http.NewRequest(http.MethodHead, c.objectURL(id), nil)
+	}, true)
+	if err != nil {
+		if c.cfg.debug {
+			log.Printf("debug: has object request failed: %s, %v\n", id, err)
+		}
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode == http.StatusOK
+}
+
+// ListObject - streams objects listed by gateway
+func (c *client) ListObject(ctx context.Context) <-chan objectstore.ListObjectEvent {
+	ch := make(chan objectstore.ListObjectEvent)
+	go func() {
+		defer close(ch)
+		resp, err := c.do(ctx, func() (*http.Request, error) {
+			return http.NewRequest(http.MethodGet, c.baseURL+_objectsPath, nil)
+		}, true)
+		if err != nil {
+			send(ctx, ch, objectstore.ListObjectEvent{Error: requestError(ctx, err)})
+			return
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			send(ctx, ch, objectstore.ListObjectEvent{Error: responseError(resp)})
+			return
+		}
+		decoder := json.NewDecoder(resp.Body)
+		for {
+			wire := listEvent{}
+			if err := decoder.Decode(&wire); err != nil {
+				if err != io.EOF {
+					send(ctx, ch, objectstore.ListObjectEvent{Error: requestError(ctx, err)})
+				}
+				return
+			}
+			event := objectstore.ListObjectEvent{Object: wire.Object}
+			if len(wire.Error) > 0 {
+				event.Error = errors.New(wire.Error)
+			}
+			if !send(ctx, ch, event) {
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+// send - sends event to ch unless ctx is done first, so listing goroutine never outlives an abandoned listing
+func send(ctx context.Context, ch chan<- objectstore.ListObjectEvent, event objectstore.ListObjectEvent) bool {
+	select {
+	case ch <- event:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// ReadJournal - returns up to limit journal entries of gateway store whose sequence is greater than since
+func (c *client) ReadJournal(ctx context.Context, since uint64, limit int) ([]fsstore.JournalEntry, error) {
+	url := fmt.Sprintf("%s%s?since=%d&limit=%d", c.baseURL, _journalPath, since, limit)
+	resp, err := c.do(ctx, func() (*http.Request, error) {
+		return http.NewRequest(http.MethodGet, url, nil)
+	}, true)
+	if err != nil {
+		return nil, requestError(ctx, err)
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotImplemented:
+		return nil, fsstore.ErrJournalDisabled
+	default:
+		return nil, responseError(resp)
+	}
+	entries := []fsstore.JournalEntry{}
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		wire := journalEvent{}
+		if err := decoder.Decode(&wire); err != nil {
+			if err != io.EOF {
+				return nil, requestError(ctx, err)
+			}
+			return entries, nil
+		}
+		id, err := cid.Decode(wire.Cid)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, fsstore.JournalEntry{Seq: wire.Seq, Op: wire.Op, Cid: id, Size: wire.Size, Time: wire.Time})
+	}
+}
+
+// UpdateConfig - applies changes to runtime configuration of gateway store, and returns resulting configuration;
+// empty changes only return current configuration
+func (c *client) UpdateConfig(ctx context.Context, changes fsstore.ConfigChanges) (fsstore.RuntimeConfig, error) {
+	body, err := json.Marshal(changes)
+	if err != nil {
+		return fsstore.RuntimeConfig{}, err
+	}
+	resp, err := c.do(ctx, func() (*http.Request, error) {
+		req, err := http.NewRequest(http.MethodPatch, c.baseURL+_configPath, bytes.NewReader(body))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		return req, err
+	}, true)
+	if err != nil {
+		return fsstore.RuntimeConfig{}, requestError(ctx, err)
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusBadRequest:
+		return fsstore.RuntimeConfig{}, fsstore.ErrInvalidConfigChange
+	case http.StatusUnauthorized:
+		return fsstore.RuntimeConfig{}, ErrUnauthenticated
+	case http.StatusNotImplemented:
+		return fsstore.RuntimeConfig{}, ErrConfigUnsupported
+	default:
+		return fsstore.RuntimeConfig{}, responseError(resp)
+	}
+	current := fsstore.RuntimeConfig{}
+	if err := json.NewDecoder(resp.Body).Decode(&current); err != nil {
+		return fsstore.RuntimeConfig{}, err
+	}
+	return current, nil
+}
+
+// ResolveName - returns cid of object named by alias on gateway, following redirect of name route (or reading
+// location of object streamed by it)
+func (c *client) ResolveName(ctx context.Context, alias string) (cid.Cid, error) {
+	resp, err := c.do(ctx, func() (*http.Request, error) {
+		return http.NewRequest(http.MethodHead, c.baseURL+_namesPath+"/"+url.PathEscape(alias), nil)
+	}, true)
+	if err != nil {
+		return cid.Undef, requestError(ctx, err)
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return cid.Undef, fsstore.ErrNameNotExists
+	case http.StatusNotImplemented:
+		return cid.Undef, ErrNamesUnsupported
+	default:
+		return cid.Undef, responseError(resp)
+	}
+	location := resp.Header.Get("Content-Location")
+	if len(location) == 0 {
+		location = resp.Request.URL.Path
+	}
+	return cid.Decode(path.Base(location))
+}
+
+// objectURL - returns gateway url of object
+func (c *client) objectURL(id cid.Cid) string {
+	return c.baseURL + _objectsPath + "/" + id.String()
+}
+
+// requestError - maps failed request to objectstore errors when context is the cause
+func requestError(ctx context.Context, err error) error {
+	if ctxErr := contextError(ctx); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+// contextError - maps context errors to objectstore errors
+func contextError(ctx context.Context) error {
+	switch ctx.Err() {
+	case context.Canceled:
+		return objectstore.ErrOperationCancelled
+	case context.DeadlineExceeded:
+		return objectstore.ErrOperationDeadlineExceeded
+	default:
+		return nil
+	}
+}
+
+// responseError - maps unexpected gateway responses to objectstore errors
+func responseError(resp *http.Response) error {
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return objectstore.ErrObjectNotExists
+	case http.StatusForbidden:
+		return fsstore.ErrAccessDenied
+	case http.StatusConflict:
+		return fsstore.ErrStandbyReadOnly
+	case http.StatusLocked:
+		return fsstore.ErrMaintenance
+	case http.StatusUnavailableForLegalReasons:
+		return fsstore.ErrObjectBlocked
+	case http.StatusUnprocessableEntity:
+		// gateways answer rejections with error message carrying reason of content scanner
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		reason := strings.TrimPrefix(strings.TrimSpace(string(body)), fsstore.ErrObjectRejected.Error()+": ")
+		return &fsstore.RejectionError{Reason: reason}
+	default:
+		return fmt.Errorf("%w: %s", ErrUnexpectedStatus, resp.Status)
+	}
+}
@@ -0,0 +1,141 @@
+package httpstore
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	fsstore "github.com/igumus/go-objectstore-fs"
+	"github.com/ipfs/go-cid"
+)
+
+// _gzipEncoding handles the content coding gateway compresses responses with
+const _gzipEncoding = "gzip"
+
+// _identityEncoding handles the content coding of responses served decoded
+const _identityEncoding = "identity"
+
+// _compressMinSize handles the minimum size of responses gateway compresses, smaller ones gain too little
+const _compressMinSize = 1 << 10
+
+// _compressedTypes handles the media type prefixes of content compressed already, which gateway never compresses
+var _compressedTypes = []string{
+	"image/", "video/", "audio/", "font/woff",
+	"application/zip", "application/gzip", "application/x-gzip", "application/zstd", "application/x-bzip2",
+	"application/x-xz", "application/x-7z-compressed", "application/x-rar-compressed", "application/pdf",
+}
+
+// WithCompression returns a HandlerOption that specifies whether gateway compresses responses on the fly (with
+// `gzip`) for clients accepting it. Content compressed already, by its media type or as stored (see
+// `fsstore.Metadata`), and responses smaller than `1KiB` are served as is. If not set, the default is `false`
+func WithCompression(compress bool) HandlerOption {
+	return func(hc *handlerConfig) {
+		hc.compress = compress
+	}
+}
+
+// accepts - checks whether `Accept-Encoding` of request accepts content coding, by its quality value
+func accepts(r *http.Request, coding string) bool {
+	coding = strings.ToLower(coding)
+	q, wildcard := -1.0, -1.0
+	for _, item := range strings.Split(strings.Join(r.Header.Values("Accept-Encoding"), ","), ",") {
+		name, params := item, ""
+		if i := strings.Index(item, ";"); i >= 0 {
+			name, params = item[:i], item[i+1:]
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		value := 1.0
+		if params = strings.TrimSpace(params); strings.HasPrefix(params, "q=") {
+			if v, err := strconv.ParseFloat(params[2:], 64); err == nil {
+				value = v
+			}
+		}
+		switch {
+		case name == coding, name == "x-"+coding:
+			q = value
+		case name == "*":
+			wildcard = value
+		}
+	}
+	if q < 0 {
+		q = wildcard
+	}
+	return q > 0
+}
+
+// compressible - checks whether content of media type is worth compressing; content of unknown type is not
+func compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	if mediaType == "image/svg+xml" {
+		return true
+	}
+	for _, prefix := range _compressedTypes {
+		if strings.HasPrefix(mediaType, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+// compresses - checks whether gateway compresses response of size for request, when content is compressible
+func (h *handler) compresses(r *http.Request, size int64) bool {
+	return h.cfg.compress && size >= _compressMinSize && accepts(r, _gzipEncoding)
+}
+
+// decodable - checks whether gateway can decode content stored in content coding
+func decodable(coding string) bool {
+	switch strings.ToLower(coding) {
+	case "gzip", "x-gzip", "deflate":
+		return true
+	default:
+		return false
+	}
+}
+
+// decoder - returns reader decoding body stored in content coding, or `ErrEncodingNotAcceptable` for codings
+// gateway cannot decode
+func decoder(coding string, body io.Reader) (io.ReadCloser, error) {
+	switch strings.ToLower(coding) {
+	case "gzip", "x-gzip":
+		return gzip.NewReader(body)
+	case "deflate":
+		return zlib.NewReader(body)
+	default:
+		return nil, ErrEncodingNotAcceptable
+	}
+}
+
+// encodedETag - returns etag of representation of content in content coding
+func encodedETag(etag, coding string) string {
+	return strings.TrimSuffix(etag, `"`) + "-" + coding + `"`
+}
+
+// metadataOf - returns metadata of object, or nil when store keeps none
+func (h *handler) metadataOf(ctx context.Context, c cid.Cid) *fsstore.Metadata {
+	meta, ok := h.store.(fsstore.MetadataStore)
+	if !ok {
+		return nil
+	}
+	m, err := meta.GetMetadata(ctx, c)
+	if err != nil {
+		return nil
+	}
+	return m
+}
+
+// writeCompressed - writes body to response compressed with `gzip`
+func writeCompressed(w io.Writer, body io.Reader) error {
+	gz := gzip.NewWriter(w)
+	if _, err := io.Copy(gz, body); err != nil {
+		return err
+	}
+	return gz.Close()
+}
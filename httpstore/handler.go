@@ -0,0 +1,439 @@
+package httpstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	fsstore "github.com/igumus/go-objectstore-fs"
+	"github.com/igumus/go-objectstore-lib"
+	"github.com/ipfs/go-cid"
+)
+
+// _defNameTTL handles the default duration clients may cache resolution of names for
+const _defNameTTL = time.Minute
+
+// _defCacheControl handles the default cache control of cid addressed routes, as content of a cid never changes
+const _defCacheControl = "public, max-age=31536000, immutable"
+
+// Captures/Represents gateway handler configuration information
+type handlerConfig struct {
+	debug        bool
+	principal    func(*http.Request) (fsstore.Principal, bool)
+	tenant       func(*http.Request) (string, bool)
+	nameTTL      time.Duration
+	streamNames  bool
+	sites        bool
+	uploadWindow int
+	compress     bool
+	cacheControl string
+}
+
+// A HandlerOption sets options such as debug mode.
+type HandlerOption func(*handlerConfig)
+
+// WithHandlerDebugMode returns a HandlerOption that specifies debug mode.
+// If not set, the default is `false`
+func WithHandlerDebugMode(dm bool) HandlerOption {
+	return func(hc *handlerConfig) {
+		hc.debug = dm
+	}
+}
+
+// WithPrincipalFunc returns a HandlerOption that specifies how principal of request is determined (e.g. from
+// a verified token or client certificate); principal is attached to operation context, for an authorizer
+// configured via `fsstore.WithAuthorizer` to inspect. Requests without principal are served anonymously.
+func WithPrincipalFunc(fn func(*http.Request) (fsstore.Principal, bool)) HandlerOption {
+	return func(hc *handlerConfig) {
+		hc.principal = fn
+	}
+}
+
+// WithTenantFunc returns a HandlerOption that specifies how tenant of request is determined (e.g. from a header set
+// by an authenticating proxy); tenant is attached to operation context, so store accounts usage of request to it
+// (see `fsstore.WithUsageAccounting`). Requests without tenant are accounted to their principal.
+func WithTenantFunc(fn func(*http.Request) (string, bool)) HandlerOption {
+	return func(hc *handlerConfig) {
+		hc.tenant = fn
+	}
+}
+
+// WithNameTTL returns a HandlerOption that specifies how long clients may cache responses of name routes; names
+// move to other objects, so it is kept short. If not set, the default is `1m`
+func WithNameTTL(ttl time.Duration) HandlerOption {
+	return func(hc *handlerConfig) {
+		if ttl > 0 {
+			hc.nameTTL = ttl
+		}
+	}
+}
+
+// WithNameStreaming returns a HandlerOption that specifies whether name routes stream named object, rather than
+// redirecting to its object route. If not set, the default is `false`
+func WithNameStreaming(stream bool) HandlerOption {
+	return func(hc *handlerConfig) {
+		hc.streamNames = stream
+	}
+}
+
+// WithCacheControl returns a HandlerOption that specifies cache control of cid addressed responses (object and
+// site routes) of objects not hinting one in their metadata (see `fsstore.Metadata`). If not set, the default
+// is `public, max-age=31536000, immutable`
+func WithCacheControl(cc string) HandlerOption {
+	return func(hc *handlerConfig) {
+		if len(cc) > 0 {
+			hc.cacheControl = cc
+		}
+	}
+}
+
+// handler serves objectstore operations over HTTP
+type handler struct {
+	store objectstore.ObjectStore
+	cfg   *handlerConfig
+	mux   *http.ServeMux
+	// uploads serializes requests of same resumable upload
+	uploadLocks uploadLocks
+}
+
+// NewHandler creates HTTP gateway handler serving given objectstore via configuration options.
+func NewHandler(store objectstore.ObjectStore, opts ...HandlerOption) http.Handler {
+	cfg := &handlerConfig{nameTTL: _defNameTTL, uploadWindow: _defUploadWindow, cacheControl: _defCacheControl}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	h := &handler{store: store, cfg: cfg, mux: http.NewServeMux()}
+	h.mux.HandleFunc(_objectsPath, h.objects)
+	h.mux.HandleFunc(_objectsPath+"/", h.object)
+	h.mux.HandleFunc(_namesPath+"/", h.names)
+	if cfg.sites {
+		h.mux.HandleFunc(_sitesPath+"/", h.site)
+	}
+	h.mux.HandleFunc(_uploadsPath, h.uploads)
+	h.mux.HandleFunc(_uploadsPath+"/", h.upload)
+	h.mux.HandleFunc(_healthPath, h.health)
+	h.mux.HandleFunc(_journalPath, h.journal)
+	h.mux.HandleFunc(_configPath, h.config)
+	h.mux.HandleFunc(_jobsPath, h.jobs)
+	h.mux.HandleFunc(_jobsPath+"/", h.job)
+	h.mux.HandleFunc(_blocklistPath, h.blocklist)
+	h.mux.HandleFunc(_blocklistPath+"/", h.blocked)
+	h.mux.HandleFunc(_usagePath, h.usage)
+	h.mux.HandleFunc(_adminPath+"/", h.admin)
+	return h
+}
+
+// ServeHTTP - dispatches request to gateway routes
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.cfg.debug {
+		log.Printf("debug: gateway request: %s %s\n", r.Method, r.URL.Path)
+	}
+	if h.cfg.principal != nil {
+		if p, ok := h.cfg.principal(r); ok {
+			r = r.WithContext(fsstore.ContextWithPrincipal(r.Context(), p))
+		}
+	}
+	if h.cfg.tenant != nil {
+		if t, ok := h.cfg.tenant(r); ok {
+			r = r.WithContext(fsstore.ContextWithTenant(r.Context(), t))
+		}
+	}
+	meta := fsstore.RequestMeta{RequestID: r.Header.Get(fsstore.RequestIDHeader), TraceID: r.Header.Get(fsstore.TraceIDHeader)}
+	if len(meta.RequestID) > 0 || len(meta.TraceID) > 0 {
+		r = r.WithContext(fsstore.ContextWithRequestMeta(r.Context(), meta))
+	}
+	h.mux.ServeHTTP(w, r)
+}
+
+// objects - serves listing and creation of objects
+func (h *handler) objects(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.list(w, r)
+	case http.MethodPost:
+		h.create(w, r)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+// object - serves reading and existence check of object, cacheable as its metadata hints or otherwise forever
+func (h *handler) object(w http.ResponseWriter, r *http.Request) {
+	c, err := cid.Decode(strings.TrimPrefix(r.URL.Path, _objectsPath+"/"))
+	if err != nil {
+		http.Error(w, "invalid cid", http.StatusBadRequest)
+		return
+	}
+	h.serveObject(w, r, c, h.cacheOf(h.metadataOf(r.Context(), c)))
+}
+
+// cacheOf - returns cache control of cid addressed responses of object with metadata (possibly nil), hinted by
+// metadata or otherwise gateway default
+func (h *handler) cacheOf(meta *fsstore.Metadata) string {
+	if meta != nil && len(meta.CacheControl) > 0 {
+		return meta.CacheControl
+	}
+	return h.cfg.cacheControl
+}
+
+// names - resolves alias of path (see `fsstore.NameResolver`) into object, redirecting to its object route, or
+// streaming it when gateway streams names; responses are cacheable for name ttl only, as names move
+func (h *handler) names(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	resolver, ok := h.store.(fsstore.NameResolver)
+	if !ok {
+		http.Error(w, ErrNamesUnsupported.Error(), http.StatusNotImplemented)
+		return
+	}
+	c, err := resolver.ResolveName(r.Context(), strings.TrimPrefix(r.URL.Path, _namesPath+"/"))
+	if err != nil {
+		http.Error(w, err.Error(), statusOf(err))
+		return
+	}
+	cache := h.nameCache()
+	location := _objectsPath + "/" + c.String()
+	if !h.cfg.streamNames {
+		w.Header().Set("Cache-Control", cache)
+		http.Redirect(w, r, location, http.StatusFound)
+		return
+	}
+	w.Header().Set("Content-Location", location)
+	h.serveObject(w, r, c, cache)
+}
+
+// nameCache - returns cache control of responses resolving names, cacheable for name ttl
+func (h *handler) nameCache() string {
+	return fmt.Sprintf("public, max-age=%d", int64(h.cfg.nameTTL/time.Second))
+}
+
+// serveObject - serves reading (or existence check, for `HEAD`) of object with given cache control, tagged
+// by its cid so revalidating clients are answered `304 Not Modified`
+func (h *handler) serveObject(w http.ResponseWriter, r *http.Request, c cid.Cid, cache string) {
+	etag := `"` + c.String() + `"`
+	etags := []string{etag}
+	if h.cfg.compress {
+		w.Header().Add("Vary", "Accept-Encoding")
+		etags = append(etags, encodedETag(etag, _gzipEncoding))
+	}
+	switch r.Method {
+	case http.MethodHead:
+		if !h.store.HasObject(r.Context(), c) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Cache-Control", cache)
+		w.Header().Set("ETag", etag)
+		w.WriteHeader(http.StatusOK)
+	case http.MethodGet:
+		if match := r.Header.Get("If-None-Match"); len(match) > 0 && h.store.HasObject(r.Context(), c) {
+			for _, tag := range etags {
+				if matchesETag(match, tag) {
+					w.Header().Set("Cache-Control", cache)
+					w.Header().Set("ETag", tag)
+					w.WriteHeader(http.StatusNotModified)
+					return
+				}
+			}
+		}
+		data, err := h.store.ReadObject(r.Context(), c)
+		if err != nil {
+			http.Error(w, err.Error(), statusOf(err))
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Cache-Control", cache)
+		// compression is transport only: clients decode object as created
+		if h.compresses(r, int64(len(data))) && compressible(h.objectType(r.Context(), c, data)) {
+			w.Header().Set("Content-Encoding", _gzipEncoding)
+			w.Header().Set("ETag", encodedETag(etag, _gzipEncoding))
+			writeCompressed(w, bytes.NewReader(data))
+			return
+		}
+		w.Header().Set("ETag", etag)
+		w.Write(data)
+	default:
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+// objectType - returns media type of object content, of its metadata or otherwise sniffed; content of objects
+// stored pre-compressed is of unknown type
+func (h *handler) objectType(ctx context.Context, c cid.Cid, data []byte) string {
+	if meta := h.metadataOf(ctx, c); meta != nil {
+		if len(meta.ContentEncoding) > 0 {
+			return ""
+		}
+		if len(meta.ContentType) > 0 {
+			return meta.ContentType
+		}
+	}
+	return http.DetectContentType(data)
+}
+
+// matchesETag - checks whether `If-None-Match` header value lists etag (or is `*`)
+func matchesETag(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// list - streams objects as newline delimited json events
+func (h *handler) list(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	flusher, _ := w.(http.Flusher)
+	encoder := json.NewEncoder(w)
+	for event := range h.store.ListObject(r.Context()) {
+		wire := listEvent{Object: event.Object}
+		if event.Error != nil {
+			wire.Error = event.Error.Error()
+		}
+		if err := encoder.Encode(wire); err != nil {
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
+
+// create - creates object from request body, or an object of every part of `multipart/form-data` bodies
+func (h *handler) create(w http.ResponseWriter, r *http.Request) {
+	if isMultipart(r) {
+		h.createParts(w, r)
+		return
+	}
+	c, err := h.store.CreateObject(r.Context(), r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), statusOf(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", _objectsPath+"/"+c.String())
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(createResponse{Cid: c.String()})
+}
+
+// health - reports store health, degraded when last maintenance run failed or found corrupted objects
+func (h *handler) health(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	resp := healthResponse{Status: "ok"}
+	status := http.StatusOK
+	if m, ok := h.store.(fsstore.Maintainer); ok {
+		ms := m.MaintenanceStatus()
+		resp.Maintenance = &ms
+		if ms.Err != "" || (ms.Verify != nil && len(ms.Verify.Corrupt) > 0) {
+			resp.Status = "degraded"
+			status = http.StatusServiceUnavailable
+		}
+	}
+	if m, ok := h.store.(fsstore.DataDirMonitor); ok {
+		resp.DataDirs = m.DataDirHealth()
+		for _, dir := range resp.DataDirs {
+			if !dir.Healthy() {
+				resp.Status = "degraded"
+				status = http.StatusServiceUnavailable
+			}
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// journal - streams journal entries after `since` sequence as newline delimited json, up to `limit` entries
+func (h *handler) journal(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	reader, ok := h.store.(fsstore.JournalReader)
+	if !ok {
+		http.Error(w, fsstore.ErrJournalDisabled.Error(), http.StatusNotImplemented)
+		return
+	}
+	query := r.URL.Query()
+	var since uint64
+	var limit int
+	var err error
+	if v := query.Get("since"); len(v) > 0 {
+		if since, err = strconv.ParseUint(v, 10, 64); err != nil {
+			http.Error(w, "invalid since", http.StatusBadRequest)
+			return
+		}
+	}
+	if v := query.Get("limit"); len(v) > 0 {
+		if limit, err = strconv.Atoi(v); err != nil {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+	entries, err := reader.ReadJournal(r.Context(), since, limit)
+	if err != nil {
+		http.Error(w, err.Error(), statusOf(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	encoder := json.NewEncoder(w)
+	for _, entry := range entries {
+		wire := journalEvent{Seq: entry.Seq, Op: entry.Op, Cid: entry.Cid.String(), Size: entry.Size, Time: entry.Time}
+		if err := encoder.Encode(wire); err != nil {
+			return
+		}
+	}
+}
+
+// config - reports runtime configuration of store, or applies changes to it; reporting applies no changes, so
+// it is authorized as changes are
+func (h *handler) config(w http.ResponseWriter, r *http.Request) {
+	if !h.authenticated(w, r) {
+		return
+	}
+	updater, ok := h.store.(fsstore.ConfigUpdater)
+	if !ok {
+		http.Error(w, ErrConfigUnsupported.Error(), http.StatusNotImplemented)
+		return
+	}
+	changes := fsstore.ConfigChanges{}
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPatch:
+		decoder := json.NewDecoder(r.Body)
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&changes); err != nil {
+			http.Error(w, "invalid configuration changes", http.StatusBadRequest)
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, PATCH")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	current, err := updater.UpdateConfig(r.Context(), changes)
+	if err != nil {
+		http.Error(w, err.Error(), statusOf(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(current)
+}
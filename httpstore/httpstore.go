@@ -0,0 +1,190 @@
+// Package httpstore serves an objectstore over HTTP, and provides a client implementing
+// objectstore.ObjectStore against such a gateway, so applications can switch between an
+// embedded fsstore and a remote one via configuration alone.
+//
+// Gateway routes:
+//
+//	GET  /objects        lists objects as newline delimited json events
+//	POST /objects        creates object from request body, responds json `{"cid": ...}`; `multipart/form-data`
+//	                     bodies create an object of every part, streamed (see `WithUploadWindow`), and respond
+//	                     json array `[{"part", "filename", "cid"}]`
+//	GET  /objects/{cid}  reads object
+//	HEAD /objects/{cid}  checks object existence
+//	GET  /names/{alias}  resolves alias to object (see `fsstore.NameResolver`), redirecting to its object route,
+//	                     or streaming it (see `WithNameStreaming`)
+//	HEAD /names/{alias}  checks alias resolves
+//	GET  /sites/{cid}/{path} serves file of tree manifest as static website, directories via their `index.html`
+//	                     and sites addressed by name redirected to current cid (see `WithSiteHosting`)
+//	POST /uploads        starts resumable upload (see `fsstore.ResumableUploader`), located at its route
+//	HEAD /uploads/{id}   reports count of bytes received so far as `Upload-Offset` header
+//	PATCH /uploads/{id}  appends request body at `Upload-Offset`, which must equal bytes received so far
+//	                     (`409 Conflict` otherwise); bytes received before an interruption are kept
+//	PUT  /uploads/{id}   commits upload as object, responds json `{"cid": ...}`
+//	DELETE /uploads/{id} aborts upload
+//	GET  /health         reports health, with last maintenance run when store is scheduling one, and health
+//	                     of data directories; degraded when one is read-only or low on inodes
+//	GET  /journal        streams journal entries after `since` (up to `limit`) as newline delimited json,
+//	                     so a standby store (see `fsstore.WithStandby`) can follow gateway store
+//	GET  /admin/config   reports runtime configuration of store, see `fsstore.ConfigUpdater`
+//	PATCH /admin/config  applies json `fsstore.ConfigChanges` to store, responds resulting configuration
+//	POST /admin/gc       starts garbage collection job, retention given as json `{"keepLast", "keepWithin", "dryRun"}`
+//	POST /admin/verify   starts verification job
+//	POST /admin/rebalance starts rebalancing job, resumed by store when interrupted by its shutdown
+//	POST /admin/compact  starts journal compaction job
+//	POST /admin/prune    starts job pruning empty shard directories, see `fsstore.DirPruner`
+//	POST /admin/snapshot starts bucket snapshot job
+//	GET  /admin/jobs     lists queued, running and recently finished jobs of store, see `fsstore.JobManager`
+//	GET  /admin/jobs/{id} reports state, progress and result of job started by routes above, which respond
+//	                     `202 Accepted` with job located at its route
+//	DELETE /admin/jobs/{id} cancels job
+//	GET  /admin/blocklist lists objects blocked from being read or written, see `fsstore.Blocklist`
+//	PUT  /admin/blocklist/{cid} blocks object, reason given as json `{"reason"}`
+//	DELETE /admin/blocklist/{cid} unblocks object
+//	GET  /admin/usage    reports bandwidth used by tenants during days `from` to `to` (`2006-01-02`), see `fsstore.UsageReporter`
+//
+// Responses are compressed on the fly for clients accepting `gzip` when gateway compresses (see `WithCompression`);
+// object routes serve objects as created, while site routes serve files stored pre-compressed (see
+// `fsstore.Metadata`) as is to clients accepting their coding, and decoded to others.
+//
+// Object and site routes are cacheable forever (see `WithCacheControl`) unless object metadata hints otherwise,
+// tagged by cid of object; name routes are cacheable for a short ttl only (see `WithNameTTL`), as names move to
+// other objects.
+//
+// Admin routes require an authenticated principal (see `WithPrincipalFunc`), and respond `401 Unauthorized`
+// otherwise; store authorizes principal for admin operations.
+//
+// Request and trace ids (`X-Request-Id`, `X-Trace-Id` headers) sent by client are attached to request
+// context via `fsstore.ContextWithRequestMeta`, so gateway store logs and audits them.
+package httpstore
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	fsstore "github.com/igumus/go-objectstore-fs"
+	"github.com/igumus/go-objectstore-lib"
+)
+
+// ErrUnexpectedStatus is return, when gateway responds with an unexpected status code.
+var ErrUnexpectedStatus = errors.New("httpstore: unexpected response status")
+
+// ErrUnauthenticated is return, when admin route is requested without an authenticated principal.
+var ErrUnauthenticated = errors.New("httpstore: unauthenticated")
+
+// ErrJobUnsupported is return, when gateway store does not support operation of admin job.
+var ErrJobUnsupported = errors.New("httpstore: job operation not supported")
+
+// ErrNamesUnsupported is return, when gateway store does not support resolving names of objects.
+var ErrNamesUnsupported = errors.New("httpstore: names not supported")
+
+// ErrSitesUnsupported is return, when gateway store does not support resolving paths of tree manifests.
+var ErrSitesUnsupported = errors.New("httpstore: sites not supported")
+
+// ErrPartTooLarge is return, when part of multipart upload exceeds upload window of a store not supporting uploads.
+var ErrPartTooLarge = errors.New("httpstore: multipart part too large")
+
+// ErrUploadsUnsupported is return, when gateway store does not support resumable uploads.
+var ErrUploadsUnsupported = errors.New("httpstore: resumable uploads not supported")
+
+// ErrUploadOffsetMismatch is return, when resumable upload is appended to at an offset other than its size, e.g.
+// after another client appended to it.
+var ErrUploadOffsetMismatch = errors.New("httpstore: upload offset mismatch")
+
+// ErrEncodingNotAcceptable is return, when content is stored in a coding client does not accept, and gateway
+// cannot decode.
+var ErrEncodingNotAcceptable = errors.New("httpstore: content encoding not acceptable")
+
+// ErrConfigUnsupported is return, when gateway store does not support runtime configuration changes.
+var ErrConfigUnsupported = errors.New("httpstore: runtime configuration not supported")
+
+// _objectsPath handles the route prefix of object operations
+const _objectsPath = "/objects"
+
+// _namesPath handles the route prefix of named objects
+const _namesPath = "/names"
+
+// _uploadsPath handles the route prefix of resumable uploads
+const _uploadsPath = "/uploads"
+
+// _healthPath handles the route of health endpoint
+const _healthPath = "/health"
+
+// _journalPath handles the route of journal endpoint
+const _journalPath = "/journal"
+
+// _adminPath handles the route prefix of admin operations
+const _adminPath = "/admin"
+
+// _configPath handles the route of runtime configuration endpoint
+const _configPath = _adminPath + "/config"
+
+// _jobsPath handles the route prefix of admin jobs
+const _jobsPath = _adminPath + "/jobs"
+
+// _blocklistPath handles the route prefix of deny-list of store
+const _blocklistPath = _adminPath + "/blocklist"
+
+// _usagePath handles the route of usage of tenants of store
+const _usagePath = _adminPath + "/usage"
+
+// listEvent captures wire format of a listed object
+type listEvent struct {
+	Object string `json:"object,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// healthResponse captures wire format of health report
+type healthResponse struct {
+	Status      string                     `json:"status"`
+	Maintenance *fsstore.MaintenanceStatus `json:"maintenance,omitempty"`
+	DataDirs    []fsstore.DataDirHealth    `json:"dataDirs,omitempty"`
+}
+
+// journalEvent captures wire format of journal entry
+type journalEvent struct {
+	Seq  uint64            `json:"seq"`
+	Op   fsstore.JournalOp `json:"op"`
+	Cid  string            `json:"cid"`
+	Size int64             `json:"size"`
+	Time time.Time         `json:"time"`
+}
+
+// createResponse captures wire format of created object
+type createResponse struct {
+	Cid string `json:"cid"`
+}
+
+// statusOf - maps objectstore errors to HTTP status codes
+func statusOf(err error) int {
+	switch {
+	case errors.Is(err, objectstore.ErrObjectNotExists), errors.Is(err, fsstore.ErrJobNotExists), errors.Is(err, fsstore.ErrNameNotExists),
+		errors.Is(err, fsstore.ErrPathNotExists), errors.Is(err, fsstore.ErrNotTreeManifest), errors.Is(err, fsstore.ErrUploadNotExists),
+		errors.Is(err, fsstore.ErrUploadClosed):
+		return http.StatusNotFound
+	case errors.Is(err, fsstore.ErrAccessDenied):
+		return http.StatusForbidden
+	case errors.Is(err, fsstore.ErrStandbyReadOnly):
+		return http.StatusConflict
+	case errors.Is(err, fsstore.ErrMaintenance):
+		return http.StatusLocked
+	case errors.Is(err, fsstore.ErrObjectBlocked):
+		return http.StatusUnavailableForLegalReasons
+	case errors.Is(err, fsstore.ErrObjectRejected):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, fsstore.ErrInvalidConfigChange), errors.Is(err, fsstore.ErrInvalidBlockedCID):
+		return http.StatusBadRequest
+	case errors.Is(err, fsstore.ErrInvalidRange):
+		return http.StatusRequestedRangeNotSatisfiable
+	case errors.Is(err, ErrEncodingNotAcceptable):
+		return http.StatusNotAcceptable
+	case errors.Is(err, fsstore.ErrJournalDisabled), errors.Is(err, fsstore.ErrUsageDisabled):
+		return http.StatusNotImplemented
+	case errors.Is(err, objectstore.ErrOperationCancelled), errors.Is(err, fsstore.ErrStoreClosed), errors.Is(err, fsstore.ErrChaosInjected):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, objectstore.ErrOperationDeadlineExceeded):
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,132 @@
+package httpstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+
+	fsstore "github.com/igumus/go-objectstore-fs"
+	"github.com/ipfs/go-cid"
+)
+
+// _defUploadWindow handles the default count of bytes of a multipart upload part held in memory
+const _defUploadWindow = 8 << 20
+
+// WithUploadWindow returns a HandlerOption that specifies how many bytes of a part of multipart uploads are held
+// in memory. Parts fitting window are created as objects right away; larger parts are streamed into a pending
+// upload (see `fsstore.ResumableUploader`), or rejected when store does not support those. If not set, the
+// default is `8MiB`
+func WithUploadWindow(n int) HandlerOption {
+	return func(hc *handlerConfig) {
+		if n > 0 {
+			hc.uploadWindow = n
+		}
+	}
+}
+
+// partResponse captures wire format of an object created from part of multipart upload
+type partResponse struct {
+	Part     string `json:"part"`
+	Filename string `json:"filename,omitempty"`
+	Cid      string `json:"cid"`
+}
+
+// isMultipart - checks whether request body is `multipart/form-data`
+func isMultipart(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == "multipart/form-data"
+}
+
+// createParts - creates every part of multipart request body as object in order, streaming parts without
+// buffering more than upload window, and responds json array of parts with their cids
+func (h *handler) createParts(w http.ResponseWriter, r *http.Request) {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		http.Error(w, "invalid multipart body", http.StatusBadRequest)
+		return
+	}
+	created := []partResponse{}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			http.Error(w, "invalid multipart body", http.StatusBadRequest)
+			return
+		}
+		c, status, err := h.createPart(r.Context(), part)
+		part.Close()
+		if err != nil {
+			http.Error(w, err.Error(), status)
+			return
+		}
+		created = append(created, partResponse{Part: part.FormName(), Filename: part.FileName(), Cid: c.String()})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+// createPart - creates part as object, via a pending upload when part exceeds upload window; returns status
+// of failure along with error
+func (h *handler) createPart(ctx context.Context, part *multipart.Part) (cid.Cid, int, error) {
+	window := make([]byte, h.cfg.uploadWindow+1)
+	n, err := io.ReadFull(part, window)
+	switch err {
+	case io.EOF, io.ErrUnexpectedEOF:
+		c, err := h.store.CreateObject(ctx, bytes.NewReader(window[:n]))
+		if err != nil {
+			return cid.Undef, statusOf(err), err
+		}
+		return c, 0, nil
+	case nil:
+	default:
+		return cid.Undef, http.StatusBadRequest, err
+	}
+
+	uploader, ok := h.store.(fsstore.ResumableUploader)
+	if !ok {
+		return cid.Undef, http.StatusRequestEntityTooLarge, ErrPartTooLarge
+	}
+	upload, err := uploader.NewUpload(ctx)
+	if err != nil {
+		return cid.Undef, statusOf(err), err
+	}
+	if _, err := upload.Write(window[:n]); err != nil {
+		upload.Abort()
+		return cid.Undef, statusOf(err), err
+	}
+	body := &partReader{part: part}
+	if _, err := io.Copy(upload, body); err != nil {
+		upload.Abort()
+		if body.err != nil {
+			return cid.Undef, http.StatusBadRequest, err
+		}
+		return cid.Undef, statusOf(err), err
+	}
+	c, err := upload.Commit()
+	if err != nil {
+		return cid.Undef, statusOf(err), err
+	}
+	return c, 0, nil
+}
+
+// partReader reads part of multipart upload, keeping read failure apart from failures of store
+type partReader struct {
+	part io.Reader
+	err  error
+}
+
+// Read - reads from part, recording failure
+func (p *partReader) Read(b []byte) (int, error) {
+	n, err := p.part.Read(b)
+	if err != nil && err != io.EOF {
+		p.err = err
+	}
+	return n, err
+}
@@ -0,0 +1,196 @@
+package httpstore
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	fsstore "github.com/igumus/go-objectstore-fs"
+	"github.com/ipfs/go-cid"
+)
+
+// _sitesPath handles the route prefix of static websites, see `WithSiteHosting`
+const _sitesPath = "/sites"
+
+// _indexFile handles the file name served for directories of static websites
+const _indexFile = "index.html"
+
+// WithSiteHosting returns a HandlerOption that specifies whether gateway serves tree manifests (see
+// `fsstore.WithIngestTree`) as static websites under `/sites/{cid}/`, making store an origin of versioned
+// sites. If not set, the default is `false`
+func WithSiteHosting(sites bool) HandlerOption {
+	return func(hc *handlerConfig) {
+		hc.sites = sites
+	}
+}
+
+// site - serves file of static website addressed by tree manifest cid and path, directories via their
+// `index.html`; sites addressed by name (see `fsstore.NameResolver`) are redirected to their current cid
+func (h *handler) site(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	resolver, ok := h.store.(fsstore.TreeResolver)
+	if !ok {
+		http.Error(w, ErrSitesUnsupported.Error(), http.StatusNotImplemented)
+		return
+	}
+	rest := strings.TrimPrefix(r.URL.Path, _sitesPath+"/")
+	root, p := rest, ""
+	if i := strings.Index(rest, "/"); i >= 0 {
+		root, p = rest[:i], rest[i:]
+	}
+	c, err := cid.Decode(root)
+	if err != nil {
+		h.siteName(w, r, root, p)
+		return
+	}
+	if len(p) == 0 {
+		// relative links of root document resolve against site directory only
+		http.Redirect(w, r, _sitesPath+"/"+root+"/", http.StatusMovedPermanently)
+		return
+	}
+
+	entry, err := resolver.ResolvePath(r.Context(), c, p)
+	if err == nil && entry.Dir {
+		if !strings.HasSuffix(p, "/") {
+			http.Redirect(w, r, r.URL.Path+"/", http.StatusMovedPermanently)
+			return
+		}
+		p = path.Join(p, _indexFile)
+		entry, err = resolver.ResolvePath(r.Context(), c, p)
+		if err == nil && entry.Dir {
+			err = fsstore.ErrPathNotExists
+		}
+	}
+	if err != nil {
+		http.Error(w, err.Error(), statusOf(err))
+		return
+	}
+	h.serveSiteFile(w, r, entry, p)
+}
+
+// siteName - redirects site addressed by name to its current cid, cacheable for name ttl only
+func (h *handler) siteName(w http.ResponseWriter, r *http.Request, name, p string) {
+	names, ok := h.store.(fsstore.NameResolver)
+	if !ok {
+		http.Error(w, "invalid cid", http.StatusBadRequest)
+		return
+	}
+	c, err := names.ResolveName(r.Context(), name)
+	if err != nil {
+		http.Error(w, err.Error(), statusOf(err))
+		return
+	}
+	if len(p) == 0 {
+		p = "/"
+	}
+	location := _sitesPath + "/" + c.String() + p
+	if len(r.URL.RawQuery) > 0 {
+		location += "?" + r.URL.RawQuery
+	}
+	w.Header().Set("Cache-Control", h.nameCache())
+	http.Redirect(w, r, location, http.StatusFound)
+}
+
+// serveSiteFile - serves file of static website, cacheable as its metadata hints or otherwise forever, with
+// content type of its metadata or otherwise of its extension; chunked files are streamed chunk by chunk. Files
+// stored pre-compressed are served as is to clients accepting their coding and decoded for others, and
+// compressible files are compressed for gateways compressing responses (see `WithCompression`)
+func (h *handler) serveSiteFile(w http.ResponseWriter, r *http.Request, entry fsstore.TreeEntry, p string) {
+	contentType, stored := "", ""
+	meta := h.metadataOf(r.Context(), entry.Cid)
+	if meta != nil {
+		contentType, stored = meta.ContentType, meta.ContentEncoding
+	}
+	if len(contentType) == 0 {
+		contentType = mime.TypeByExtension(path.Ext(p))
+	}
+	etag := `"` + entry.Cid.String() + `"`
+	coding, decode, compress := "", false, false
+	switch {
+	case len(stored) > 0 && accepts(r, stored):
+		coding = stored
+	case len(stored) > 0 && !decodable(stored):
+		http.Error(w, ErrEncodingNotAcceptable.Error(), http.StatusNotAcceptable)
+		return
+	case len(stored) > 0:
+		decode, etag = true, encodedETag(etag, _identityEncoding)
+	case h.compresses(r, entry.Size) && compressible(contentType):
+		coding, compress, etag = _gzipEncoding, true, encodedETag(etag, _gzipEncoding)
+	}
+	cached := func() {
+		if len(contentType) > 0 {
+			w.Header().Set("Content-Type", contentType)
+		}
+		if len(stored) > 0 || h.cfg.compress {
+			w.Header().Add("Vary", "Accept-Encoding")
+		}
+		if len(coding) > 0 {
+			w.Header().Set("Content-Encoding", coding)
+		}
+		w.Header().Set("Cache-Control", h.cacheOf(meta))
+		w.Header().Set("ETag", etag)
+	}
+	if match := r.Header.Get("If-None-Match"); len(match) > 0 && matchesETag(match, etag) {
+		cached()
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	if r.Method == http.MethodHead {
+		cached()
+		if !decode && !compress {
+			w.Header().Set("Content-Length", strconv.FormatInt(entry.Size, 10))
+		}
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	var body io.Reader
+	if entry.Cid.Prefix().Codec == cid.Raw {
+		data, err := h.store.ReadObject(r.Context(), entry.Cid)
+		if err != nil {
+			http.Error(w, err.Error(), statusOf(err))
+			return
+		}
+		body = bytes.NewReader(data)
+	} else {
+		chunker, ok := h.store.(fsstore.Chunker)
+		if !ok {
+			http.Error(w, ErrSitesUnsupported.Error(), http.StatusNotImplemented)
+			return
+		}
+		reader, err := chunker.ReadChunked(r.Context(), entry.Cid)
+		if errors.Is(err, fsstore.ErrNotChunkManifest) {
+			err = fsstore.ErrPathNotExists
+		}
+		if err != nil {
+			http.Error(w, err.Error(), statusOf(err))
+			return
+		}
+		defer reader.Close()
+		body = reader
+	}
+	if decode {
+		decoded, err := decoder(stored, body)
+		if err != nil {
+			http.Error(w, err.Error(), statusOf(err))
+			return
+		}
+		defer decoded.Close()
+		body = decoded
+	}
+	cached()
+	if compress {
+		writeCompressed(w, body)
+		return
+	}
+	io.Copy(w, body)
+}
@@ -0,0 +1,315 @@
+package httpstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"hash/fnv"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	fsstore "github.com/igumus/go-objectstore-fs"
+	"github.com/ipfs/go-cid"
+)
+
+// _uploadOffsetHeader handles the header carrying count of bytes of a resumable upload received so far
+const _uploadOffsetHeader = "Upload-Offset"
+
+// _uploadLocks handles the count of locks appends to resumable uploads are serialized by
+const _uploadLocks = 64
+
+// uploadLocks serializes requests of same resumable upload, so appends checking offset do not interleave
+type uploadLocks [_uploadLocks]sync.Mutex
+
+// lock - locks upload with id, returning unlock
+func (l *uploadLocks) lock(id string) func() {
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	mu := &l[h.Sum32()%_uploadLocks]
+	mu.Lock()
+	return mu.Unlock
+}
+
+// uploads - serves starting resumable uploads
+func (h *handler) uploads(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	uploader, ok := h.store.(fsstore.ResumableUploader)
+	if !ok {
+		http.Error(w, ErrUploadsUnsupported.Error(), http.StatusNotImplemented)
+		return
+	}
+	upload, err := uploader.NewUpload(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), statusOf(err))
+		return
+	}
+	defer upload.Close()
+	w.Header().Set("Location", _uploadsPath+"/"+upload.ID())
+	w.Header().Set(_uploadOffsetHeader, "0")
+	w.WriteHeader(http.StatusCreated)
+}
+
+// upload - serves resumable upload: `HEAD` reports its offset, `PATCH` appends body at offset, `PUT` commits
+// it as object and `DELETE` aborts it
+func (h *handler) upload(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodHead, http.MethodPatch, http.MethodPut, http.MethodDelete:
+	default:
+		w.Header().Set("Allow", "HEAD, PATCH, PUT, DELETE")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	uploader, ok := h.store.(fsstore.ResumableUploader)
+	if !ok {
+		http.Error(w, ErrUploadsUnsupported.Error(), http.StatusNotImplemented)
+		return
+	}
+	id := strings.TrimPrefix(r.URL.Path, _uploadsPath+"/")
+	defer h.uploadLocks.lock(id)()
+	upload, err := uploader.ResumeUpload(r.Context(), id)
+	if err != nil {
+		http.Error(w, err.Error(), statusOf(err))
+		return
+	}
+	defer upload.Close()
+	w.Header().Set("Cache-Control", "no-store")
+
+	switch r.Method {
+	case http.MethodHead:
+		w.Header().Set(_uploadOffsetHeader, strconv.FormatInt(upload.Size(), 10))
+		w.WriteHeader(http.StatusOK)
+	case http.MethodPatch:
+		offset, err := strconv.ParseInt(r.Header.Get(_uploadOffsetHeader), 10, 64)
+		if err != nil || offset < 0 {
+			http.Error(w, "invalid upload offset", http.StatusBadRequest)
+			return
+		}
+		if offset != upload.Size() {
+			w.Header().Set(_uploadOffsetHeader, strconv.FormatInt(upload.Size(), 10))
+			http.Error(w, ErrUploadOffsetMismatch.Error(), http.StatusConflict)
+			return
+		}
+		// bytes received before an interruption are kept, client resumes after them
+		body := &partReader{part: r.Body}
+		_, err = io.Copy(upload, body)
+		w.Header().Set(_uploadOffsetHeader, strconv.FormatInt(upload.Size(), 10))
+		if err != nil {
+			status := statusOf(err)
+			if body.err != nil {
+				status = http.StatusBadRequest
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodPut:
+		c, err := upload.Commit()
+		if err != nil {
+			http.Error(w, err.Error(), statusOf(err))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", _objectsPath+"/"+c.String())
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(createResponse{Cid: c.String()})
+	case http.MethodDelete:
+		if err := upload.Abort(); err != nil {
+			http.Error(w, err.Error(), statusOf(err))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+var _ fsstore.ResumableUploader = (*client)(nil)
+
+// remoteUpload is a resumable upload pending on gateway, every write appended by a request of its own
+type remoteUpload struct {
+	c      *client
+	ctx    context.Context
+	id     string
+	mu     sync.Mutex
+	size   int64
+	closed bool
+}
+
+// NewUpload - starts a resumable upload on gateway
+func (c *client) NewUpload(ctx context.Context) (fsstore.Upload, error) {
+	resp, err := c.do(ctx, func() (*http.Request, error) {
+		return http.NewRequest(http.MethodPost, c.baseURL+_uploadsPath, nil)
+	}, false)
+	if err != nil {
+		return nil, requestError(ctx, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return nil, uploadError(resp)
+	}
+	id := strings.TrimPrefix(resp.Header.Get("Location"), _uploadsPath+"/")
+	return &remoteUpload{c: c, ctx: ctx, id: id}, nil
+}
+
+// ResumeUpload - continues resumable upload with given id on gateway, appending after bytes it received so far
+func (c *client) ResumeUpload(ctx context.Context, id string) (fsstore.Upload, error) {
+	u := &remoteUpload{c: c, ctx: ctx, id: id}
+	size, err := u.offset()
+	if err != nil {
+		return nil, err
+	}
+	u.size = size
+	return u, nil
+}
+
+// ID - returns identifier of upload
+func (u *remoteUpload) ID() string {
+	return u.id
+}
+
+// Size - returns count of bytes gateway received so far
+func (u *remoteUpload) Size() int64 {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.size
+}
+
+// Write - appends p to upload; appends interrupted midway continue after bytes gateway received
+func (u *remoteUpload) Write(p []byte) (int, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.closed {
+		return 0, fsstore.ErrUploadClosed
+	}
+	written := 0
+	for written < len(p) {
+		offset, err := u.append(p[written:])
+		if offset > u.size+int64(len(p)-written) || offset < u.size {
+			// upload is appended to by another client
+			return written, ErrUploadOffsetMismatch
+		}
+		progress := offset - u.size
+		written += int(progress)
+		u.size = offset
+		// a conflict after progress means gateway received part of an earlier attempt, resend the remainder
+		if err != nil && (progress == 0 || !errors.Is(err, ErrUploadOffsetMismatch)) {
+			return written, err
+		}
+	}
+	return written, nil
+}
+
+// append - sends p at current size of upload, and returns offset gateway reports afterwards
+func (u *remoteUpload) append(p []byte) (int64, error) {
+	resp, err := u.c.do(u.ctx, func() (*http.Request, error) {
+		req, err := http.NewRequest(http.MethodPatch, u.c.baseURL+_uploadsPath+"/"+u.id, bytes.NewReader(p))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/octet-stream")
+			req.Header.Set(_uploadOffsetHeader, strconv.FormatInt(u.size, 10))
+		}
+		return req, err
+	}, false)
+	if err != nil {
+		// gateway may have received part of p before connection broke
+		if offset, offsetErr := u.offset(); offsetErr == nil {
+			return offset, requestError(u.ctx, err)
+		}
+		return u.size, requestError(u.ctx, err)
+	}
+	resp.Body.Close()
+	offset, parseErr := strconv.ParseInt(resp.Header.Get(_uploadOffsetHeader), 10, 64)
+	switch {
+	case resp.StatusCode == http.StatusNoContent && parseErr == nil:
+		return offset, nil
+	case resp.StatusCode == http.StatusConflict && parseErr == nil:
+		return offset, ErrUploadOffsetMismatch
+	default:
+		return u.size, uploadError(resp)
+	}
+}
+
+// offset - asks gateway for count of bytes of upload received so far
+func (u *remoteUpload) offset() (int64, error) {
+	resp, err := u.c.do(u.ctx, func() (*http.Request, error) {
+		return http.NewRequest(http.MethodHead, u.c.baseURL+_uploadsPath+"/"+u.id, nil)
+	}, true)
+	if err != nil {
+		return 0, requestError(u.ctx, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, uploadError(resp)
+	}
+	return strconv.ParseInt(resp.Header.Get(_uploadOffsetHeader), 10, 64)
+}
+
+// Abort - discards upload on gateway
+func (u *remoteUpload) Abort() error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.closed {
+		return fsstore.ErrUploadClosed
+	}
+	resp, err := u.c.do(u.ctx, func() (*http.Request, error) {
+		return http.NewRequest(http.MethodDelete, u.c.baseURL+_uploadsPath+"/"+u.id, nil)
+	}, true)
+	if err != nil {
+		return requestError(u.ctx, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		return uploadError(resp)
+	}
+	u.closed = true
+	return nil
+}
+
+// Commit - stores content gateway received as object, and returns its cid
+func (u *remoteUpload) Commit() (cid.Cid, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.closed {
+		return cid.Undef, fsstore.ErrUploadClosed
+	}
+	resp, err := u.c.do(u.ctx, func() (*http.Request, error) {
+		return http.NewRequest(http.MethodPut, u.c.baseURL+_uploadsPath+"/"+u.id, nil)
+	}, false)
+	if err != nil {
+		return cid.Undef, requestError(u.ctx, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return cid.Undef, uploadError(resp)
+	}
+	created := createResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		return cid.Undef, err
+	}
+	u.closed = true
+	return cid.Decode(created.Cid)
+}
+
+// Close - releases upload, keeping it pending on gateway; uploads hold no resources of client
+func (u *remoteUpload) Close() error {
+	return nil
+}
+
+// uploadError - maps unexpected upload route responses to errors
+func uploadError(resp *http.Response) error {
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return fsstore.ErrUploadNotExists
+	case http.StatusConflict:
+		return ErrUploadOffsetMismatch
+	case http.StatusNotImplemented:
+		return ErrUploadsUnsupported
+	default:
+		return responseError(resp)
+	}
+}
@@ -0,0 +1,47 @@
+package httpstore
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	fsstore "github.com/igumus/go-objectstore-fs"
+)
+
+// usage - serves reporting bandwidth used by tenants of store, during days `from` (inclusive) to `to`
+// (exclusive), given as `2006-01-02` query parameters
+func (h *handler) usage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	if !h.authenticated(w, r) {
+		return
+	}
+	reporter, ok := h.store.(fsstore.UsageReporter)
+	if !ok {
+		http.Error(w, "usage not supported", http.StatusNotImplemented)
+		return
+	}
+	var from, to time.Time
+	for key, t := range map[string]*time.Time{"from": &from, "to": &to} {
+		value := r.URL.Query().Get(key)
+		if len(value) == 0 {
+			continue
+		}
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			http.Error(w, "invalid "+key+" day", http.StatusBadRequest)
+			return
+		}
+		*t = parsed
+	}
+	usage, err := reporter.Usage(r.Context(), from, to)
+	if err != nil {
+		http.Error(w, err.Error(), statusOf(err))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(usage)
+}
@@ -0,0 +1,101 @@
+package fsstore
+
+import (
+	"context"
+	"log"
+	"sort"
+
+	"github.com/ipfs/go-cid"
+)
+
+// CIDLister defines the functions clients need to enumerate key set of bucket cheaply.
+type CIDLister interface {
+	ListCIDs(context.Context) <-chan cid.Cid
+}
+
+var _ CIDLister = (*fsObjectStoreService)(nil)
+
+// ListCIDs - streams cids of objects of bucket in cid order from cid index (see `WithCIDIndex`), without walking
+// bucket directory. Index is recovered from statistics checkpoint when store is opened; when it could not be
+// recovered (or store is not indexing), first call walks bucket once to load it. Channel is closed once every
+// cid is sent, or early when listing is not authorized, index can not be loaded or ctx is done.
+func (f *fsObjectStoreService) ListCIDs(ctx context.Context) <-chan cid.Cid {
+	ch := make(chan cid.Cid, f.listBuffer)
+
+	go func() {
+		defer close(ch)
+		if err := f.authorize(ctx, OpList, cid.Undef); err != nil {
+			return
+		}
+		cids, err := f.indexedCIDs(withSystem(ctx))
+		f.audit(ctx, OpList, cid.Undef, err)
+		if err != nil {
+			log.Printf("err: loading cid index failed: %s, %v\n", f.bucket, err)
+			return
+		}
+		for _, c := range cids {
+			select {
+			case ch <- c:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+// listIndexed - feeds cids of point in time copy of index to lister, so changes of bucket during listing do not
+// affect it
+func (f *fsObjectStoreService) listIndexed(ctx context.Context, l *lister) error {
+	cids, err := f.indexedCIDs(withSystem(ctx))
+	if err != nil {
+		log.Printf("err: loading cid index failed: %s, %v\n", f.bucket, err)
+		return err
+	}
+	for _, c := range cids {
+		if err := l.emit(c.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// indexedCIDs - returns cids of index in cid order, loading index by walking bucket when not loaded
+func (f *fsObjectStoreService) indexedCIDs(ctx context.Context) ([]cid.Cid, error) {
+	s := f.stats
+	s.mu.Lock()
+	if !s.indexed {
+		if f.isDebug() {
+			log.Printf("debug: cid index not loaded, walking bucket: %s\n", f.bucket)
+		}
+		s.indexing = true
+		if err := f.loadWalk(ctx, s); err != nil {
+			s.mu.Unlock()
+			return nil, err
+		}
+	}
+	keys := make([]string, 0, len(s.index))
+	for key := range s.index {
+		keys = append(keys, key)
+	}
+	cids := make([]cid.Cid, 0, len(keys))
+	sort.Strings(keys)
+	for _, key := range keys {
+		cids = append(cids, s.index[key])
+	}
+	s.mu.Unlock()
+	return cids, nil
+}
+
+// decodeIndex - decodes checkpointed cid index
+func decodeIndex(keys []string) (map[string]cid.Cid, error) {
+	index := make(map[string]cid.Cid, len(keys))
+	for _, key := range keys {
+		c, err := cid.Decode(key)
+		if err != nil {
+			return nil, err
+		}
+		index[key] = c
+	}
+	return index, nil
+}
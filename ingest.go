@@ -0,0 +1,291 @@
+package fsstore
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipld/go-ipld-prime/datamodel"
+	"github.com/ipld/go-ipld-prime/fluent/qp"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipld/go-ipld-prime/node/basicnode"
+	"github.com/multiformats/go-multicodec"
+)
+
+// ErrNotDirectory is return, when ingested path is not a directory.
+var ErrNotDirectory = errors.New("fsobjectstore: not a directory")
+
+// ErrIngestIncomplete is return, when tree manifest of ingested directory is not built since some files failed.
+var ErrIngestIncomplete = errors.New("fsobjectstore: ingest incomplete")
+
+// _defIngestConcurrency handles the default number of files ingested concurrently
+const _defIngestConcurrency = 4
+
+// Captures/Represents directory ingestion configuration information
+type ingestConfig struct {
+	concurrency int
+	tree        bool
+	link        bool
+	mapping     string
+}
+
+// An IngestOption sets options of directory ingestion, such as concurrency.
+type IngestOption func(*ingestConfig)
+
+// WithIngestConcurrency returns an IngestOption that specifies number of files ingested concurrently.
+// If not set, the default is `4`
+func WithIngestConcurrency(n int) IngestOption {
+	return func(ic *ingestConfig) {
+		if n > 0 {
+			ic.concurrency = n
+		}
+	}
+}
+
+// WithIngestTree returns an IngestOption that specifies a dag-cbor tree manifest is built once every file is
+// ingested: each directory is a node `{size, entries: {name: {cid, size}}}` linking its files and directories.
+// If not set, the default is `false`
+func WithIngestTree(t bool) IngestOption {
+	return func(ic *ingestConfig) {
+		ic.tree = t
+	}
+}
+
+// IngestEvent captures outcome of ingesting a file, `/` separated relative to ingested directory. When tree
+// manifest is built, closing event carries its root with path `.`. `Linked` reports file is hard linked into
+// bucket rather than copied, see `AdoptDirectory`
+type IngestEvent struct {
+	Path   string
+	Cid    cid.Cid
+	Size   int64
+	Linked bool
+	Error  error
+}
+
+// Ingestor defines the functions clients need to bulk load local directories.
+type Ingestor interface {
+	IngestDirectory(context.Context, string, ...IngestOption) (<-chan IngestEvent, error)
+}
+
+var _ Ingestor = (*fsObjectStoreService)(nil)
+
+// treeEntry captures an ingested file or directory listed in tree manifest
+type treeEntry struct {
+	cid  cid.Cid
+	size int64
+}
+
+// IngestDirectory - walks local directory at dir, and creates every regular file in it as object concurrently,
+// reporting each file on returned channel, which is closed once ingestion finishes. Symbolic links and special
+// files are skipped.
+func (f *fsObjectStoreService) IngestDirectory(ctx context.Context, dir string, opts ...IngestOption) (<-chan IngestEvent, error) {
+	if err := f.authorize(ctx, OpWrite, cid.Undef); err != nil {
+		return nil, err
+	}
+	// files are created (and admitted while store is in maintenance) one by one
+	f.writeDone(ctx)
+	cfg := &ingestConfig{concurrency: _defIngestConcurrency}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	info, err := os.Stat(dir)
+	if err != nil || !info.IsDir() {
+		return nil, ErrNotDirectory
+	}
+
+	ch := make(chan IngestEvent, cfg.concurrency)
+	go func() {
+		defer close(ch)
+		send := func(event IngestEvent) bool {
+			select {
+			case ch <- event:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		entries, dirs, ok := f.ingest(ctx, dir, cfg, send)
+		if !ok || !cfg.tree {
+			return
+		}
+		root, err := f.buildTree(ctx, entries, dirs)
+		send(IngestEvent{Path: ".", Cid: root.cid, Size: root.size, Error: err})
+	}()
+	return ch, nil
+}
+
+// ingest - creates files of dir via worker pool, returning ingested files and directories by relative path,
+// and whether every file is ingested
+func (f *fsObjectStoreService) ingest(ctx context.Context, dir string, cfg *ingestConfig, send func(IngestEvent) bool) (map[string]treeEntry, []string, bool) {
+	jobs := make(chan string)
+	entries := map[string]treeEntry{}
+	dirs := []string{"."}
+	mu := sync.Mutex{}
+	failed := false
+	t := NewProgressTracker(ctx, "ingest")
+	defer t.Finish()
+
+	ingestFile := f.ingestFile
+	if cfg.link {
+		ingestFile = f.linkFile
+	}
+	wg := sync.WaitGroup{}
+	for i := 0; i < cfg.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for rel := range jobs {
+				event := ingestFile(ctx, filepath.Join(dir, filepath.FromSlash(rel)))
+				event.Path = rel
+				mu.Lock()
+				if event.Error == nil {
+					entries[rel] = treeEntry{cid: event.Cid, size: event.Size}
+				} else {
+					failed = true
+				}
+				mu.Unlock()
+				t.Add(1, event.Size)
+				send(event)
+			}
+		}()
+	}
+
+	walkErr := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if ctxErr := checkContextError(ctx, f.isDebug()); ctxErr != nil {
+			return ctxErr
+		}
+		rel, relErr := filepath.Rel(dir, path)
+		if relErr != nil {
+			return relErr
+		}
+		rel = filepath.ToSlash(rel)
+		if err != nil {
+			mu.Lock()
+			failed = true
+			mu.Unlock()
+			send(IngestEvent{Path: rel, Error: err})
+			if info != nil && info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		switch {
+		case info.IsDir():
+			if rel != "." {
+				dirs = append(dirs, rel)
+			}
+		case info.Mode().IsRegular():
+			t.Expect(1)
+			select {
+			case jobs <- rel:
+			case <-ctx.Done():
+				return checkContextError(ctx, f.isDebug())
+			}
+		default:
+			if f.isDebug() {
+				log.Printf("debug: skipping non regular file: %s, %s\n", path, info.Mode())
+			}
+		}
+		return nil
+	})
+	close(jobs)
+	wg.Wait()
+	if walkErr != nil {
+		send(IngestEvent{Error: walkErr})
+		return nil, nil, false
+	}
+	if f.isDebug() {
+		log.Printf("debug: ingested directory: %s, %d files, failed %t\n", dir, len(entries), failed)
+	}
+	if failed {
+		if cfg.tree {
+			send(IngestEvent{Path: ".", Error: ErrIngestIncomplete})
+		}
+		return nil, nil, false
+	}
+	return entries, dirs, true
+}
+
+// ingestFile - creates content of file at path as object
+func (f *fsObjectStoreService) ingestFile(ctx context.Context, path string) IngestEvent {
+	file, err := os.Open(path)
+	if err != nil {
+		return IngestEvent{Error: err}
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return IngestEvent{Error: err}
+	}
+	digest, err := f.CreateObject(ctx, file)
+	return IngestEvent{Cid: digest, Size: info.Size(), Error: err}
+}
+
+// buildTree - creates tree manifest nodes of ingested directories deepest first, returning root directory
+func (f *fsObjectStoreService) buildTree(ctx context.Context, entries map[string]treeEntry, dirs []string) (treeEntry, error) {
+	children := map[string]map[string]treeEntry{}
+	for _, dir := range dirs {
+		children[dir] = map[string]treeEntry{}
+	}
+	for rel, entry := range entries {
+		children[path.Dir(rel)][path.Base(rel)] = entry
+	}
+	// deeper directories sort after their parents, so walking backwards visits children first
+	depth := func(dir string) int {
+		if dir == "." {
+			return -1
+		}
+		return strings.Count(dir, "/")
+	}
+	sort.SliceStable(dirs, func(i, j int) bool {
+		return depth(dirs[i]) < depth(dirs[j])
+	})
+	for i := len(dirs) - 1; i >= 0; i-- {
+		dir := dirs[i]
+		node, size, err := treeNode(children[dir])
+		if err != nil {
+			log.Printf("err: building tree manifest failed: %s, %v\n", dir, err)
+			return treeEntry{}, ErrNodeEncodingFailed
+		}
+		digest, err := f.CreateNode(ctx, node, multicodec.DagCbor)
+		if err != nil {
+			return treeEntry{}, err
+		}
+		if dir == "." {
+			return treeEntry{cid: digest, size: size}, nil
+		}
+		children[path.Dir(dir)][path.Base(dir)] = treeEntry{cid: digest, size: size}
+	}
+	return treeEntry{}, ErrIngestIncomplete
+}
+
+// treeNode - builds directory node `{size, entries: {name: {cid, size}}}` of given entries, along with total size
+func treeNode(entries map[string]treeEntry) (datamodel.Node, int64, error) {
+	names := make([]string, 0, len(entries))
+	var total int64
+	for name, entry := range entries {
+		names = append(names, name)
+		total += entry.size
+	}
+	sort.Strings(names)
+	node, err := qp.BuildMap(basicnode.Prototype.Any, 2, func(ma datamodel.MapAssembler) {
+		qp.MapEntry(ma, "size", qp.Int(total))
+		qp.MapEntry(ma, "entries", qp.Map(int64(len(names)), func(ma datamodel.MapAssembler) {
+			for _, name := range names {
+				entry := entries[name]
+				qp.MapEntry(ma, name, qp.Map(2, func(ma datamodel.MapAssembler) {
+					qp.MapEntry(ma, "cid", qp.Link(cidlink.Link{Cid: entry.cid}))
+					qp.MapEntry(ma, "size", qp.Int(entry.size))
+				}))
+			}
+		}))
+	})
+	return node, total, err
+}
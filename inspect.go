@@ -0,0 +1,158 @@
+package fsstore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/igumus/go-objectstore-lib"
+	"github.com/ipfs/go-cid"
+)
+
+// StorageKind represents how content of object is stored on disk
+type StorageKind string
+
+const (
+	// StoragePlain is reported for objects storing their content in full
+	StoragePlain StorageKind = "plain"
+	// StorageDelta is reported for objects storing their content as delta against a base object (see `CreateDelta`)
+	StorageDelta StorageKind = "delta"
+)
+
+// ObjectInfo captures on-disk details of an object, for troubleshooting purposes. `Size` is size of content, and
+// `StoredSize` size of object file, which differ for deltas and encrypted objects. `DeltaBase` and `DeltaDepth`
+// are set for deltas only, `KeyID` for encrypted objects only.
+type ObjectInfo struct {
+	Cid        cid.Cid
+	Path       string
+	Size       int64
+	StoredSize int64
+	ModTime    time.Time
+	Kind       StorageKind
+	Encrypted  bool
+	DeltaBase  cid.Cid
+	DeltaDepth int
+	Verified   bool
+	RefCount   int
+	Refs       []cid.Cid
+	Metadata   *Metadata
+	KeyID      string
+}
+
+// Inspector defines the functions clients need to troubleshoot objects of objectstore.
+type Inspector interface {
+	Inspect(context.Context, cid.Cid) (*ObjectInfo, error)
+}
+
+var _ Inspector = (*fsObjectStoreService)(nil)
+
+// Inspect - returns on-disk details of object with specified cid (aka content identifier)
+func (f *fsObjectStoreService) Inspect(ctx context.Context, c cid.Cid) (*ObjectInfo, error) {
+	if err := f.authorize(ctx, OpRead, c); err != nil {
+		return nil, err
+	}
+	ctx = withSystem(ctx)
+	if !f.has(c) {
+		return nil, objectstore.ErrObjectNotExists
+	}
+	if ctxErr := checkContextError(ctx, f.isDebug()); ctxErr != nil {
+		return nil, ctxErr
+	}
+	objLink := f.objectPath(c)
+	stat, err := os.Stat(objLink)
+	if err != nil {
+		return nil, objectstore.ErrObjectReadingFailed
+	}
+	stored, err := f.readStored(ctx, objLink)
+	if err != nil {
+		return nil, err
+	}
+	inner, keyID, err := f.decrypt(stored)
+	if err != nil {
+		return nil, err
+	}
+	data, err := f.resolve(ctx, inner)
+	if err != nil {
+		return nil, err
+	}
+	digest, err := c.Prefix().Sum(data)
+	if err != nil {
+		return nil, ErrDataDigestionFailed
+	}
+	refCount, err := f.RefCount(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	refs, err := f.Refs(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	meta, err := f.GetMetadata(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	info := &ObjectInfo{
+		Cid:        c,
+		Path:       objLink,
+		Size:       int64(len(data)),
+		StoredSize: stat.Size(),
+		ModTime:    stat.ModTime(),
+		Kind:       StoragePlain,
+		Verified:   digest.Equals(c),
+		RefCount:   refCount,
+		Refs:       refs,
+		Metadata:   meta,
+		KeyID:      keyID,
+	}
+	if isEnveloped(stored) {
+		kind, _, _, _ := openEnvelope(stored)
+		info.Encrypted = kind == envelopeEncrypted
+	}
+	if depth, base := deltaHeader(inner); depth > 0 {
+		info.Kind, info.DeltaDepth, info.DeltaBase = StorageDelta, depth, base
+	}
+	return info, nil
+}
+
+// WriteTo - prints object details to given writer in human readable form
+func (o *ObjectInfo) WriteTo(w io.Writer) (int64, error) {
+	n, err := fmt.Fprintf(w, "cid:       %s\npath:      %s\nsize:      %d\nstored:    %d\nmodified:  %s\nkind:      %s\nencrypted: %t\nverified:  %t\nref count: %d\n",
+		o.Cid, o.Path, o.Size, o.StoredSize, o.ModTime.Format(time.RFC3339), o.Kind, o.Encrypted, o.Verified, o.RefCount)
+	total := int64(n)
+	if err != nil {
+		return total, err
+	}
+	if o.Kind == StorageDelta {
+		n, err = fmt.Fprintf(w, "delta of:  %s\ndepth:     %d\n", o.DeltaBase, o.DeltaDepth)
+		total += int64(n)
+		if err != nil {
+			return total, err
+		}
+	}
+	if len(o.KeyID) > 0 {
+		n, err = fmt.Fprintf(w, "key id:    %s\n", o.KeyID)
+		total += int64(n)
+		if err != nil {
+			return total, err
+		}
+	}
+	if o.Metadata != nil {
+		meta, _ := json.Marshal(o.Metadata)
+		n, err = fmt.Fprintf(w, "metadata:  %s\n", meta)
+		total += int64(n)
+		if err != nil {
+			return total, err
+		}
+	}
+	for _, ref := range o.Refs {
+		n, err = fmt.Fprintf(w, "ref:       %s\n", ref)
+		total += int64(n)
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
@@ -0,0 +1,107 @@
+package fsstore
+
+import (
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrTempDirCrossDevice is return, when file system objectstore's temp directory resides on another device than bucket.
+var ErrTempDirCrossDevice = errors.New("fsobjectstore: temp directory not on same device with bucket")
+
+// ErrTempDirInsideBucket is return, when file system objectstore's temp directory is inside bucket but not under internals.
+var ErrTempDirInsideBucket = errors.New("fsobjectstore: temp directory inside bucket objects")
+
+// ErrLayoutMigrationFailed is return, when file system objectstore's legacy internal files could not be migrated.
+var ErrLayoutMigrationFailed = errors.New("fsobjectstore: layout migration failed")
+
+// _internalDir handles the reserved bucket relative directory name holding all non-object files
+const _internalDir = ".fsstore"
+
+// _tempDir handles the internal directory name of default staging area
+const _tempDir = "tmp"
+
+// _legacyInternals maps bucket relative legacy internal paths to their reserved directory relative paths
+var _legacyInternals = map[string]string{
+	".refs": _refsDir,
+}
+
+// internalPath - returns file system path of store internal file/directory under reserved directory
+func (f *fsObjectStoreService) internalPath(elem ...string) string {
+	return filepath.Join(append([]string{f.bucketDir(), _internalDir}, elem...)...)
+}
+
+// isInternal - checks whether given file system path belongs to store internals (of any stripe)
+func (f *fsObjectStoreService) isInternal(path string) bool {
+	for _, dir := range f.stripeDirs() {
+		if within(filepath.Join(dir, _internalDir), path) {
+			return true
+		}
+	}
+	return false
+}
+
+// within - checks whether given path equals to or resides under base path
+func within(base, path string) bool {
+	rel, err := filepath.Rel(base, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// hasLegacyInternals - checks whether bucket holds internal files of legacy layouts, not yet migrated
+func (f *fsObjectStoreService) hasLegacyInternals() bool {
+	for legacy := range _legacyInternals {
+		if exists(filepath.Join(f.bucketDir(), legacy)) {
+			return true
+		}
+	}
+	return false
+}
+
+// migrateLayout - moves internal files of legacy layouts under reserved directory
+func (f *fsObjectStoreService) migrateLayout() error {
+	for legacy, current := range _legacyInternals {
+		from := filepath.Join(f.bucketDir(), legacy)
+		if !exists(from) {
+			continue
+		}
+		to := f.internalPath(current)
+		if exists(to) {
+			log.Printf("err: migrating layout failed, both exist: %s, %s\n", from, to)
+			return ErrLayoutMigrationFailed
+		}
+		if err := os.MkdirAll(filepath.Dir(to), 0777); err != nil {
+			log.Printf("err: migrating layout failed: %s, %v\n", from, err)
+			return ErrLayoutMigrationFailed
+		}
+		if err := os.Rename(from, to); err != nil {
+			log.Printf("err: migrating layout failed: %s, %v\n", from, err)
+			return ErrLayoutMigrationFailed
+		}
+		if f.isDebug() {
+			log.Printf("debug: migrated layout: %s, %s\n", from, to)
+		}
+	}
+	return nil
+}
+
+// validateTempDir - creates staging directory and rejects, when staged writes can not be renamed into bucket
+func (f *fsObjectStoreService) validateTempDir() error {
+	if err := os.MkdirAll(f.tempDir, 0777); err != nil {
+		log.Printf("err: creating temp directory failed: %s, %v\n", f.tempDir, err)
+		return err
+	}
+	if within(f.bucketDir(), f.tempDir) && !f.isInternal(f.tempDir) {
+		return ErrTempDirInsideBucket
+	}
+	same, err := sameDevice(f.tempDir, f.bucketDir())
+	if err != nil {
+		log.Printf("err: checking temp directory device failed: %s, %v\n", f.tempDir, err)
+		return err
+	}
+	if !same {
+		return ErrTempDirCrossDevice
+	}
+	return nil
+}
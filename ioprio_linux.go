@@ -0,0 +1,33 @@
+package fsstore
+
+import (
+	"runtime"
+
+	"golang.org/x/sys/unix"
+)
+
+// _ioprioWhoProcess, _ioprioClassShift and _ioprioClassIdle handle the ioprio_set(2) constants, see linux/ioprio.h
+const (
+	_ioprioWhoProcess = 1
+	_ioprioClassShift = 13
+	_ioprioClassIdle  = 3
+)
+
+// lowerIOPriority - moves calling goroutine, locked to its thread, into idle IO scheduling class; returned function
+// restores previous priority of thread and unlocks it
+func lowerIOPriority() (func(), error) {
+	runtime.LockOSThread()
+	prev, _, errno := unix.Syscall(unix.SYS_IOPRIO_GET, _ioprioWhoProcess, 0, 0)
+	if errno != 0 {
+		runtime.UnlockOSThread()
+		return nil, errno
+	}
+	if _, _, errno := unix.Syscall(unix.SYS_IOPRIO_SET, _ioprioWhoProcess, 0, _ioprioClassIdle<<_ioprioClassShift); errno != 0 {
+		runtime.UnlockOSThread()
+		return nil, errno
+	}
+	return func() {
+		unix.Syscall(unix.SYS_IOPRIO_SET, _ioprioWhoProcess, 0, prev)
+		runtime.UnlockOSThread()
+	}, nil
+}
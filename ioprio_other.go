@@ -0,0 +1,8 @@
+//go:build !linux
+
+package fsstore
+
+// lowerIOPriority - returns errIOPriorityUnsupported, since IO scheduling classes are not supported on this platform
+func lowerIOPriority() (func(), error) {
+	return nil, errIOPriorityUnsupported
+}
@@ -0,0 +1,96 @@
+package fsstore
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+
+	"github.com/ipfs/go-cid"
+)
+
+// errIOPriorityUnsupported is return, when platform has no IO scheduling classes
+var errIOPriorityUnsupported = errors.New("fsobjectstore: io priority not supported")
+
+// IOBudget captures IO budget of walkers scanning bucket (verification, garbage collection, listing, rebalancing
+// and the like), shared by every walker of store so they yield disk to foreground traffic. Zero rates are unlimited.
+// `IdlePriority` additionally runs walkers in idle IO scheduling class where platform supports it (Linux `ionice`).
+type IOBudget struct {
+	BytesPerSec  int64 `json:"bytesPerSec,omitempty"`
+	OpsPerSec    int64 `json:"opsPerSec,omitempty"`
+	IdlePriority bool  `json:"idlePriority,omitempty"`
+}
+
+// IOThrottler defines the functions clients need to tune IO budget of walkers at runtime.
+type IOThrottler interface {
+	SetIOBudget(context.Context, IOBudget) error
+	IOBudget() IOBudget
+}
+
+var _ IOThrottler = (*fsObjectStoreService)(nil)
+
+// ioThrottle paces walkers to IO budget, replaced as a whole when budget changes
+type ioThrottle struct {
+	mu     sync.Mutex
+	budget IOBudget
+	bytes  *rateLimiter
+	ops    *rateLimiter
+}
+
+// set - replaces budget of throttle; walkers waiting on previous budget finish their wait
+func (t *ioThrottle) set(budget IOBudget) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.budget = budget
+	t.bytes = newRateLimiter(budget.BytesPerSec)
+	t.ops = newRateLimiter(budget.OpsPerSec)
+}
+
+// wait - blocks until given file system operations and bytes fit budget, or context is done
+func (t *ioThrottle) wait(ctx context.Context, ops, bytes int64) error {
+	t.mu.Lock()
+	opsLimit, bytesLimit := t.ops, t.bytes
+	t.mu.Unlock()
+	if err := opsLimit.wait(ctx, ops); err != nil {
+		return err
+	}
+	return bytesLimit.wait(ctx, bytes)
+}
+
+// lower - lowers IO priority of calling walker when budget asks for idle priority, returning function restoring it
+func (t *ioThrottle) lower(debug bool) func() {
+	t.mu.Lock()
+	idle := t.budget.IdlePriority
+	t.mu.Unlock()
+	if !idle {
+		return func() {}
+	}
+	restore, err := lowerIOPriority()
+	if err != nil {
+		if debug {
+			log.Printf("debug: lowering io priority failed: %v\n", err)
+		}
+		return func() {}
+	}
+	return restore
+}
+
+// SetIOBudget - replaces IO budget of walkers (see `WithIOBudget`), taking effect for walkers already running
+func (f *fsObjectStoreService) SetIOBudget(ctx context.Context, budget IOBudget) error {
+	if err := f.authorize(ctx, OpAdmin, cid.Undef); err != nil {
+		return err
+	}
+	f.io.set(budget)
+	if f.isDebug() {
+		log.Printf("debug: set io budget: %s, %d bytes/s, %d ops/s, idle %t\n", f.bucket, budget.BytesPerSec, budget.OpsPerSec, budget.IdlePriority)
+	}
+	f.audit(ctx, OpAdmin, cid.Undef, nil)
+	return nil
+}
+
+// IOBudget - returns IO budget of walkers
+func (f *fsObjectStoreService) IOBudget() IOBudget {
+	f.io.mu.Lock()
+	defer f.io.mu.Unlock()
+	return f.io.budget
+}
@@ -0,0 +1,457 @@
+package fsstore
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/igumus/go-objectstore-lib"
+	"github.com/ipfs/go-cid"
+)
+
+// ErrJobNotExists is return, when a job is not known to store, or finished too long ago to be kept in history.
+var ErrJobNotExists = errors.New("fsobjectstore: job not exists")
+
+// ErrStoreClosed is return, when a job is submitted to a closed store.
+var ErrStoreClosed = errors.New("fsobjectstore: store closed")
+
+// ErrUnknownJobKind is return, when a built-in job of unknown kind is started.
+var ErrUnknownJobKind = errors.New("fsobjectstore: unknown job kind")
+
+// _jobsFile handles the internal file name of built-in jobs interrupted by `Close`, resumed when store is reopened
+const _jobsFile = "jobs"
+
+// _defJobConcurrency handles the default count of jobs running at once
+const _defJobConcurrency = 2
+
+// _defJobHistory handles the default count of finished jobs kept for `Job` and `Jobs`
+const _defJobHistory = 64
+
+// JobState represents lifecycle state of a job
+type JobState string
+
+const (
+	// JobQueued is state of job waiting for a free slot (see `WithJobConcurrency`)
+	JobQueued JobState = "queued"
+	// JobRunning is state of job still running
+	JobRunning JobState = "running"
+	// JobSucceeded is state of job finished successfully, with its result
+	JobSucceeded JobState = "succeeded"
+	// JobFailed is state of job finished with an error
+	JobFailed JobState = "failed"
+	// JobCanceled is state of job canceled (see `CancelJob`), or stopped as store is closed
+	JobCanceled JobState = "canceled"
+)
+
+// Kinds of jobs store runs itself (see `StartJob`, and scheduled maintenance); clients may submit jobs of any kind.
+// Built-in `rebalance` and `reencrypt` jobs are resumable: interrupted by `Close`, they continue once store is
+// reopened, under same id.
+const (
+	JobVerify    = "verify"
+	JobLifecycle = "lifecycle"
+	JobGC        = "gc"
+	JobRebalance = "rebalance"
+	JobReencrypt = "reencrypt"
+	JobReconcile = "reconcile"
+)
+
+// JobStatus captures state of a job, with progress reported by it and its result once succeeded
+type JobStatus struct {
+	ID        string
+	Kind      string
+	Owner     string
+	State     JobState
+	Submitted time.Time
+	Started   time.Time
+	Finished  time.Time
+	Progress  *ProgressUpdate
+	Result    interface{}
+	Err       string
+}
+
+// JobFunc runs a job, returning its result; ctx is canceled when job is canceled or store is closed
+type JobFunc func(ctx context.Context) (interface{}, error)
+
+// JobManager defines the functions clients need to run long operations (e.g. `CollectGarbage`, `Verify`,
+// `Rebalance`, `ExportWhere`) in background, sharing cancellation, concurrency limit and observability.
+type JobManager interface {
+	SubmitJob(ctx context.Context, kind string, run JobFunc) (JobStatus, error)
+	StartJob(ctx context.Context, kind string) (JobStatus, error)
+	Job(ctx context.Context, id string) (JobStatus, error)
+	Jobs(ctx context.Context) ([]JobStatus, error)
+	CancelJob(ctx context.Context, id string) error
+}
+
+var _ JobManager = (*fsObjectStoreService)(nil)
+
+// jobs tracks jobs of store, limiting how many run at once and forgetting oldest finished ones beyond history
+type jobs struct {
+	slots    chan struct{}
+	history  int
+	mu       sync.Mutex
+	byID     map[string]*job
+	order    []string
+	finished []string
+}
+
+// job captures tracked job, along with cancellation of its context and latest checkpoint of resumable job
+type job struct {
+	status    JobStatus
+	cancel    context.CancelFunc
+	canceled  bool
+	resumable bool
+	state     interface{}
+}
+
+// resumer runs built-in job from state checkpointed before store was closed (nil when started afresh), passing
+// state to resume from to checkpoint as it progresses
+type resumer func(ctx context.Context, state json.RawMessage, checkpoint func(interface{})) (interface{}, error)
+
+// suspendedJob captures built-in job interrupted by `Close`
+type suspendedJob struct {
+	ID    string          `json:"id"`
+	Kind  string          `json:"kind"`
+	Owner string          `json:"owner,omitempty"`
+	State json.RawMessage `json:"state,omitempty"`
+}
+
+// jobContext carries values (principal, request metadata, progress observer) of context job is submitted with,
+// while its cancellation follows the job itself
+type jobContext struct {
+	context.Context
+	values context.Context
+}
+
+// Value - returns value of submitting context
+func (c jobContext) Value(key interface{}) interface{} {
+	return c.values.Value(key)
+}
+
+// newJobs - creates job tracker running up to concurrency jobs at once, and keeping history finished jobs
+func newJobs(concurrency, history int) *jobs {
+	return &jobs{slots: make(chan struct{}, concurrency), history: history, byID: map[string]*job{}}
+}
+
+// SubmitJob - queues run as job of kind, and returns its status right away. Job runs as principal of ctx
+// once a slot is free, detached from cancellation of ctx; see `CancelJob` instead.
+func (f *fsObjectStoreService) SubmitJob(ctx context.Context, kind string, run JobFunc) (JobStatus, error) {
+	if err := f.authorize(ctx, OpAdmin, cid.Undef); err != nil {
+		return JobStatus{}, err
+	}
+	if f.bgCtx.Err() != nil {
+		f.audit(ctx, OpAdmin, cid.Undef, ErrStoreClosed)
+		return JobStatus{}, ErrStoreClosed
+	}
+	j, jobCtx := f.jobs.add(f.bgCtx, ctx, "", kind)
+	f.background(func(context.Context) {
+		f.runJob(jobCtx, j, run)
+	})
+	f.audit(ctx, OpAdmin, cid.Undef, nil)
+	return f.jobs.snapshot(j), nil
+}
+
+// StartJob - queues built-in job of kind (`verify`, `rebalance`, `reencrypt` or `reconcile`), run by store itself, and returns
+// its status right away; see `SubmitJob`
+func (f *fsObjectStoreService) StartJob(ctx context.Context, kind string) (JobStatus, error) {
+	if err := f.authorize(ctx, OpAdmin, cid.Undef); err != nil {
+		return JobStatus{}, err
+	}
+	status, err := f.startJob(ctx, kind)
+	f.audit(ctx, OpAdmin, cid.Undef, err)
+	return status, err
+}
+
+// startJob - queues built-in job of kind afresh
+func (f *fsObjectStoreService) startJob(ctx context.Context, kind string) (JobStatus, error) {
+	return f.resumeJob(ctx, suspendedJob{Kind: kind})
+}
+
+// resumeJob - queues built-in job, resuming it from its checkpointed state (and keeping its id) when suspended,
+// running in background until store is closed
+func (f *fsObjectStoreService) resumeJob(ctx context.Context, suspended suspendedJob) (JobStatus, error) {
+	resume, resumable := f.builtinJob(suspended.Kind)
+	if resume == nil {
+		return JobStatus{}, ErrUnknownJobKind
+	}
+	if f.bgCtx.Err() != nil {
+		return JobStatus{}, ErrStoreClosed
+	}
+	j, jobCtx := f.jobs.add(f.bgCtx, ctx, suspended.ID, suspended.Kind)
+	j.resumable = resumable
+	checkpoint := func(state interface{}) {
+		f.jobs.mu.Lock()
+		defer f.jobs.mu.Unlock()
+		j.state = state
+	}
+	f.background(func(context.Context) {
+		f.runJob(jobCtx, j, func(ctx context.Context) (interface{}, error) {
+			return resume(ctx, suspended.State, checkpoint)
+		})
+	})
+	return f.jobs.snapshot(j), nil
+}
+
+// builtinJob - returns resumer of built-in job kind, nil when kind is unknown, and whether job is resumable
+func (f *fsObjectStoreService) builtinJob(kind string) (resumer, bool) {
+	switch kind {
+	case JobVerify:
+		return func(ctx context.Context, _ json.RawMessage, _ func(interface{})) (interface{}, error) {
+			return f.Verify(ctx)
+		}, false
+	case JobRebalance:
+		return f.resumeRebalance, true
+	case JobReencrypt:
+		return f.reencrypt, true
+	case JobReconcile:
+		return func(ctx context.Context, _ json.RawMessage, _ func(interface{})) (interface{}, error) {
+			return f.Reconcile(ctx)
+		}, false
+	default:
+		return nil, false
+	}
+}
+
+// suspendJobs - records resumable built-in jobs interrupted by `Close` (queued or running), once they stopped,
+// so they are resumed when store is reopened
+func (f *fsObjectStoreService) suspendJobs() {
+	f.jobs.mu.Lock()
+	suspended := []suspendedJob{}
+	for _, id := range f.jobs.order {
+		j := f.jobs.byID[id]
+		if !j.resumable || j.canceled || j.status.State != JobCanceled {
+			continue
+		}
+		s := suspendedJob{ID: id, Kind: j.status.Kind, Owner: j.status.Owner}
+		if j.state != nil {
+			state, err := json.Marshal(j.state)
+			if err != nil {
+				log.Printf("err: encoding job checkpoint failed, job restarts: %s, %s, %v\n", f.bucket, id, err)
+			}
+			s.State = state
+		}
+		suspended = append(suspended, s)
+	}
+	f.jobs.mu.Unlock()
+	if len(suspended) == 0 || !f.isProvisioned() {
+		return
+	}
+	data, err := json.Marshal(suspended)
+	if err == nil {
+		err = f.writeInternal(f.internalPath(_jobsFile), data)
+	}
+	if err != nil {
+		log.Printf("err: recording interrupted jobs failed: %s, %v\n", f.bucket, err)
+		return
+	}
+	if f.isDebug() {
+		log.Printf("debug: interrupted jobs recorded: %s, %d jobs\n", f.bucket, len(suspended))
+	}
+}
+
+// resumeJobs - resumes built-in jobs interrupted when store was closed last, as principals which started them
+func (f *fsObjectStoreService) resumeJobs() {
+	path := f.internalPath(_jobsFile)
+	if !exists(path) {
+		return
+	}
+	data, err := f.readInternal(path)
+	suspended := []suspendedJob{}
+	if err == nil {
+		err = json.Unmarshal(data, &suspended)
+	}
+	if err != nil {
+		log.Printf("warn: reading interrupted jobs failed, jobs are not resumed: %s, %v\n", path, err)
+	}
+	if err := os.Remove(path); err != nil {
+		log.Printf("err: removing interrupted jobs failed: %s, %v\n", path, err)
+		return
+	}
+	for _, s := range suspended {
+		ctx := withSystem(ContextWithPrincipal(context.Background(), Principal{ID: s.Owner}))
+		if _, err := f.resumeJob(ctx, s); err != nil {
+			log.Printf("err: resuming job failed: %s, %s, %s, %v\n", f.bucket, s.Kind, s.ID, err)
+			continue
+		}
+		if f.isDebug() {
+			log.Printf("debug: job resumed: %s, %s, %s\n", f.bucket, s.Kind, s.ID)
+		}
+	}
+}
+
+// runTracked - runs run as job of kind tracked like submitted ones, waiting for it to finish; canceling ctx
+// cancels job
+func (f *fsObjectStoreService) runTracked(ctx context.Context, kind string, run JobFunc) error {
+	j, jobCtx := f.jobs.add(ctx, ctx, "", kind)
+	return f.runJob(jobCtx, j, run)
+}
+
+// runJob - waits for a free slot, then runs job and records its outcome
+func (f *fsObjectStoreService) runJob(ctx context.Context, j *job, run JobFunc) error {
+	defer j.cancel()
+	select {
+	case f.jobs.slots <- struct{}{}:
+	case <-ctx.Done():
+		err := checkContextError(ctx, f.isDebug())
+		f.jobs.finish(j, nil, err, true)
+		return err
+	}
+	defer func() { <-f.jobs.slots }()
+
+	f.jobs.start(j)
+	if f.isDebug() {
+		log.Printf("debug: job started: %s, %s, %s\n", f.bucket, j.status.Kind, j.status.ID)
+	}
+	result, err := run(ctx)
+	if err != nil && ctx.Err() == nil {
+		log.Printf("err: job failed: %s, %s, %s, %v\n", f.bucket, j.status.Kind, j.status.ID, err)
+	}
+	f.jobs.finish(j, result, err, ctx.Err() == context.Canceled)
+	if f.isDebug() {
+		log.Printf("debug: job finished: %s, %s, %s\n", f.bucket, j.status.Kind, j.status.ID)
+	}
+	return err
+}
+
+// Job - returns status of job with id
+func (f *fsObjectStoreService) Job(ctx context.Context, id string) (JobStatus, error) {
+	if err := f.authorize(ctx, OpAdmin, cid.Undef); err != nil {
+		return JobStatus{}, err
+	}
+	f.jobs.mu.Lock()
+	defer f.jobs.mu.Unlock()
+	j, ok := f.jobs.byID[id]
+	if !ok {
+		return JobStatus{}, ErrJobNotExists
+	}
+	return f.jobs.copyOf(j), nil
+}
+
+// Jobs - returns status of queued, running and recently finished jobs, in order of submission
+func (f *fsObjectStoreService) Jobs(ctx context.Context) ([]JobStatus, error) {
+	if err := f.authorize(ctx, OpAdmin, cid.Undef); err != nil {
+		return nil, err
+	}
+	f.jobs.mu.Lock()
+	defer f.jobs.mu.Unlock()
+	ret := make([]JobStatus, 0, len(f.jobs.order))
+	for _, id := range f.jobs.order {
+		ret = append(ret, f.jobs.copyOf(f.jobs.byID[id]))
+	}
+	return ret, nil
+}
+
+// CancelJob - cancels job with id; queued job never runs, running job observes cancellation of its context.
+// Canceling a finished job has no effect.
+func (f *fsObjectStoreService) CancelJob(ctx context.Context, id string) error {
+	if err := f.authorize(ctx, OpAdmin, cid.Undef); err != nil {
+		return err
+	}
+	f.jobs.mu.Lock()
+	j, ok := f.jobs.byID[id]
+	if ok && j.status.Finished.IsZero() {
+		j.canceled = true
+		j.cancel()
+	}
+	f.jobs.mu.Unlock()
+	if !ok {
+		return ErrJobNotExists
+	}
+	f.audit(ctx, OpAdmin, cid.Undef, nil)
+	return nil
+}
+
+// add - tracks new queued job of kind (with id, or a new one when empty), and returns it with context it runs
+// with: canceled along with parent, carrying values of submitting context and reporting progress to job (and to
+// observer of submitting context)
+func (t *jobs) add(parent, submit context.Context, id, kind string) (*job, context.Context) {
+	if len(id) == 0 {
+		rnd := make([]byte, 8)
+		rand.Read(rnd)
+		id = hex.EncodeToString(rnd)
+	}
+	p, _ := PrincipalFromContext(submit)
+	j := &job{status: JobStatus{ID: id, Kind: kind, Owner: p.ID, State: JobQueued, Submitted: time.Now().UTC()}}
+
+	cancelCtx, cancel := context.WithCancel(parent)
+	j.cancel = cancel
+	observer := ProgressFromContext(submit)
+	ctx := ContextWithProgress(jobContext{Context: cancelCtx, values: submit}, ProgressFunc(func(p ProgressUpdate) {
+		t.mu.Lock()
+		j.status.Progress = &p
+		t.mu.Unlock()
+		if observer != nil {
+			observer.Report(p)
+		}
+	}))
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.byID[j.status.ID] = j
+	t.order = append(t.order, j.status.ID)
+	return j, ctx
+}
+
+// start - marks job running
+func (t *jobs) start(j *job) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	j.status.State = JobRunning
+	j.status.Started = time.Now().UTC()
+}
+
+// finish - records outcome of job, canceled when its context was, forgetting oldest finished job beyond history
+func (t *jobs) finish(j *job, result interface{}, err error, canceled bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	j.status.Finished = time.Now().UTC()
+	switch {
+	case err == nil:
+		j.status.State = JobSucceeded
+		j.status.Result = result
+	case canceled && errors.Is(err, objectstore.ErrOperationCancelled):
+		j.status.State = JobCanceled
+		j.status.Err = err.Error()
+	default:
+		j.status.State = JobFailed
+		j.status.Err = err.Error()
+	}
+	t.finished = append(t.finished, j.status.ID)
+	for len(t.finished) > t.history {
+		t.forget(t.finished[0])
+		t.finished = t.finished[1:]
+	}
+}
+
+// forget - drops finished job with id from tracker; requires t.mu held
+func (t *jobs) forget(id string) {
+	delete(t.byID, id)
+	for i, tracked := range t.order {
+		if tracked == id {
+			t.order = append(t.order[:i], t.order[i+1:]...)
+			return
+		}
+	}
+}
+
+// snapshot - returns copy of job status
+func (t *jobs) snapshot(j *job) JobStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.copyOf(j)
+}
+
+// copyOf - returns copy of job status, not sharing progress with job; requires t.mu held
+func (t *jobs) copyOf(j *job) JobStatus {
+	status := j.status
+	if status.Progress != nil {
+		progress := *status.Progress
+		status.Progress = &progress
+	}
+	return status
+}
@@ -0,0 +1,387 @@
+package fsstore
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-cid"
+)
+
+// ErrJournalDisabled is return, when journal is read but objectstore is not configured to keep one.
+var ErrJournalDisabled = errors.New("fsobjectstore: journal disabled")
+
+// ErrJournalWritingFailed is return, when appending journal entry failed.
+var ErrJournalWritingFailed = errors.New("fsobjectstore: writing journal failed")
+
+// ErrJournalReadingFailed is return, when reading journal entries failed.
+var ErrJournalReadingFailed = errors.New("fsobjectstore: reading journal failed")
+
+// ErrJournalCorrupted is return, when a journal entry does not match its checksum.
+var ErrJournalCorrupted = errors.New("fsobjectstore: journal corrupted")
+
+// _journalFile handles the internal file name of operation journal
+const _journalFile = "journal"
+
+// JournalOp represents kind of journaled operation
+type JournalOp string
+
+const (
+	// JournalCreate is journaled, when an object is newly written
+	JournalCreate JournalOp = "create"
+	// JournalDelete is journaled, when an object is deleted by client
+	JournalDelete JournalOp = "delete"
+	// JournalGC is journaled, when an object is deleted by garbage collection
+	JournalGC JournalOp = "gc"
+)
+
+// JournalEntry captures a journaled operation, sequence numbers start from 1 and increase monotonically
+type JournalEntry struct {
+	Seq  uint64
+	Op   JournalOp
+	Cid  cid.Cid
+	Size int64
+	Time time.Time
+}
+
+// JournalReader defines the functions clients need to follow operations applied to objectstore.
+type JournalReader interface {
+	ReadJournal(ctx context.Context, since uint64, limit int) ([]JournalEntry, error)
+}
+
+var _ JournalReader = (*fsObjectStoreService)(nil)
+
+// journalRecord captures wire format of journal entry
+type journalRecord struct {
+	Seq  uint64    `json:"seq"`
+	Op   JournalOp `json:"op"`
+	Cid  string    `json:"cid"`
+	Size int64     `json:"size"`
+	Time time.Time `json:"time"`
+}
+
+// journal appends operations to journal file
+type journal struct {
+	mu     sync.Mutex
+	path   string
+	file   *os.File
+	seq    uint64
+	clock  Clock
+	legacy bool
+}
+
+// openJournal - opens journal file at path for appending, resuming sequence from its last entry; entries are
+// timestamped by clock, and entries without checksum are accepted only when legacy (see `unframeLine`)
+func openJournal(path string, clock Clock, legacy bool) (*journal, error) {
+	j := &journal{path: path, clock: clock, legacy: legacy}
+	if err := repairJournal(path); err != nil {
+		return nil, err
+	}
+	err := scanJournal(path, legacy, func(rec journalRecord) bool {
+		j.seq = rec.Seq
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0666)
+	if err != nil {
+		log.Printf("err: opening journal failed: %s, %v\n", path, err)
+		return nil, ErrJournalWritingFailed
+	}
+	j.file = file
+	return j, nil
+}
+
+// append - durably appends operation to journal, returning its sequence
+func (j *journal) append(op JournalOp, c cid.Cid, size int64) (uint64, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	rec := journalRecord{Seq: j.seq + 1, Op: op, Cid: c.String(), Size: size, Time: j.clock.Now().UTC()}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return 0, ErrJournalWritingFailed
+	}
+	if _, err := fmt.Fprintf(j.file, "%s\n", frameLine(data)); err != nil {
+		log.Printf("err: appending journal failed: %s, %v\n", j.path, err)
+		return 0, ErrJournalWritingFailed
+	}
+	if err := j.file.Sync(); err != nil {
+		log.Printf("err: syncing journal failed: %s, %v\n", j.path, err)
+		return 0, ErrJournalWritingFailed
+	}
+	j.seq = rec.Seq
+	return rec.Seq, nil
+}
+
+// flush - syncs journal file, so entries appended are durable once store is closed
+func (j *journal) flush() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err := j.file.Sync(); err != nil {
+		log.Printf("err: syncing journal failed: %s, %v\n", j.path, err)
+	}
+}
+
+// scanJournal - decodes journal records of path in order, until fn returns false; records without checksum are
+// accepted only when legacy
+func scanJournal(path string, legacy bool, fn func(journalRecord) bool) error {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		log.Printf("err: opening journal failed: %s, %v\n", path, err)
+		return ErrJournalReadingFailed
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line, err := unframeLine(scanner.Bytes(), legacy)
+		if err != nil {
+			log.Printf("err: journal entry checksum mismatch: %s, %s\n", path, scanner.Bytes())
+			return ErrJournalCorrupted
+		}
+		rec := journalRecord{}
+		if err := json.Unmarshal(line, &rec); err != nil {
+			log.Printf("err: decoding journal record failed: %s, %v\n", path, err)
+			return ErrJournalReadingFailed
+		}
+		if !fn(rec) {
+			return nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		log.Printf("err: reading journal failed: %s, %v\n", path, err)
+		return ErrJournalReadingFailed
+	}
+	return nil
+}
+
+// repairJournal - truncates entry torn by an interrupted append (one not terminated by newline) off journal
+func repairJournal(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) || len(data) == 0 || data[len(data)-1] == '\n' {
+		return nil
+	}
+	if err != nil {
+		log.Printf("err: opening journal failed: %s, %v\n", path, err)
+		return ErrJournalReadingFailed
+	}
+	size := bytes.LastIndexByte(data, '\n') + 1
+	if err := os.Truncate(path, int64(size)); err != nil {
+		log.Printf("err: truncating torn journal entry failed: %s, %v\n", path, err)
+		return ErrJournalWritingFailed
+	}
+	log.Printf("warn: truncated torn journal entry: %s, %d bytes\n", path, len(data)-size)
+	return nil
+}
+
+// rebuildJournal - replaces corrupted journal at path with one journaling every object on file system as created,
+// sequenced after every entry of corrupted journal, so followers reading it (see `ReadJournal`) converge on state
+// of store. Corrupted journal is kept aside, and statistics checkpoint is dropped, since it can not be replayed
+// onto rebuilt journal.
+func (f *fsObjectStoreService) rebuildJournal(path string) (*journal, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Printf("err: opening journal failed: %s, %v\n", path, err)
+		return nil, ErrJournalReadingFailed
+	}
+	var seq uint64
+	for _, framed := range bytes.Split(data, []byte("\n")) {
+		if len(framed) == 0 {
+			continue
+		}
+		// corrupted entries are counted, so sequence stays ahead of whatever they recorded
+		seq++
+		rec := journalRecord{}
+		if line, err := unframeLine(framed, f.legacyFrames); err == nil && json.Unmarshal(line, &rec) == nil && rec.Seq > seq {
+			seq = rec.Seq
+		}
+	}
+	if err := os.Rename(path, fmt.Sprintf("%s.corrupted-%d", path, f.now().UnixNano())); err != nil {
+		log.Printf("err: setting corrupted journal aside failed: %s, %v\n", path, err)
+		return nil, ErrJournalWritingFailed
+	}
+
+	rebuilt := bytes.Buffer{}
+	entries := 0
+	now := f.now().UTC()
+	err = f.walkObjects(withSystem(context.Background()), func(c cid.Cid, objPath string, info os.FileInfo) error {
+		seq++
+		data, err := json.Marshal(journalRecord{Seq: seq, Op: JournalCreate, Cid: c.String(), Size: f.objectSize(objPath, info.Size()), Time: now})
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&rebuilt, "%s\n", frameLine(data))
+		entries++
+		return nil
+	})
+	if err != nil {
+		log.Printf("err: rebuilding journal failed: %s, %v\n", path, err)
+		return nil, ErrJournalWritingFailed
+	}
+	if err := write(f.internalPath(_tempDir), path, rebuilt.Bytes()); err != nil {
+		log.Printf("err: writing rebuilt journal failed: %s, %v\n", path, err)
+		return nil, ErrJournalWritingFailed
+	}
+	if err := os.Remove(f.internalPath(_statsFile)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("warn: dropping stats checkpoint failed: %s, %v\n", path, err)
+	}
+	log.Printf("warn: journal corrupted, rebuilt from file system: %s, %d entries\n", path, entries)
+	return openJournal(path, f.clock, f.legacyFrames)
+}
+
+// journaled - accounts operation in store statistics (and catalog), and appends it to journal when journal is enabled;
+// created objects are stamped with store clock (see `stamp`).
+// Statistics are updated along with journal, so checkpointed statistics match journal sequence they record.
+func (f *fsObjectStoreService) journaled(op JournalOp, c cid.Cid, size int64) error {
+	if op == JournalCreate {
+		f.stamp(c)
+	}
+	f.catalog.record(op, c, size)
+	f.stats.mu.Lock()
+	defer f.stats.mu.Unlock()
+	f.stats.record(op, c, size)
+	if f.journal == nil {
+		return nil
+	}
+	seq, err := f.journal.append(op, c, size)
+	if err == nil {
+		f.stats.seq = seq
+	}
+	return err
+}
+
+// ReadJournal - returns up to limit journal entries whose sequence is greater than since; limit
+// less than 1 returns every such entry
+func (f *fsObjectStoreService) ReadJournal(ctx context.Context, since uint64, limit int) ([]JournalEntry, error) {
+	if err := f.authorize(ctx, OpAdmin, cid.Undef); err != nil {
+		return nil, err
+	}
+	if f.journal == nil {
+		return nil, ErrJournalDisabled
+	}
+	if ctxErr := checkContextError(ctx, f.isDebug()); ctxErr != nil {
+		return nil, ctxErr
+	}
+	ret := []JournalEntry{}
+	var decodeErr error
+	err := scanJournal(f.journal.path, f.journal.legacy, func(rec journalRecord) bool {
+		if rec.Seq <= since {
+			return true
+		}
+		c, err := cid.Decode(rec.Cid)
+		if err != nil {
+			decodeErr = ErrJournalReadingFailed
+			return false
+		}
+		ret = append(ret, JournalEntry{Seq: rec.Seq, Op: rec.Op, Cid: c, Size: rec.Size, Time: rec.Time})
+		return limit < 1 || len(ret) < limit
+	})
+	if err != nil {
+		return nil, err
+	}
+	if decodeErr != nil {
+		return nil, decodeErr
+	}
+	return ret, nil
+}
+
+// JournalCompactor defines the functions clients need to bound journal growth.
+type JournalCompactor interface {
+	CompactJournal(context.Context) (int, error)
+}
+
+var _ JournalCompactor = (*fsObjectStoreService)(nil)
+
+// CompactJournal - rewrites journal keeping only last entry of every object, and returns number of entries
+// dropped. Kept entries keep their sequence, so followers reading journal (see `ReadJournal`) still converge
+// on state of store, and `ListChangesSince` lists same changes. Statistics are checkpointed first and
+// journaling is paused meanwhile, so dropped entries are never replayed.
+func (f *fsObjectStoreService) CompactJournal(ctx context.Context) (int, error) {
+	if err := f.authorize(ctx, OpAdmin, cid.Undef); err != nil {
+		return 0, err
+	}
+	dropped, err := f.compactJournal(ctx)
+	f.audit(ctx, OpAdmin, cid.Undef, err)
+	return dropped, err
+}
+
+// compactJournal - rewrites journal under statistics and journal locks, staging it in internal temp directory
+func (f *fsObjectStoreService) compactJournal(ctx context.Context) (int, error) {
+	if f.journal == nil {
+		return 0, ErrJournalDisabled
+	}
+	f.stats.mu.Lock()
+	defer f.stats.mu.Unlock()
+	if f.isProvisioned() {
+		if err := f.writeStats(f.stats, false); err != nil {
+			log.Printf("err: writing stats checkpoint failed: %s, %v\n", f.bucket, err)
+			return 0, ErrJournalWritingFailed
+		}
+		f.stats.dirty = false
+	}
+	j := f.journal
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	last := map[string]uint64{}
+	total := 0
+	err := scanJournal(j.path, j.legacy, func(rec journalRecord) bool {
+		last[rec.Cid] = rec.Seq
+		total++
+		return true
+	})
+	if err != nil {
+		return 0, err
+	}
+	if ctxErr := checkContextError(ctx, f.isDebug()); ctxErr != nil {
+		return 0, ctxErr
+	}
+	compacted := bytes.Buffer{}
+	var encodeErr error
+	err = scanJournal(j.path, j.legacy, func(rec journalRecord) bool {
+		if last[rec.Cid] != rec.Seq {
+			return true
+		}
+		data, err := json.Marshal(rec)
+		if err != nil {
+			encodeErr = err
+			return false
+		}
+		fmt.Fprintf(&compacted, "%s\n", frameLine(data))
+		return true
+	})
+	if err != nil {
+		return 0, err
+	}
+	if encodeErr != nil {
+		return 0, ErrJournalWritingFailed
+	}
+	if err := write(f.internalPath(_tempDir), j.path, compacted.Bytes()); err != nil {
+		log.Printf("err: writing compacted journal failed: %s, %v\n", j.path, err)
+		return 0, ErrJournalWritingFailed
+	}
+	// appends continue on compacted journal, file previously open was renamed over
+	file, err := os.OpenFile(j.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0666)
+	if err != nil {
+		log.Printf("err: reopening compacted journal failed: %s, %v\n", j.path, err)
+		return 0, ErrJournalWritingFailed
+	}
+	j.file.Close()
+	j.file = file
+	dropped := total - len(last)
+	if f.isDebug() {
+		log.Printf("debug: compacted journal: %s, %d of %d entries dropped\n", f.bucket, dropped, total)
+	}
+	return dropped, nil
+}
@@ -0,0 +1,63 @@
+package fsstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrKeyNotFound is return, when key provider has no key with requested id.
+var ErrKeyNotFound = errors.New("fsobjectstore: encryption key not found")
+
+// KeyProvider resolves at-rest encryption keys by id. Keys are resolved once per id and cached for the
+// lifetime of store, so providers may be backed by remote key management services.
+type KeyProvider interface {
+	Key(ctx context.Context, id string) ([]byte, error)
+}
+
+// StaticKeyProvider serves keys held in memory, by id
+type StaticKeyProvider map[string][]byte
+
+// Key - returns key with given id
+func (s StaticKeyProvider) Key(ctx context.Context, id string) ([]byte, error) {
+	key, ok := s[id]
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+	return key, nil
+}
+
+// fileKeyProvider serves keys stored as files named by key id
+type fileKeyProvider struct {
+	dir string
+}
+
+// NewFileKeyProvider creates KeyProvider reading key with id from file `<dir>/<id>`. File holds either raw
+// key bytes, or key encoded as hex (surrounding whitespace ignored).
+func NewFileKeyProvider(dir string) KeyProvider {
+	return &fileKeyProvider{dir: dir}
+}
+
+// Key - reads key with given id from key directory
+func (p *fileKeyProvider) Key(ctx context.Context, id string) ([]byte, error) {
+	if len(id) == 0 || strings.ContainsAny(id, `/\`) || id == "." || id == ".." {
+		return nil, ErrKeyNotFound
+	}
+	data, err := ioutil.ReadFile(filepath.Join(p.dir, id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	text := bytes.TrimSpace(data)
+	if key, err := hex.DecodeString(string(text)); err == nil && len(key) > 0 {
+		return key, nil
+	}
+	return data, nil
+}
@@ -0,0 +1,157 @@
+package fsstore
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/igumus/go-objectstore-lib"
+	"github.com/ipfs/go-cid"
+)
+
+// ObjectKind represents kind of listed object
+type ObjectKind string
+
+const (
+	// KindManifest marks ipld node object (e.g. chunk manifest created via `CreateChunked`), which may link to
+	// other objects
+	KindManifest ObjectKind = "manifest"
+	// KindRaw marks raw content object, linking to no other object
+	KindRaw ObjectKind = "raw"
+)
+
+// ListFilter represents which objects a kind aware listing returns
+type ListFilter int
+
+const (
+	// ListAll lists every object
+	ListAll ListFilter = iota
+	// ListRoots lists objects no manifest object links to, i.e. whole objects clients created
+	ListRoots
+	// ListManifests lists manifest objects only
+	ListManifests
+	// ListRaw lists raw objects only, including chunks manifests link to
+	ListRaw
+)
+
+// KindListEvent captures a listed object along with its kind, and whether no manifest object links to it
+type KindListEvent struct {
+	Object string
+	Kind   ObjectKind
+	Root   bool
+	Error  error
+}
+
+// KindLister defines the functions clients need to list objects by kind.
+type KindLister interface {
+	ListObjectKinds(context.Context, ListFilter) <-chan KindListEvent
+}
+
+var _ KindLister = (*fsObjectStoreService)(nil)
+
+// ListObjectKinds - lists objects as `ListObject` does, annotated with their kind and keeping only objects
+// matching given filter
+func (f *fsObjectStoreService) ListObjectKinds(ctx context.Context, filter ListFilter) <-chan KindListEvent {
+	ch := make(chan KindListEvent, f.listBuffer)
+
+	go func() {
+		defer close(ch)
+		err := f.authorize(ctx, OpList, cid.Undef)
+		var linked map[string]struct{}
+		if err == nil {
+			linked, err = f.manifestLinks(ctx)
+		}
+		if err != nil {
+			select {
+			case ch <- KindListEvent{Error: err}:
+			case <-ctx.Done():
+			}
+			return
+		}
+		for event := range f.ListObject(ctx) {
+			out := KindListEvent{Object: event.Object, Error: event.Error}
+			if event.Error == nil {
+				c, err := cid.Decode(event.Object)
+				if err != nil {
+					continue
+				}
+				out.Kind = kindOf(c)
+				_, ok := linked[c.String()]
+				out.Root = !ok
+				if !filter.match(out) {
+					continue
+				}
+			}
+			select {
+			case ch <- out:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+// kindOf - returns kind of object with specified cid
+func kindOf(c cid.Cid) ObjectKind {
+	if c.Prefix().Codec == cid.Raw {
+		return KindRaw
+	}
+	return KindManifest
+}
+
+// manifestLinks - returns set of objects manifest objects link to, from recorded references; raw objects
+// recording references (deltas, linking to their base) do not make their base a linked object
+func (f *fsObjectStoreService) manifestLinks(ctx context.Context) (map[string]struct{}, error) {
+	linked := map[string]struct{}{}
+	err := filepath.Walk(f.internalPath(_refsDir, _refsOut), func(path string, info os.FileInfo, err error) error {
+		if ctxErr := checkContextError(ctx, f.isDebug()); ctxErr != nil {
+			return ctxErr
+		}
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		if err != nil || info.IsDir() {
+			return err
+		}
+		parent, err := cid.Decode(info.Name())
+		if err != nil || kindOf(parent) != KindManifest || !f.has(parent) {
+			// references of deleted manifests linger until they are purged
+			return nil
+		}
+		f.refMu.Lock()
+		children, err := f.readRefs(parent)
+		f.refMu.Unlock()
+		if err != nil {
+			return err
+		}
+		for _, child := range children {
+			linked[child.String()] = struct{}{}
+		}
+		return nil
+	})
+	if err != nil {
+		if ctxErr := checkContextError(ctx, f.isDebug()); ctxErr != nil {
+			return nil, ctxErr
+		}
+		log.Printf("err: reading manifest references failed: %s, %v\n", f.bucket, err)
+		return nil, objectstore.ErrReferenceReadingFailed
+	}
+	return linked, nil
+}
+
+// match - checks whether listed object passes filter
+func (l ListFilter) match(event KindListEvent) bool {
+	switch l {
+	case ListRoots:
+		return event.Root
+	case ListManifests:
+		return event.Kind == KindManifest
+	case ListRaw:
+		return event.Kind == KindRaw
+	default:
+		return true
+	}
+}
@@ -0,0 +1,98 @@
+// Package kms resolves fsstore encryption keys from customer managed key services. Data keys are stored
+// wrapped (encrypted) by a key management service, and unwrapped on first use via the small Decrypter
+// interface, so applications adapt the SDK they already use (e.g. aws-sdk-go's KMS client) and fsstore
+// itself stays free of cloud dependencies. A Vault transit Decrypter is provided via plain HTTP.
+package kms
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	fsstore "github.com/igumus/go-objectstore-fs"
+)
+
+// ErrUnwrapFailed is return, when key management service could not decrypt a wrapped data key.
+var ErrUnwrapFailed = errors.New("kms: unwrapping data key failed")
+
+// Decrypter defines the key management service operation clients need to provide for unwrapping data keys.
+type Decrypter interface {
+	Decrypt(ctx context.Context, ciphertext []byte) ([]byte, error)
+}
+
+// DecrypterFunc adapts an ordinary function (e.g. a closure calling AWS KMS `Decrypt`) to Decrypter
+type DecrypterFunc func(ctx context.Context, ciphertext []byte) ([]byte, error)
+
+// Decrypt - calls fn
+func (fn DecrypterFunc) Decrypt(ctx context.Context, ciphertext []byte) ([]byte, error) {
+	return fn(ctx, ciphertext)
+}
+
+// Captures/Represents key provider configuration information
+type providerConfig struct {
+	debug bool
+}
+
+// A ProviderOption sets options such as debug mode.
+type ProviderOption func(*providerConfig)
+
+// WithDebugMode returns a ProviderOption that specifies debug mode.
+// If not set, the default is `false`
+func WithDebugMode(dm bool) ProviderOption {
+	return func(pc *providerConfig) {
+		pc.debug = dm
+	}
+}
+
+// provider unwraps data keys stored as files named by key id
+type provider struct {
+	dir       string
+	decrypter Decrypter
+	cfg       *providerConfig
+	mu        sync.Mutex
+	keys      map[string][]byte
+}
+
+// NewProvider creates fsstore.KeyProvider reading wrapped data key with id from file `<dir>/<id>`, and
+// unwrapping it via decrypter. Unwrapped keys are kept in memory only.
+func NewProvider(dir string, decrypter Decrypter, opts ...ProviderOption) fsstore.KeyProvider {
+	cfg := &providerConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return &provider{dir: dir, decrypter: decrypter, cfg: cfg, keys: map[string][]byte{}}
+}
+
+// Key - returns unwrapped data key with given id
+func (p *provider) Key(ctx context.Context, id string) ([]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if key, ok := p.keys[id]; ok {
+		return key, nil
+	}
+	if len(id) == 0 || strings.ContainsAny(id, `/\`) || id == "." || id == ".." {
+		return nil, fsstore.ErrKeyNotFound
+	}
+	wrapped, err := ioutil.ReadFile(filepath.Join(p.dir, id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fsstore.ErrKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	key, err := p.decrypter.Decrypt(ctx, wrapped)
+	if err != nil {
+		log.Printf("err: unwrapping data key failed: %s, %v\n", id, err)
+		return nil, ErrUnwrapFailed
+	}
+	if p.cfg.debug {
+		log.Printf("debug: unwrapped data key: %s\n", id)
+	}
+	p.keys[id] = key
+	return key, nil
+}
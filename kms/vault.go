@@ -0,0 +1,60 @@
+package kms
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// vault unwraps data keys via Vault transit secrets engine
+type vault struct {
+	addr   string
+	token  string
+	key    string
+	client *http.Client
+}
+
+// NewVaultDecrypter creates Decrypter unwrapping data keys via transit key named key of Vault at addr
+// (e.g. `https://vault:8200`), authenticating with token. Wrapped data key files hold transit ciphertext
+// (`vault:v1:...`) as returned by transit `encrypt` of base64 encoded data key.
+func NewVaultDecrypter(addr, token, key string, client *http.Client) Decrypter {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &vault{addr: strings.TrimRight(addr, "/"), token: token, key: key, client: client}
+}
+
+// Decrypt - calls transit decrypt endpoint with ciphertext, and returns decoded plaintext
+func (v *vault) Decrypt(ctx context.Context, ciphertext []byte) ([]byte, error) {
+	body, err := json.Marshal(map[string]string{"ciphertext": strings.TrimSpace(string(ciphertext))})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, v.addr+"/v1/transit/decrypt/"+v.key, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", v.token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("kms: vault responded %s", resp.Status)
+	}
+	var decoded struct {
+		Data struct {
+			Plaintext string `json:"plaintext"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(decoded.Data.Plaintext)
+}
@@ -0,0 +1,136 @@
+package fsstore
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/igumus/go-objectstore-lib"
+	"github.com/ipfs/go-cid"
+)
+
+// _flatLayout handles the name of built-in historical layout storing object files directly in bucket directory
+const _flatLayout = "flat"
+
+// _builtinLayouts maps names of historical layouts store knows to bucket relative links of objects
+var _builtinLayouts = map[string]objectstore.LinkFunc{
+	_flatLayout: func(key string) string { return key },
+}
+
+// layoutProbe captures a historical layout objects of bucket may still be stored in
+type layoutProbe struct {
+	name string
+	link objectstore.LinkFunc
+}
+
+// legacyLink - returns bucket relative link of object with specified cid in layout, or empty link when it is not a
+// safe relative path (or is link of current layout)
+func (p layoutProbe) legacyLink(c cid.Cid) string {
+	key := c.String()
+	link := p.link(key)
+	if !validLink(link) || link == objectLink(c) {
+		return ""
+	}
+	return link
+}
+
+// recordLayouts - records configured historical layouts in bucket metadata, and resolves every layout recorded
+// so far (by this or an earlier configuration) to probes; recorded layouts with unknown links are skipped
+func (f *fsObjectStoreService) recordLayouts(configured map[string]objectstore.LinkFunc) error {
+	meta, err := f.readBucketMetadata()
+	if err != nil {
+		return err
+	}
+	recorded := map[string]struct{}{}
+	for _, name := range meta.Layouts {
+		recorded[name] = struct{}{}
+	}
+	changed := false
+	for name := range configured {
+		if _, ok := recorded[name]; !ok {
+			meta.Layouts = append(meta.Layouts, name)
+			recorded[name] = struct{}{}
+			changed = true
+		}
+	}
+	if changed && !f.readOnly {
+		sort.Strings(meta.Layouts)
+		if err := f.writeBucketMetadata(f.bucketDir(), meta); err != nil {
+			return err
+		}
+	}
+	f.layouts = nil
+	for _, name := range meta.Layouts {
+		link, ok := configured[name]
+		if !ok {
+			link, ok = _builtinLayouts[name]
+		}
+		if !ok {
+			log.Printf("warn: historical layout of bucket unknown, not probed: %s, %s\n", f.bucket, name)
+			continue
+		}
+		f.layouts = append(f.layouts, layoutProbe{name: name, link: link})
+	}
+	return nil
+}
+
+// readLegacy - reads object with specified cid from historical layouts of bucket (see `WithHistoricalLayout`),
+// moving it into current layout on hit when configured; reports `ErrObjectNotExists` when no layout holds it
+func (f *fsObjectStoreService) readLegacy(ctx context.Context, c cid.Cid) ([]byte, error) {
+	stripe, path := f.legacyPath(c)
+	if len(path) == 0 {
+		return nil, objectstore.ErrObjectNotExists
+	}
+	data, err := f.load(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	if f.isDebug() {
+		log.Printf("debug: read object from historical layout: %s, %s\n", c, path)
+	}
+	if f.layoutRewrite {
+		f.relayout(ctx, c, stripe, path)
+	}
+	return data, nil
+}
+
+// legacyPath - returns stripe and path of object file with specified cid in first historical layout holding it,
+// or empty path when none does
+func (f *fsObjectStoreService) legacyPath(c cid.Cid) (string, string) {
+	for _, probe := range f.layouts {
+		link := probe.legacyLink(c)
+		if len(link) == 0 {
+			continue
+		}
+		for _, stripe := range f.stripeDirs() {
+			if path := filepath.Join(stripe, filepath.FromSlash(link)); exists(path) {
+				return stripe, path
+			}
+		}
+	}
+	return "", ""
+}
+
+// relayout - moves object file with specified cid at path of historical layout to current layout of its stripe,
+// within open file budget as other object file operations; object is read from historical layout again when
+// moving fails (or budget is not available before ctx is done)
+func (f *fsObjectStoreService) relayout(ctx context.Context, c cid.Cid, stripe, path string) {
+	target := filepath.Join(stripe, objectLink(c))
+	if exists(target) {
+		return
+	}
+	if err := f.fds.acquire(ctx); err != nil {
+		return
+	}
+	defer f.fds.release()
+	if err := placeFile(target, func() error { return os.Rename(path, target) }); err != nil {
+		log.Printf("warn: moving object into current layout failed: %s, %v\n", path, err)
+		return
+	}
+	f.verified.forget(c.String())
+	if f.isDebug() {
+		log.Printf("debug: moved object into current layout: %s, %s\n", path, target)
+	}
+}
@@ -0,0 +1,76 @@
+package fsstore
+
+import (
+	"context"
+	"log"
+	"path/filepath"
+	"sync/atomic"
+
+	"github.com/ipfs/go-cid"
+)
+
+// Provisioner defines the functions clients need to provision bucket of a lazily initialized store
+// (see `WithLazyInit`) explicitly.
+type Provisioner interface {
+	EnsureBucket(context.Context) error
+}
+
+var _ Provisioner = (*fsObjectStoreService)(nil)
+
+// EnsureBucket - creates bucket directories (and opens journal and audit log, when configured) of store, unless
+// already provisioned. Stores opened without `WithLazyInit` are provisioned when opened.
+func (f *fsObjectStoreService) EnsureBucket(ctx context.Context) error {
+	if err := f.authorize(ctx, OpAdmin, cid.Undef); err != nil {
+		return err
+	}
+	err := f.ensureBucket()
+	f.audit(ctx, OpAdmin, cid.Undef, err)
+	return err
+}
+
+// ensureBucket - provisions store on first call when provisioning is deferred, see `WithLazyInit`
+func (f *fsObjectStoreService) ensureBucket() error {
+	if f.isProvisioned() {
+		return nil
+	}
+	f.provisionMu.Lock()
+	defer f.provisionMu.Unlock()
+	if f.pending == nil {
+		return nil
+	}
+	if err := f.provision(f.pending); err != nil {
+		log.Printf("err: provisioning bucket failed: %s, %v\n", f.bucket, err)
+		return err
+	}
+	if f.isDebug() {
+		log.Printf("debug: bucket provisioned: %s\n", f.bucketDir())
+	}
+	f.pending = nil
+	atomic.StoreInt32(&f.provisioned, 1)
+	return nil
+}
+
+// isProvisioned - checks whether store is provisioned; an unprovisioned store holds no objects yet
+func (f *fsObjectStoreService) isProvisioned() bool {
+	return atomic.LoadInt32(&f.provisioned) == 1
+}
+
+// provisionedOnDisk - checks whether bucket and internal directories of store already exist, in which case
+// opening store lazily has nothing to defer
+func (f *fsObjectStoreService) provisionedOnDisk() bool {
+	return exists(f.bucketDir()) && exists(f.internalPath())
+}
+
+// deferProvision - defers provisioning of store with given configuration to first write; placement ring is
+// built in memory meanwhile, so objects are located on (not yet existing) stripes as usual
+func (f *fsObjectStoreService) deferProvision(cfg *fsObjectStoreConfig) {
+	f.pending = cfg
+	if f.active < 2 {
+		return
+	}
+	members := make([]string, 0, f.active)
+	for _, stripe := range f.stripes[:f.active] {
+		members = append(members, filepath.Dir(stripe))
+	}
+	f.ring = newRing(members, _ringVnodes)
+}
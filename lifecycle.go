@@ -0,0 +1,215 @@
+package fsstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/ipfs/go-cid"
+)
+
+// ErrInvalidLifecycleRule is return, when a lifecycle rule has an unknown action, an unparsable selector, or
+// transitions objects while no transitioner is configured.
+var ErrInvalidLifecycleRule = errors.New("fsobjectstore: invalid lifecycle rule")
+
+// LifecycleAction represents what lifecycle rule does with objects it matches
+type LifecycleAction string
+
+const (
+	// LifecycleExpire moves matched objects to trash, as `DeleteObject` does
+	LifecycleExpire LifecycleAction = "expire"
+	// LifecycleTransition hands matched objects to transitioner (see `WithLifecycleTransition`), e.g. one
+	// demoting them to a cold tier, and moves them to trash once transitioned
+	LifecycleTransition LifecycleAction = "transition"
+)
+
+// LifecycleRule declares action applied to objects of bucket older than `After` (since they were created),
+// labeled as `Selector` requires (see `ParseSelector`) and of at least `MinSize` bytes. Zero fields match every object.
+type LifecycleRule struct {
+	Name     string          `json:"name"`
+	Selector string          `json:"selector,omitempty"`
+	MinSize  int64           `json:"minSize,omitempty"`
+	After    time.Duration   `json:"after,omitempty"`
+	Action   LifecycleAction `json:"action"`
+}
+
+// Transitioner moves object content out of store, e.g. to a cold tier, for `LifecycleTransition` rules
+type Transitioner interface {
+	Transition(ctx context.Context, c cid.Cid, r io.Reader) error
+}
+
+// LifecycleReport captures outcome of a lifecycle run; objects are acted upon by first rule they match
+type LifecycleReport struct {
+	Expired      int
+	Transitioned int
+	Skipped      int
+	Sample       []cid.Cid
+	DryRun       bool
+}
+
+// LifecycleManager defines the functions clients need to apply lifecycle rules of bucket on demand.
+type LifecycleManager interface {
+	ApplyLifecycle(context.Context) (*LifecycleReport, error)
+	ApplyLifecycleWith(context.Context, ...CallOption) (*LifecycleReport, error)
+}
+
+var _ LifecycleManager = (*fsObjectStoreService)(nil)
+
+// lifecycleMatch captures a lifecycle rule with its parsed selector
+type lifecycleMatch struct {
+	rule     LifecycleRule
+	selector *Selector
+}
+
+// validateLifecycle - checks that rules are applicable with given transitioner
+func validateLifecycle(rules []LifecycleRule, t Transitioner) error {
+	for _, rule := range rules {
+		if _, err := ParseSelector(rule.Selector); err != nil {
+			return ErrInvalidLifecycleRule
+		}
+		switch rule.Action {
+		case LifecycleExpire:
+		case LifecycleTransition:
+			if t == nil {
+				return ErrInvalidLifecycleRule
+			}
+		default:
+			return ErrInvalidLifecycleRule
+		}
+	}
+	return nil
+}
+
+// ApplyLifecycle - expires objects past `Expires` of their metadata (see `WithTTL`), and applies lifecycle rules of
+// bucket (see `WithLifecycleRules`) to other objects, as scheduled maintenance does; pinned objects are kept
+func (f *fsObjectStoreService) ApplyLifecycle(ctx context.Context) (*LifecycleReport, error) {
+	if err := f.authorize(ctx, OpAdmin, cid.Undef); err != nil {
+		return nil, err
+	}
+	report, err := f.applyLifecycle(withSystem(ctx))
+	f.audit(ctx, OpAdmin, cid.Undef, err)
+	return report, err
+}
+
+// ApplyLifecycleWith - applies lifecycle rules as `ApplyLifecycle` does, tuned by given call options; on dry run
+// (see `WithDryRun`) matched objects are counted, but left in place
+func (f *fsObjectStoreService) ApplyLifecycleWith(ctx context.Context, opts ...CallOption) (*LifecycleReport, error) {
+	ctx, cancel := newCallConfig(opts).withCall(ctx)
+	defer cancel()
+	return f.ApplyLifecycle(ctx)
+}
+
+// applyLifecycle - walks bucket, expires objects past `Expires` of their metadata, and applies first rule every
+// other object matches; pinned objects are left alone
+func (f *fsObjectStoreService) applyLifecycle(ctx context.Context) (*LifecycleReport, error) {
+	dryRun := isDryRun(ctx)
+	report := &LifecycleReport{DryRun: dryRun}
+	rules := make([]lifecycleMatch, 0, len(f.lifecycle))
+	for _, rule := range f.lifecycle {
+		sel, _ := ParseSelector(rule.Selector)
+		rules = append(rules, lifecycleMatch{rule: rule, selector: sel})
+	}
+
+	type matched struct {
+		c      cid.Cid
+		action LifecycleAction
+	}
+	now := f.now()
+	due := []matched{}
+	walkErr := f.walkObjects(ctx, func(c cid.Cid, path string, info os.FileInfo) error {
+		meta, err := f.GetMetadata(ctx, c)
+		if err != nil {
+			log.Printf("warn: skipping object of unreadable metadata: %s, %v\n", c, err)
+			return nil
+		}
+		if meta.Pinned {
+			return nil
+		}
+		if !meta.Expires.IsZero() && !now.Before(meta.Expires) {
+			due = append(due, matched{c: c, action: LifecycleExpire})
+			return nil
+		}
+		for _, m := range rules {
+			if now.Sub(f.placedAt(c.String(), info)) < m.rule.After {
+				continue
+			}
+			if m.rule.MinSize > 0 && f.objectSize(path, info.Size()) < m.rule.MinSize {
+				continue
+			}
+			if len(m.selector.reqs) > 0 && !m.selector.Matches(meta.Tags) {
+				continue
+			}
+			due = append(due, matched{c: c, action: m.rule.Action})
+			return nil
+		}
+		return nil
+	})
+	if walkErr != nil {
+		return nil, walkErr
+	}
+
+	for _, m := range due {
+		if dryRun {
+			f.countLifecycle(report, m.c, m.action)
+			continue
+		}
+		err := f.applyRule(ctx, m.c, m.action)
+		switch {
+		case err == nil:
+			f.countLifecycle(report, m.c, m.action)
+		case errors.Is(err, ErrObjectReferenced):
+			report.Skipped++
+		default:
+			if ctxErr := checkContextError(ctx, f.isDebug()); ctxErr != nil {
+				return report, ctxErr
+			}
+			log.Printf("warn: applying lifecycle rule failed: %s, %s, %v\n", m.action, m.c, err)
+			report.Skipped++
+		}
+	}
+	if f.isDebug() {
+		log.Printf("debug: applied lifecycle rules: %s, %d expired, %d transitioned, %d skipped, dry run %t\n",
+			f.bucket, report.Expired, report.Transitioned, report.Skipped, dryRun)
+	}
+	return report, nil
+}
+
+// countLifecycle - accounts object acted upon in lifecycle report
+func (f *fsObjectStoreService) countLifecycle(report *LifecycleReport, c cid.Cid, action LifecycleAction) {
+	if action == LifecycleTransition {
+		report.Transitioned++
+	} else {
+		report.Expired++
+	}
+	report.Sample = sample(report.Sample, c)
+}
+
+// applyRule - applies lifecycle action to object; referenced objects stay in store, so they are not transitioned either
+func (f *fsObjectStoreService) applyRule(ctx context.Context, c cid.Cid, action LifecycleAction) error {
+	if action == LifecycleTransition {
+		count, err := f.readRefCount(c)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrObjectReferenced
+		}
+		if err := f.transition(ctx, c); err != nil {
+			return err
+		}
+	}
+	return f.deleteObject(ctx, c)
+}
+
+// transition - hands content of object to transitioner
+func (f *fsObjectStoreService) transition(ctx context.Context, c cid.Cid) error {
+	obj, err := f.openObject(ctx, c)
+	if err != nil {
+		return err
+	}
+	defer obj.Close()
+	return f.transitioner.Transition(ctx, c, obj)
+}
@@ -0,0 +1,197 @@
+package fsstore
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/igumus/go-objectstore-lib"
+)
+
+// ErrListIncomplete is return, when listing continued past walk errors skipped some entries.
+var ErrListIncomplete = errors.New("fsobjectstore: listing incomplete")
+
+// ListError is reported on listing, when an entry of bucket can not be walked
+type ListError struct {
+	Path string
+	Err  error
+}
+
+// Error - returns error message of walk failure, along with offending path
+func (e *ListError) Error() string {
+	return fmt.Sprintf("fsobjectstore: listing failed: %s: %v", e.Path, e.Err)
+}
+
+// Unwrap - returns underlying walk error
+func (e *ListError) Unwrap() error {
+	return e.Err
+}
+
+// ListSummary is reported as last event of listing continuing past walk errors (see `WithContinueOnError`),
+// with counts of listed objects and skipped entries; it wraps `ErrListIncomplete` when entries are skipped
+type ListSummary struct {
+	Listed  int64
+	Skipped int64
+}
+
+// Error - returns summary of listing
+func (s *ListSummary) Error() string {
+	return fmt.Sprintf("fsobjectstore: listed %d objects, skipped %d entries", s.Listed, s.Skipped)
+}
+
+// Unwrap - returns `ErrListIncomplete` when entries are skipped
+func (s *ListSummary) Unwrap() error {
+	if s.Skipped > 0 {
+		return ErrListIncomplete
+	}
+	return nil
+}
+
+// WithContinueOnError returns a CallOption that specifies listing reports walk errors as events and continues
+// past them, closing with a `*ListSummary` event. Options not applying to a call are ignored.
+func WithContinueOnError(c bool) CallOption {
+	return func(cc *callConfig) {
+		cc.tolerant = c
+	}
+}
+
+// ListObjectWith - lists objects of bucket as `ListObject` does, tuned by given call options
+func (f *fsObjectStoreService) ListObjectWith(ctx context.Context, opts ...CallOption) <-chan objectstore.ListObjectEvent {
+	ctx, cancel := newCallConfig(opts).withCall(ctx)
+	return f.listObject(ctx, cancel)
+}
+
+// lister feeds listed objects to consumer channel. When consumer stalls longer than stall timeout,
+// remaining walk is spilled to a paged snapshot in temp directory, so walk finishes (releasing its
+// directory handles) and consumer is fed from snapshot afterwards.
+type lister struct {
+	f        *fsObjectStoreService
+	ctx      context.Context
+	ch       chan<- objectstore.ListObjectEvent
+	spill    *os.File
+	buf      *bufio.Writer
+	count    int64
+	tolerant bool
+	skipped  int64
+	pending  []*ListError
+}
+
+// emit - sends listed object to consumer, or spills it once consumer stalled
+func (l *lister) emit(name string) error {
+	l.count++
+	if l.spill != nil {
+		return l.write(name)
+	}
+	event := objectstore.ListObjectEvent{Object: name}
+	if l.f.listStall <= 0 {
+		select {
+		case l.ch <- event:
+			return nil
+		case <-l.ctx.Done():
+			return checkContextError(l.ctx, l.f.isDebug())
+		}
+	}
+
+	timer := time.NewTimer(l.f.listStall)
+	defer timer.Stop()
+	select {
+	case l.ch <- event:
+		return nil
+	case <-l.ctx.Done():
+		return checkContextError(l.ctx, l.f.isDebug())
+	case <-timer.C:
+	}
+
+	file, err := stage(l.f.tempDir)
+	if err != nil {
+		return err
+	}
+	if l.f.isDebug() {
+		log.Printf("debug: list consumer stalled, spilling to snapshot: %s\n", file.Name())
+	}
+	l.spill = file
+	l.buf = bufio.NewWriter(file)
+	return l.write(name)
+}
+
+// fail - reports entry which can not be walked, aborting walk unless listing continues past errors. Errors
+// found once walk is spilled are reported after spilled objects.
+func (l *lister) fail(path string, err error) error {
+	listErr := &ListError{Path: path, Err: err}
+	log.Printf("err: listing entry failed: %s, %v\n", path, err)
+	if !l.tolerant {
+		return listErr
+	}
+	l.skipped++
+	if l.spill != nil {
+		l.pending = append(l.pending, listErr)
+		return nil
+	}
+	select {
+	case l.ch <- objectstore.ListObjectEvent{Error: listErr}:
+		return nil
+	case <-l.ctx.Done():
+		return checkContextError(l.ctx, l.f.isDebug())
+	}
+}
+
+// write - appends listed object to spilled snapshot
+func (l *lister) write(name string) error {
+	if _, err := l.buf.WriteString(name + "\n"); err != nil {
+		log.Printf("err: spilling listing failed: %s, %v\n", l.spill.Name(), err)
+		return err
+	}
+	return nil
+}
+
+// finish - feeds spilled snapshot (if any) to consumer, then reports walk error (if any), or summary when
+// listing continues past errors
+func (l *lister) finish(walkErr error) {
+	if l.spill != nil {
+		if err := l.drain(); err != nil && walkErr == nil {
+			walkErr = err
+		}
+	}
+	events := []objectstore.ListObjectEvent{}
+	for _, listErr := range l.pending {
+		events = append(events, objectstore.ListObjectEvent{Error: listErr})
+	}
+	switch {
+	case walkErr != nil:
+		events = append(events, objectstore.ListObjectEvent{Object: "", Error: walkErr})
+	case l.tolerant:
+		summary := &ListSummary{Listed: l.count, Skipped: l.skipped}
+		events = append(events, objectstore.ListObjectEvent{Error: summary})
+	}
+	for _, event := range events {
+		select {
+		case l.ch <- event:
+		case <-l.ctx.Done():
+			return
+		}
+	}
+}
+
+// drain - streams spilled snapshot page by page to consumer and removes it
+func (l *lister) drain() error {
+	defer discard(l.spill)
+	if err := l.buf.Flush(); err != nil {
+		return err
+	}
+	if _, err := l.spill.Seek(0, 0); err != nil {
+		return err
+	}
+	scanner := bufio.NewScanner(l.spill)
+	for scanner.Scan() {
+		select {
+		case l.ch <- objectstore.ListObjectEvent{Object: scanner.Text()}:
+		case <-l.ctx.Done():
+			return checkContextError(l.ctx, l.f.isDebug())
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,198 @@
+package fsstore
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/ipfs/go-cid"
+)
+
+// ErrInvalidConfigChange is return, when a runtime configuration change is not valid; no change is applied then.
+var ErrInvalidConfigChange = errors.New("fsobjectstore: invalid configuration change")
+
+// RuntimeConfig captures settings of store that can be changed while it is open, see `UpdateConfig`
+type RuntimeConfig struct {
+	Debug               bool             `json:"debug"`
+	ScrubRate           int64            `json:"scrubRate"`
+	RebalanceRate       int64            `json:"rebalanceRate"`
+	IOBudget            IOBudget         `json:"ioBudget"`
+	NegativeCacheSize   int              `json:"negativeCacheSize"`
+	MaintenanceSchedule string           `json:"maintenanceSchedule,omitempty"`
+	GCRetention         *PolicyRetention `json:"gcRetention,omitempty"`
+}
+
+// ConfigChanges captures changes of runtime configuration; nil fields leave their setting unchanged. Empty
+// `MaintenanceSchedule` pauses scheduled maintenance, and `GCRetention` with neither field set stops scheduled
+// garbage collection.
+type ConfigChanges struct {
+	Debug               *bool            `json:"debug,omitempty"`
+	ScrubRate           *int64           `json:"scrubRate,omitempty"`
+	RebalanceRate       *int64           `json:"rebalanceRate,omitempty"`
+	IOBudget            *IOBudget        `json:"ioBudget,omitempty"`
+	NegativeCacheSize   *int             `json:"negativeCacheSize,omitempty"`
+	MaintenanceSchedule *string          `json:"maintenanceSchedule,omitempty"`
+	GCRetention         *PolicyRetention `json:"gcRetention,omitempty"`
+}
+
+// ConfigUpdater defines the functions clients need to tune store while it is open, avoiding restarts.
+type ConfigUpdater interface {
+	UpdateConfig(context.Context, ConfigChanges) (RuntimeConfig, error)
+	CurrentConfig() RuntimeConfig
+}
+
+var _ ConfigUpdater = (*fsObjectStoreService)(nil)
+
+// isDebug - checks whether store runs in debug mode
+func (f *fsObjectStoreService) isDebug() bool {
+	return atomic.LoadInt32(&f.debug) == 1
+}
+
+// setDebug - switches debug mode of store
+func (f *fsObjectStoreService) setDebug(debug bool) {
+	var v int32
+	if debug {
+		v = 1
+	}
+	atomic.StoreInt32(&f.debug, v)
+}
+
+// UpdateConfig - applies changes to runtime configuration of store, and returns resulting configuration.
+// Changes are validated as a whole before any is applied. Changing maintenance schedule of a store opened
+// without one starts scheduled maintenance.
+func (f *fsObjectStoreService) UpdateConfig(ctx context.Context, changes ConfigChanges) (RuntimeConfig, error) {
+	if err := f.authorize(ctx, OpAdmin, cid.Undef); err != nil {
+		return RuntimeConfig{}, err
+	}
+	err := f.updateConfig(changes)
+	f.audit(ctx, OpAdmin, cid.Undef, err)
+	if err != nil {
+		return RuntimeConfig{}, err
+	}
+	return f.CurrentConfig(), nil
+}
+
+// updateConfig - validates changes, then applies them
+func (f *fsObjectStoreService) updateConfig(changes ConfigChanges) error {
+	f.liveMu.Lock()
+	defer f.liveMu.Unlock()
+	var sched schedule
+	if changes.MaintenanceSchedule != nil && len(*changes.MaintenanceSchedule) > 0 {
+		parsed, err := parseSchedule(*changes.MaintenanceSchedule)
+		if err != nil {
+			return ErrInvalidConfigChange
+		}
+		sched = parsed
+	}
+	// retention of a scheduler started by this change is the one set before, unless changed as well
+	gcRetention := f.policy.GCRetention
+	if changes.GCRetention != nil {
+		gcRetention = changes.GCRetention
+	}
+	var retention *RetentionPolicy
+	if gcRetention != nil && (gcRetention.KeepLast > 0 || len(gcRetention.KeepWithin) > 0) {
+		retention = &RetentionPolicy{KeepLast: gcRetention.KeepLast}
+		if len(gcRetention.KeepWithin) > 0 {
+			within, err := time.ParseDuration(gcRetention.KeepWithin)
+			if err != nil || within < 0 {
+				return ErrInvalidConfigChange
+			}
+			retention.KeepWithin = within
+		}
+	}
+	if (changes.ScrubRate != nil && *changes.ScrubRate < 0) || (changes.RebalanceRate != nil && *changes.RebalanceRate < 0) {
+		return ErrInvalidConfigChange
+	}
+	if changes.NegativeCacheSize != nil && *changes.NegativeCacheSize < 1 {
+		return ErrInvalidConfigChange
+	}
+	if changes.IOBudget != nil && (changes.IOBudget.BytesPerSec < 0 || changes.IOBudget.OpsPerSec < 0) {
+		return ErrInvalidConfigChange
+	}
+
+	policy := *f.policy
+	if changes.Debug != nil {
+		f.setDebug(*changes.Debug)
+	}
+	if changes.ScrubRate != nil {
+		f.scrubLimit.setRate(*changes.ScrubRate)
+		policy.ScrubRate = *changes.ScrubRate
+	}
+	if changes.RebalanceRate != nil {
+		f.rebalanceLimit.setRate(*changes.RebalanceRate)
+		policy.RebalanceRate = *changes.RebalanceRate
+	}
+	if changes.IOBudget != nil {
+		f.io.set(*changes.IOBudget)
+	}
+	if changes.NegativeCacheSize != nil {
+		f.negative.resize(*changes.NegativeCacheSize)
+	}
+	if changes.MaintenanceSchedule != nil || changes.GCRetention != nil {
+		if changes.MaintenanceSchedule != nil {
+			policy.MaintenanceSchedule = *changes.MaintenanceSchedule
+		}
+		if changes.GCRetention != nil {
+			policy.GCRetention = nil
+			if retention != nil {
+				policy.GCRetention = changes.GCRetention
+			}
+		}
+		f.rescheduleMaintenance(changes, sched, retention)
+	}
+	f.policy = &policy
+	if f.isDebug() {
+		log.Printf("debug: updated runtime configuration: %s\n", f.bucket)
+	}
+	return nil
+}
+
+// rescheduleMaintenance - applies schedule and retention changes to maintenance scheduler, starting one when
+// store has none yet; caller holds liveMu
+func (f *fsObjectStoreService) rescheduleMaintenance(changes ConfigChanges, sched schedule, retention *RetentionPolicy) {
+	m := f.maint
+	if m == nil {
+		if sched == nil {
+			return
+		}
+		f.maint = f.spawnMaintenance(sched, retention)
+		return
+	}
+	m.mu.Lock()
+	current, currentRetention := m.sched, m.retention
+	m.mu.Unlock()
+	if changes.MaintenanceSchedule != nil {
+		current = sched
+	}
+	if changes.GCRetention != nil {
+		currentRetention = retention
+	}
+	m.reschedule(current, currentRetention)
+}
+
+// CurrentConfig - returns runtime configuration of store
+func (f *fsObjectStoreService) CurrentConfig() RuntimeConfig {
+	f.liveMu.Lock()
+	policy := f.policy
+	f.liveMu.Unlock()
+	f.negative.mu.Lock()
+	cacheSize := f.negative.size
+	f.negative.mu.Unlock()
+	f.scrubLimit.mu.Lock()
+	scrubRate := f.scrubLimit.rate
+	f.scrubLimit.mu.Unlock()
+	f.rebalanceLimit.mu.Lock()
+	rebalanceRate := f.rebalanceLimit.rate
+	f.rebalanceLimit.mu.Unlock()
+	return RuntimeConfig{
+		Debug:               f.isDebug(),
+		ScrubRate:           scrubRate,
+		RebalanceRate:       rebalanceRate,
+		IOBudget:            f.IOBudget(),
+		NegativeCacheSize:   cacheSize,
+		MaintenanceSchedule: policy.MaintenanceSchedule,
+		GCRetention:         policy.GCRetention,
+	}
+}
@@ -0,0 +1,26 @@
+//go:build !windows
+
+package fsstore
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// lockFile - opens file at path holding an exclusive lock on it, until returned file is closed; fails with
+// ErrStoreLocked when another open file holds lock already
+func lockFile(path string) (*os.File, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0666)
+	if err != nil {
+		return nil, err
+	}
+	if err := unix.Flock(int(file.Fd()), unix.LOCK_EX|unix.LOCK_NB); err != nil {
+		file.Close()
+		if err == unix.EWOULDBLOCK {
+			return nil, ErrStoreLocked
+		}
+		return nil, err
+	}
+	return file, nil
+}
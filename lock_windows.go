@@ -0,0 +1,27 @@
+//go:build windows
+
+package fsstore
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// lockFile - opens file at path holding an exclusive lock on it, until returned file is closed; fails with
+// ErrStoreLocked when another open file holds lock already
+func lockFile(path string) (*os.File, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0666)
+	if err != nil {
+		return nil, err
+	}
+	flags := uint32(windows.LOCKFILE_EXCLUSIVE_LOCK | windows.LOCKFILE_FAIL_IMMEDIATELY)
+	if err := windows.LockFileEx(windows.Handle(file.Fd()), flags, 0, 1, 0, &windows.Overlapped{}); err != nil {
+		file.Close()
+		if err == windows.ERROR_LOCK_VIOLATION {
+			return nil, ErrStoreLocked
+		}
+		return nil, err
+	}
+	return file, nil
+}
@@ -0,0 +1,204 @@
+package fsstore
+
+import (
+	"context"
+	"io"
+	"log"
+	"sync"
+	"time"
+)
+
+// MaintenanceStatus captures outcome of last scheduled maintenance run
+type MaintenanceStatus struct {
+	Started   time.Time
+	Finished  time.Time
+	Next      time.Time
+	Verify    *VerifyReport
+	Lifecycle *LifecycleReport
+	GC        *GCReport
+	Err       string
+}
+
+// Maintainer defines the functions clients need to observe scheduled maintenance.
+type Maintainer interface {
+	MaintenanceStatus() MaintenanceStatus
+}
+
+var _ Maintainer = (*fsObjectStoreService)(nil)
+var _ io.Closer = (*fsObjectStoreService)(nil)
+
+// maintenance runs verification, lifecycle rules and garbage collection on schedule, serialized so runs never overlap
+type maintenance struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+	wake   chan struct{}
+
+	mu        sync.Mutex
+	sched     schedule
+	retention *RetentionPolicy
+	status    MaintenanceStatus
+}
+
+// startMaintenance - starts maintenance scheduler of store
+func (f *fsObjectStoreService) startMaintenance(sched schedule, retention *RetentionPolicy) {
+	f.liveMu.Lock()
+	defer f.liveMu.Unlock()
+	f.maint = f.spawnMaintenance(sched, retention)
+}
+
+// spawnMaintenance - runs a new maintenance scheduler in background
+func (f *fsObjectStoreService) spawnMaintenance(sched schedule, retention *RetentionPolicy) *maintenance {
+	ctx, cancel := context.WithCancel(withSystem(context.Background()))
+	m := &maintenance{sched: sched, retention: retention, cancel: cancel, done: make(chan struct{}), wake: make(chan struct{}, 1)}
+	go f.runMaintenance(ctx, m)
+	return m
+}
+
+// maintenance - returns maintenance scheduler of store, nil when none was started
+func (f *fsObjectStoreService) maintenance() *maintenance {
+	f.liveMu.Lock()
+	defer f.liveMu.Unlock()
+	return f.maint
+}
+
+// reschedule - replaces schedule and retention of maintenance, waking scheduler so next activation follows
+// new schedule; nil schedule pauses maintenance
+func (m *maintenance) reschedule(sched schedule, retention *RetentionPolicy) {
+	m.mu.Lock()
+	m.sched, m.retention = sched, retention
+	m.mu.Unlock()
+	select {
+	case m.wake <- struct{}{}:
+	default:
+	}
+}
+
+// runMaintenance - waits for next activation, then runs maintenance jobs one after another
+func (f *fsObjectStoreService) runMaintenance(ctx context.Context, m *maintenance) {
+	defer close(m.done)
+	for {
+		m.mu.Lock()
+		sched, retention := m.sched, m.retention
+		var next time.Time
+		if sched != nil {
+			next = sched.next(time.Now())
+			if next.IsZero() {
+				log.Printf("err: maintenance schedule never activates: %s\n", f.bucket)
+			}
+		}
+		m.status.Next = next
+		m.mu.Unlock()
+
+		// paused scheduler (no activation) only waits for a new schedule
+		timer := time.NewTimer(time.Until(next))
+		activate := timer.C
+		if next.IsZero() {
+			timer.Stop()
+			activate = nil
+		}
+		select {
+		case <-activate:
+		case <-m.wake:
+			timer.Stop()
+			continue
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		}
+
+		// steps run as jobs, so they are listed by `Jobs` and share its concurrency limit
+		status := MaintenanceStatus{Started: f.now()}
+		err := f.runTracked(ctx, JobVerify, func(ctx context.Context) (interface{}, error) {
+			report, err := f.Verify(ctx)
+			status.Verify = report
+			return report, err
+		})
+		if err == nil {
+			err = f.runTracked(ctx, JobLifecycle, func(ctx context.Context) (interface{}, error) {
+				report, err := f.applyLifecycle(ctx)
+				status.Lifecycle = report
+				return report, err
+			})
+		}
+		if err == nil && retention != nil {
+			err = f.runTracked(ctx, JobGC, func(ctx context.Context) (interface{}, error) {
+				report, err := f.CollectGarbage(ctx, *retention)
+				status.GC = report
+				return report, err
+			})
+		}
+		if err != nil {
+			status.Err = err.Error()
+			log.Printf("err: maintenance run failed: %s, %v\n", f.bucket, err)
+		}
+		status.Finished = f.now()
+		if f.isDebug() {
+			log.Printf("debug: maintenance run finished: %s, %s\n", f.bucket, status.Finished.Sub(status.Started))
+		}
+		m.mu.Lock()
+		m.status = status
+		m.mu.Unlock()
+	}
+}
+
+// MaintenanceStatus - returns outcome of last scheduled maintenance run, and next activation time
+func (f *fsObjectStoreService) MaintenanceStatus() MaintenanceStatus {
+	m := f.maintenance()
+	if m == nil {
+		return MaintenanceStatus{}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.status
+}
+
+// background - runs job in background until it returns or store is closed
+func (f *fsObjectStoreService) background(job func(context.Context)) {
+	f.bgWG.Add(1)
+	go func() {
+		defer f.bgWG.Done()
+		job(f.bgCtx)
+	}()
+}
+
+// _closeDrainTimeout handles the duration `Close` waits for client writes in flight to finish
+const _closeDrainTimeout = 10 * time.Second
+
+// Close - shuts store down in order: jobs and background workers are canceled and waited for (resumable jobs are
+// recorded to resume when store is reopened, see `StartJob`), then scheduled maintenance; once client writes in
+// flight finish, journal and audit log are flushed and statistics (and cid index) are checkpointed clean, so a
+// store closed mid-maintenance reopens consistent, and bucket is unlocked for other stores; stores not closed
+// reconcile their indexes when reopened. Stores opened read only (see `WithReadOnly`) have nothing to checkpoint
+func (f *fsObjectStoreService) Close() error {
+	f.closeOnce.Do(func() {
+		f.bgCancel()
+		f.bgWG.Wait()
+		if m := f.maintenance(); m != nil {
+			m.cancel()
+			<-m.done
+		}
+		if f.readOnly {
+			return
+		}
+		f.suspendJobs()
+		if f.webhook != nil {
+			f.webhook.close()
+		}
+		if !f.gate.drain(_closeDrainTimeout) {
+			log.Printf("warn: closing store with writes in flight: %s\n", f.bucket)
+		}
+		if f.journal != nil {
+			f.journal.flush()
+		}
+		if f.auditLog != nil {
+			f.auditLog.flush()
+		}
+		f.checkpointStats(true)
+		f.checkpointUsage()
+		f.checkpointNegativeCache()
+		f.placed.close()
+		f.markClosed()
+		f.unlockBucket()
+	})
+	return nil
+}
@@ -0,0 +1,192 @@
+package fsstore
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-cid"
+)
+
+// ErrMaintenance is return, when a write is rejected because store is in maintenance mode, or maintenance
+// mode is entered while already in it.
+var ErrMaintenance = errors.New("fsobjectstore: store in maintenance")
+
+// ErrNotInMaintenance is return, when maintenance mode is exited while store is not in it.
+var ErrNotInMaintenance = errors.New("fsobjectstore: store not in maintenance")
+
+// MaintenanceMode defines the functions clients need to pause writes of store, so maintenance (such as garbage
+// collection, rebalancing or migration) runs exclusively.
+type MaintenanceMode interface {
+	EnterMaintenance(context.Context) error
+	ExitMaintenance(context.Context) error
+	InMaintenance() bool
+}
+
+var _ MaintenanceMode = (*fsObjectStoreService)(nil)
+
+// writeGate admits client writes of store, holding them back while store is in maintenance mode
+type writeGate struct {
+	mu       sync.Mutex
+	bound    int
+	active   bool
+	queued   int
+	inflight int
+	resume   chan struct{}
+	idle     chan struct{}
+}
+
+// EnterMaintenance - pauses new writes (and deletes) of clients, and waits for writes in flight to finish, so
+// maintenance operations started afterwards run exclusively. Paused writes wait for `ExitMaintenance` when
+// queue has room (see `WithMaintenanceQueue`), otherwise they are rejected with `ErrMaintenance`. Reads continue.
+// When ctx is done before writes in flight finish, store leaves maintenance mode again.
+func (f *fsObjectStoreService) EnterMaintenance(ctx context.Context) error {
+	if err := f.authorize(ctx, OpAdmin, cid.Undef); err != nil {
+		return err
+	}
+	err := f.gate.enter(ctx, f.isDebug())
+	if err == nil && f.isDebug() {
+		log.Printf("debug: entered maintenance: %s\n", f.bucket)
+	}
+	f.audit(ctx, OpAdmin, cid.Undef, err)
+	return err
+}
+
+// ExitMaintenance - resumes writes of clients, releasing queued ones
+func (f *fsObjectStoreService) ExitMaintenance(ctx context.Context) error {
+	if err := f.authorize(ctx, OpAdmin, cid.Undef); err != nil {
+		return err
+	}
+	err := f.gate.exit()
+	if err == nil && f.isDebug() {
+		log.Printf("debug: exited maintenance: %s\n", f.bucket)
+	}
+	f.audit(ctx, OpAdmin, cid.Undef, err)
+	return err
+}
+
+// InMaintenance - checks whether store is in maintenance mode
+func (f *fsObjectStoreService) InMaintenance() bool {
+	f.gate.mu.Lock()
+	defer f.gate.mu.Unlock()
+	return f.gate.active
+}
+
+// admitWrite - admits write of client, waiting in queue (or rejected) while store is in maintenance mode;
+// admitted writes must be finished via `writeDone`. Store internal writes are always admitted
+func (f *fsObjectStoreService) admitWrite(ctx context.Context) error {
+	if system, _ := ctx.Value(systemKey{}).(bool); system {
+		return nil
+	}
+	err := f.gate.admit(ctx, f.isDebug())
+	if errors.Is(err, ErrMaintenance) && f.isDebug() {
+		log.Printf("debug: write rejected in maintenance: %s\n", f.bucket)
+	}
+	return err
+}
+
+// writeDone - finishes write of client admitted via `admitWrite`
+func (f *fsObjectStoreService) writeDone(ctx context.Context) {
+	if system, _ := ctx.Value(systemKey{}).(bool); system {
+		return
+	}
+	f.gate.done()
+}
+
+// admit - admits write, waiting for maintenance mode to end when queue has room
+func (g *writeGate) admit(ctx context.Context, debug bool) error {
+	g.mu.Lock()
+	for g.active {
+		if g.queued >= g.bound {
+			g.mu.Unlock()
+			return ErrMaintenance
+		}
+		g.queued++
+		resume := g.resume
+		g.mu.Unlock()
+		select {
+		case <-resume:
+		case <-ctx.Done():
+		}
+		g.mu.Lock()
+		g.queued--
+		if ctxErr := checkContextError(ctx, debug); ctxErr != nil {
+			g.mu.Unlock()
+			return ctxErr
+		}
+	}
+	g.inflight++
+	g.mu.Unlock()
+	return nil
+}
+
+// done - finishes admitted write, signaling maintenance mode waiting for writes in flight once none left
+func (g *writeGate) done() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.inflight--
+	if g.inflight == 0 && g.idle != nil {
+		close(g.idle)
+		g.idle = nil
+	}
+}
+
+// enter - enters maintenance mode, waiting for writes in flight to finish
+func (g *writeGate) enter(ctx context.Context, debug bool) error {
+	g.mu.Lock()
+	if g.active {
+		g.mu.Unlock()
+		return ErrMaintenance
+	}
+	g.active = true
+	g.resume = make(chan struct{})
+	idle := make(chan struct{})
+	if g.inflight == 0 {
+		close(idle)
+	} else {
+		g.idle = idle
+	}
+	g.mu.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+	}
+	g.mu.Lock()
+	g.idle = nil
+	g.mu.Unlock()
+	g.exit()
+	return checkContextError(ctx, debug)
+}
+
+// drain - waits up to timeout for admitted writes to finish, and reports whether none is left in flight
+func (g *writeGate) drain(timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		g.mu.Lock()
+		inflight := g.inflight
+		g.mu.Unlock()
+		if inflight == 0 {
+			return true
+		}
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// exit - leaves maintenance mode, releasing queued writes
+func (g *writeGate) exit() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.active {
+		return ErrNotInMaintenance
+	}
+	g.active = false
+	close(g.resume)
+	return nil
+}
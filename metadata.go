@@ -0,0 +1,178 @@
+package fsstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/igumus/go-objectstore-lib"
+	"github.com/ipfs/go-cid"
+)
+
+// ErrMetadataWritingFailed is return, when writing object metadata failed.
+var ErrMetadataWritingFailed = errors.New("fsobjectstore: writing metadata failed")
+
+// ErrMetadataReadingFailed is return, when reading object metadata failed.
+var ErrMetadataReadingFailed = errors.New("fsobjectstore: reading metadata failed")
+
+// errXattrNotExists is return, when object has no metadata extended attribute
+var errXattrNotExists = errors.New("fsobjectstore: extended attribute not exists")
+
+// errXattrUnsupported is return, when file system does not support extended attributes
+var errXattrUnsupported = errors.New("fsobjectstore: extended attributes not supported")
+
+// _metaDir handles the internal directory name of metadata sidecars
+const _metaDir = "meta"
+
+// _metaSuffix handles the file name suffix of metadata sidecars
+const _metaSuffix = ".json"
+
+// _metaXattr handles the extended attribute name of object metadata
+const _metaXattr = "user.fsstore.metadata"
+
+// Metadata captures descriptive information of an object. Objects past `Expires` are moved to trash by lifecycle
+// runs (see `ApplyLifecycle`), zero `Expires` means object never expires; `Pinned` objects are neither expired nor
+// acted upon by lifecycle rules. `Tags` are
+// free form labels objects can be queried by (see `WithCatalog`), or addressed by (see `NameTag`).
+// `ContentEncoding` names content coding object is stored in (e.g. `gzip`), for objects created pre-compressed;
+// `CacheControl` hints how long clients of gateways may cache object.
+type Metadata struct {
+	ContentType     string    `json:"contentType,omitempty"`
+	ContentEncoding string    `json:"contentEncoding,omitempty"`
+	CacheControl    string    `json:"cacheControl,omitempty"`
+	Expires         time.Time `json:"expires"`
+	Pinned          bool      `json:"pinned,omitempty"`
+	Tags            []string  `json:"tags,omitempty"`
+}
+
+// MetadataStore defines the functions clients need to attach descriptive information to objects.
+type MetadataStore interface {
+	SetMetadata(context.Context, cid.Cid, Metadata) error
+	GetMetadata(context.Context, cid.Cid) (*Metadata, error)
+}
+
+var _ MetadataStore = (*fsObjectStoreService)(nil)
+
+// SetMetadata - attaches metadata to object with specified cid (aka content identifier). Metadata is stored
+// in extended attributes when enabled and supported, otherwise in a sidecar file.
+func (f *fsObjectStoreService) SetMetadata(ctx context.Context, c cid.Cid, meta Metadata) error {
+	if err := f.authorize(ctx, OpWrite, c); err != nil {
+		return err
+	}
+	defer f.writeDone(ctx)
+	err := f.setMetadata(ctx, c, meta)
+	f.audit(ctx, OpWrite, c, err)
+	return err
+}
+
+// setMetadata - stores metadata of object
+func (f *fsObjectStoreService) setMetadata(ctx context.Context, c cid.Cid, meta Metadata) error {
+	if !f.has(c) {
+		return objectstore.ErrObjectNotExists
+	}
+	if ctxErr := checkContextError(ctx, f.isDebug()); ctxErr != nil {
+		return ctxErr
+	}
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return ErrMetadataWritingFailed
+	}
+	if f.useXattrs() {
+		objLink := f.objectPath(c)
+		// extended attributes belong to file, which may be shared with a cloned bucket
+		err := f.breakLink(objLink)
+		if err == nil {
+			err = setXattr(objLink, _metaXattr, data)
+		}
+		switch {
+		case err == nil:
+			// stale sidecar of a previous fallback must not shadow extended attribute
+			os.Remove(f.metaPath(c))
+			f.catalog.describe(c, meta)
+			return nil
+		case errors.Is(err, errXattrUnsupported):
+			f.disableXattrs()
+		default:
+			log.Printf("err: writing metadata xattr failed: %s, %v\n", c, err)
+			return ErrMetadataWritingFailed
+		}
+	}
+	if err := f.writeInternal(f.metaPath(c), data); err != nil {
+		return ErrMetadataWritingFailed
+	}
+	f.catalog.describe(c, meta)
+	if f.isDebug() {
+		log.Printf("debug: set metadata: %s\n", c)
+	}
+	return nil
+}
+
+// GetMetadata - returns metadata of object with specified cid (aka content identifier); zero metadata
+// is returned when none attached
+func (f *fsObjectStoreService) GetMetadata(ctx context.Context, c cid.Cid) (*Metadata, error) {
+	if err := f.authorize(ctx, OpRead, c); err != nil {
+		return nil, err
+	}
+	if !f.has(c) {
+		return nil, objectstore.ErrObjectNotExists
+	}
+	if ctxErr := checkContextError(ctx, f.isDebug()); ctxErr != nil {
+		return nil, ctxErr
+	}
+	var data []byte
+	if f.useXattrs() {
+		value, err := getXattr(f.objectPath(c), _metaXattr)
+		switch {
+		case err == nil:
+			data = value
+		case errors.Is(err, errXattrUnsupported):
+			f.disableXattrs()
+		case errors.Is(err, errXattrNotExists):
+		default:
+			log.Printf("err: reading metadata xattr failed: %s, %v\n", c, err)
+			return nil, ErrMetadataReadingFailed
+		}
+	}
+	if data == nil {
+		metaLink := f.metaPath(c)
+		if !exists(metaLink) {
+			return &Metadata{}, nil
+		}
+		value, err := f.readInternal(metaLink)
+		if err != nil {
+			return nil, ErrMetadataReadingFailed
+		}
+		data = value
+	}
+	meta := &Metadata{}
+	if err := json.Unmarshal(data, meta); err != nil {
+		log.Printf("err: decoding metadata failed: %s, %v\n", c, err)
+		return nil, ErrMetadataReadingFailed
+	}
+	return meta, nil
+}
+
+// metaPath - returns file system path of metadata sidecar of object
+func (f *fsObjectStoreService) metaPath(c cid.Cid) string {
+	link := objectLink(c)
+	if len(link) == 0 {
+		return ""
+	}
+	return f.internalPath(_metaDir, link+_metaSuffix)
+}
+
+// useXattrs - checks whether metadata is stored in extended attributes
+func (f *fsObjectStoreService) useXattrs() bool {
+	return atomic.LoadInt32(&f.xattrs) == 1
+}
+
+// disableXattrs - falls back to sidecar files, once file system turns out to lack extended attributes
+func (f *fsObjectStoreService) disableXattrs() {
+	if atomic.CompareAndSwapInt32(&f.xattrs, 1, 0) {
+		log.Printf("warn: extended attributes not supported, falling back to metadata sidecars: %s\n", f.bucketDir())
+	}
+}
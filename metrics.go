@@ -0,0 +1,168 @@
+package fsstore
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// OpMetrics captures count of operations of a kind performed on behalf of clients, and how many of them failed
+type OpMetrics struct {
+	Count  int64
+	Errors int64
+}
+
+// StoreMetrics captures a snapshot of counters and gauges of store since it is opened, so embedders can export
+// them to monitoring system of their choice
+type StoreMetrics struct {
+	Ops                 map[Operation]OpMetrics
+	BytesRead           int64
+	BytesWritten        int64
+	NegativeCacheHits   int64
+	NegativeCacheMisses int64
+	VerifyCacheHits     int64
+	VerifyCacheMisses   int64
+	GCRuns              int64
+	GCDeleted           int64
+	GCDeletedBytes      int64
+	GCPurgedTrash       int64
+	LastGC              time.Time
+	OpenFiles           int
+	StatsLoaded         bool
+	Objects             int64
+	Bytes               int64
+	Usage               []TenantUsage
+}
+
+// MetricsReporter defines the functions clients need to observe operational metrics of store.
+type MetricsReporter interface {
+	Metrics() StoreMetrics
+}
+
+var _ MetricsReporter = (*fsObjectStoreService)(nil)
+
+// _metricOps handles the operation kinds metrics are counted for
+var _metricOps = []Operation{OpRead, OpList, OpWrite, OpDelete, OpAdmin}
+
+// metrics maintains counters of store; counters are updated atomically, so hot paths take no lock
+type metrics struct {
+	ops          map[Operation]*[2]int64
+	bytesRead    int64
+	bytesWritten int64
+	negHits      int64
+	negMisses    int64
+	verifyHits   int64
+	verifyMisses int64
+	gcRuns       int64
+	gcDeleted    int64
+	gcBytes      int64
+	gcPurged     int64
+	mu           sync.Mutex
+	lastGC       time.Time
+}
+
+// newMetrics - creates zeroed metrics
+func newMetrics() *metrics {
+	m := &metrics{ops: make(map[Operation]*[2]int64, len(_metricOps))}
+	for _, op := range _metricOps {
+		m.ops[op] = &[2]int64{}
+	}
+	return m
+}
+
+// op - accounts operation performed on behalf of client with its result
+func (m *metrics) op(op Operation, err error) {
+	counters, ok := m.ops[op]
+	if !ok {
+		return
+	}
+	atomic.AddInt64(&counters[0], 1)
+	if err != nil {
+		atomic.AddInt64(&counters[1], 1)
+	}
+}
+
+// transfer - accounts content bytes moved by operation observed via `observe`
+func (m *metrics) transfer(op string, size int64) {
+	switch op {
+	case "read":
+		atomic.AddInt64(&m.bytesRead, size)
+	case "create", "put", "upload":
+		atomic.AddInt64(&m.bytesWritten, size)
+	}
+}
+
+// negativeLookup - accounts negative cache lookup
+func (m *metrics) negativeLookup(hit bool) {
+	if hit {
+		atomic.AddInt64(&m.negHits, 1)
+	} else {
+		atomic.AddInt64(&m.negMisses, 1)
+	}
+}
+
+// verifyLookup - accounts verification cache lookup of verified read
+func (m *metrics) verifyLookup(hit bool) {
+	if hit {
+		atomic.AddInt64(&m.verifyHits, 1)
+	} else {
+		atomic.AddInt64(&m.verifyMisses, 1)
+	}
+}
+
+// gc - accounts garbage collection run finished at now; dry runs reclaim nothing, so are not counted
+func (m *metrics) gc(report *GCReport, now time.Time) {
+	if report == nil || report.DryRun {
+		return
+	}
+	atomic.AddInt64(&m.gcRuns, 1)
+	atomic.AddInt64(&m.gcDeleted, int64(report.Deleted))
+	atomic.AddInt64(&m.gcBytes, report.DeletedBytes)
+	atomic.AddInt64(&m.gcPurged, int64(report.PurgedTrash))
+	m.mu.Lock()
+	m.lastGC = now
+	m.mu.Unlock()
+}
+
+// Metrics - returns snapshot of operation, byte, negative and verification cache and garbage collection counters, along with
+// open file and (when already loaded, see `Stats`) object gauges, and usage of tenants since store opened (when
+// accounted, see `WithUsageAccounting`). Operations performed internally are not counted.
+func (f *fsObjectStoreService) Metrics() StoreMetrics {
+	m := f.metrics
+	ret := StoreMetrics{
+		Ops:                 make(map[Operation]OpMetrics, len(m.ops)),
+		BytesRead:           atomic.LoadInt64(&m.bytesRead),
+		BytesWritten:        atomic.LoadInt64(&m.bytesWritten),
+		NegativeCacheHits:   atomic.LoadInt64(&m.negHits),
+		NegativeCacheMisses: atomic.LoadInt64(&m.negMisses),
+		VerifyCacheHits:     atomic.LoadInt64(&m.verifyHits),
+		VerifyCacheMisses:   atomic.LoadInt64(&m.verifyMisses),
+		GCRuns:              atomic.LoadInt64(&m.gcRuns),
+		GCDeleted:           atomic.LoadInt64(&m.gcDeleted),
+		GCDeletedBytes:      atomic.LoadInt64(&m.gcBytes),
+		GCPurgedTrash:       atomic.LoadInt64(&m.gcPurged),
+		OpenFiles:           f.OpenFiles().InUse,
+	}
+	for op, counters := range m.ops {
+		ret.Ops[op] = OpMetrics{Count: atomic.LoadInt64(&counters[0]), Errors: atomic.LoadInt64(&counters[1])}
+	}
+	m.mu.Lock()
+	ret.LastGC = m.lastGC
+	m.mu.Unlock()
+
+	f.stats.mu.Lock()
+	ret.StatsLoaded = f.stats.loaded
+	if f.stats.loaded {
+		for i := range f.stats.counts {
+			ret.Objects += f.stats.counts[i]
+			ret.Bytes += f.stats.bytes[i]
+		}
+	}
+	f.stats.mu.Unlock()
+	if u := f.usage; u != nil {
+		u.mu.Lock()
+		ret.Usage = sortedUsage(u.opened)
+		u.mu.Unlock()
+	}
+	return ret
+}
@@ -0,0 +1,103 @@
+package fsstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"os"
+	"time"
+
+	"github.com/ipfs/go-cid"
+)
+
+// ErrNameNotExists is return, when no object is named by alias being resolved.
+var ErrNameNotExists = errors.New("fsobjectstore: name not exists")
+
+// NameTag - returns metadata tag naming object by alias, see `ResolveName`
+func NameTag(alias string) string {
+	return "name=" + alias
+}
+
+// NameResolver defines the functions clients need to address objects by alias rather than by cid.
+type NameResolver interface {
+	ResolveName(context.Context, string) (cid.Cid, error)
+}
+
+var _ NameResolver = (*fsObjectStoreService)(nil)
+
+// ResolveName - returns cid of object named by alias, i.e. labeled `name` with alias in its metadata tags (see
+// `NameTag`). Tagging a newer object with alias moves it: of several objects named alike, most recently created
+// wins. Names are resolved via catalog when configured (see `WithCatalog`), otherwise by walking bucket.
+func (f *fsObjectStoreService) ResolveName(ctx context.Context, alias string) (cid.Cid, error) {
+	if err := f.authorize(ctx, OpRead, cid.Undef); err != nil {
+		return cid.Undef, err
+	}
+	c, err := f.resolveName(withSystem(ctx), alias)
+	f.audit(ctx, OpRead, c, err)
+	return c, err
+}
+
+// resolveName - resolves alias via catalog, falling back to walking bucket when catalog fails
+func (f *fsObjectStoreService) resolveName(ctx context.Context, alias string) (cid.Cid, error) {
+	if len(alias) == 0 {
+		return cid.Undef, ErrNameNotExists
+	}
+	if f.catalog != nil {
+		c, err := f.catalog.resolve(ctx, NameTag(alias))
+		if err == nil || errors.Is(err, ErrNameNotExists) {
+			return c, err
+		}
+		if ctxErr := checkContextError(ctx, f.isDebug()); ctxErr != nil {
+			return cid.Undef, ctxErr
+		}
+		log.Printf("warn: resolving name via catalog failed, walking bucket: %s, %v\n", alias, err)
+	}
+	tag := NameTag(alias)
+	found, foundAt := cid.Undef, time.Time{}
+	err := f.walkObjects(ctx, func(c cid.Cid, path string, info os.FileInfo) error {
+		if found.Defined() && info.ModTime().Before(foundAt) {
+			return nil
+		}
+		meta, err := f.GetMetadata(ctx, c)
+		if err != nil {
+			log.Printf("warn: skipping object of unreadable metadata: %s, %v\n", c, err)
+			return nil
+		}
+		for _, t := range meta.Tags {
+			if t == tag && (!found.Defined() || info.ModTime().After(foundAt) || c.String() < found.String()) {
+				found, foundAt = c, info.ModTime()
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		if ctxErr := checkContextError(ctx, f.isDebug()); ctxErr != nil {
+			return cid.Undef, ctxErr
+		}
+		log.Printf("err: resolving name failed: %s, %v\n", alias, err)
+		return cid.Undef, err
+	}
+	if !found.Defined() {
+		return cid.Undef, ErrNameNotExists
+	}
+	if f.isDebug() {
+		log.Printf("debug: resolved name: %s, %s\n", alias, found)
+	}
+	return found, nil
+}
+
+// resolve - returns most recently created cataloged object carrying tag
+func (c *catalog) resolve(ctx context.Context, tag string) (cid.Cid, error) {
+	var key string
+	err := c.db.QueryRowContext(ctx, `SELECT o.cid FROM fsstore_objects o JOIN fsstore_tags t
+		ON t.bucket = o.bucket AND t.cid = o.cid WHERE o.bucket = ? AND t.tag = ?
+		ORDER BY o.mtime DESC, o.cid LIMIT 1`, c.bucket, tag).Scan(&key)
+	if errors.Is(err, sql.ErrNoRows) {
+		return cid.Undef, ErrNameNotExists
+	}
+	if err != nil {
+		return cid.Undef, err
+	}
+	return cid.Decode(key)
+}
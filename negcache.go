@@ -0,0 +1,175 @@
+package fsstore
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-cid"
+)
+
+// _defNegCacheSize handles the default count of absent cids remembered by negative cache
+const _defNegCacheSize = 4096
+
+// _defNegCacheTTL handles the default duration absent cids are remembered, bounding staleness
+// against writers of other processes sharing bucket
+const _defNegCacheTTL = 2 * time.Second
+
+// _negCacheFile handles the internal file name of persisted negative cache
+const _negCacheFile = "negcache"
+
+// negativeCache remembers recently looked up absent objects, so repeated misses skip the disk
+type negativeCache struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	entries map[string]time.Time
+}
+
+// newNegativeCache - creates negative cache remembering up to size keys for ttl
+func newNegativeCache(size int, ttl time.Duration) *negativeCache {
+	return &negativeCache{size: size, ttl: ttl, entries: make(map[string]time.Time)}
+}
+
+// contains - checks whether key is remembered as absent
+func (n *negativeCache) contains(key string) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	expires, ok := n.entries[key]
+	if !ok {
+		return false
+	}
+	if time.Now().After(expires) {
+		delete(n.entries, key)
+		return false
+	}
+	return true
+}
+
+// add - remembers key as absent, evicting expired (or if none, arbitrary) entries when full
+func (n *negativeCache) add(key string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if len(n.entries) >= n.size {
+		now := time.Now()
+		for k, expires := range n.entries {
+			if now.After(expires) {
+				delete(n.entries, k)
+			}
+		}
+		for k := range n.entries {
+			if len(n.entries) < n.size {
+				break
+			}
+			delete(n.entries, k)
+		}
+	}
+	n.entries[key] = time.Now().Add(n.ttl)
+}
+
+// resize - changes count of keys remembered, forgetting arbitrary keys beyond it
+func (n *negativeCache) resize(size int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.size = size
+	for k := range n.entries {
+		if len(n.entries) <= n.size {
+			break
+		}
+		delete(n.entries, k)
+	}
+}
+
+// remove - forgets key, called once object is written
+func (n *negativeCache) remove(key string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	delete(n.entries, key)
+}
+
+// snapshot - returns remembered keys not yet expired, along with time they expire at
+func (n *negativeCache) snapshot() map[string]time.Time {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	now := time.Now()
+	ret := make(map[string]time.Time, len(n.entries))
+	for k, expires := range n.entries {
+		if now.Before(expires) {
+			ret[k] = expires
+		}
+	}
+	return ret
+}
+
+// rememberAbsent - remembers key as absent locally; when replicas are configured, they may still hold it, so
+// only misses of every replica are remembered (see `hedgedRead`), and objects held by a historical layout (see
+// `WithHistoricalLayout`) are not remembered at all
+func (f *fsObjectStoreService) rememberAbsent(key string) {
+	if len(f.replicas) > 0 {
+		return
+	}
+	if len(f.layouts) > 0 {
+		// object missing in current layout may still be read from a historical one
+		c, err := cid.Decode(key)
+		if err != nil {
+			return
+		}
+		if _, legacy := f.legacyPath(c); len(legacy) > 0 {
+			return
+		}
+	}
+	f.negative.add(key)
+}
+
+// loadNegativeCache - recovers persisted absent cids not yet expired, forgetting those created meanwhile
+// (e.g. by another process sharing bucket)
+func (f *fsObjectStoreService) loadNegativeCache() {
+	if !f.negPersist {
+		return
+	}
+	path := f.internalPath(_negCacheFile)
+	if !exists(path) {
+		return
+	}
+	data, err := f.readInternal(path)
+	entries := map[string]time.Time{}
+	if err == nil {
+		err = json.Unmarshal(data, &entries)
+	}
+	if err != nil {
+		log.Printf("warn: reading negative cache failed, absent cids are looked up again: %s, %v\n", path, err)
+		return
+	}
+	now, restored := time.Now(), 0
+	n := f.negative
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for key, expires := range entries {
+		if len(n.entries) >= n.size || !now.Before(expires) {
+			continue
+		}
+		if c, err := cid.Decode(key); err != nil || f.has(c) {
+			continue
+		}
+		n.entries[key] = expires
+		restored++
+	}
+	if f.isDebug() {
+		log.Printf("debug: negative cache recovered: %s, %d absent cids\n", path, restored)
+	}
+}
+
+// checkpointNegativeCache - persists absent cids remembered, so they are not looked up again once store is reopened
+func (f *fsObjectStoreService) checkpointNegativeCache() {
+	if !f.negPersist || !f.isProvisioned() {
+		return
+	}
+	data, err := json.Marshal(f.negative.snapshot())
+	if err == nil {
+		err = f.writeInternal(f.internalPath(_negCacheFile), data)
+	}
+	if err != nil {
+		log.Printf("err: writing negative cache failed: %s, %v\n", f.bucket, err)
+	}
+}
@@ -0,0 +1,181 @@
+package fsstore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"log"
+
+	"github.com/igumus/go-objectstore-lib"
+	"github.com/ipfs/go-cid"
+	"github.com/ipld/go-ipld-prime"
+	_ "github.com/ipld/go-ipld-prime/codec/dagcbor"
+	_ "github.com/ipld/go-ipld-prime/codec/dagjson"
+	_ "github.com/ipld/go-ipld-prime/codec/raw"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	ipldmc "github.com/ipld/go-ipld-prime/multicodec"
+	"github.com/ipld/go-ipld-prime/node/basicnode"
+	"github.com/multiformats/go-multicodec"
+	mh "github.com/multiformats/go-multihash"
+)
+
+// ErrUnsupportedCodec is return, when requested codec has no registered ipld encoder/decoder.
+var ErrUnsupportedCodec = errors.New("fsobjectstore: unsupported codec")
+
+// ErrNodeEncodingFailed is return, when encoding ipld node failed.
+var ErrNodeEncodingFailed = errors.New("fsobjectstore: node encoding failed")
+
+// ErrNodeDecodingFailed is return, when decoding ipld node failed.
+var ErrNodeDecodingFailed = errors.New("fsobjectstore: node decoding failed")
+
+// NodeStore defines the functions clients need to store structured data as ipld nodes.
+type NodeStore interface {
+	CreateNode(context.Context, ipld.Node, multicodec.Code) (cid.Cid, error)
+	ReadNode(context.Context, cid.Cid) (ipld.Node, error)
+}
+
+var _ NodeStore = (*fsObjectStoreService)(nil)
+
+// CreateNode - encodes ipld node with specified codec, and creates it as object. Links of node are
+// recorded as references, so linked objects are kept as long as node exists.
+func (f *fsObjectStoreService) CreateNode(ctx context.Context, node ipld.Node, codec multicodec.Code) (cid.Cid, error) {
+	if err := f.authorize(ctx, OpWrite, cid.Undef); err != nil {
+		return cid.Undef, err
+	}
+	defer f.writeDone(ctx)
+	digest, err := f.createNode(ctx, node, codec, nil)
+	f.audit(ctx, OpWrite, digest, err)
+	return digest, err
+}
+
+// createNode - encodes node with codec, and creates it as object recording its links as references; content
+// scanners inspect source in place of encoded node when given
+func (f *fsObjectStoreService) createNode(ctx context.Context, node ipld.Node, codec multicodec.Code, source *scanSource) (cid.Cid, error) {
+	encoder, err := ipldmc.LookupEncoder(uint64(codec))
+	if err != nil {
+		return cid.Undef, ErrUnsupportedCodec
+	}
+	buf := bytes.Buffer{}
+	if err := encoder(node, &buf); err != nil {
+		log.Printf("err: encoding node failed: %s, %v\n", codec, err)
+		return cid.Undef, ErrNodeEncodingFailed
+	}
+	links, err := collectLinks(node, nil)
+	if err != nil {
+		log.Printf("err: collecting node links failed: %s, %v\n", codec, err)
+		return cid.Undef, ErrNodeEncodingFailed
+	}
+
+	prefix := cid.Prefix{
+		Version:  1,
+		Codec:    uint64(codec),
+		MhType:   mh.SHA2_256,
+		MhLength: -1,
+	}
+	digest, err := prefix.Sum(buf.Bytes())
+	if err != nil {
+		log.Printf("err: digesting node failed: %s\n", err.Error())
+		return cid.Undef, ErrDataDigestionFailed
+	}
+	if f.isDebug() {
+		log.Printf("debug: created node cid: %s, %d links\n", digest, len(links))
+	}
+	if err := f.checkBlocked(ctx, OpWrite, digest); err != nil {
+		return digest, err
+	}
+	if f.has(digest) {
+		f.stats.deduplicated()
+		return digest, nil
+	}
+	if source == nil {
+		encoded := buf.Bytes()
+		source = &scanSource{size: int64(len(encoded)), open: func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(encoded)), nil
+		}}
+	}
+	if err := f.scan(ctx, digest, source.size, source.open); err != nil {
+		return digest, err
+	}
+
+	// references are recorded before node itself, so linked objects never become
+	// collectable while node exists.
+	if len(links) > 0 {
+		if err := f.addRefs(ctx, digest, links); err != nil {
+			return cid.Undef, err
+		}
+	}
+	objLink := f.objectPath(digest)
+	stored, err := f.encrypt(escapePlain(buf.Bytes()))
+	if err != nil {
+		return digest, objectstore.ErrObjectWritingFailed
+	}
+	if err := f.fds.acquire(ctx); err != nil {
+		return digest, err
+	}
+	defer f.fds.release()
+	if err := f.writeObject(digest, objLink, stored); err != nil {
+		return digest, err
+	}
+	f.negative.remove(digest.String())
+	if err := f.journaled(JournalCreate, digest, int64(buf.Len())); err != nil {
+		return digest, err
+	}
+	return digest, nil
+}
+
+// ReadNode - reads object with specified cid (aka content identifier), and decodes it as ipld node via codec of cid
+func (f *fsObjectStoreService) ReadNode(ctx context.Context, c cid.Cid) (ipld.Node, error) {
+	decoder, err := ipldmc.LookupDecoder(c.Prefix().Codec)
+	if err != nil {
+		return nil, ErrUnsupportedCodec
+	}
+	data, err := f.ReadObject(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	builder := basicnode.Prototype.Any.NewBuilder()
+	if err := decoder(builder, bytes.NewReader(data)); err != nil {
+		log.Printf("err: decoding node failed: %s, %v\n", c, err)
+		return nil, ErrNodeDecodingFailed
+	}
+	return builder.Build(), nil
+}
+
+// collectLinks - appends cids of all links reachable in given node to acc
+func collectLinks(node ipld.Node, acc []cid.Cid) ([]cid.Cid, error) {
+	switch node.Kind() {
+	case ipld.Kind_Link:
+		link, err := node.AsLink()
+		if err != nil {
+			return nil, err
+		}
+		if cl, ok := link.(cidlink.Link); ok {
+			acc = append(acc, cl.Cid)
+		}
+	case ipld.Kind_Map:
+		it := node.MapIterator()
+		for !it.Done() {
+			_, value, err := it.Next()
+			if err != nil {
+				return nil, err
+			}
+			if acc, err = collectLinks(value, acc); err != nil {
+				return nil, err
+			}
+		}
+	case ipld.Kind_List:
+		it := node.ListIterator()
+		for !it.Done() {
+			_, value, err := it.Next()
+			if err != nil {
+				return nil, err
+			}
+			if acc, err = collectLinks(value, acc); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return acc, nil
+}
@@ -0,0 +1,251 @@
+// Package objectstoretest is a conformance suite for ObjectStore implementations. Run exercises create, read,
+// existence, listing and (when implemented) deletion semantics, context cancellation, concurrent access and
+// error contracts, so fsstore, in-memory stores and third-party implementations are held to same behavior:
+//
+//	func TestConformance(t *testing.T) {
+//		objectstoretest.Run(t, func(t *testing.T) objectstore.ObjectStore {
+//			store, err := fsstore.NewFileSystemObjectStore(fsstore.WithDataDir(t.TempDir()))
+//			if err != nil {
+//				t.Fatal(err)
+//			}
+//			return store
+//		})
+//	}
+package objectstoretest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/igumus/go-objectstore-lib"
+	"github.com/ipfs/go-cid"
+)
+
+// _concurrency handles the number of goroutines concurrency cases run
+const _concurrency = 8
+
+// Deleter is implemented by stores supporting deletion; deletion cases are skipped for other stores.
+type Deleter interface {
+	DeleteObject(context.Context, cid.Cid) error
+}
+
+// Run - runs conformance suite against stores created via newStore, which is called once per case and must
+// return an empty store
+func Run(t *testing.T, newStore func(t *testing.T) objectstore.ObjectStore) {
+	cases := []struct {
+		name string
+		fn   func(*testing.T, objectstore.ObjectStore)
+	}{
+		{"CreateRead", testCreateRead},
+		{"CreateIdempotent", testCreateIdempotent},
+		{"EmptyObject", testEmptyObject},
+		{"ReadMissing", testReadMissing},
+		{"Has", testHas},
+		{"ListEmpty", testListEmpty},
+		{"List", testList},
+		{"ListCancel", testListCancel},
+		{"Cancelled", testCancelled},
+		{"Concurrent", testConcurrent},
+		{"Delete", testDelete},
+	}
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			tc.fn(t, newStore(t))
+		})
+	}
+}
+
+// create - creates object of given content, failing test on error
+func create(t *testing.T, store objectstore.ObjectStore, data []byte) cid.Cid {
+	t.Helper()
+	c, err := store.CreateObject(context.Background(), bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("create object failed: %v", err)
+	}
+	return c
+}
+
+// list - drains listing of store, failing test on listing error
+func list(t *testing.T, store objectstore.ObjectStore) map[string]int {
+	t.Helper()
+	listed := map[string]int{}
+	for event := range store.ListObject(context.Background()) {
+		if event.Error != nil {
+			t.Fatalf("list objects failed: %v", event.Error)
+		}
+		listed[event.Object]++
+	}
+	return listed
+}
+
+// isCancellation - checks whether error reports cancelled (or timed out) operation
+func isCancellation(err error) bool {
+	return errors.Is(err, objectstore.ErrOperationCancelled) || errors.Is(err, objectstore.ErrOperationDeadlineExceeded) ||
+		errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+func testCreateRead(t *testing.T, store objectstore.ObjectStore) {
+	data := []byte("conformance content")
+	c := create(t, store, data)
+	expected, _ := objectstore.DigestPrefix.Sum(data)
+	if !c.Equals(expected) {
+		t.Fatalf("create object returned cid %s, expected %s", c, expected)
+	}
+	read, err := store.ReadObject(context.Background(), c)
+	if err != nil {
+		t.Fatalf("read object failed: %v", err)
+	}
+	if !bytes.Equal(read, data) {
+		t.Fatalf("read object returned %q, expected %q", read, data)
+	}
+}
+
+func testCreateIdempotent(t *testing.T, store objectstore.ObjectStore) {
+	first := create(t, store, []byte("same content"))
+	second := create(t, store, []byte("same content"))
+	if !first.Equals(second) {
+		t.Fatalf("creating same content returned %s and %s", first, second)
+	}
+	if n := list(t, store)[first.String()]; n != 1 {
+		t.Fatalf("object created twice listed %d times, expected once", n)
+	}
+}
+
+func testEmptyObject(t *testing.T, store objectstore.ObjectStore) {
+	c := create(t, store, nil)
+	read, err := store.ReadObject(context.Background(), c)
+	if err != nil {
+		t.Fatalf("read empty object failed: %v", err)
+	}
+	if len(read) != 0 {
+		t.Fatalf("read empty object returned %d bytes", len(read))
+	}
+}
+
+func testReadMissing(t *testing.T, store objectstore.ObjectStore) {
+	missing, _ := objectstore.DigestPrefix.Sum([]byte("never created"))
+	if _, err := store.ReadObject(context.Background(), missing); !errors.Is(err, objectstore.ErrObjectNotExists) {
+		t.Fatalf("read missing object returned %v, expected %v", err, objectstore.ErrObjectNotExists)
+	}
+}
+
+func testHas(t *testing.T, store objectstore.ObjectStore) {
+	missing, _ := objectstore.DigestPrefix.Sum([]byte("never created"))
+	if store.HasObject(context.Background(), missing) {
+		t.Fatal("has object reported missing object")
+	}
+	c := create(t, store, []byte("present"))
+	if !store.HasObject(context.Background(), c) {
+		t.Fatal("has object did not report created object")
+	}
+}
+
+func testListEmpty(t *testing.T, store objectstore.ObjectStore) {
+	if listed := list(t, store); len(listed) != 0 {
+		t.Fatalf("empty store listed %d objects", len(listed))
+	}
+}
+
+func testList(t *testing.T, store objectstore.ObjectStore) {
+	created := map[string]bool{}
+	for i := 0; i < 20; i++ {
+		created[create(t, store, []byte(fmt.Sprintf("object %d", i))).String()] = true
+	}
+	listed := list(t, store)
+	for c := range created {
+		if listed[c] != 1 {
+			t.Fatalf("object %s listed %d times, expected once", c, listed[c])
+		}
+	}
+	for c := range listed {
+		if !created[c] {
+			t.Fatalf("listed object %s never created", c)
+		}
+	}
+}
+
+func testListCancel(t *testing.T, store objectstore.ObjectStore) {
+	for i := 0; i < 20; i++ {
+		create(t, store, []byte(fmt.Sprintf("object %d", i)))
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := store.ListObject(ctx)
+	<-ch
+	cancel()
+	// channel must be closed eventually, without consumer draining every object
+	for range ch {
+	}
+}
+
+func testCancelled(t *testing.T, store objectstore.ObjectStore) {
+	c := create(t, store, []byte("created before cancellation"))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := store.ReadObject(ctx, c); !isCancellation(err) {
+		t.Fatalf("read object with cancelled context returned %v, expected cancellation", err)
+	}
+	if _, err := store.CreateObject(ctx, bytes.NewReader([]byte("created after cancellation"))); !isCancellation(err) {
+		t.Fatalf("create object with cancelled context returned %v, expected cancellation", err)
+	}
+}
+
+func testConcurrent(t *testing.T, store objectstore.ObjectStore) {
+	var wg sync.WaitGroup
+	errs := make(chan error, _concurrency*2)
+	for i := 0; i < _concurrency; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for _, data := range [][]byte{[]byte("shared content"), []byte(fmt.Sprintf("content %d", i))} {
+				c, err := store.CreateObject(context.Background(), bytes.NewReader(data))
+				if err == nil {
+					var read []byte
+					if read, err = store.ReadObject(context.Background(), c); err == nil && !bytes.Equal(read, data) {
+						err = fmt.Errorf("read %q, expected %q", read, data)
+					}
+				}
+				if err != nil {
+					errs <- err
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("concurrent access failed: %v", err)
+	}
+	if listed := list(t, store); len(listed) != _concurrency+1 {
+		t.Fatalf("listed %d objects after concurrent creates, expected %d", len(listed), _concurrency+1)
+	}
+}
+
+func testDelete(t *testing.T, store objectstore.ObjectStore) {
+	deleter, ok := store.(Deleter)
+	if !ok {
+		t.Skip("store does not support deletion")
+	}
+	c := create(t, store, []byte("deleted"))
+	kept := create(t, store, []byte("kept"))
+	if err := deleter.DeleteObject(context.Background(), c); err != nil {
+		t.Fatalf("delete object failed: %v", err)
+	}
+	if store.HasObject(context.Background(), c) {
+		t.Fatal("has object reported deleted object")
+	}
+	if _, err := store.ReadObject(context.Background(), c); !errors.Is(err, objectstore.ErrObjectNotExists) {
+		t.Fatalf("read deleted object returned %v, expected %v", err, objectstore.ErrObjectNotExists)
+	}
+	listed := list(t, store)
+	if listed[c.String()] != 0 || listed[kept.String()] != 1 {
+		t.Fatal("listing after delete does not match remaining objects")
+	}
+	if err := deleter.DeleteObject(context.Background(), c); !errors.Is(err, objectstore.ErrObjectNotExists) {
+		t.Fatalf("delete missing object returned %v, expected %v", err, objectstore.ErrObjectNotExists)
+	}
+}
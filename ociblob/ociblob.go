@@ -0,0 +1,369 @@
+// Package ociblob adapts objectstores to blob semantics of the OCI distribution API, so a minimal registry can
+// use them as its blob backend. Blobs are addressed by their `sha256:<hex>` digest, which maps one to one onto
+// raw cids of SHA-256 multihash stores address content by (see `objectstore.DigestPrefix`), so stores chunking
+// objects into manifests (see `fsstore.WithChunkSizes`) are not suitable. Every blob is shared by repositories
+// it is linked to, recorded as `oci.repository=<name>` tags of its metadata (see `fsstore.Metadata`): blobs are
+// visible to repositories they are linked to only, cross repository mounts link blobs to another repository
+// without transferring them, and deleting blob of its last repository deletes it from store.
+package ociblob
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"hash/fnv"
+	"io"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+
+	fsstore "github.com/igumus/go-objectstore-fs"
+	"github.com/igumus/go-objectstore-lib"
+	"github.com/ipfs/go-cid"
+	mh "github.com/multiformats/go-multihash"
+)
+
+// ErrStoreUnsupported is return, when store lacks features blob store needs: putting objects by cid, seekable
+// reads, metadata, deletion and resumable uploads.
+var ErrStoreUnsupported = errors.New("ociblob: store does not support blob semantics")
+
+// ErrBlobUnknown is return, when blob is not in store, or not linked to repository (`BLOB_UNKNOWN`).
+var ErrBlobUnknown = errors.New("ociblob: blob unknown to repository")
+
+// ErrDigestInvalid is return, when digest is malformed, or does not match uploaded content (`DIGEST_INVALID`).
+var ErrDigestInvalid = errors.New("ociblob: digest invalid")
+
+// ErrDigestUnsupported is return, when digest is of another algorithm than `sha256` (`UNSUPPORTED`).
+var ErrDigestUnsupported = errors.New("ociblob: digest algorithm unsupported")
+
+// ErrNameInvalid is return, when repository name is not a valid OCI repository name (`NAME_INVALID`).
+var ErrNameInvalid = errors.New("ociblob: invalid repository name")
+
+// _digestAlgorithm handles the algorithm of blob digests
+const _digestAlgorithm = "sha256"
+
+// _defMediaType handles the media type of blobs uploaded without one
+const _defMediaType = "application/octet-stream"
+
+// _repositoryTag handles the metadata tag key linking blobs to repositories
+const _repositoryTag = "oci.repository"
+
+// _blobLocks handles the count of locks linking blobs to repositories is serialized by
+const _blobLocks = 64
+
+// _repositoryName handles the pattern of valid repository names of OCI distribution specification
+var _repositoryName = regexp.MustCompile(`^[a-z0-9]+((\.|_|__|-+)[a-z0-9]+)*(/[a-z0-9]+((\.|_|__|-+)[a-z0-9]+)*)*$`)
+
+// Descriptor captures a blob as OCI content descriptors describe it
+type Descriptor struct {
+	MediaType string
+	Digest    string
+	Size      int64
+}
+
+// BlobStore exposes an objectstore through blob semantics of OCI distribution API
+type BlobStore struct {
+	putter   fsstore.Putter
+	opener   fsstore.ObjectOpener
+	meta     fsstore.MetadataStore
+	deleter  fsstore.Deleter
+	uploader fsstore.ResumableUploader
+	locks    [_blobLocks]sync.Mutex
+}
+
+// NewBlobStore creates BlobStore instance serving blobs of given store, or `ErrStoreUnsupported` when store lacks
+// features blob store needs.
+func NewBlobStore(store objectstore.ObjectStore) (*BlobStore, error) {
+	putter, ok1 := store.(fsstore.Putter)
+	opener, ok2 := store.(fsstore.ObjectOpener)
+	meta, ok3 := store.(fsstore.MetadataStore)
+	deleter, ok4 := store.(fsstore.Deleter)
+	uploader, ok5 := store.(fsstore.ResumableUploader)
+	if !ok1 || !ok2 || !ok3 || !ok4 || !ok5 {
+		return nil, ErrStoreUnsupported
+	}
+	return &BlobStore{putter: putter, opener: opener, meta: meta, deleter: deleter, uploader: uploader}, nil
+}
+
+// CidOf - returns cid of blob with given digest
+func CidOf(digest string) (cid.Cid, error) {
+	i := strings.Index(digest, ":")
+	if i < 0 {
+		return cid.Undef, ErrDigestInvalid
+	}
+	if digest[:i] != _digestAlgorithm {
+		return cid.Undef, ErrDigestUnsupported
+	}
+	sum, err := hex.DecodeString(digest[i+1:])
+	// digests of specification are lowercase hex only
+	if err != nil || len(sum) != 32 || strings.ToLower(digest[i+1:]) != digest[i+1:] {
+		return cid.Undef, ErrDigestInvalid
+	}
+	hash, err := mh.Encode(sum, mh.SHA2_256)
+	if err != nil {
+		return cid.Undef, ErrDigestInvalid
+	}
+	return cid.NewCidV1(cid.Raw, hash), nil
+}
+
+// DigestOf - returns digest of blob with given cid
+func DigestOf(c cid.Cid) (string, error) {
+	if !c.Defined() || c.Prefix().Codec != cid.Raw {
+		return "", ErrDigestUnsupported
+	}
+	decoded, err := mh.Decode(c.Hash())
+	if err != nil || decoded.Code != mh.SHA2_256 {
+		return "", ErrDigestUnsupported
+	}
+	return _digestAlgorithm + ":" + hex.EncodeToString(decoded.Digest), nil
+}
+
+// checkName - checks whether repository name is valid
+func checkName(repo string) error {
+	if !_repositoryName.MatchString(repo) {
+		return ErrNameInvalid
+	}
+	return nil
+}
+
+// repositoryTag - returns metadata tag linking blob to repository
+func repositoryTag(repo string) string {
+	return _repositoryTag + "=" + repo
+}
+
+// repositoryOf - returns repository metadata tag links blob to, if any
+func repositoryOf(tag string) (string, bool) {
+	if !strings.HasPrefix(tag, _repositoryTag+"=") {
+		return "", false
+	}
+	return tag[len(_repositoryTag)+1:], true
+}
+
+// lock - locks metadata of blob with cid, returning unlock
+func (b *BlobStore) lock(c cid.Cid) func() {
+	h := fnv.New32a()
+	h.Write(c.Bytes())
+	mu := &b.locks[h.Sum32()%_blobLocks]
+	mu.Lock()
+	return mu.Unlock
+}
+
+// resolve - returns cid and metadata of blob with digest linked to repository
+func (b *BlobStore) resolve(ctx context.Context, repo, digest string) (cid.Cid, *fsstore.Metadata, error) {
+	if err := checkName(repo); err != nil {
+		return cid.Undef, nil, err
+	}
+	c, err := CidOf(digest)
+	if err != nil {
+		return cid.Undef, nil, err
+	}
+	meta, err := b.meta.GetMetadata(ctx, c)
+	if errors.Is(err, objectstore.ErrObjectNotExists) {
+		return cid.Undef, nil, ErrBlobUnknown
+	}
+	if err != nil {
+		return cid.Undef, nil, err
+	}
+	if !linked(meta, repo) {
+		return cid.Undef, nil, ErrBlobUnknown
+	}
+	return c, meta, nil
+}
+
+// linked - checks whether metadata links blob to repository
+func linked(meta *fsstore.Metadata, repo string) bool {
+	tag := repositoryTag(repo)
+	for _, t := range meta.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// Stat - describes blob with digest of repository, `ErrBlobUnknown` when repository has no such blob
+func (b *BlobStore) Stat(ctx context.Context, repo, digest string) (Descriptor, error) {
+	c, meta, err := b.resolve(ctx, repo, digest)
+	if err != nil {
+		return Descriptor{}, err
+	}
+	return b.describe(ctx, c, meta)
+}
+
+// describe - returns descriptor of blob with cid and metadata
+func (b *BlobStore) describe(ctx context.Context, c cid.Cid, meta *fsstore.Metadata) (Descriptor, error) {
+	obj, err := b.opener.OpenObject(ctx, c)
+	if err != nil {
+		if errors.Is(err, objectstore.ErrObjectNotExists) {
+			return Descriptor{}, ErrBlobUnknown
+		}
+		return Descriptor{}, err
+	}
+	defer obj.Close()
+	size, err := obj.Seek(0, io.SeekEnd)
+	if err != nil {
+		log.Printf("err: sizing blob failed: %s, %v\n", c, err)
+		return Descriptor{}, objectstore.ErrObjectReadingFailed
+	}
+	digest, err := DigestOf(c)
+	if err != nil {
+		return Descriptor{}, err
+	}
+	mediaType := meta.ContentType
+	if len(mediaType) == 0 {
+		mediaType = _defMediaType
+	}
+	return Descriptor{MediaType: mediaType, Digest: digest, Size: size}, nil
+}
+
+// Open - opens blob with digest of repository for seekable (aka range) reading, `ErrBlobUnknown` when
+// repository has no such blob. Callers must close returned reader.
+func (b *BlobStore) Open(ctx context.Context, repo, digest string) (io.ReadSeekCloser, error) {
+	c, _, err := b.resolve(ctx, repo, digest)
+	if err != nil {
+		return nil, err
+	}
+	obj, err := b.opener.OpenObject(ctx, c)
+	if errors.Is(err, objectstore.ErrObjectNotExists) {
+		return nil, ErrBlobUnknown
+	}
+	return obj, err
+}
+
+// Put - stores content of r as blob of repository in a single request (monolithic upload), only when it matches
+// digest; empty media type defaults to `application/octet-stream`
+func (b *BlobStore) Put(ctx context.Context, repo, digest, mediaType string, r io.Reader) (Descriptor, error) {
+	if err := checkName(repo); err != nil {
+		return Descriptor{}, err
+	}
+	c, err := CidOf(digest)
+	if err != nil {
+		return Descriptor{}, err
+	}
+	if err := b.putter.PutObject(ctx, c, r); err != nil {
+		if errors.Is(err, fsstore.ErrObjectCIDMismatch) {
+			return Descriptor{}, ErrDigestInvalid
+		}
+		return Descriptor{}, err
+	}
+	return b.link(ctx, c, repo, mediaType)
+}
+
+// Mount - links blob with digest of repository from to repository to, without transferring it (cross repository
+// blob mount). Returns `ErrBlobUnknown` when from has no such blob, so callers fall back to uploading it.
+func (b *BlobStore) Mount(ctx context.Context, from, to, digest string) (Descriptor, error) {
+	if err := checkName(to); err != nil {
+		return Descriptor{}, err
+	}
+	c, _, err := b.resolve(ctx, from, digest)
+	if err != nil {
+		return Descriptor{}, err
+	}
+	return b.link(ctx, c, to, "")
+}
+
+// link - links stored blob with cid to repository, recording media type for blobs without one
+func (b *BlobStore) link(ctx context.Context, c cid.Cid, repo, mediaType string) (Descriptor, error) {
+	unlock := b.lock(c)
+	defer unlock()
+	meta, err := b.meta.GetMetadata(ctx, c)
+	if errors.Is(err, objectstore.ErrObjectNotExists) {
+		return Descriptor{}, ErrBlobUnknown
+	}
+	if err != nil {
+		return Descriptor{}, err
+	}
+	changed := false
+	if !linked(meta, repo) {
+		meta.Tags = append(meta.Tags, repositoryTag(repo))
+		changed = true
+	}
+	if len(meta.ContentType) == 0 && len(mediaType) > 0 {
+		meta.ContentType = mediaType
+		changed = true
+	}
+	if changed {
+		if err := b.meta.SetMetadata(ctx, c, *meta); err != nil {
+			return Descriptor{}, err
+		}
+	}
+	return b.describe(ctx, c, meta)
+}
+
+// Delete - unlinks blob with digest from repository, deleting it from store (see `fsstore.Deleter`) once no
+// repository links it. Returns `ErrBlobUnknown` when repository has no such blob.
+func (b *BlobStore) Delete(ctx context.Context, repo, digest string) error {
+	c, _, err := b.resolve(ctx, repo, digest)
+	if err != nil {
+		return err
+	}
+	unlock := b.lock(c)
+	defer unlock()
+	meta, err := b.meta.GetMetadata(ctx, c)
+	if errors.Is(err, objectstore.ErrObjectNotExists) {
+		return ErrBlobUnknown
+	}
+	if err != nil {
+		return err
+	}
+	tags := []string{}
+	remaining := 0
+	for _, t := range meta.Tags {
+		if t == repositoryTag(repo) {
+			continue
+		}
+		if _, ok := repositoryOf(t); ok {
+			remaining++
+		}
+		tags = append(tags, t)
+	}
+	if remaining == 0 {
+		return b.remove(ctx, c, meta)
+	}
+	meta.Tags = tags
+	return b.meta.SetMetadata(ctx, c, *meta)
+}
+
+// remove - deletes blob with cid no repository links anymore, unlinking it first, as metadata of deleted objects
+// outlives them (and blob uploaded again must not reappear in repositories); blobs still referenced by other
+// objects are kept for them, unlinked
+func (b *BlobStore) remove(ctx context.Context, c cid.Cid, meta *fsstore.Metadata) error {
+	tags := []string{}
+	for _, t := range meta.Tags {
+		if _, ok := repositoryOf(t); !ok {
+			tags = append(tags, t)
+		}
+	}
+	meta.Tags = tags
+	if err := b.meta.SetMetadata(ctx, c, *meta); err != nil {
+		return err
+	}
+	if err := b.deleter.DeleteObject(ctx, c); err != nil && !errors.Is(err, fsstore.ErrObjectReferenced) {
+		return err
+	}
+	return nil
+}
+
+// Repositories - returns names of repositories blob with digest is linked to, `ErrBlobUnknown` when it is not
+// in store
+func (b *BlobStore) Repositories(ctx context.Context, digest string) ([]string, error) {
+	c, err := CidOf(digest)
+	if err != nil {
+		return nil, err
+	}
+	meta, err := b.meta.GetMetadata(ctx, c)
+	if errors.Is(err, objectstore.ErrObjectNotExists) {
+		return nil, ErrBlobUnknown
+	}
+	if err != nil {
+		return nil, err
+	}
+	repos := []string{}
+	for _, t := range meta.Tags {
+		if repo, ok := repositoryOf(t); ok {
+			repos = append(repos, repo)
+		}
+	}
+	return repos, nil
+}
@@ -0,0 +1,99 @@
+package ociblob
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	fsstore "github.com/igumus/go-objectstore-fs"
+	"github.com/ipfs/go-cid"
+)
+
+// BlobWriter feeds a blob of repository incrementally (chunked upload). Pending uploads survive process restarts,
+// and can be continued via `Resume` with their id.
+type BlobWriter interface {
+	// Write - appends chunk to upload
+	Write(p []byte) (int, error)
+	// ID - returns identifier of upload, to resume it later
+	ID() string
+	// Size - returns count of bytes written so far, i.e. offset next chunk is appended at
+	Size() int64
+	// Commit - stores written content as blob of repository, only when it matches digest
+	Commit(ctx context.Context, digest, mediaType string) (Descriptor, error)
+	// Cancel - discards upload
+	Cancel() error
+	// Close - releases upload, keeping it pending to be resumed
+	Close() error
+}
+
+// blobWriter is a pending chunked upload of a repository, fed into a resumable upload of store
+type blobWriter struct {
+	fsstore.Upload
+	b    *BlobStore
+	repo string
+}
+
+// Create - starts a chunked upload of a blob of repository
+func (b *BlobStore) Create(ctx context.Context, repo string) (BlobWriter, error) {
+	if err := checkName(repo); err != nil {
+		return nil, err
+	}
+	upload, err := b.uploader.NewUpload(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &blobWriter{Upload: upload, b: b, repo: repo}, nil
+}
+
+// Resume - continues pending chunked upload of a blob of repository with id
+func (b *BlobStore) Resume(ctx context.Context, repo, id string) (BlobWriter, error) {
+	if err := checkName(repo); err != nil {
+		return nil, err
+	}
+	upload, err := b.uploader.ResumeUpload(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return &blobWriter{Upload: upload, b: b, repo: repo}, nil
+}
+
+// Cancel - discards upload
+func (w *blobWriter) Cancel() error {
+	return w.Abort()
+}
+
+// Commit - stores written content as object, and links it to repository when its cid matches digest; content
+// not matching digest is deleted again, unless a repository links it already
+func (w *blobWriter) Commit(ctx context.Context, digest, mediaType string) (Descriptor, error) {
+	expected, err := CidOf(digest)
+	if err != nil {
+		return Descriptor{}, err
+	}
+	c, err := w.Upload.Commit()
+	if err != nil {
+		return Descriptor{}, err
+	}
+	if !c.Equals(expected) {
+		w.b.discard(ctx, c)
+		return Descriptor{}, ErrDigestInvalid
+	}
+	return w.b.link(ctx, c, w.repo, mediaType)
+}
+
+// discard - deletes object of a chunked upload not matching its digest, when no repository links it
+func (b *BlobStore) discard(ctx context.Context, c cid.Cid) {
+	unlock := b.lock(c)
+	defer unlock()
+	meta, err := b.meta.GetMetadata(ctx, c)
+	if err != nil {
+		return
+	}
+	for _, t := range meta.Tags {
+		if _, ok := repositoryOf(t); ok {
+			return
+		}
+	}
+	if err := b.deleter.DeleteObject(ctx, c); err != nil && !errors.Is(err, fsstore.ErrObjectReferenced) {
+		log.Printf("err: deleting blob of invalid digest failed: %s, %v\n", c, err)
+	}
+}
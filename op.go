@@ -0,0 +1,61 @@
+package fsstore
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/ipfs/go-cid"
+)
+
+// withDeadline - derives operation context bounded by default operation timeout (see `WithOperationTimeout`),
+// unless context already carries a deadline
+func (f *fsObjectStoreService) withDeadline(ctx context.Context) (context.Context, context.CancelFunc) {
+	if f.opTimeout <= 0 {
+		return ctx, func() {}
+	}
+	if _, ok := ctx.Deadline(); ok {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, f.opTimeout)
+}
+
+// bounded - runs file system call fn, returning early with context error when context is done before fn
+// returns. Calls are not started once context is done. Abandoned calls finish in background; since writes
+// are staged and renamed atomically, an abandoned write either lands completely or not at all.
+func (f *fsObjectStoreService) bounded(ctx context.Context, fn func() error) error {
+	if ctx.Done() == nil {
+		return fn()
+	}
+	if ctxErr := checkContextError(ctx, f.isDebug()); ctxErr != nil {
+		return ctxErr
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- fn()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return checkContextError(ctx, f.isDebug())
+	}
+}
+
+// observe - accounts bytes moved by operation in metrics, and logs structured warning when operation took
+// longer (by wall clock) than slow operation threshold (see `WithSlowOpThreshold`), along with request metadata of ctx
+func (f *fsObjectStoreService) observe(ctx context.Context, op string, start time.Time, c cid.Cid, size int64) {
+	f.metrics.transfer(op, size)
+	f.recordUsage(ctx, op, size)
+	if f.slowOp <= 0 {
+		return
+	}
+	if elapsed := time.Since(start); elapsed >= f.slowOp {
+		key := "-"
+		if c.Defined() {
+			key = c.String()
+		}
+		log.Printf("warn: slow operation: op=%s bucket=%s cid=%s duration=%s size=%d%s\n", op, f.bucket, key, elapsed, size,
+			RequestMetaFromContext(ctx).logFields())
+	}
+}
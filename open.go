@@ -0,0 +1,79 @@
+package fsstore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+
+	"github.com/igumus/go-objectstore-lib"
+	"github.com/ipfs/go-cid"
+)
+
+// ObjectOpener defines the functions clients need to access objects without reading them fully into memory.
+type ObjectOpener interface {
+	OpenObject(context.Context, cid.Cid) (io.ReadSeekCloser, error)
+}
+
+var _ ObjectOpener = (*fsObjectStoreService)(nil)
+
+// OpenObject - opens object with specified cid (aka content identifier) for seekable reading. Callers must
+// close returned reader. Content is not verified against cid; see `Verify` for detecting corruption. Enveloped
+// objects (e.g. deltas) are reconstructed in memory.
+func (f *fsObjectStoreService) OpenObject(ctx context.Context, c cid.Cid) (io.ReadSeekCloser, error) {
+	if err := f.authorize(ctx, OpRead, c); err != nil {
+		return nil, err
+	}
+	obj, err := f.openObject(ctx, c)
+	if err == nil {
+		f.popularity.record(c)
+	}
+	f.audit(ctx, OpRead, c, err)
+	return obj, err
+}
+
+// openObject - opens object file for seekable reading, reconstructing enveloped objects in memory
+func (f *fsObjectStoreService) openObject(ctx context.Context, cid cid.Cid) (io.ReadSeekCloser, error) {
+	key := cid.String()
+	if f.negative.contains(key) {
+		return nil, objectstore.ErrObjectNotExists
+	}
+	if ctxErr := checkContextError(ctx, f.isDebug()); ctxErr != nil {
+		return nil, ctxErr
+	}
+	objLink := f.objectPath(cid)
+	if err := f.fds.acquire(ctx); err != nil {
+		return nil, err
+	}
+	file, err := f.openObjectFile(objLink)
+	if errors.Is(err, objectstore.ErrObjectNotExists) && f.isRebalancing() {
+		// object may have been moved to another stripe after it was located
+		objLink = f.objectPath(cid)
+		file, err = f.openObjectFile(objLink)
+	}
+	if err != nil {
+		f.fds.release()
+		if errors.Is(err, objectstore.ErrObjectNotExists) {
+			f.rememberAbsent(key)
+		}
+		return nil, err
+	}
+	head := make([]byte, len(_envelopeMagic))
+	if n, _ := file.ReadAt(head, 0); isEnveloped(head[:n]) {
+		file.Close()
+		f.fds.release()
+		data, err := f.load(ctx, objLink)
+		if err != nil {
+			return nil, err
+		}
+		return bytesObject{bytes.NewReader(data)}, nil
+	}
+	if f.isDebug() {
+		log.Printf("debug: opened object: %s\n", objLink)
+	}
+	if f.fds != nil {
+		return &budgetedFile{File: file, budget: f.fds}, nil
+	}
+	return file, nil
+}
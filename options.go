@@ -1,8 +1,11 @@
 package fsstore
 
 import (
+	"database/sql"
 	"errors"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/igumus/go-objectstore-lib"
 )
@@ -21,9 +24,72 @@ const _defDataDir = "/data"
 
 // Captures/Represents file system based objectstore configuration information
 type fsObjectStoreConfig struct {
-	dir    string
-	bucket string
-	debug  bool
+	dir             string
+	extraDirs       []string
+	retiredDirs     []string
+	rebalanceRate   int64
+	bucket          string
+	debug           bool
+	tempDir         string
+	xattrs          bool
+	listBuffer      int
+	listStall       time.Duration
+	consistentList  bool
+	watchExternal   bool
+	layouts         map[string]objectstore.LinkFunc
+	layoutRewrite   bool
+	journal         bool
+	webhookURL      string
+	webhookSecret   string
+	scrubRate       int64
+	schedule        schedule
+	scheduleSpec    string
+	scheduleErr     error
+	retention       *RetentionPolicy
+	opTimeout       time.Duration
+	slowOp          time.Duration
+	chunkMin        int
+	chunkAvg        int
+	chunkMax        int
+	chunkWorkers    int
+	maxDeltaDepth   int
+	replicas        []objectstore.ObjectStore
+	hedgeDelay      time.Duration
+	keys            StaticKeyProvider
+	keyProvider     KeyProvider
+	activeKey       string
+	eagerRotation   bool
+	authorizer      Authorizer
+	audit           bool
+	trashRetention  time.Duration
+	uploadTTL       time.Duration
+	topCapacity     int
+	symlinks        SymlinkPolicy
+	maxOpenFiles    int
+	standbySource   StandbySource
+	standbyPoll     time.Duration
+	lazyInit        bool
+	readOnly        bool
+	statsInterval   time.Duration
+	pool            bool
+	maintQueue      int
+	cidIndex        bool
+	catalogDB       *sql.DB
+	lifecycle       []LifecycleRule
+	transitioner    Transitioner
+	ioBudget        IOBudget
+	jobConcurrency  int
+	jobHistory      int
+	verifyCacheSize int
+	verifyCacheTTL  time.Duration
+	signer          Signer
+	scanners        []ContentScanner
+	usageRetention  time.Duration
+	chaos           *ChaosProfile
+	clock           Clock
+	negCacheSize    int
+	negCacheTTL     time.Duration
+	negPersist      bool
 }
 
 // validate - returns error if constructed configuration not valid, otherwise returns nil
@@ -31,8 +97,21 @@ func (f *fsObjectStoreConfig) validate() error {
 	if len(f.dir) == 0 {
 		return ErrDataDirNotSpecified
 	}
-	if len(f.bucket) == 0 {
-		return objectstore.ErrBucketNotSpecified
+	if err := validateBucket(f.bucket); err != nil {
+		return err
+	}
+	seen := map[string]struct{}{filepath.Clean(f.dir): {}}
+	for _, dir := range append(append([]string{}, f.extraDirs...), f.retiredDirs...) {
+		if _, ok := seen[filepath.Clean(dir)]; ok {
+			return ErrDuplicateDataDir
+		}
+		seen[filepath.Clean(dir)] = struct{}{}
+	}
+	if f.scheduleErr != nil {
+		return f.scheduleErr
+	}
+	if err := validateLifecycle(f.lifecycle, f.transitioner); err != nil {
+		return err
 	}
 	return nil
 }
@@ -42,9 +121,27 @@ func (f *fsObjectStoreConfig) validate() error {
 // with its default  values
 func defaultFSObjectstoreConfig() *fsObjectStoreConfig {
 	return &fsObjectStoreConfig{
-		dir:    _defDataDir,
-		bucket: _defBucket,
-		debug:  _defDebug,
+		dir:             _defDataDir,
+		bucket:          _defBucket,
+		debug:           _defDebug,
+		chunkMin:        _defChunkMin,
+		chunkAvg:        _defChunkAvg,
+		chunkMax:        _defChunkMax,
+		chunkWorkers:    _defChunkWorkers,
+		maxDeltaDepth:   _defMaxDeltaDepth,
+		hedgeDelay:      _defHedgeDelay,
+		trashRetention:  _defTrashRetention,
+		uploadTTL:       _defUploadTTL,
+		symlinks:        _defSymlinkPolicy,
+		standbyPoll:     _defStandbyPoll,
+		statsInterval:   _defStatsCheckpoint,
+		clock:           systemClock{},
+		negCacheSize:    _defNegCacheSize,
+		negCacheTTL:     _defNegCacheTTL,
+		jobConcurrency:  _defJobConcurrency,
+		jobHistory:      _defJobHistory,
+		verifyCacheSize: _defVerifyCacheSize,
+		verifyCacheTTL:  _defVerifyCacheTTL,
 	}
 }
 
@@ -67,6 +164,44 @@ func WithDataDir(d string) FSObjectstoreConfigOption {
 	}
 }
 
+// WithDataDirs returns a FSObjectstoreConfigOption that specifies several data directories (e.g. mount points
+// of separate disks) objects are striped across, placed by consistent hashing over cid (see `PlacementMap`),
+// so adding a directory moves only objects it takes over. First directory is primary, holding store internals
+// besides its share of objects. Objects are read from whichever directory holds them, so directories may be
+// appended later on; see `Rebalance` to move objects to directories they are placed on.
+// If not set, objects are stored under single data directory (see `WithDataDir`)
+func WithDataDirs(dirs ...string) FSObjectstoreConfigOption {
+	return func(fosc *fsObjectStoreConfig) {
+		if len(dirs) > 0 {
+			fosc.dir = strings.TrimSpace(dirs[0])
+			fosc.extraDirs = nil
+			for _, dir := range dirs[1:] {
+				fosc.extraDirs = append(fosc.extraDirs, strings.TrimSpace(dir))
+			}
+		}
+	}
+}
+
+// WithRetiredDataDirs returns a FSObjectstoreConfigOption that specifies data directories being removed from
+// store. Objects are still read from them, but never placed on them; `Rebalance` drains them.
+// If not set, no data directory is retired
+func WithRetiredDataDirs(dirs ...string) FSObjectstoreConfigOption {
+	return func(fosc *fsObjectStoreConfig) {
+		fosc.retiredDirs = nil
+		for _, dir := range dirs {
+			fosc.retiredDirs = append(fosc.retiredDirs, strings.TrimSpace(dir))
+		}
+	}
+}
+
+// WithRebalanceRate returns a FSObjectstoreConfigOption that specifies bytes per second `Rebalance` moves objects.
+// If not set, the default is `0` (unlimited)
+func WithRebalanceRate(bytesPerSec int64) FSObjectstoreConfigOption {
+	return func(fosc *fsObjectStoreConfig) {
+		fosc.rebalanceRate = bytesPerSec
+	}
+}
+
 // WithDebugMode returns a FSObjectstoreConfigOption that specifies debug mode.
 // If not set, the default is `false`
 func WithDebugMode(dm bool) FSObjectstoreConfigOption {
@@ -74,3 +209,534 @@ func WithDebugMode(dm bool) FSObjectstoreConfigOption {
 		fosc.debug = dm
 	}
 }
+
+// WithTempDir returns a FSObjectstoreConfigOption that specifies staging directory of atomic writes.
+// Directory must reside on same file system with bucket. If not set, the default is `.fsstore/tmp` inside bucket
+func WithTempDir(d string) FSObjectstoreConfigOption {
+	return func(fosc *fsObjectStoreConfig) {
+		fosc.tempDir = strings.TrimSpace(d)
+	}
+}
+
+// WithXattrMetadata returns a FSObjectstoreConfigOption that specifies whether object metadata is stored in
+// extended attributes instead of sidecar files. Falls back to sidecars where not supported. If not set, the default is `false`
+func WithXattrMetadata(x bool) FSObjectstoreConfigOption {
+	return func(fosc *fsObjectStoreConfig) {
+		fosc.xattrs = x
+	}
+}
+
+// WithListBuffer returns a FSObjectstoreConfigOption that specifies buffer size of listing channel.
+// If not set, the default is `0` (unbuffered)
+func WithListBuffer(n int) FSObjectstoreConfigOption {
+	return func(fosc *fsObjectStoreConfig) {
+		if n >= 0 {
+			fosc.listBuffer = n
+		}
+	}
+}
+
+// WithListStallTimeout returns a FSObjectstoreConfigOption that specifies how long listing waits for a
+// stalled consumer, before spilling rest of listing to a paged snapshot on disk. If not set, the default
+// is `0` (listing waits for consumer indefinitely)
+func WithListStallTimeout(d time.Duration) FSObjectstoreConfigOption {
+	return func(fosc *fsObjectStoreConfig) {
+		fosc.listStall = d
+	}
+}
+
+// WithConsistentListing returns a FSObjectstoreConfigOption that specifies whether listing is served from a point
+// in time copy of cid index (see `WithCIDIndex`), taken as listing starts, rather than a live walk of bucket; so
+// objects created or deleted while listing is consumed are neither listed twice nor missed, and objects are listed
+// in cid order. First listing walks bucket to build index, unless it is checkpointed. If not set, the default is
+// `false`
+func WithConsistentListing(c bool) FSObjectstoreConfigOption {
+	return func(fosc *fsObjectStoreConfig) {
+		fosc.consistentList = c
+	}
+}
+
+// WithJournal returns a FSObjectstoreConfigOption that specifies whether created/deleted objects are
+// recorded in an append-only journal, which replication and eventing follow. If not set, the default is `false`
+func WithJournal(j bool) FSObjectstoreConfigOption {
+	return func(fosc *fsObjectStoreConfig) {
+		fosc.journal = j
+	}
+}
+
+// WithCreateWebhook returns a FSObjectstoreConfigOption that specifies an url notified after each successful
+// CreateObject, with notification body signed via HMAC-SHA256 of secret. If not set, no webhook is notified
+func WithCreateWebhook(url, secret string) FSObjectstoreConfigOption {
+	return func(fosc *fsObjectStoreConfig) {
+		fosc.webhookURL = strings.TrimSpace(url)
+		fosc.webhookSecret = secret
+	}
+}
+
+// WithScrubRate returns a FSObjectstoreConfigOption that specifies bytes per second verification reads objects.
+// If not set, the default is `0` (unlimited)
+func WithScrubRate(bytesPerSec int64) FSObjectstoreConfigOption {
+	return func(fosc *fsObjectStoreConfig) {
+		fosc.scrubRate = bytesPerSec
+	}
+}
+
+// WithMaintenanceSchedule returns a FSObjectstoreConfigOption that specifies when verification (followed by
+// lifecycle rules, see `WithLifecycleRules`, and garbage collection, when `WithGCRetention` is set) runs in background. Spec is either `@every <duration>`,
+// `@hourly`, `@daily`, `@weekly` or a five field cron expression. If not set, no maintenance is scheduled
+func WithMaintenanceSchedule(spec string) FSObjectstoreConfigOption {
+	return func(fosc *fsObjectStoreConfig) {
+		fosc.schedule, fosc.scheduleErr = parseSchedule(spec)
+		fosc.scheduleSpec = spec
+	}
+}
+
+// WithGCRetention returns a FSObjectstoreConfigOption that specifies retention policy of scheduled garbage collection.
+// If not set, scheduled maintenance does not collect garbage
+func WithGCRetention(policy RetentionPolicy) FSObjectstoreConfigOption {
+	return func(fosc *fsObjectStoreConfig) {
+		fosc.retention = &policy
+	}
+}
+
+// WithOperationTimeout returns a FSObjectstoreConfigOption that specifies default deadline of read and create
+// operations, applied when caller context carries no deadline; operations blocked on file system (e.g. hung
+// NFS mounts) return `objectstore.ErrOperationDeadlineExceeded` once it passes.
+// If not set, the default is `0` (no deadline)
+func WithOperationTimeout(d time.Duration) FSObjectstoreConfigOption {
+	return func(fosc *fsObjectStoreConfig) {
+		fosc.opTimeout = d
+	}
+}
+
+// WithSlowOpThreshold returns a FSObjectstoreConfigOption that specifies duration beyond which operations
+// log a `warn: slow operation` line carrying op, cid, duration and size.
+// If not set, the default is `0` (disabled)
+func WithSlowOpThreshold(d time.Duration) FSObjectstoreConfigOption {
+	return func(fosc *fsObjectStoreConfig) {
+		fosc.slowOp = d
+	}
+}
+
+// WithChunkSizes returns a FSObjectstoreConfigOption that specifies minimum, average and maximum chunk sizes of
+// content defined chunking (see `CreateChunked`). Changing sizes moves cut points, so chunks written with other
+// sizes are not deduplicated.
+// If not set, the default is `16KiB`, `64KiB`, `256KiB`
+func WithChunkSizes(min, avg, max int) FSObjectstoreConfigOption {
+	return func(fosc *fsObjectStoreConfig) {
+		fosc.chunkMin = min
+		fosc.chunkAvg = avg
+		fosc.chunkMax = max
+	}
+}
+
+// WithChunkDecodeWorkers returns a FSObjectstoreConfigOption that specifies how many chunks are read and decoded
+// in parallel when chunked object is read in full (see `ReadChunkedAll`). If not set, the default is `4`
+func WithChunkDecodeWorkers(n int) FSObjectstoreConfigOption {
+	return func(fosc *fsObjectStoreConfig) {
+		if n > 0 {
+			fosc.chunkWorkers = n
+		}
+	}
+}
+
+// WithMaxDeltaDepth returns a FSObjectstoreConfigOption that specifies maximum length of delta chains
+// (see `CreateDelta`); content based on a chain already that long is stored in full, bounding reconstruction cost.
+// If not set, the default is `4`
+func WithMaxDeltaDepth(depth int) FSObjectstoreConfigOption {
+	return func(fosc *fsObjectStoreConfig) {
+		fosc.maxDeltaDepth = depth
+	}
+}
+
+// WithNegativeCache returns a FSObjectstoreConfigOption that specifies how many absent cids are remembered, and for
+// how long, so repeated lookups of missing objects (by `HasObject`, reads and opens) skip disk, and replicas (see
+// `WithReplica`) once every one of them missed. Long ttl suits pull-through and replication workloads; it bounds
+// how late objects written by other processes sharing bucket are noticed.
+// If not set, the default is `4096` cids, remembered for `2s`
+func WithNegativeCache(size int, ttl time.Duration) FSObjectstoreConfigOption {
+	return func(fosc *fsObjectStoreConfig) {
+		if size > 0 {
+			fosc.negCacheSize = size
+		}
+		if ttl > 0 {
+			fosc.negCacheTTL = ttl
+		}
+	}
+}
+
+// WithNegativeCachePersistence returns a FSObjectstoreConfigOption that specifies whether remembered absent cids
+// are persisted along with statistics (see `WithStatsCheckpointInterval`), so they survive restarts until they
+// expire. If not set, the default is `false`
+func WithNegativeCachePersistence(p bool) FSObjectstoreConfigOption {
+	return func(fosc *fsObjectStoreConfig) {
+		fosc.negPersist = p
+	}
+}
+
+// WithReplica returns a FSObjectstoreConfigOption that adds a replica (e.g. a mirror or origin gateway) reads
+// are hedged against; replicas are tried in order they are added.
+// If not set, reads are served from file system only
+func WithReplica(replica objectstore.ObjectStore) FSObjectstoreConfigOption {
+	return func(fosc *fsObjectStoreConfig) {
+		fosc.replicas = append(fosc.replicas, replica)
+	}
+}
+
+// WithHedgeDelay returns a FSObjectstoreConfigOption that specifies how long a read waits for an answer
+// before also being issued to next replica.
+// If not set, the default is `50ms`
+func WithHedgeDelay(d time.Duration) FSObjectstoreConfigOption {
+	return func(fosc *fsObjectStoreConfig) {
+		fosc.hedgeDelay = d
+	}
+}
+
+// WithEncryptionKey returns a FSObjectstoreConfigOption that adds an AES-128/192/256 key (16, 24 or 32 bytes)
+// objects are encrypted at rest with (AES-GCM). Key added last is active, unless `RotateKey` activated
+// another one; keys objects are still encrypted with must stay configured.
+// If not set, objects are stored unencrypted
+func WithEncryptionKey(id string, key []byte) FSObjectstoreConfigOption {
+	return func(fosc *fsObjectStoreConfig) {
+		if fosc.keys == nil {
+			fosc.keys = StaticKeyProvider{}
+		}
+		fosc.keys[id] = key
+		fosc.keyProvider = fosc.keys
+		fosc.activeKey = id
+	}
+}
+
+// WithKeyProvider returns a FSObjectstoreConfigOption that specifies provider resolving encryption keys by id
+// (e.g. a file, KMS or Vault backed provider), and id of key new objects are encrypted with, unless
+// `RotateKey` activated another one. Replaces keys added via `WithEncryptionKey`.
+// If not set, objects are stored unencrypted
+func WithKeyProvider(provider KeyProvider, activeID string) FSObjectstoreConfigOption {
+	return func(fosc *fsObjectStoreConfig) {
+		fosc.keys = nil
+		fosc.keyProvider = provider
+		fosc.activeKey = activeID
+	}
+}
+
+// WithEagerKeyRotation returns a FSObjectstoreConfigOption that specifies whether `RotateKey` re-encrypts
+// every object via a background job, rather than only as objects are read.
+// If not set, the default is `false`
+func WithEagerKeyRotation(eager bool) FSObjectstoreConfigOption {
+	return func(fosc *fsObjectStoreConfig) {
+		fosc.eagerRotation = eager
+	}
+}
+
+// WithAuthorizer returns a FSObjectstoreConfigOption that specifies authorizer consulted before every operation,
+// with principal front ends attach to context via `ContextWithPrincipal`.
+// If not set, every operation is allowed
+func WithAuthorizer(a Authorizer) FSObjectstoreConfigOption {
+	return func(fosc *fsObjectStoreConfig) {
+		fosc.authorizer = a
+	}
+}
+
+// WithAuditLog returns a FSObjectstoreConfigOption that specifies whether operations (principal, operation,
+// time and result) are recorded in an append-only, hash chained audit log. Denials are recorded for every
+// operation, outcomes for object reads, writes and admin operations. If not set, the default is `false`
+func WithAuditLog(a bool) FSObjectstoreConfigOption {
+	return func(fosc *fsObjectStoreConfig) {
+		fosc.audit = a
+	}
+}
+
+// WithTrashRetention returns a FSObjectstoreConfigOption that specifies how long deleted objects stay in trash,
+// restorable via `RestoreObject`, before garbage collection purges them.
+// If not set, the default is `168h` (7 days)
+func WithTrashRetention(d time.Duration) FSObjectstoreConfigOption {
+	return func(fosc *fsObjectStoreConfig) {
+		fosc.trashRetention = d
+	}
+}
+
+// WithUploadTTL returns a FSObjectstoreConfigOption that specifies how long pending uploads (see `NewUpload`) may
+// stay idle, i.e. neither written nor resumed, before garbage collection removes them as abandoned; idle uploads can
+// not be resumed anymore. TTL `0` keeps pending uploads until they are committed or aborted.
+// If not set, the default is `24h`
+func WithUploadTTL(d time.Duration) FSObjectstoreConfigOption {
+	return func(fosc *fsObjectStoreConfig) {
+		if d >= 0 {
+			fosc.uploadTTL = d
+		}
+	}
+}
+
+// WithPopularityTracking returns a FSObjectstoreConfigOption that specifies whether object reads are counted
+// (approximately, in bounded memory) for `TopObjects` to report hottest objects.
+// If not set, the default is `false`
+func WithPopularityTracking(enabled bool) FSObjectstoreConfigOption {
+	return func(fosc *fsObjectStoreConfig) {
+		fosc.topCapacity = 0
+		if enabled {
+			fosc.topCapacity = _defTopCapacity
+		}
+	}
+}
+
+// WithUsageAccounting returns a FSObjectstoreConfigOption that specifies reads and writes of clients are accounted
+// to their tenant (see `ContextWithTenant`, falling back to principal), in daily windows retained for retention, so
+// `Usage` reports bandwidth for chargeback. Windows are persisted along with statistics (see
+// `WithStatsCheckpointInterval`). If not set, the default is `0`, and usage is not accounted
+func WithUsageAccounting(retention time.Duration) FSObjectstoreConfigOption {
+	return func(fosc *fsObjectStoreConfig) {
+		if retention >= 0 {
+			fosc.usageRetention = retention
+		}
+	}
+}
+
+// WithChaos returns a FSObjectstoreConfigOption that specifies latency and failures injected into operations of
+// clients (see `ChaosProfile`), to rehearse degraded storage in staging environments. Profile takes effect only
+// when store is built with `chaos` build tag, and is ignored (with a warning) otherwise.
+// If not set, no chaos is injected
+func WithChaos(profile ChaosProfile) FSObjectstoreConfigOption {
+	return func(fosc *fsObjectStoreConfig) {
+		fosc.chaos = &profile
+	}
+}
+
+// WithClock returns a FSObjectstoreConfigOption that specifies clock store tells time by (see `Clock`), e.g. a
+// `ManualClock` advanced by tests. If not set, the default is wall clock
+func WithClock(c Clock) FSObjectstoreConfigOption {
+	return func(fosc *fsObjectStoreConfig) {
+		if c != nil {
+			fosc.clock = c
+		}
+	}
+}
+
+// WithSymlinkPolicy returns a FSObjectstoreConfigOption that specifies how symbolic links in bucket directory are
+// treated by listing, verification and reads. Links resolving outside of bucket are never followed.
+// If not set, the default is `SymlinkSkip`
+func WithSymlinkPolicy(p SymlinkPolicy) FSObjectstoreConfigOption {
+	return func(fosc *fsObjectStoreConfig) {
+		fosc.symlinks = p
+	}
+}
+
+// WithMaxOpenFiles returns a FSObjectstoreConfigOption that specifies how many object files may be open at once
+// for reads and writes; further opens wait for budget (see `OpenFiles` for wait statistics). Objects opened via
+// `OpenObject` hold budget until closed.
+// If not set, the default is `0` (unlimited)
+func WithMaxOpenFiles(n int) FSObjectstoreConfigOption {
+	return func(fosc *fsObjectStoreConfig) {
+		fosc.maxOpenFiles = n
+	}
+}
+
+// WithStandby returns a FSObjectstoreConfigOption that specifies store is a warm standby of primary store
+// served by source: primary journal (see `WithJournal`) is followed in background, creations and deletions
+// are applied locally, and client writes are rejected until store is promoted (see `Standby`).
+// If not set, store is a primary
+func WithStandby(source StandbySource) FSObjectstoreConfigOption {
+	return func(fosc *fsObjectStoreConfig) {
+		fosc.standbySource = source
+	}
+}
+
+// WithStandbyPollInterval returns a FSObjectstoreConfigOption that specifies how often a caught up standby
+// polls primary journal for new entries.
+// If not set, the default is `1s`
+func WithStandbyPollInterval(d time.Duration) FSObjectstoreConfigOption {
+	return func(fosc *fsObjectStoreConfig) {
+		if d > 0 {
+			fosc.standbyPoll = d
+		}
+	}
+}
+
+// WithLazyInit returns a FSObjectstoreConfigOption that specifies bucket is not provisioned when store is opened
+// but on first write (or explicitly via `EnsureBucket`), so opening store on a read-only mount modifies nothing.
+// Journal and audit log of a lazily provisioned bucket are opened once it is provisioned; standby stores are
+// always provisioned when opened. If not set, the default is `false`
+func WithLazyInit(l bool) FSObjectstoreConfigOption {
+	return func(fosc *fsObjectStoreConfig) {
+		fosc.lazyInit = l
+	}
+}
+
+// WithReadOnly returns a FSObjectstoreConfigOption that specifies store only reads bucket, e.g. for troubleshooting
+// a bucket another process holds open: nothing is provisioned, locked, marked open, checkpointed or maintained in
+// background, and writes and deletes of clients fail with ErrStoreReadOnly. Administrative operations modifying
+// bucket (e.g. garbage collection) must not be run on read only stores. If not set, the default is `false`
+func WithReadOnly(r bool) FSObjectstoreConfigOption {
+	return func(fosc *fsObjectStoreConfig) {
+		fosc.readOnly = r
+	}
+}
+
+// WithStatsCheckpointInterval returns a FSObjectstoreConfigOption that specifies how often changed statistics
+// (see `Stats`) are checkpointed to bucket internals, so they are recovered instead of reloaded when store is
+// reopened; operations journaled after checkpoint (see `WithJournal`) are replayed. Without journal statistics
+// are recovered only when store was closed. Interval `0` checkpoints only when store is closed.
+// If not set, the default is `1m`
+func WithStatsCheckpointInterval(d time.Duration) FSObjectstoreConfigOption {
+	return func(fosc *fsObjectStoreConfig) {
+		if d >= 0 {
+			fosc.statsInterval = d
+		}
+	}
+}
+
+// WithSharedPool returns a FSObjectstoreConfigOption that specifies objects are stored once in a content pool shared
+// by every bucket of data directory, buckets holding hard links to pooled objects as membership references; so
+// identical content created in several buckets consumes disk once. Garbage collection reclaims pooled objects
+// no bucket links anymore. Encrypted objects (see `WithKeyProvider`) are never pooled. If not set, the default is `false`
+func WithSharedPool(s bool) FSObjectstoreConfigOption {
+	return func(fosc *fsObjectStoreConfig) {
+		fosc.pool = s
+	}
+}
+
+// WithMaintenanceQueue returns a FSObjectstoreConfigOption that specifies how many writes wait for store to leave
+// maintenance mode (see `EnterMaintenance`), before further writes are rejected with `ErrMaintenance`.
+// If not set, the default is `0` (writes are rejected)
+func WithMaintenanceQueue(n int) FSObjectstoreConfigOption {
+	return func(fosc *fsObjectStoreConfig) {
+		if n >= 0 {
+			fosc.maintQueue = n
+		}
+	}
+}
+
+// WithCIDIndex returns a FSObjectstoreConfigOption that specifies whether cids of objects are kept in an index
+// maintained as objects are created and deleted, and checkpointed along with statistics (see
+// `WithStatsCheckpointInterval`), so `ListCIDs` streams key set of bucket without walking it. If not set,
+// the default is `false`, and first `ListCIDs` call walks bucket to build index
+func WithCIDIndex(i bool) FSObjectstoreConfigOption {
+	return func(fosc *fsObjectStoreConfig) {
+		fosc.cidIndex = i
+	}
+}
+
+// WithExternalWrites returns a FSObjectstoreConfigOption that specifies whether bucket is watched (via inotify, on
+// linux only) for object files other processes place into it directly, e.g. rsync restores or manual copies; once
+// settled, files named after a cid, stored at its path and matching it are indexed as created (journaled,
+// accounted in statistics, cid index and catalog), so they are picked up without `Reconcile`. Cid index is
+// maintained (see `WithCIDIndex`) to tell them from objects store knows. If not set, the default is `false`
+func WithExternalWrites(w bool) FSObjectstoreConfigOption {
+	return func(fosc *fsObjectStoreConfig) {
+		fosc.watchExternal = w
+	}
+}
+
+// WithHistoricalLayout returns a FSObjectstoreConfigOption that specifies a layout (link function of earlier
+// sharding or link encoding) objects of bucket may still be stored in; reads missing object in current layout probe
+// historical layouts before reporting it absent. Layouts are recorded in bucket metadata, so later opens probe
+// them too; link of built-in layout `flat` (object files in bucket directory) may be nil. May be given repeatedly.
+// If not set, only layouts recorded earlier are probed
+func WithHistoricalLayout(name string, link objectstore.LinkFunc) FSObjectstoreConfigOption {
+	return func(fosc *fsObjectStoreConfig) {
+		if link == nil {
+			link = _builtinLayouts[name]
+		}
+		if len(name) == 0 || link == nil {
+			return
+		}
+		if fosc.layouts == nil {
+			fosc.layouts = map[string]objectstore.LinkFunc{}
+		}
+		fosc.layouts[name] = link
+	}
+}
+
+// WithLayoutRewrite returns a FSObjectstoreConfigOption that specifies whether objects read from a historical layout
+// (see `WithHistoricalLayout`) are moved into current layout, so they are found there next time.
+// If not set, the default is `false`
+func WithLayoutRewrite(r bool) FSObjectstoreConfigOption {
+	return func(fosc *fsObjectStoreConfig) {
+		fosc.layoutRewrite = r
+	}
+}
+
+// WithCatalog returns a FSObjectstoreConfigOption that specifies a SQLite database (opened by caller, with driver of
+// its choice) objects of bucket are cataloged in as they are created, deleted and described (see `SetMetadata`),
+// so they can be queried by size, age, content type and tags (see `Query`). Several buckets may share a database.
+// If not set, the default is `nil` (no catalog)
+func WithCatalog(db *sql.DB) FSObjectstoreConfigOption {
+	return func(fosc *fsObjectStoreConfig) {
+		fosc.catalogDB = db
+	}
+}
+
+// WithLifecycleRules returns a FSObjectstoreConfigOption that specifies lifecycle rules of bucket, applied by
+// scheduled maintenance (see `WithMaintenanceSchedule`) before garbage collection, or via `ApplyLifecycle`.
+// Every object is acted upon by first rule it matches. If not set, no lifecycle rules apply
+func WithLifecycleRules(rules ...LifecycleRule) FSObjectstoreConfigOption {
+	return func(fosc *fsObjectStoreConfig) {
+		fosc.lifecycle = append(fosc.lifecycle, rules...)
+	}
+}
+
+// WithLifecycleTransition returns a FSObjectstoreConfigOption that specifies where `LifecycleTransition` rules move
+// objects to. If not set, the default is `nil`, and transition rules are rejected
+func WithLifecycleTransition(t Transitioner) FSObjectstoreConfigOption {
+	return func(fosc *fsObjectStoreConfig) {
+		fosc.transitioner = t
+	}
+}
+
+// WithIOBudget returns a FSObjectstoreConfigOption that specifies IO budget shared by walkers scanning bucket, so
+// verification, garbage collection and listing yield disk to foreground traffic; budget can be changed at runtime
+// via `SetIOBudget`. `WithScrubRate` and `WithRebalanceRate` apply on top. If not set, walkers are unthrottled
+func WithIOBudget(b IOBudget) FSObjectstoreConfigOption {
+	return func(fosc *fsObjectStoreConfig) {
+		fosc.ioBudget = b
+	}
+}
+
+// WithJobConcurrency returns a FSObjectstoreConfigOption that specifies how many jobs (see `SubmitJob`, and
+// steps of scheduled maintenance) run at once; further jobs are queued. If not set, the default is `2`
+func WithJobConcurrency(n int) FSObjectstoreConfigOption {
+	return func(fosc *fsObjectStoreConfig) {
+		if n > 0 {
+			fosc.jobConcurrency = n
+		}
+	}
+}
+
+// WithJobHistory returns a FSObjectstoreConfigOption that specifies how many finished jobs are kept, for their
+// status to be reported by `Job` and `Jobs`. If not set, the default is `64`
+func WithJobHistory(n int) FSObjectstoreConfigOption {
+	return func(fosc *fsObjectStoreConfig) {
+		if n >= 0 {
+			fosc.jobHistory = n
+		}
+	}
+}
+
+// WithVerifyCache returns a FSObjectstoreConfigOption that specifies how many objects verified by reads (see
+// `WithVerify`) and verification are remembered, and for how long, so verified reads of hot objects skip rehashing
+// while their stored file keeps its size and modification time. Size `0` disables caching. If not set, the
+// default is `4096` objects for `10m`
+func WithVerifyCache(size int, ttl time.Duration) FSObjectstoreConfigOption {
+	return func(fosc *fsObjectStoreConfig) {
+		if size >= 0 && ttl > 0 {
+			fosc.verifyCacheSize, fosc.verifyCacheTTL = size, ttl
+		}
+	}
+}
+
+// WithSigner returns a FSObjectstoreConfigOption that specifies signer of bucket exports (see `ExportWhere`),
+// snapshots (see `SnapshotBucket`) and checksum manifests (see `ExportChecksumManifest`), so their recipients can
+// authenticate them, e.g. via `VerifyExport`. If not set, nothing is signed
+func WithSigner(s Signer) FSObjectstoreConfigOption {
+	return func(fosc *fsObjectStoreConfig) {
+		fosc.signer = s
+	}
+}
+
+// WithContentScanners returns a FSObjectstoreConfigOption that specifies scanners inspecting content of objects
+// before they are committed (see `ContentScanner`), in given order; first rejection aborts write. If not set,
+// content is not scanned
+func WithContentScanners(scanners ...ContentScanner) FSObjectstoreConfigOption {
+	return func(fosc *fsObjectStoreConfig) {
+		fosc.scanners = append(fosc.scanners, scanners...)
+	}
+}
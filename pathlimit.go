@@ -0,0 +1,52 @@
+package fsstore
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+
+	"github.com/igumus/go-objectstore-lib"
+)
+
+// ErrPathTooLong is return, when paths store writes objects (or their internals) at exceed platform path limits
+// for configured data directories.
+var ErrPathTooLong = errors.New("fsobjectstore: path exceeds platform limit")
+
+// validatePaths - checks that longest paths objects created by store (and their trash, references, metadata
+// and staged files) are written at fit platform path and component limits, so misconfigured data directories
+// fail when store is opened instead of on writes
+func (f *fsObjectStoreService) validatePaths() error {
+	probe, err := objectstore.DigestPrefix.Sum(nil)
+	if err != nil {
+		return err
+	}
+	staged := _tempPrefix + strings.Repeat("0", 16)
+	paths := []string{
+		f.refsPath(_refsOut, probe),
+		f.refsPath(_refsIn, probe),
+		f.metaPath(probe),
+		filepath.Join(f.tempDir, staged),
+	}
+	for _, stripe := range f.stripeDirs() {
+		paths = append(paths, filepath.Join(stripe, objectLink(probe)), f.trashPath(stripe, probe),
+			filepath.Join(f.tempFor(stripe), staged), f.poolPath(stripe, probe))
+	}
+	for _, path := range paths {
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+		if len(path) > _maxPath {
+			log.Printf("err: object path exceeds platform limit: %s, %d > %d\n", path, len(path), _maxPath)
+			return fmt.Errorf("%w: %s is %d bytes, limit is %d", ErrPathTooLong, path, len(path), _maxPath)
+		}
+		for _, elem := range strings.Split(filepath.ToSlash(path), "/") {
+			if len(elem) > _maxName {
+				log.Printf("err: path component exceeds platform limit: %s, %d > %d\n", elem, len(elem), _maxName)
+				return fmt.Errorf("%w: component %s of %s is %d bytes, limit is %d", ErrPathTooLong, elem, path, len(elem), _maxName)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,7 @@
+package fsstore
+
+// _maxPath handles the longest file system path (excluding terminating NUL) accepted by platform, see PATH_MAX
+const _maxPath = 4095
+
+// _maxName handles the longest path component accepted by platform, see NAME_MAX
+const _maxName = 255
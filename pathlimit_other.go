@@ -0,0 +1,9 @@
+//go:build !linux && !darwin && !windows
+
+package fsstore
+
+// _maxPath handles the longest file system path (excluding terminating NUL) assumed for platform, see PATH_MAX
+const _maxPath = 1023
+
+// _maxName handles the longest path component assumed for platform, see NAME_MAX
+const _maxName = 255
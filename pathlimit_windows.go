@@ -0,0 +1,10 @@
+//go:build windows
+
+package fsstore
+
+// _maxPath handles the longest file system path accepted by platform; os package prefixes long absolute paths
+// with `\\?\`, lifting MAX_PATH to the limit of extended-length paths
+const _maxPath = 32767
+
+// _maxName handles the longest path component accepted by platform
+const _maxName = 255
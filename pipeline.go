@@ -0,0 +1,214 @@
+package fsstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/igumus/go-objectstore-lib"
+	"github.com/ipfs/go-cid"
+)
+
+// ErrIngestQueueFull is return, when an object is offered to an ingest pipeline whose queue is full (see
+// `TryEnqueue`).
+var ErrIngestQueueFull = errors.New("fsobjectstore: ingest queue full")
+
+// ErrIngestPipelineClosed is return, when an object is enqueued to a closed ingest pipeline.
+var ErrIngestPipelineClosed = errors.New("fsobjectstore: ingest pipeline closed")
+
+// _defPipelineQueue handles the default count of objects ingest pipeline queues before enqueuing blocks
+const _defPipelineQueue = 256
+
+// _defPipelineWorkers handles the default count of objects ingest pipeline writes at once
+const _defPipelineWorkers = 4
+
+// Captures/Represents ingest pipeline configuration information
+type pipelineConfig struct {
+	queue   int
+	workers int
+}
+
+// A PipelineOption sets options of ingest pipeline, such as its queue size.
+type PipelineOption func(*pipelineConfig)
+
+// WithPipelineQueue returns a PipelineOption that specifies count of objects queued before `Enqueue` blocks
+// (and `TryEnqueue` refuses them). If not set, the default is `256`
+func WithPipelineQueue(n int) PipelineOption {
+	return func(pc *pipelineConfig) {
+		if n > 0 {
+			pc.queue = n
+		}
+	}
+}
+
+// WithPipelineWorkers returns a PipelineOption that specifies count of objects written at once; write
+// throughput of pipeline is bounded by what that many writers sustain. If not set, the default is `4`
+func WithPipelineWorkers(n int) PipelineOption {
+	return func(pc *pipelineConfig) {
+		if n > 0 {
+			pc.workers = n
+		}
+	}
+}
+
+// IngestDone is called once a queued object is written, with its cid, or with error writing it failed with
+type IngestDone func(cid.Cid, error)
+
+// PipelineStats captures state of ingest pipeline queue, and counts of objects it handled so far. `QueueWait`
+// is total time written objects spent queued, so `QueueWait / (Succeeded + Failed)` is their average wait.
+type PipelineStats struct {
+	Capacity  int
+	Workers   int
+	Queued    int
+	Writing   int64
+	Enqueued  int64
+	Rejected  int64
+	Succeeded int64
+	Failed    int64
+	Bytes     int64
+	QueueWait time.Duration
+}
+
+// pipelineItem captures queued object, with context and callback of call enqueuing it
+type pipelineItem struct {
+	ctx    context.Context
+	reader io.Reader
+	done   IngestDone
+	queued time.Time
+}
+
+// IngestPipeline smooths bursts of object writes: services enqueue objects at line rate into a bounded queue,
+// and a pool of workers creates them in store at the pace it sustains. Once queue is full, `Enqueue` blocks (and
+// `TryEnqueue` refuses objects), pushing back on producers rather than buffering without limit.
+type IngestPipeline struct {
+	// counters are accessed atomically, so they lead struct to stay 64-bit aligned
+	writing int64
+	stats   struct {
+		enqueued, rejected, succeeded, failed, bytes, wait int64
+	}
+	store   objectstore.ObjectStore
+	queue   chan pipelineItem
+	workers int
+	mu      sync.RWMutex
+	closed  bool
+	wg      sync.WaitGroup
+}
+
+// NewIngestPipeline - creates ingest pipeline writing objects into store, and starts its workers; pipeline must
+// be closed to stop them
+func NewIngestPipeline(store objectstore.ObjectStore, opts ...PipelineOption) *IngestPipeline {
+	cfg := &pipelineConfig{queue: _defPipelineQueue, workers: _defPipelineWorkers}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	p := &IngestPipeline{store: store, queue: make(chan pipelineItem, cfg.queue), workers: cfg.workers}
+	for i := 0; i < cfg.workers; i++ {
+		p.wg.Add(1)
+		go p.work()
+	}
+	return p
+}
+
+// Enqueue - queues content of reader to be created as object, waiting while queue is full until ctx is done.
+// Object is written with ctx (so it carries principal and tenant of caller), and done is called with outcome
+// once it is; reader must stay readable until then.
+func (p *IngestPipeline) Enqueue(ctx context.Context, reader io.Reader, done IngestDone) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return ErrIngestPipelineClosed
+	}
+	select {
+	case p.queue <- pipelineItem{ctx: ctx, reader: reader, done: done, queued: time.Now()}:
+		atomic.AddInt64(&p.stats.enqueued, 1)
+		return nil
+	case <-ctx.Done():
+		atomic.AddInt64(&p.stats.rejected, 1)
+		return checkContextError(ctx, false)
+	}
+}
+
+// TryEnqueue - queues content of reader as `Enqueue` does, but refuses it with `ErrIngestQueueFull` rather than
+// waiting when queue is full
+func (p *IngestPipeline) TryEnqueue(ctx context.Context, reader io.Reader, done IngestDone) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return ErrIngestPipelineClosed
+	}
+	select {
+	case p.queue <- pipelineItem{ctx: ctx, reader: reader, done: done, queued: time.Now()}:
+		atomic.AddInt64(&p.stats.enqueued, 1)
+		return nil
+	default:
+		atomic.AddInt64(&p.stats.rejected, 1)
+		return ErrIngestQueueFull
+	}
+}
+
+// Stats - returns state of queue and counts of objects handled so far
+func (p *IngestPipeline) Stats() PipelineStats {
+	return PipelineStats{
+		Capacity:  cap(p.queue),
+		Workers:   p.workers,
+		Queued:    len(p.queue),
+		Writing:   atomic.LoadInt64(&p.writing),
+		Enqueued:  atomic.LoadInt64(&p.stats.enqueued),
+		Rejected:  atomic.LoadInt64(&p.stats.rejected),
+		Succeeded: atomic.LoadInt64(&p.stats.succeeded),
+		Failed:    atomic.LoadInt64(&p.stats.failed),
+		Bytes:     atomic.LoadInt64(&p.stats.bytes),
+		QueueWait: time.Duration(atomic.LoadInt64(&p.stats.wait)),
+	}
+}
+
+// Close - stops accepting objects, and waits until queued ones are written and their callbacks called
+func (p *IngestPipeline) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	close(p.queue)
+	p.mu.Unlock()
+	p.wg.Wait()
+	return nil
+}
+
+// work - writes queued objects until queue is closed and drained
+func (p *IngestPipeline) work() {
+	defer p.wg.Done()
+	for item := range p.queue {
+		atomic.AddInt64(&p.stats.wait, int64(time.Since(item.queued)))
+		atomic.AddInt64(&p.writing, 1)
+		reader := &countingReader{Reader: item.reader}
+		c, err := p.store.CreateObject(item.ctx, reader)
+		atomic.AddInt64(&p.writing, -1)
+		if err == nil {
+			atomic.AddInt64(&p.stats.succeeded, 1)
+			atomic.AddInt64(&p.stats.bytes, reader.n)
+		} else {
+			atomic.AddInt64(&p.stats.failed, 1)
+		}
+		if item.done != nil {
+			item.done(c, err)
+		}
+	}
+}
+
+// countingReader counts bytes read through it
+type countingReader struct {
+	io.Reader
+	n int64
+}
+
+// Read - reads from underlying reader, counting bytes read
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.n += int64(n)
+	return n, err
+}
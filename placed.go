@@ -0,0 +1,198 @@
+package fsstore
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-cid"
+)
+
+// _placedFile handles the internal file name of recorded placement times of bucket entries sharing their inode
+const _placedFile = "placed"
+
+// placements records when bucket entries sharing their inode with other files (e.g. adopted files, and objects
+// shared via content pool) were placed into bucket. Modification time of a shared inode tells when its content was
+// first written by any of its names, and must not be changed for one of them, so placement time of such entries is
+// kept apart from it. Records are appended, and compacted by garbage collection.
+type placements struct {
+	mu      sync.Mutex
+	path    string
+	tempDir string
+	file    *os.File
+	entries map[string]time.Time
+}
+
+// placementRecord captures wire format of a placement in placements file
+type placementRecord struct {
+	Key  string    `json:"key"`
+	Time time.Time `json:"time"`
+}
+
+// openPlacements - loads placements recorded at path (see `loadPlacements`), and opens it for appending
+func openPlacements(path, tempDir string, legacy bool) (*placements, error) {
+	p, err := loadPlacements(path, legacy)
+	if err != nil {
+		return nil, err
+	}
+	p.tempDir = tempDir
+	if err := p.reopen(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// loadPlacements - loads placements recorded at path, not opened for appending; records without checksum are
+// accepted only when legacy. Unreadable records are skipped, so their entries fall back to modification time
+func loadPlacements(path string, legacy bool) (*placements, error) {
+	p := &placements{path: path, entries: map[string]time.Time{}}
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		log.Printf("err: reading placements failed: %s, %v\n", path, err)
+		return nil, err
+	}
+	skipped := 0
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		var rec placementRecord
+		line, err := unframeLine(scanner.Bytes(), legacy)
+		if err == nil {
+			err = json.Unmarshal(line, &rec)
+		}
+		if err != nil {
+			skipped++
+			continue
+		}
+		p.entries[rec.Key] = rec.Time
+	}
+	if skipped > 0 {
+		log.Printf("warn: skipped unreadable placements: %s, %d records\n", path, skipped)
+	}
+	return p, nil
+}
+
+// reopen - opens placements file for appending
+func (p *placements) reopen() error {
+	file, err := os.OpenFile(p.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0666)
+	if err != nil {
+		log.Printf("err: opening placements failed: %s, %v\n", p.path, err)
+		return err
+	}
+	p.file = file
+	return nil
+}
+
+// record - durably records entry with key as placed at t
+func (p *placements) record(key string, t time.Time) {
+	if p == nil {
+		return
+	}
+	data, err := json.Marshal(placementRecord{Key: key, Time: t.UTC()})
+	if err != nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.entries[key] = t
+	if _, err := fmt.Fprintf(p.file, "%s\n", frameLine(data)); err != nil {
+		log.Printf("err: appending placement failed: %s, %v\n", p.path, err)
+		return
+	}
+	if err := p.file.Sync(); err != nil {
+		log.Printf("err: syncing placements failed: %s, %v\n", p.path, err)
+	}
+}
+
+// lookup - returns time entry with key is recorded as placed at, and whether one is recorded
+func (p *placements) lookup(key string) (time.Time, bool) {
+	if p == nil {
+		return time.Time{}, false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	t, ok := p.entries[key]
+	return t, ok
+}
+
+// compact - forgets placements of entries live reports gone, rewriting placements file with the rest
+func (p *placements) compact(live func(key string) bool) error {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	buf := bytes.Buffer{}
+	for key, t := range p.entries {
+		if !live(key) {
+			delete(p.entries, key)
+			continue
+		}
+		data, err := json.Marshal(placementRecord{Key: key, Time: t.UTC()})
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&buf, "%s\n", frameLine(data))
+	}
+	p.file.Close()
+	err := write(p.tempDir, p.path, buf.Bytes())
+	if err != nil {
+		log.Printf("err: compacting placements failed: %s, %v\n", p.path, err)
+	}
+	if reopenErr := p.reopen(); err == nil {
+		err = reopenErr
+	}
+	return err
+}
+
+// close - closes placements file
+func (p *placements) close() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.file != nil {
+		p.file.Close()
+	}
+}
+
+// trashKey - returns placement key of trash entry of object with specified cid
+func trashKey(c cid.Cid) string {
+	return _trashDir + "/" + c.String()
+}
+
+// placedAt - returns time bucket entry with key, stated as info, is placed at: its modification time, or when it
+// shares its inode, time recorded as placed at if later
+func (f *fsObjectStoreService) placedAt(key string, info os.FileInfo) time.Time {
+	if t, ok := f.placed.lookup(key); ok && t.After(info.ModTime()) {
+		return t
+	}
+	return info.ModTime()
+}
+
+// compactPlacements - forgets placements of objects and trash entries no longer in bucket
+func (f *fsObjectStoreService) compactPlacements() error {
+	return f.placed.compact(func(key string) bool {
+		if c, err := cid.Decode(key); err == nil {
+			return f.has(c)
+		}
+		if len(key) <= len(_trashDir)+1 {
+			return false
+		}
+		c, err := cid.Decode(key[len(_trashDir)+1:])
+		if err != nil {
+			return false
+		}
+		for _, stripe := range f.stripeDirs() {
+			if exists(f.trashPath(stripe, c)) {
+				return true
+			}
+		}
+		return false
+	})
+}
@@ -0,0 +1,239 @@
+package fsstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/ipfs/go-cid"
+)
+
+// PolicyVersion is the version of bucket policy documents exported by store
+const PolicyVersion = 1
+
+// ErrInvalidPolicy is return, when bucket policy document can not be parsed, is of unsupported version or not valid.
+var ErrInvalidPolicy = errors.New("fsobjectstore: invalid bucket policy")
+
+// _symlinkPolicyNames maps symbolic link policies to their names in bucket policy documents
+var _symlinkPolicyNames = map[SymlinkPolicy]string{
+	SymlinkSkip:             "skip",
+	SymlinkFollowWithinRoot: "followWithinRoot",
+	SymlinkError:            "error",
+}
+
+// BucketPolicy captures policy set of bucket as a versioned document, so it can be exported from one store and
+// applied when opening another (see `Options`). Durations are given as `time.ParseDuration` strings. Secrets,
+// such as encryption keys and webhook secret, are never exported; they are passed via options applied after.
+type BucketPolicy struct {
+	Version             int               `json:"version"`
+	Bucket              string            `json:"bucket,omitempty"`
+	Exported            time.Time         `json:"exported"`
+	MaintenanceSchedule string            `json:"maintenanceSchedule,omitempty"`
+	GCRetention         *PolicyRetention  `json:"gcRetention,omitempty"`
+	TrashRetention      string            `json:"trashRetention,omitempty"`
+	OperationTimeout    string            `json:"operationTimeout,omitempty"`
+	SlowOpThreshold     string            `json:"slowOpThreshold,omitempty"`
+	HedgeDelay          string            `json:"hedgeDelay,omitempty"`
+	ScrubRate           int64             `json:"scrubRate,omitempty"`
+	RebalanceRate       int64             `json:"rebalanceRate,omitempty"`
+	MaxDeltaDepth       int               `json:"maxDeltaDepth,omitempty"`
+	Chunking            *PolicyChunking   `json:"chunking,omitempty"`
+	SymlinkPolicy       string            `json:"symlinkPolicy,omitempty"`
+	Encryption          *PolicyEncryption `json:"encryption,omitempty"`
+	Webhook             *PolicyWebhook    `json:"webhook,omitempty"`
+}
+
+// PolicyRetention captures garbage collection retention of bucket policy, see `RetentionPolicy`
+type PolicyRetention struct {
+	KeepLast   int    `json:"keepLast,omitempty"`
+	KeepWithin string `json:"keepWithin,omitempty"`
+}
+
+// PolicyChunking captures content defined chunk sizes of bucket policy, see `WithChunkSizes`
+type PolicyChunking struct {
+	Min int `json:"min"`
+	Avg int `json:"avg"`
+	Max int `json:"max"`
+}
+
+// PolicyEncryption captures encryption settings of bucket policy. Keys are not part of policy, so store policy is
+// applied to is given key provider (see `WithKeyProvider`) with `ActiveKey`, or keeps objects unencrypted.
+type PolicyEncryption struct {
+	ActiveKey     string `json:"activeKey"`
+	EagerRotation bool   `json:"eagerRotation,omitempty"`
+}
+
+// PolicyWebhook captures create notification hook of bucket policy, see `WithCreateWebhook`
+type PolicyWebhook struct {
+	URL string `json:"url"`
+}
+
+// PolicyExporter defines the functions clients need to export policy set of bucket.
+type PolicyExporter interface {
+	ExportPolicy(context.Context) (*BucketPolicy, error)
+}
+
+var _ PolicyExporter = (*fsObjectStoreService)(nil)
+
+// newBucketPolicy - captures policy set of given configuration
+func newBucketPolicy(cfg *fsObjectStoreConfig) *BucketPolicy {
+	p := &BucketPolicy{
+		Version:             PolicyVersion,
+		MaintenanceSchedule: cfg.scheduleSpec,
+		TrashRetention:      cfg.trashRetention.String(),
+		ScrubRate:           cfg.scrubRate,
+		RebalanceRate:       cfg.rebalanceRate,
+		MaxDeltaDepth:       cfg.maxDeltaDepth,
+		HedgeDelay:          cfg.hedgeDelay.String(),
+		Chunking:            &PolicyChunking{Min: cfg.chunkMin, Avg: cfg.chunkAvg, Max: cfg.chunkMax},
+		SymlinkPolicy:       _symlinkPolicyNames[cfg.symlinks],
+	}
+	if cfg.retention != nil {
+		p.GCRetention = &PolicyRetention{KeepLast: cfg.retention.KeepLast}
+		if cfg.retention.KeepWithin > 0 {
+			p.GCRetention.KeepWithin = cfg.retention.KeepWithin.String()
+		}
+	}
+	if cfg.opTimeout > 0 {
+		p.OperationTimeout = cfg.opTimeout.String()
+	}
+	if cfg.slowOp > 0 {
+		p.SlowOpThreshold = cfg.slowOp.String()
+	}
+	if cfg.keyProvider != nil {
+		p.Encryption = &PolicyEncryption{ActiveKey: cfg.activeKey, EagerRotation: cfg.eagerRotation}
+	}
+	if len(cfg.webhookURL) > 0 {
+		p.Webhook = &PolicyWebhook{URL: cfg.webhookURL}
+	}
+	return p
+}
+
+// ExportPolicy - returns policy set of bucket, with key currently active (see `RotateKey`) as encryption key
+func (f *fsObjectStoreService) ExportPolicy(ctx context.Context) (*BucketPolicy, error) {
+	if err := f.authorize(ctx, OpAdmin, cid.Undef); err != nil {
+		return nil, err
+	}
+	f.liveMu.Lock()
+	p := *f.policy
+	f.liveMu.Unlock()
+	p.Bucket = f.bucket
+	p.Exported = f.now().UTC()
+	if p.Encryption != nil && f.keys != nil {
+		encryption := *p.Encryption
+		encryption.ActiveKey, _, _ = f.keys.current()
+		p.Encryption = &encryption
+	}
+	if f.isDebug() {
+		log.Printf("debug: exported bucket policy: %s\n", f.bucket)
+	}
+	f.audit(ctx, OpAdmin, cid.Undef, nil)
+	return &p, nil
+}
+
+// ReadPolicyFile - reads bucket policy document at path, see `ReadPolicy`
+func ReadPolicyFile(path string) (*BucketPolicy, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		log.Printf("err: opening bucket policy failed: %s, %v\n", path, err)
+		return nil, ErrInvalidPolicy
+	}
+	defer file.Close()
+	return ReadPolicy(file)
+}
+
+// ReadPolicy - decodes json bucket policy document read from r, rejecting documents of newer versions
+func ReadPolicy(r io.Reader) (*BucketPolicy, error) {
+	p := &BucketPolicy{}
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(p); err != nil {
+		log.Printf("err: decoding bucket policy failed: %v\n", err)
+		return nil, ErrInvalidPolicy
+	}
+	if p.Version < 1 || p.Version > PolicyVersion {
+		log.Printf("err: unsupported bucket policy version: %d\n", p.Version)
+		return nil, ErrInvalidPolicy
+	}
+	return p, nil
+}
+
+// Options - returns configuration options applying policy when opening a store, e.g. one of another bucket.
+// Options configuring bucket and data directories (and secrets, see `BucketPolicy`) are appended by caller.
+func (p *BucketPolicy) Options() ([]FSObjectstoreConfigOption, error) {
+	if p.Version < 1 || p.Version > PolicyVersion {
+		return nil, ErrInvalidPolicy
+	}
+	opts := []FSObjectstoreConfigOption{}
+	if len(p.MaintenanceSchedule) > 0 {
+		opts = append(opts, WithMaintenanceSchedule(p.MaintenanceSchedule))
+	}
+	if p.ScrubRate > 0 {
+		opts = append(opts, WithScrubRate(p.ScrubRate))
+	}
+	if p.RebalanceRate > 0 {
+		opts = append(opts, WithRebalanceRate(p.RebalanceRate))
+	}
+	if p.MaxDeltaDepth > 0 {
+		opts = append(opts, WithMaxDeltaDepth(p.MaxDeltaDepth))
+	}
+	if p.Chunking != nil {
+		opts = append(opts, WithChunkSizes(p.Chunking.Min, p.Chunking.Avg, p.Chunking.Max))
+	}
+	if len(p.SymlinkPolicy) > 0 {
+		found := false
+		for policy, name := range _symlinkPolicyNames {
+			if name == p.SymlinkPolicy {
+				opts = append(opts, WithSymlinkPolicy(policy))
+				found = true
+			}
+		}
+		if !found {
+			log.Printf("err: unknown symbolic link policy: %s\n", p.SymlinkPolicy)
+			return nil, ErrInvalidPolicy
+		}
+	}
+	durations := []struct {
+		value string
+		opt   func(time.Duration) FSObjectstoreConfigOption
+	}{
+		{p.TrashRetention, WithTrashRetention},
+		{p.OperationTimeout, WithOperationTimeout},
+		{p.SlowOpThreshold, WithSlowOpThreshold},
+		{p.HedgeDelay, WithHedgeDelay},
+	}
+	for _, d := range durations {
+		if len(d.value) == 0 {
+			continue
+		}
+		parsed, err := time.ParseDuration(d.value)
+		if err != nil {
+			log.Printf("err: parsing bucket policy duration failed: %s, %v\n", d.value, err)
+			return nil, ErrInvalidPolicy
+		}
+		opts = append(opts, d.opt(parsed))
+	}
+	if p.GCRetention != nil {
+		policy := RetentionPolicy{KeepLast: p.GCRetention.KeepLast}
+		if len(p.GCRetention.KeepWithin) > 0 {
+			within, err := time.ParseDuration(p.GCRetention.KeepWithin)
+			if err != nil {
+				log.Printf("err: parsing bucket policy duration failed: %s, %v\n", p.GCRetention.KeepWithin, err)
+				return nil, ErrInvalidPolicy
+			}
+			policy.KeepWithin = within
+		}
+		opts = append(opts, WithGCRetention(policy))
+	}
+	if p.Encryption != nil {
+		opts = append(opts, WithEagerKeyRotation(p.Encryption.EagerRotation))
+	}
+	if p.Webhook != nil && len(p.Webhook.URL) > 0 {
+		opts = append(opts, WithCreateWebhook(p.Webhook.URL, ""))
+	}
+	return opts, nil
+}
@@ -0,0 +1,174 @@
+package fsstore
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/ipfs/go-cid"
+)
+
+// _poolDir handles the directory name of content pool shared by buckets of a data directory; bucket names
+// never start with a dot, so pool never collides with a bucket
+const _poolDir = ".pool"
+
+// pooled - checks whether objects of store are shared via content pool (see `WithSharedPool`). Encrypted
+// content differs per key, so stores encrypting objects keep private copies
+func (f *fsObjectStoreService) pooled() bool {
+	return f.pool && f.keys == nil
+}
+
+// poolPath - returns file system path of pooled object with specified cid, in pool of data directory of stripe
+func (f *fsObjectStoreService) poolPath(stripe string, c cid.Cid) string {
+	objLink := objectLink(c)
+	if len(objLink) == 0 {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(stripe), _poolDir, objLink)
+}
+
+// poolDirs - returns pool directories of every data directory of store
+func (f *fsObjectStoreService) poolDirs() []string {
+	dirs := []string{}
+	for _, stripe := range f.stripeDirs() {
+		dirs = append(dirs, filepath.Join(filepath.Dir(stripe), _poolDir))
+	}
+	return dirs
+}
+
+// adopt - makes object with specified cid member of bucket at objLink, by linking pooled copy another bucket
+// already created; reports whether object is adopted, so caller writes object otherwise. Linked entry keeps time pool
+// copy was first written at, so caller journaling it records its placement time (see `touch`) instead of stamping
+// inode shared with other buckets
+func (f *fsObjectStoreService) adopt(c cid.Cid, objLink string) bool {
+	if !f.pooled() {
+		return false
+	}
+	pool := f.poolPath(f.stripeOf(objLink), c)
+	if len(pool) == 0 || !exists(pool) {
+		return false
+	}
+	if err := placeFile(objLink, func() error { return os.Link(pool, objLink) }); err != nil {
+		if f.isDebug() {
+			log.Printf("debug: adopting pooled object failed: %s, %v\n", c, err)
+		}
+		return false
+	}
+	if f.isDebug() {
+		log.Printf("debug: adopted pooled object: %s, %s\n", c, objLink)
+	}
+	return true
+}
+
+// share - shares object with specified cid just written at objLink via content pool: object is pooled when pool
+// has no copy yet, otherwise its private copy is replaced by pooled one. Sharing is best effort, object stays
+// stored privately when it fails
+func (f *fsObjectStoreService) share(c cid.Cid, objLink string) {
+	if !f.pooled() {
+		return
+	}
+	pool := f.poolPath(f.stripeOf(objLink), c)
+	if len(pool) == 0 {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(pool), 0777); err != nil {
+		log.Printf("warn: creating pool directory failed: %s, %v\n", filepath.Dir(pool), err)
+		return
+	}
+	err := os.Link(objLink, pool)
+	if err == nil || !os.IsExist(err) {
+		if err != nil && f.isDebug() {
+			log.Printf("debug: pooling object failed: %s, %v\n", c, err)
+		}
+		return
+	}
+	// pool already holds object, private copy is swapped for a link to it
+	staged, err := stage(f.tempFor(objLink))
+	if err != nil {
+		return
+	}
+	discard(staged)
+	if err := os.Link(pool, staged.Name()); err != nil {
+		if f.isDebug() {
+			log.Printf("debug: linking pooled object failed: %s, %v\n", c, err)
+		}
+		return
+	}
+	if err := os.Rename(staged.Name(), objLink); err != nil {
+		os.Remove(staged.Name())
+		if f.isDebug() {
+			log.Printf("debug: replacing object with pooled one failed: %s, %v\n", c, err)
+		}
+	}
+}
+
+// writeObject - writes stored content of object with specified cid at objLink, adopting pooled copy instead
+// when content pool already holds object
+func (f *fsObjectStoreService) writeObject(c cid.Cid, objLink string, stored []byte) error {
+	if f.adopt(c, objLink) {
+		return nil
+	}
+	if err := write(f.tempFor(objLink), objLink, stored); err != nil {
+		return err
+	}
+	f.share(c, objLink)
+	return nil
+}
+
+// sweepPool - removes pooled objects no bucket (or its trash) links anymore, returning count and bytes of them;
+// on dry run they are only counted. Objects are reclaimed by link count, so references of every bucket sharing
+// pool are honored, whichever store created them
+func (f *fsObjectStoreService) sweepPool(ctx context.Context, dryRun bool) (int, int64, error) {
+	if !f.pool {
+		return 0, 0, nil
+	}
+	reclaimed, bytes := 0, int64(0)
+	for _, dir := range f.poolDirs() {
+		if !exists(dir) {
+			continue
+		}
+		err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if ctxErr := checkContextError(ctx, f.isDebug()); ctxErr != nil {
+				return ctxErr
+			}
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			if !info.Mode().IsRegular() {
+				return nil
+			}
+			links, err := linkCount(path, info)
+			if err != nil {
+				// pooled objects of unknown link count may still be linked, so they are kept
+				log.Printf("warn: reading link count of pooled object failed: %s, %v\n", path, err)
+				return nil
+			}
+			if links > 1 {
+				return nil
+			}
+			if !dryRun {
+				if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+					return err
+				}
+			}
+			reclaimed++
+			bytes += info.Size()
+			return nil
+		})
+		if err != nil {
+			if ctxErr := checkContextError(ctx, f.isDebug()); ctxErr != nil {
+				return reclaimed, bytes, ctxErr
+			}
+			log.Printf("err: sweeping content pool failed: %s, %v\n", dir, err)
+			return reclaimed, bytes, ErrGarbageCollectionFailed
+		}
+	}
+	if f.isDebug() {
+		log.Printf("debug: swept content pool: %s, %d objects, %d bytes\n", f.bucket, reclaimed, bytes)
+	}
+	return reclaimed, bytes, nil
+}
@@ -0,0 +1,128 @@
+package fsstore
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"sort"
+	"sync"
+
+	"github.com/ipfs/go-cid"
+)
+
+// ErrPopularityDisabled is return, when popularity is requested but tracking is not enabled.
+var ErrPopularityDisabled = errors.New("fsobjectstore: popularity tracking disabled")
+
+// _sketchWidth handles the number of counters per row of read count sketch
+const _sketchWidth = 2048
+
+// _sketchDepth handles the number of rows (independent hashes) of read count sketch
+const _sketchDepth = 4
+
+// _defTopCapacity handles the default number of hottest objects tracked individually
+const _defTopCapacity = 1024
+
+// ObjectPopularity captures approximate read count of an object. Count may overestimate, never underestimate.
+type ObjectPopularity struct {
+	Cid   cid.Cid
+	Reads uint64
+}
+
+// PopularityReporter defines the functions clients need to find objects dominating read traffic.
+type PopularityReporter interface {
+	TopObjects(ctx context.Context, n int) ([]ObjectPopularity, error)
+}
+
+var _ PopularityReporter = (*fsObjectStoreService)(nil)
+
+// popularity counts object reads approximately via count-min sketch, bounding memory regardless of number
+// of objects, and keeps hottest objects as candidates of top list
+type popularity struct {
+	mu       sync.Mutex
+	counts   [_sketchDepth][_sketchWidth]uint64
+	top      map[cid.Cid]uint64
+	capacity int
+	floor    uint64
+}
+
+// newPopularity - creates empty read counter tracking at most capacity hottest objects
+func newPopularity(capacity int) *popularity {
+	return &popularity{top: make(map[cid.Cid]uint64, capacity), capacity: capacity}
+}
+
+// record - counts a read of object with specified cid
+func (p *popularity) record(c cid.Cid) {
+	if p == nil {
+		return
+	}
+	h := fnv.New64a()
+	h.Write(c.Bytes())
+	sum := h.Sum64()
+	h1, h2 := sum&0xffffffff, sum>>32|1
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	estimate := ^uint64(0)
+	for row := 0; row < _sketchDepth; row++ {
+		col := (h1 + uint64(row)*h2) % _sketchWidth
+		p.counts[row][col]++
+		if p.counts[row][col] < estimate {
+			estimate = p.counts[row][col]
+		}
+	}
+	if _, ok := p.top[c]; ok || len(p.top) < p.capacity {
+		p.top[c] = estimate
+		return
+	}
+	// floor is a lower bound of coldest candidate, candidates only get hotter
+	if estimate <= p.floor {
+		return
+	}
+	coldest, coldestReads := cid.Undef, ^uint64(0)
+	for candidate, reads := range p.top {
+		if reads < coldestReads {
+			coldest, coldestReads = candidate, reads
+		}
+	}
+	p.floor = coldestReads
+	if estimate <= coldestReads {
+		return
+	}
+	delete(p.top, coldest)
+	p.top[c] = estimate
+}
+
+// hottest - returns at most n candidates with highest read counts, hottest first
+func (p *popularity) hottest(n int) []ObjectPopularity {
+	p.mu.Lock()
+	ret := make([]ObjectPopularity, 0, len(p.top))
+	for c, reads := range p.top {
+		ret = append(ret, ObjectPopularity{Cid: c, Reads: reads})
+	}
+	p.mu.Unlock()
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].Reads != ret[j].Reads {
+			return ret[i].Reads > ret[j].Reads
+		}
+		return ret[i].Cid.String() < ret[j].Cid.String()
+	})
+	if n >= 0 && n < len(ret) {
+		ret = ret[:n]
+	}
+	return ret
+}
+
+// TopObjects - returns at most n most read objects since store opened, hottest first. Counts are approximate;
+// see `WithPopularityTracking`.
+func (f *fsObjectStoreService) TopObjects(ctx context.Context, n int) ([]ObjectPopularity, error) {
+	if err := f.authorize(ctx, OpAdmin, cid.Undef); err != nil {
+		return nil, err
+	}
+	if f.popularity == nil {
+		return nil, ErrPopularityDisabled
+	}
+	if ctxErr := checkContextError(ctx, f.isDebug()); ctxErr != nil {
+		return nil, ctxErr
+	}
+	return f.popularity.hottest(n), nil
+}
@@ -0,0 +1,148 @@
+package fsstore
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-cid"
+)
+
+// ProgressUpdate captures progress of a long running operation, such as `gc`, `purge`, `verify`,
+// `rebalance`, `ingest`, or `import` and `mirror` of s3sync
+type ProgressUpdate struct {
+	Operation string
+	Done      int64
+	Total     int64
+	Bytes     int64
+	Elapsed   time.Duration
+	Finished  bool
+}
+
+// ETA - returns estimated time until operation finishes, extrapolated from elapsed time; zero when total
+// is not known (yet)
+func (p ProgressUpdate) ETA() time.Duration {
+	if p.Total <= 0 || p.Done <= 0 || p.Done >= p.Total {
+		return 0
+	}
+	return time.Duration(float64(p.Elapsed) * float64(p.Total-p.Done) / float64(p.Done))
+}
+
+// Progress defines the functions clients need to observe long running operations, e.g. to render progress
+// bars. Updates are delivered synchronously from operation, so implementations must return quickly.
+type Progress interface {
+	Report(ProgressUpdate)
+}
+
+// ProgressFunc adapts an ordinary function to Progress
+type ProgressFunc func(ProgressUpdate)
+
+// Report - calls fn with update
+func (fn ProgressFunc) Report(p ProgressUpdate) {
+	fn(p)
+}
+
+// ProgressChan returns Progress sending updates on ch; updates are dropped while ch is full, except the last one
+// of an operation, so a slow consumer never stalls operation but still observes it finishing.
+func ProgressChan(ch chan<- ProgressUpdate) Progress {
+	return ProgressFunc(func(p ProgressUpdate) {
+		if p.Finished {
+			ch <- p
+			return
+		}
+		select {
+		case ch <- p:
+		default:
+		}
+	})
+}
+
+// progressKey is context key of progress observer
+type progressKey struct{}
+
+// ContextWithProgress returns copy of ctx carrying progress observer, which long running operations called
+// with ctx report to
+func ContextWithProgress(ctx context.Context, p Progress) context.Context {
+	return context.WithValue(ctx, progressKey{}, p)
+}
+
+// ProgressFromContext returns progress observer attached to ctx, nil when none attached
+func ProgressFromContext(ctx context.Context) Progress {
+	p, _ := ctx.Value(progressKey{}).(Progress)
+	return p
+}
+
+// WithProgress returns a CallOption that specifies progress observer of a long running call.
+func WithProgress(p Progress) CallOption {
+	return func(cc *callConfig) {
+		cc.progress = p
+	}
+}
+
+// ProgressTracker accumulates progress of an operation and reports it to progress observer; nil tracker
+// reports nothing, so operations track progress unconditionally
+type ProgressTracker struct {
+	progress Progress
+	update   ProgressUpdate
+	start    time.Time
+	mu       sync.Mutex
+}
+
+// NewProgressTracker returns tracker of operation reporting to progress observer of ctx, nil when ctx carries none
+func NewProgressTracker(ctx context.Context, op string) *ProgressTracker {
+	p := ProgressFromContext(ctx)
+	if p == nil {
+		return nil
+	}
+	return &ProgressTracker{progress: p, update: ProgressUpdate{Operation: op}, start: time.Now()}
+}
+
+// Expect - adds to total count of items operation processes
+func (t *ProgressTracker) Expect(total int64) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.update.Total += total
+	t.mu.Unlock()
+}
+
+// Add - accounts processed items and bytes, and reports progress
+func (t *ProgressTracker) Add(done, bytes int64) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.update.Done += done
+	t.update.Bytes += bytes
+	t.update.Elapsed = time.Since(t.start)
+	t.progress.Report(t.update)
+}
+
+// Finish - reports operation finished
+func (t *ProgressTracker) Finish() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.update.Elapsed = time.Since(t.start)
+	t.update.Finished = true
+	t.progress.Report(t.update)
+}
+
+// countObjects - adds number of object files of bucket to total of tracker, so walking operations report an
+// ETA; nothing is counted when tracker is nil
+func (f *fsObjectStoreService) countObjects(ctx context.Context, t *ProgressTracker) {
+	if t == nil {
+		return
+	}
+	var count int64
+	f.walkObjects(ctx, func(c cid.Cid, path string, info os.FileInfo) error {
+		count++
+		return nil
+	})
+	t.Expect(count)
+}
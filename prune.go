@@ -0,0 +1,123 @@
+package fsstore
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/ipfs/go-cid"
+)
+
+// _placeAttempts handles the upper bound of attempts placing a file into a directory pruned concurrently
+const _placeAttempts = 64
+
+// DirPruner defines the functions clients need to remove shard directories emptied by deletes and garbage collection.
+type DirPruner interface {
+	PruneEmptyDirs(ctx context.Context) (int, error)
+}
+
+var _ DirPruner = (*fsObjectStoreService)(nil)
+
+// PruneEmptyDirs - removes empty shard directories of bucket, which deletes and garbage collection leave behind and
+// which slow walks down, returning count of directories removed. Garbage collection prunes them automatically.
+// Directories are removed only while empty, so objects written concurrently are never lost: writers recreate
+// directory pruned between creating it and placing object.
+func (f *fsObjectStoreService) PruneEmptyDirs(ctx context.Context) (int, error) {
+	if err := f.authorize(ctx, OpAdmin, cid.Undef); err != nil {
+		return 0, err
+	}
+	pruned, err := f.pruneEmptyDirs(withSystem(ctx))
+	f.audit(ctx, OpAdmin, cid.Undef, err)
+	return pruned, err
+}
+
+// pruneEmptyDirs - removes empty shard directories of every stripe, keeping stripe directories themselves
+func (f *fsObjectStoreService) pruneEmptyDirs(ctx context.Context) (int, error) {
+	if !f.isProvisioned() {
+		return 0, nil
+	}
+	defer f.io.lower(f.isDebug())()
+	pruned := 0
+	for _, dir := range f.stripeDirs() {
+		entries, err := os.ReadDir(dir)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			log.Printf("err: reading stripe failed: %s, %v\n", dir, err)
+			return pruned, err
+		}
+		for _, entry := range entries {
+			path := filepath.Join(dir, entry.Name())
+			if !entry.IsDir() || f.isInternal(path) {
+				continue
+			}
+			if _, err := f.pruneDir(ctx, path, &pruned); err != nil {
+				return pruned, err
+			}
+		}
+	}
+	if f.isDebug() {
+		log.Printf("debug: pruned empty directories: %s, %d\n", f.bucket, pruned)
+	}
+	return pruned, nil
+}
+
+// pruneDir - removes empty directories below dir depth first, then dir itself once it is empty; reports whether
+// dir is removed. Symbolic links are not followed.
+func (f *fsObjectStoreService) pruneDir(ctx context.Context, dir string, pruned *int) (bool, error) {
+	if ctxErr := checkContextError(ctx, f.isDebug()); ctxErr != nil {
+		return false, ctxErr
+	}
+	if err := f.io.wait(ctx, 1, 0); err != nil {
+		return false, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if f.isDebug() {
+			log.Printf("debug: reading directory to prune failed: %s, %v\n", dir, err)
+		}
+		return false, nil
+	}
+	remaining := len(entries)
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		removed, err := f.pruneDir(ctx, filepath.Join(dir, entry.Name()), pruned)
+		if err != nil {
+			return false, err
+		}
+		if removed {
+			remaining--
+		}
+	}
+	if remaining > 0 {
+		return false, nil
+	}
+	// removal fails when an object was placed meanwhile, which keeps directory
+	if err := os.Remove(dir); err != nil {
+		return false, nil
+	}
+	*pruned++
+	return true, nil
+}
+
+// placeFile - creates parent directory of path and places file at path via place (e.g. a rename or link),
+// creating directory again as long as it was pruned (see `PruneEmptyDirs`) before file was placed; placement
+// failing while directory exists (e.g. source is missing) is not retried
+func placeFile(path string, place func() error) error {
+	dir := filepath.Dir(path)
+	var err error
+	for attempt := 0; attempt < _placeAttempts; attempt++ {
+		if err = os.MkdirAll(dir, 0777); err != nil {
+			return err
+		}
+		if err = place(); !errors.Is(err, os.ErrNotExist) || exists(dir) {
+			return err
+		}
+	}
+	return err
+}
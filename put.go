@@ -0,0 +1,123 @@
+package fsstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	mh "github.com/multiformats/go-multihash"
+)
+
+// ErrObjectCIDMismatch is return, when computed cid of object content not matches with expected one.
+var ErrObjectCIDMismatch = errors.New("fsobjectstore: object cid mismatch")
+
+// Putter defines the functions clients need to store content whose cid (aka content identifier) is known up front.
+type Putter interface {
+	PutObject(context.Context, cid.Cid, io.Reader) error
+}
+
+var _ Putter = (*fsObjectStoreService)(nil)
+
+// PutObject - streams object content to file system, and stores it only when computed cid matches with expected cid
+func (f *fsObjectStoreService) PutObject(ctx context.Context, expected cid.Cid, reader io.Reader) error {
+	if err := f.authorize(ctx, OpWrite, expected); err != nil {
+		return err
+	}
+	defer f.writeDone(ctx)
+	err := f.putObject(ctx, expected, reader)
+	f.audit(ctx, OpWrite, expected, err)
+	return err
+}
+
+// putObject - streams reader into object with expected cid
+func (f *fsObjectStoreService) putObject(ctx context.Context, expected cid.Cid, reader io.Reader) error {
+	if !expected.Defined() {
+		return ErrObjectCIDMismatch
+	}
+	if f.has(expected) {
+		f.stats.deduplicated()
+		return nil
+	}
+	if ctxErr := checkContextError(ctx, f.isDebug()); ctxErr != nil {
+		return ctxErr
+	}
+
+	objLink := f.objectPath(expected)
+	if err := f.fds.acquire(ctx); err != nil {
+		return err
+	}
+	defer f.fds.release()
+	file, err := stage(f.tempFor(objLink))
+	if err != nil {
+		return err
+	}
+	prefix := expected.Prefix()
+	counter := &countingWriter{w: file}
+	defer func(start time.Time) { f.observe(ctx, "put", start, expected, counter.n) }(time.Now())
+	hash, err := mh.SumStream(io.TeeReader(reader, counter), prefix.MhType, prefix.MhLength)
+	if err != nil {
+		discard(file)
+		log.Printf("err: digesting object failed: %s, %v\n", expected, err)
+		return ErrDataDigestionFailed
+	}
+
+	var digest cid.Cid
+	if prefix.Version == 0 {
+		digest = cid.NewCidV0(hash)
+	} else {
+		digest = cid.NewCidV1(prefix.Codec, hash)
+	}
+	if !digest.Equals(expected) {
+		discard(file)
+		if f.isDebug() {
+			log.Printf("debug: put object cid mismatch: %s, %s\n", expected, digest)
+		}
+		return ErrObjectCIDMismatch
+	}
+	if ctxErr := checkContextError(ctx, f.isDebug()); ctxErr != nil {
+		discard(file)
+		return ctxErr
+	}
+
+	err = f.scan(ctx, expected, counter.n, func() (io.ReadCloser, error) {
+		return os.Open(file.Name())
+	})
+	if err != nil {
+		discard(file)
+		return err
+	}
+	if err := f.sealFile(file.Name()); err != nil {
+		discard(file)
+		return err
+	}
+	if err := commit(file, objLink); err != nil {
+		return err
+	}
+	f.share(expected, objLink)
+	f.negative.remove(expected.String())
+	if err := f.journaled(JournalCreate, expected, counter.n); err != nil {
+		return err
+	}
+	f.notifyCreated(ctx, expected, counter.n)
+	if f.isDebug() {
+		log.Printf("debug: put object cid: %s\n", expected)
+	}
+	return nil
+}
+
+// countingWriter counts bytes written through to underlying writer
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+// Write - writes p to underlying writer, counting written bytes
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
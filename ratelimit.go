@@ -0,0 +1,61 @@
+package fsstore
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// rateLimiter paces consumption of a budget (e.g. bytes) to rate units per second
+type rateLimiter struct {
+	mu   sync.Mutex
+	rate int64
+	next time.Time
+}
+
+// newRateLimiter - creates rate limiter pacing to rate units per second, nil when rate is unlimited
+func newRateLimiter(rate int64) *rateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	return &rateLimiter{rate: rate}
+}
+
+// setRate - changes rate of limiter, rate less than 1 being unlimited; units already paced keep their schedule
+func (r *rateLimiter) setRate(rate int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rate = rate
+}
+
+// wait - blocks until n units can be consumed within rate, or context is done
+func (r *rateLimiter) wait(ctx context.Context, n int64) error {
+	if r == nil || n <= 0 {
+		return nil
+	}
+	r.mu.Lock()
+	if r.rate <= 0 {
+		r.mu.Unlock()
+		return nil
+	}
+	now := time.Now()
+	if r.next.Before(now) {
+		r.next = now
+	}
+	at := r.next
+	r.next = r.next.Add(time.Duration(n) * time.Second / time.Duration(r.rate))
+	r.mu.Unlock()
+
+	delay := time.Until(at)
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,79 @@
+package fsstore
+
+import (
+	"errors"
+	"log"
+	"sync/atomic"
+)
+
+// ErrStoreLocked is return, when opening a store whose bucket another store (of this or another process) holds open.
+var ErrStoreLocked = errors.New("fsobjectstore: bucket opened by another store")
+
+// ErrStoreReadOnly is return, when a client writes to a store opened read only.
+var ErrStoreReadOnly = errors.New("fsobjectstore: store is read only")
+
+// _lockFile handles the internal file name locked by store holding bucket open, for its lifetime
+const _lockFile = "lock"
+
+// lockBucket - locks bucket for store exclusively until it is closed, so stores of other processes (e.g. a
+// troubleshooting tool against a live store) neither take open mark (see `markOpen`) of it nor overwrite its
+// checkpoints; they open bucket read only instead (see `WithReadOnly`)
+func (f *fsObjectStoreService) lockBucket() error {
+	path := f.internalPath(_lockFile)
+	lock, err := lockFile(path)
+	if errors.Is(err, ErrStoreLocked) {
+		log.Printf("err: bucket opened by another store: %s\n", f.bucketDir())
+		return err
+	}
+	if err != nil {
+		log.Printf("err: locking bucket failed: %s, %v\n", path, err)
+		return err
+	}
+	f.lock = lock
+	return nil
+}
+
+// unlockBucket - releases lock of bucket taken via `lockBucket`
+func (f *fsObjectStoreService) unlockBucket() {
+	if f.lock == nil {
+		return
+	}
+	if err := f.lock.Close(); err != nil {
+		log.Printf("err: unlocking bucket failed: %s, %v\n", f.bucket, err)
+	}
+	f.lock = nil
+}
+
+// openReadOnly - loads what reading bucket needs (format, placement ring, historical layouts, blocklist,
+// statistics and placements) without modifying anything: bucket is neither locked nor marked open, so store is
+// not reconciled either. Bucket not provisioned yet reads as empty
+func (f *fsObjectStoreService) openReadOnly(cfg *fsObjectStoreConfig) error {
+	if !f.provisionedOnDisk() {
+		// placement ring is built in memory as for lazily provisioned stores, but store is never provisioned
+		f.deferProvision(cfg)
+		f.pending = nil
+		return nil
+	}
+	if err := f.loadFormat(false); err != nil {
+		return err
+	}
+	if len(f.stripes) > 0 || exists(f.internalPath(_ringFile)) {
+		if err := f.loadRing(); err != nil {
+			return err
+		}
+	}
+	if err := f.recordLayouts(cfg.layouts); err != nil {
+		return err
+	}
+	if err := f.loadBlocklist(); err != nil {
+		return err
+	}
+	f.loadStats()
+	placed, err := loadPlacements(f.internalPath(_placedFile), f.legacyFrames)
+	if err != nil {
+		return err
+	}
+	f.placed = placed
+	atomic.StoreInt32(&f.provisioned, 1)
+	return nil
+}
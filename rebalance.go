@@ -0,0 +1,178 @@
+package fsstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+
+	"github.com/ipfs/go-cid"
+)
+
+// ErrRebalanceFailed is return, when migrating objects between data directories failed.
+var ErrRebalanceFailed = errors.New("fsobjectstore: rebalance failed")
+
+// RebalanceReport captures outcome of a rebalance run
+type RebalanceReport struct {
+	Checked    int
+	Moved      int
+	MovedBytes int64
+	Duplicates int
+	Sample     []cid.Cid
+	DryRun     bool
+}
+
+// Rebalancer defines the functions clients need to migrate objects after data directories are added or retired.
+type Rebalancer interface {
+	Rebalance(context.Context) (*RebalanceReport, error)
+}
+
+var _ Rebalancer = (*fsObjectStoreService)(nil)
+
+// Rebalance - moves every object not residing on data directory it is placed on (see `WithDataDirs`), draining
+// retired data directories (see `WithRetiredDataDirs`). Moves are throttled via `WithRebalanceRate`. Objects
+// are committed to their new place before removed from old one, and reads racing with a move retry on new
+// place, so reads never fail mid-rebalance. Run is idempotent; an interrupted run is resumed by running it
+// again, objects already in place are skipped without copying.
+func (f *fsObjectStoreService) Rebalance(ctx context.Context) (*RebalanceReport, error) {
+	if err := f.authorize(ctx, OpAdmin, cid.Undef); err != nil {
+		return nil, err
+	}
+	report, err := f.rebalance(withSystem(ctx))
+	f.audit(ctx, OpAdmin, cid.Undef, err)
+	return report, err
+}
+
+// rebalanceState captures checkpoint of rebalance job, resumed when store is reopened
+type rebalanceState struct {
+	Cursor walkCursor      `json:"cursor"`
+	Report RebalanceReport `json:"report"`
+}
+
+// rebalance - walks objects of every stripe, moving misplaced ones to their placement stripe; on dry run (see
+// `WithDryRun`) objects are only reported
+func (f *fsObjectStoreService) rebalance(ctx context.Context) (*RebalanceReport, error) {
+	return f.rebalanceFrom(ctx, rebalanceState{}, nil)
+}
+
+// resumeRebalance - runs rebalance as built-in job, resuming from checkpointed state unless nil
+func (f *fsObjectStoreService) resumeRebalance(ctx context.Context, state json.RawMessage, checkpoint func(interface{})) (interface{}, error) {
+	from := rebalanceState{}
+	if state != nil {
+		if err := json.Unmarshal(state, &from); err != nil {
+			return nil, err
+		}
+	}
+	return f.rebalanceFrom(withSystem(ctx), from, checkpoint)
+}
+
+// rebalanceFrom - rebalances objects walked after cursor of state, accounting them on its report; state after every
+// handled object is passed to checkpoint unless nil
+func (f *fsObjectStoreService) rebalanceFrom(ctx context.Context, from rebalanceState, checkpoint func(interface{})) (*RebalanceReport, error) {
+	report := &from.Report
+	report.DryRun = isDryRun(ctx)
+	if !report.DryRun {
+		atomic.AddInt32(&f.rebalancing, 1)
+		defer atomic.AddInt32(&f.rebalancing, -1)
+	}
+	t := NewProgressTracker(ctx, "rebalance")
+	defer t.Finish()
+	f.countObjects(ctx, t)
+	err := f.walkObjectsFrom(ctx, from.Cursor, func(c cid.Cid, path string, info os.FileInfo, at walkCursor) (err error) {
+		if checkpoint != nil {
+			// resumed run continues after objects handled, and keeps accounting them
+			defer func() {
+				if err == nil {
+					checkpoint(rebalanceState{Cursor: at, Report: *report})
+				}
+			}()
+		}
+		report.Checked++
+		t.Add(1, info.Size())
+		target := filepath.Join(f.stripeDirs()[f.placement(c)], objectLink(c))
+		if filepath.Clean(path) == target {
+			return nil
+		}
+		if exists(target) {
+			// object was created again on its place meanwhile, misplaced copy is redundant
+			report.Duplicates++
+			if report.DryRun {
+				return nil
+			}
+			return os.Remove(path)
+		}
+		report.Sample = sample(report.Sample, c)
+		if report.DryRun {
+			report.Moved++
+			report.MovedBytes += info.Size()
+			return nil
+		}
+		if err := f.rebalanceLimit.wait(ctx, info.Size()); err != nil {
+			return err
+		}
+		if err := f.io.wait(ctx, 0, info.Size()); err != nil {
+			return err
+		}
+		if err := f.move(path, target); err != nil {
+			return err
+		}
+		report.Moved++
+		report.MovedBytes += info.Size()
+		if f.isDebug() {
+			log.Printf("debug: rebalanced object: %s, %s\n", path, target)
+		}
+		return nil
+	})
+	if err != nil {
+		if ctxErr := checkContextError(ctx, f.isDebug()); ctxErr != nil {
+			return report, ctxErr
+		}
+		log.Printf("err: rebalancing bucket failed: %s, %v\n", f.bucket, err)
+		return report, ErrRebalanceFailed
+	}
+	return report, nil
+}
+
+// move - moves object file at path to target; across devices object is copied (along with its metadata
+// extended attribute) and committed at target, before removed from path
+func (f *fsObjectStoreService) move(path, target string) error {
+	if same, err := sameDevice(f.stripeOf(path), f.stripeOf(target)); err == nil && same {
+		return placeFile(target, func() error { return os.Rename(path, target) })
+	}
+	staged, err := stage(f.tempFor(target))
+	if err != nil {
+		return err
+	}
+	if err := copyInto(staged, path); err != nil {
+		discard(staged)
+		return err
+	}
+	if f.useXattrs() {
+		if value, err := getXattr(path, _metaXattr); err == nil {
+			setXattr(staged.Name(), _metaXattr, value)
+		}
+	}
+	if err := commit(staged, target); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// copyInto - copies content of file at src into given open file
+func copyInto(file *os.File, src string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	_, err = file.ReadFrom(in)
+	return err
+}
+
+// isRebalancing - checks whether objects may be moving between stripes
+func (f *fsObjectStoreService) isRebalancing() bool {
+	return atomic.LoadInt32(&f.rebalancing) > 0
+}
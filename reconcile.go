@@ -0,0 +1,267 @@
+package fsstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/ipfs/go-cid"
+)
+
+// ErrReconcileFailed is return, when indexes of store can not be reconciled with bucket.
+var ErrReconcileFailed = errors.New("fsobjectstore: reconciliation failed")
+
+// _openFile handles the internal file name marking store as open; found when store is opened, it tells previous
+// run was not closed cleanly
+const _openFile = "open"
+
+// ReconcileReport captures differences found between indexes of store (cid index, statistics and catalog, where
+// maintained) and objects found walking bucket, all repaired by reconciliation. Missing objects are indexed but
+// not in bucket, orphans are in bucket but not indexed, size mismatches are cataloged with a size other than
+// stored. Drifts are object count and bytes statistics were off by, positive when overcounting.
+type ReconcileReport struct {
+	Checked        int
+	Missing        []cid.Cid
+	Orphans        []cid.Cid
+	SizeMismatches []cid.Cid
+	ObjectsDrift   int64
+	BytesDrift     int64
+}
+
+// Consistent - checks whether indexes matched bucket, so nothing was repaired
+func (r *ReconcileReport) Consistent() bool {
+	return len(r.Missing) == 0 && len(r.Orphans) == 0 && len(r.SizeMismatches) == 0 && r.ObjectsDrift == 0 && r.BytesDrift == 0
+}
+
+// Reconciler defines the functions clients need to check indexes of store against bucket, e.g. after a crash.
+type Reconciler interface {
+	Reconcile(context.Context) (*ReconcileReport, error)
+}
+
+var _ Reconciler = (*fsObjectStoreService)(nil)
+
+// reconciled captures an object found walking bucket
+type reconciled struct {
+	cid   cid.Cid
+	size  int64
+	mtime time.Time
+}
+
+// Reconcile - walks bucket and diffs found objects against cid index (see `WithCIDIndex`), statistics and catalog
+// (see `WithCatalog`), repairing them and reporting differences; statistics and cid index not loaded yet are
+// loaded from walk instead. Writers are blocked while bucket is walked, as when statistics are loaded. Store opened after it was
+// not closed cleanly runs reconciliation itself, as a `reconcile` job (see `StartJob`).
+func (f *fsObjectStoreService) Reconcile(ctx context.Context) (*ReconcileReport, error) {
+	if err := f.authorize(ctx, OpAdmin, cid.Undef); err != nil {
+		return nil, err
+	}
+	report, err := f.reconcile(withSystem(ctx))
+	f.audit(ctx, OpAdmin, cid.Undef, err)
+	return report, err
+}
+
+// reconcile - walks bucket, then repairs indexes differing from it
+func (f *fsObjectStoreService) reconcile(ctx context.Context) (*ReconcileReport, error) {
+	report := &ReconcileReport{}
+	if !f.isProvisioned() {
+		return report, nil
+	}
+	s := f.stats
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	walked := map[string]reconciled{}
+	counts, bytes := make([]int64, len(s.counts)), make([]int64, len(s.bytes))
+	err := f.walkObjects(ctx, func(c cid.Cid, path string, info os.FileInfo) error {
+		size := f.objectSize(path, info.Size())
+		walked[c.String()] = reconciled{cid: c, size: size, mtime: info.ModTime()}
+		class := sizeClass(size)
+		counts[class]++
+		bytes[class] += size
+		return nil
+	})
+	if err == nil {
+		report.Checked = len(walked)
+		missing, orphans := map[string]cid.Cid{}, map[string]cid.Cid{}
+		f.reconcileIndex(s, walked, missing, orphans)
+		f.reconcileStats(s, counts, bytes, report)
+		err = f.reconcileCatalog(ctx, walked, missing, orphans, report)
+		report.Missing, report.Orphans = sortedCIDs(missing), sortedCIDs(orphans)
+	}
+	if err != nil {
+		if ctxErr := checkContextError(ctx, f.isDebug()); ctxErr != nil {
+			return report, ctxErr
+		}
+		log.Printf("err: reconciling indexes failed: %s, %v\n", f.bucket, err)
+		return report, ErrReconcileFailed
+	}
+	if !report.Consistent() {
+		log.Printf("warn: indexes reconciled: %s, %d missing, %d orphans, %d size mismatches, stats off by %d objects, %d bytes\n",
+			f.bucket, len(report.Missing), len(report.Orphans), len(report.SizeMismatches), report.ObjectsDrift, report.BytesDrift)
+	} else if f.isDebug() {
+		log.Printf("debug: indexes consistent: %s, %d checked\n", f.bucket, report.Checked)
+	}
+	return report, nil
+}
+
+// reconcileIndex - repairs cid index when loaded (loading it when indexing but not loaded yet), collecting
+// differences; requires s.mu held
+func (f *fsObjectStoreService) reconcileIndex(s *stats, walked map[string]reconciled, missing, orphans map[string]cid.Cid) {
+	if s.indexing && !s.indexed {
+		s.index, s.indexed, s.dirty = make(map[string]cid.Cid, len(walked)), true, true
+		for key, obj := range walked {
+			s.index[key] = obj.cid
+		}
+		return
+	}
+	if !s.indexed {
+		return
+	}
+	for key, c := range s.index {
+		if _, ok := walked[key]; !ok {
+			missing[key] = c
+			delete(s.index, key)
+			s.dirty = true
+		}
+	}
+	for key, obj := range walked {
+		if _, ok := s.index[key]; !ok {
+			orphans[key] = obj.cid
+			s.index[key] = obj.cid
+			s.dirty = true
+		}
+	}
+}
+
+// reconcileStats - replaces statistics when loaded and differing from walked counts, reporting drift (loading
+// them when not loaded yet); requires s.mu held
+func (f *fsObjectStoreService) reconcileStats(s *stats, counts, bytes []int64, report *ReconcileReport) {
+	if !s.loaded {
+		copy(s.counts, counts)
+		copy(s.bytes, bytes)
+		s.loaded, s.dirty = true, true
+		return
+	}
+	drifted := false
+	for i := range counts {
+		report.ObjectsDrift += s.counts[i] - counts[i]
+		report.BytesDrift += s.bytes[i] - bytes[i]
+		drifted = drifted || s.counts[i] != counts[i] || s.bytes[i] != bytes[i]
+	}
+	if drifted {
+		copy(s.counts, counts)
+		copy(s.bytes, bytes)
+		s.dirty = true
+	}
+}
+
+// reconcileCatalog - repairs catalog when configured within a single transaction, collecting differences.
+// Catalog is updated before writers block on statistics, so objects created or deleted while bucket was
+// walked are checked again before their rows are changed.
+func (f *fsObjectStoreService) reconcileCatalog(ctx context.Context, walked map[string]reconciled, missing, orphans map[string]cid.Cid, report *ReconcileReport) error {
+	if f.catalog == nil {
+		return nil
+	}
+	return f.catalog.withTx(ctx, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, `SELECT cid, size FROM fsstore_objects WHERE bucket = ?`, f.bucket)
+		if err != nil {
+			return err
+		}
+		cataloged := map[string]int64{}
+		for rows.Next() {
+			var key string
+			var size int64
+			if err := rows.Scan(&key, &size); err != nil {
+				rows.Close()
+				return err
+			}
+			cataloged[key] = size
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for key := range cataloged {
+			if _, ok := walked[key]; ok {
+				continue
+			}
+			c, decodeErr := cid.Decode(key)
+			if decodeErr == nil && f.has(c) {
+				continue
+			}
+			if _, err := tx.Exec(`DELETE FROM fsstore_objects WHERE bucket = ? AND cid = ?`, f.bucket, key); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(`DELETE FROM fsstore_tags WHERE bucket = ? AND cid = ?`, f.bucket, key); err != nil {
+				return err
+			}
+			if decodeErr == nil {
+				missing[key] = c
+			}
+		}
+		for key, obj := range walked {
+			size, ok := cataloged[key]
+			switch {
+			case !ok:
+				if !f.has(obj.cid) {
+					continue
+				}
+				if _, err := tx.Exec(`INSERT INTO fsstore_objects (bucket, cid, size, mtime) VALUES (?, ?, ?, ?)
+					ON CONFLICT (bucket, cid) DO NOTHING`, f.bucket, key, obj.size, obj.mtime.Unix()); err != nil {
+					return err
+				}
+				orphans[key] = obj.cid
+			case size != obj.size:
+				if _, err := tx.Exec(`UPDATE fsstore_objects SET size = ? WHERE bucket = ? AND cid = ?`,
+					obj.size, f.bucket, key); err != nil {
+					return err
+				}
+				report.SizeMismatches = append(report.SizeMismatches, obj.cid)
+			}
+		}
+		sort.Slice(report.SizeMismatches, func(i, j int) bool {
+			return report.SizeMismatches[i].String() < report.SizeMismatches[j].String()
+		})
+		return nil
+	})
+}
+
+// sortedCIDs - returns cids of set in cid order
+func sortedCIDs(set map[string]cid.Cid) []cid.Cid {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	ret := make([]cid.Cid, 0, len(keys))
+	for _, key := range keys {
+		ret = append(ret, set[key])
+	}
+	return ret
+}
+
+// markOpen - records store as open, reporting whether previous run left its mark behind, i.e. was not closed
+// cleanly (see `Close`)
+func (f *fsObjectStoreService) markOpen() bool {
+	path := f.internalPath(_openFile)
+	unclean := exists(path)
+	if err := f.writeInternal(path, []byte(f.now().UTC().Format(time.RFC3339))); err != nil {
+		log.Printf("err: marking store open failed: %s, %v\n", path, err)
+	}
+	return unclean
+}
+
+// markClosed - removes open mark of store, once it is closed cleanly
+func (f *fsObjectStoreService) markClosed() {
+	if !f.isProvisioned() {
+		return
+	}
+	path := f.internalPath(_openFile)
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("err: removing open mark failed: %s, %v\n", path, err)
+	}
+}
@@ -0,0 +1,264 @@
+package fsstore
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/igumus/go-objectstore-lib"
+	"github.com/ipfs/go-cid"
+)
+
+// _refsDir handles the internal directory name of reference records
+const _refsDir = "refs"
+
+// _refsOut handles the directory name of manifest to child reference lists
+const _refsOut = "out"
+
+// _refsIn handles the directory name of per object reference counters
+const _refsIn = "in"
+
+// RefCounter defines the functions clients need to inspect references between
+// manifest objects and the objects (aka chunks) they link to.
+type RefCounter interface {
+	Refs(context.Context, cid.Cid) ([]cid.Cid, error)
+	RefCount(context.Context, cid.Cid) (int, error)
+}
+
+var _ RefCounter = (*fsObjectStoreService)(nil)
+
+// refsPath - returns file system path of reference record of given kind for specified cid
+func (f *fsObjectStoreService) refsPath(kind string, c cid.Cid) string {
+	link := objectLink(c)
+	if len(link) == 0 {
+		return ""
+	}
+	return f.internalPath(_refsDir, kind, link)
+}
+
+// Refs - returns cids of objects referenced by manifest object with specified cid
+func (f *fsObjectStoreService) Refs(ctx context.Context, parent cid.Cid) ([]cid.Cid, error) {
+	if err := f.authorize(ctx, OpRead, parent); err != nil {
+		return nil, err
+	}
+	if ctxErr := checkContextError(ctx, f.isDebug()); ctxErr != nil {
+		return nil, ctxErr
+	}
+	f.refMu.Lock()
+	defer f.refMu.Unlock()
+	return f.readRefs(parent)
+}
+
+// RefCount - returns count of manifest objects referencing object with specified cid
+func (f *fsObjectStoreService) RefCount(ctx context.Context, child cid.Cid) (int, error) {
+	if err := f.authorize(ctx, OpRead, child); err != nil {
+		return 0, err
+	}
+	if ctxErr := checkContextError(ctx, f.isDebug()); ctxErr != nil {
+		return 0, ctxErr
+	}
+	f.refMu.Lock()
+	defer f.refMu.Unlock()
+	return f.readRefCount(child)
+}
+
+// addRefs - records references of manifest object to its children, and increments
+// reference counts of children. Recording is idempotent per manifest, since manifests
+// are content addressed and always reference same children.
+func (f *fsObjectStoreService) addRefs(ctx context.Context, parent cid.Cid, children []cid.Cid) error {
+	if ctxErr := checkContextError(ctx, f.isDebug()); ctxErr != nil {
+		return ctxErr
+	}
+	f.refMu.Lock()
+	defer f.refMu.Unlock()
+
+	outLink := f.refsPath(_refsOut, parent)
+	if exists(outLink) {
+		return nil
+	}
+
+	seen := make(map[string]struct{}, len(children))
+	lines := make([]string, 0, len(children))
+	for _, child := range children {
+		key := child.String()
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		lines = append(lines, key)
+	}
+
+	// counters are incremented before reference list is written, so an interrupted
+	// recording only over-counts which keeps children safe from garbage collection.
+	for _, key := range lines {
+		child, _ := cid.Decode(key)
+		if err := f.adjustRefCount(child, 1); err != nil {
+			return err
+		}
+	}
+	if err := f.writeInternal(outLink, []byte(strings.Join(lines, "\n"))); err != nil {
+		return objectstore.ErrReferenceWritingFailed
+	}
+	if f.isDebug() {
+		log.Printf("debug: recorded refs: %s, %d\n", parent, len(lines))
+	}
+	return nil
+}
+
+// removeRefs - drops references of manifest object, and decrements reference counts of its children
+func (f *fsObjectStoreService) removeRefs(ctx context.Context, parent cid.Cid) error {
+	if ctxErr := checkContextError(ctx, f.isDebug()); ctxErr != nil {
+		return ctxErr
+	}
+	f.refMu.Lock()
+	defer f.refMu.Unlock()
+
+	children, err := f.readRefs(parent)
+	if err != nil {
+		return err
+	}
+	if len(children) == 0 {
+		return nil
+	}
+	// reference list is removed before counters are decremented, so an interrupted
+	// removal never decrements twice.
+	if err := os.Remove(f.refsPath(_refsOut, parent)); err != nil {
+		log.Printf("err: removing refs failed: %s, %v\n", parent, err)
+		return objectstore.ErrReferenceWritingFailed
+	}
+	for _, child := range children {
+		if err := f.adjustRefCount(child, -1); err != nil {
+			return err
+		}
+	}
+	if f.isDebug() {
+		log.Printf("debug: removed refs: %s, %d\n", parent, len(children))
+	}
+	return nil
+}
+
+// readRefs - reads reference list of manifest object, caller must hold refMu
+func (f *fsObjectStoreService) readRefs(parent cid.Cid) ([]cid.Cid, error) {
+	outLink := f.refsPath(_refsOut, parent)
+	if !exists(outLink) {
+		return nil, nil
+	}
+	data, err := f.readInternal(outLink)
+	if errors.Is(err, ErrInternalCorrupted) {
+		return nil, objectstore.ErrReferenceDecodingFailed
+	}
+	if err != nil {
+		return nil, objectstore.ErrReferenceReadingFailed
+	}
+	ret := []cid.Cid{}
+	for _, line := range strings.Split(string(data), "\n") {
+		if len(line) == 0 {
+			continue
+		}
+		child, err := cid.Decode(line)
+		if err != nil {
+			log.Printf("err: decoding ref failed: %s, %v\n", outLink, err)
+			return nil, objectstore.ErrReferenceDecodingFailed
+		}
+		ret = append(ret, child)
+	}
+	return ret, nil
+}
+
+// readRefCount - reads reference counter of object, caller must hold refMu
+func (f *fsObjectStoreService) readRefCount(child cid.Cid) (int, error) {
+	count, _, err := f.loadRefCount(child)
+	return count, err
+}
+
+// loadRefCount - reads reference counter of object, rebuilding it from reference lists when counter file
+// is corrupted; reports whether counter is rebuilt. Caller must hold refMu
+func (f *fsObjectStoreService) loadRefCount(child cid.Cid) (int, bool, error) {
+	inLink := f.refsPath(_refsIn, child)
+	if !exists(inLink) {
+		return 0, false, nil
+	}
+	data, err := f.readInternal(inLink)
+	if errors.Is(err, ErrInternalCorrupted) {
+		count, err := f.rebuildRefCount(child)
+		return count, true, err
+	}
+	if err != nil {
+		return 0, false, objectstore.ErrReferenceReadingFailed
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		log.Printf("err: decoding ref count failed: %s, %v\n", inLink, err)
+		return 0, false, objectstore.ErrReferenceDecodingFailed
+	}
+	return count, false, nil
+}
+
+// rebuildRefCount - recounts manifest objects referencing object from reference lists, and rewrites its
+// counter. Caller must hold refMu
+func (f *fsObjectStoreService) rebuildRefCount(child cid.Cid) (int, error) {
+	count := 0
+	key := child.String()
+	err := filepath.Walk(f.internalPath(_refsDir, _refsOut), func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return nil
+		}
+		parent, err := cid.Decode(info.Name())
+		if err != nil {
+			return nil
+		}
+		children, err := f.readRefs(parent)
+		if err != nil {
+			return nil
+		}
+		for _, c := range children {
+			if c.String() == key {
+				count++
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, objectstore.ErrReferenceReadingFailed
+	}
+	log.Printf("warn: ref count corrupted, rebuilt from reference lists: %s, %d\n", child, count)
+	inLink := f.refsPath(_refsIn, child)
+	if count == 0 {
+		os.Remove(inLink)
+		return 0, nil
+	}
+	if err := f.writeInternal(inLink, []byte(strconv.Itoa(count))); err != nil {
+		return 0, objectstore.ErrReferenceWritingFailed
+	}
+	return count, nil
+}
+
+// adjustRefCount - adds delta to reference counter of object, caller must hold refMu
+func (f *fsObjectStoreService) adjustRefCount(child cid.Cid, delta int) error {
+	count, rebuilt, err := f.loadRefCount(child)
+	if err != nil {
+		return err
+	}
+	// reference list being removed is already gone when counter is decremented, so a rebuilt counter
+	// accounts for removal; lists being added are written after counters, so additions still apply
+	if !rebuilt || delta > 0 {
+		count += delta
+	}
+	inLink := f.refsPath(_refsIn, child)
+	if count <= 0 {
+		if err := os.Remove(inLink); err != nil && !os.IsNotExist(err) {
+			log.Printf("err: removing ref count failed: %s, %v\n", inLink, err)
+			return objectstore.ErrReferenceWritingFailed
+		}
+		return nil
+	}
+	if err := f.writeInternal(inLink, []byte(strconv.Itoa(count))); err != nil {
+		return objectstore.ErrReferenceWritingFailed
+	}
+	return nil
+}
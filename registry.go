@@ -0,0 +1,264 @@
+package fsstore
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/igumus/go-objectstore-lib"
+)
+
+// ErrStoreRegistered is return, when a store is already registered with given name.
+var ErrStoreRegistered = errors.New("fsobjectstore: store already registered")
+
+// ErrStoreNotRegistered is return, when no store is registered with given name.
+var ErrStoreNotRegistered = errors.New("fsobjectstore: store not registered")
+
+// ErrInvalidStoreConfig is return, when stores configuration can not be parsed or is not valid.
+var ErrInvalidStoreConfig = errors.New("fsobjectstore: invalid store configuration")
+
+// registry holds stores registered by name, shared by process
+var registry = struct {
+	mu     sync.RWMutex
+	stores map[string]objectstore.ObjectStore
+}{stores: map[string]objectstore.ObjectStore{}}
+
+// Register - registers store with name, so it is looked up by name instead of being passed around
+func Register(name string, store objectstore.ObjectStore) error {
+	name = strings.TrimSpace(name)
+	if len(name) == 0 || store == nil {
+		return ErrInvalidStoreConfig
+	}
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	if _, ok := registry.stores[name]; ok {
+		return ErrStoreRegistered
+	}
+	registry.stores[name] = store
+	return nil
+}
+
+// Lookup - returns store registered with name
+func Lookup(name string) (objectstore.ObjectStore, error) {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	store, ok := registry.stores[name]
+	if !ok {
+		return nil, ErrStoreNotRegistered
+	}
+	return store, nil
+}
+
+// Unregister - removes store registered with name from registry, returning it so caller can close it
+func Unregister(name string) (objectstore.ObjectStore, error) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	store, ok := registry.stores[name]
+	if !ok {
+		return nil, ErrStoreNotRegistered
+	}
+	delete(registry.stores, name)
+	return store, nil
+}
+
+// Names - returns names of registered stores, sorted
+func Names() []string {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	names := make([]string, 0, len(registry.stores))
+	for name := range registry.stores {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// StoreConfig captures configuration of a store opened from stores configuration file. Durations are given
+// as `time.ParseDuration` strings, e.g. `"30s"`; unset fields keep defaults of their options. Policy of bucket
+// policy file (see `BucketPolicy`) is applied before other fields, so fields set override it.
+type StoreConfig struct {
+	Name                string    `json:"name"`
+	DataDirs            []string  `json:"dataDirs"`
+	RetiredDataDirs     []string  `json:"retiredDataDirs,omitempty"`
+	Bucket              string    `json:"bucket,omitempty"`
+	TempDir             string    `json:"tempDir,omitempty"`
+	Debug               bool      `json:"debug,omitempty"`
+	Journal             bool      `json:"journal,omitempty"`
+	XattrMetadata       bool      `json:"xattrMetadata,omitempty"`
+	AuditLog            bool      `json:"auditLog,omitempty"`
+	PopularityTracking  bool      `json:"popularityTracking,omitempty"`
+	LazyInit            bool      `json:"lazyInit,omitempty"`
+	SharedPool          bool      `json:"sharedPool,omitempty"`
+	CIDIndex            bool      `json:"cidIndex,omitempty"`
+	MaintenanceSchedule string    `json:"maintenanceSchedule,omitempty"`
+	GCKeepLast          int       `json:"gcKeepLast,omitempty"`
+	GCKeepWithin        string    `json:"gcKeepWithin,omitempty"`
+	OperationTimeout    string    `json:"operationTimeout,omitempty"`
+	SlowOpThreshold     string    `json:"slowOpThreshold,omitempty"`
+	TrashRetention      string    `json:"trashRetention,omitempty"`
+	StatsCheckpoint     string    `json:"statsCheckpoint,omitempty"`
+	MaxOpenFiles        int       `json:"maxOpenFiles,omitempty"`
+	ListBuffer          int       `json:"listBuffer,omitempty"`
+	ChunkDecodeWorkers  int       `json:"chunkDecodeWorkers,omitempty"`
+	MaintenanceQueue    int       `json:"maintenanceQueue,omitempty"`
+	JobConcurrency      int       `json:"jobConcurrency,omitempty"`
+	IOBudget            *IOBudget `json:"ioBudget,omitempty"`
+	PolicyFile          string    `json:"policyFile,omitempty"`
+}
+
+// StoresConfig captures stores configuration file, e.g.
+//
+//	{"stores": [{"name": "media", "dataDirs": ["/srv/media"], "journal": true}]}
+type StoresConfig struct {
+	Stores []StoreConfig `json:"stores"`
+}
+
+// options - returns configuration options of store config
+func (s StoreConfig) options() ([]FSObjectstoreConfigOption, error) {
+	opts := []FSObjectstoreConfigOption{}
+	if len(s.PolicyFile) > 0 {
+		policy, err := ReadPolicyFile(s.PolicyFile)
+		if err != nil {
+			return nil, err
+		}
+		if opts, err = policy.Options(); err != nil {
+			return nil, err
+		}
+	}
+	opts = append(opts,
+		WithDataDirs(s.DataDirs...),
+		WithDebugMode(s.Debug),
+		WithJournal(s.Journal),
+		WithXattrMetadata(s.XattrMetadata),
+		WithAuditLog(s.AuditLog),
+		WithPopularityTracking(s.PopularityTracking),
+		WithLazyInit(s.LazyInit),
+		WithSharedPool(s.SharedPool),
+		WithCIDIndex(s.CIDIndex),
+	)
+	if len(s.RetiredDataDirs) > 0 {
+		opts = append(opts, WithRetiredDataDirs(s.RetiredDataDirs...))
+	}
+	if len(s.Bucket) > 0 {
+		opts = append(opts, WithBucket(s.Bucket))
+	}
+	if len(s.TempDir) > 0 {
+		opts = append(opts, WithTempDir(s.TempDir))
+	}
+	if len(s.MaintenanceSchedule) > 0 {
+		opts = append(opts, WithMaintenanceSchedule(s.MaintenanceSchedule))
+	}
+	if s.MaxOpenFiles > 0 {
+		opts = append(opts, WithMaxOpenFiles(s.MaxOpenFiles))
+	}
+	if s.ListBuffer > 0 {
+		opts = append(opts, WithListBuffer(s.ListBuffer))
+	}
+	if s.MaintenanceQueue > 0 {
+		opts = append(opts, WithMaintenanceQueue(s.MaintenanceQueue))
+	}
+	if s.JobConcurrency > 0 {
+		opts = append(opts, WithJobConcurrency(s.JobConcurrency))
+	}
+	if s.ChunkDecodeWorkers > 0 {
+		opts = append(opts, WithChunkDecodeWorkers(s.ChunkDecodeWorkers))
+	}
+	if s.IOBudget != nil {
+		opts = append(opts, WithIOBudget(*s.IOBudget))
+	}
+	durations := []struct {
+		value string
+		opt   func(time.Duration) FSObjectstoreConfigOption
+	}{
+		{s.OperationTimeout, WithOperationTimeout},
+		{s.SlowOpThreshold, WithSlowOpThreshold},
+		{s.TrashRetention, WithTrashRetention},
+		{s.StatsCheckpoint, WithStatsCheckpointInterval},
+	}
+	for _, d := range durations {
+		if len(d.value) == 0 {
+			continue
+		}
+		parsed, err := time.ParseDuration(d.value)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, d.opt(parsed))
+	}
+	if s.GCKeepLast > 0 || len(s.GCKeepWithin) > 0 {
+		policy := RetentionPolicy{KeepLast: s.GCKeepLast}
+		if len(s.GCKeepWithin) > 0 {
+			within, err := time.ParseDuration(s.GCKeepWithin)
+			if err != nil {
+				return nil, err
+			}
+			policy.KeepWithin = within
+		}
+		opts = append(opts, WithGCRetention(policy))
+	}
+	return opts, nil
+}
+
+// RegisterConfigFile - opens and registers stores of stores configuration file at path, see `RegisterConfig`
+func RegisterConfigFile(path string, opts ...FSObjectstoreConfigOption) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		log.Printf("err: opening stores configuration failed: %s, %v\n", path, err)
+		return nil, ErrInvalidStoreConfig
+	}
+	defer file.Close()
+	return RegisterConfig(file, opts...)
+}
+
+// RegisterConfig - opens every store of json stores configuration read from r, applying given options (e.g.
+// `WithAuthorizer`, which can not be configured from file) after configured ones, and registers them by name.
+// Returns registered names; when a store fails to open, already opened ones are closed and unregistered.
+func RegisterConfig(r io.Reader, opts ...FSObjectstoreConfigOption) ([]string, error) {
+	cfg := StoresConfig{}
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&cfg); err != nil {
+		log.Printf("err: decoding stores configuration failed: %v\n", err)
+		return nil, ErrInvalidStoreConfig
+	}
+
+	names := []string{}
+	rollback := func() {
+		for _, name := range names {
+			if store, err := Unregister(name); err == nil {
+				if closer, ok := store.(io.Closer); ok {
+					closer.Close()
+				}
+			}
+		}
+	}
+	for _, sc := range cfg.Stores {
+		storeOpts, err := sc.options()
+		if err != nil {
+			log.Printf("err: parsing store configuration failed: %s, %v\n", sc.Name, err)
+			rollback()
+			return nil, ErrInvalidStoreConfig
+		}
+		store, err := NewFileSystemObjectStore(append(storeOpts, opts...)...)
+		if err != nil {
+			log.Printf("err: opening configured store failed: %s, %v\n", sc.Name, err)
+			rollback()
+			return nil, err
+		}
+		if err := Register(sc.Name, store); err != nil {
+			if closer, ok := store.(io.Closer); ok {
+				closer.Close()
+			}
+			rollback()
+			return nil, err
+		}
+		names = append(names, strings.TrimSpace(sc.Name))
+	}
+	return names, nil
+}
@@ -0,0 +1,53 @@
+package fsstore
+
+import (
+	"context"
+	"fmt"
+)
+
+// RequestIDHeader is the request header carrying request id, set on webhook notifications and honoured by
+// http gateway
+const RequestIDHeader = "X-Request-Id"
+
+// TraceIDHeader is the request header carrying trace id, set on webhook notifications and honoured by http gateway
+const TraceIDHeader = "X-Trace-Id"
+
+// RequestMeta captures correlation metadata of calling request, such as request and trace identifiers, which
+// store includes in its structured logs, audit records and webhook notifications
+type RequestMeta struct {
+	RequestID string
+	TraceID   string
+}
+
+// requestMetaKey is context key of request metadata
+type requestMetaKey struct{}
+
+// ContextWithRequestMeta returns copy of ctx carrying request metadata
+func ContextWithRequestMeta(ctx context.Context, meta RequestMeta) context.Context {
+	return context.WithValue(ctx, requestMetaKey{}, meta)
+}
+
+// ContextWithRequestID returns copy of ctx carrying request id, keeping trace id already attached to ctx
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	meta := RequestMetaFromContext(ctx)
+	meta.RequestID = id
+	return ContextWithRequestMeta(ctx, meta)
+}
+
+// RequestMetaFromContext returns request metadata attached to ctx, zero value when none attached
+func RequestMetaFromContext(ctx context.Context) RequestMeta {
+	meta, _ := ctx.Value(requestMetaKey{}).(RequestMeta)
+	return meta
+}
+
+// logFields - returns request metadata formatted as structured log fields, empty when metadata is not set
+func (m RequestMeta) logFields() string {
+	ret := ""
+	if len(m.RequestID) > 0 {
+		ret += fmt.Sprintf(" request=%s", m.RequestID)
+	}
+	if len(m.TraceID) > 0 {
+		ret += fmt.Sprintf(" trace=%s", m.TraceID)
+	}
+	return ret
+}
@@ -0,0 +1,211 @@
+package fsstore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"hash/fnv"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/igumus/go-objectstore-lib"
+	"github.com/ipfs/go-cid"
+	mh "github.com/multiformats/go-multihash"
+)
+
+// ErrRingCorrupted is return, when persisted placement ring could not be decoded.
+var ErrRingCorrupted = errors.New("fsobjectstore: placement ring corrupted")
+
+// _ringFile handles the internal file name persisting placement ring
+const _ringFile = "ring"
+
+// _ringVnodes handles the number of points each data directory owns on placement ring
+const _ringVnodes = 64
+
+// MigrationMove captures objects moving from one data directory to another
+type MigrationMove struct {
+	From    string
+	To      string
+	Objects int
+	Bytes   int64
+}
+
+// MigrationPlan captures objects which would move, if store was striped across planned data directories
+type MigrationPlan struct {
+	Checked int
+	Moves   []MigrationMove
+}
+
+// PlacementMap defines the functions clients need to inspect object placement across data directories, and
+// plan migrations before adding or retiring data directories.
+type PlacementMap interface {
+	Placement(cid.Cid) string
+	PlanMigration(ctx context.Context, dirs ...string) (*MigrationPlan, error)
+}
+
+var _ PlacementMap = (*fsObjectStoreService)(nil)
+
+// ringState captures persisted form of placement ring
+type ringState struct {
+	Vnodes  int      `json:"vnodes"`
+	Members []string `json:"members"`
+}
+
+// ringPoint captures a point on placement ring, owned by member with given index
+type ringPoint struct {
+	hash   uint64
+	member int
+}
+
+// ring places keys on members via consistent hashing, so adding or removing a member moves only keys
+// it gains or loses
+type ring struct {
+	points []ringPoint
+}
+
+// newRing - creates placement ring over given members, each owning vnodes points
+func newRing(members []string, vnodes int) *ring {
+	r := &ring{points: make([]ringPoint, 0, len(members)*vnodes)}
+	for i, member := range members {
+		for v := 0; v < vnodes; v++ {
+			sum := sha256.Sum256([]byte(member + "#" + strconv.Itoa(v)))
+			r.points = append(r.points, ringPoint{hash: binary.BigEndian.Uint64(sum[:8]), member: i})
+		}
+	}
+	sort.Slice(r.points, func(i, j int) bool { return r.points[i].hash < r.points[j].hash })
+	return r
+}
+
+// locate - returns index of member owning given key, first point at or after key clockwise
+func (r *ring) locate(key uint64) int {
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i].hash >= key })
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.points[i].member
+}
+
+// ringKey - returns position of object with specified cid on placement ring, derived from leading digest bytes
+func ringKey(c cid.Cid) uint64 {
+	if decoded, err := mh.Decode(c.Hash()); err == nil && len(decoded.Digest) >= 8 {
+		return binary.BigEndian.Uint64(decoded.Digest)
+	}
+	h := fnv.New64a()
+	h.Write(c.Bytes())
+	return h.Sum64()
+}
+
+// loadRing - builds placement ring over active stripes, and persists it. Data directories of a previously
+// persisted ring which are neither configured nor retired are kept as retired stripes, so objects on them
+// stay readable until drained via `Rebalance`.
+func (f *fsObjectStoreService) loadRing() error {
+	if len(f.stripes) == 0 {
+		f.stripes, f.active = []string{f.bucketDir()}, 1
+	}
+	members := make([]string, 0, f.active)
+	for _, stripe := range f.stripes[:f.active] {
+		members = append(members, filepath.Dir(stripe))
+	}
+	ringLink := f.internalPath(_ringFile)
+	if exists(ringLink) {
+		data, err := f.readInternal(ringLink)
+		if errors.Is(err, ErrInternalCorrupted) {
+			return ErrRingCorrupted
+		}
+		if err != nil {
+			return err
+		}
+		persisted := ringState{}
+		if err := json.Unmarshal(data, &persisted); err != nil {
+			log.Printf("err: decoding placement ring failed: %s, %v\n", ringLink, err)
+			return ErrRingCorrupted
+		}
+		for _, member := range persisted.Members {
+			stripe := filepath.Join(member, f.bucket)
+			known := false
+			for _, dir := range f.stripes {
+				known = known || filepath.Clean(dir) == stripe
+			}
+			if !known {
+				log.Printf("warn: data directory dropped without being retired, reading as retired: %s\n", member)
+				f.stripes = append(f.stripes, stripe)
+			}
+		}
+	}
+	data, err := json.Marshal(ringState{Vnodes: _ringVnodes, Members: members})
+	if err != nil {
+		return err
+	}
+	if !f.readOnly && !framedEqual(ringLink, data) {
+		if err := f.writeInternal(ringLink, data); err != nil {
+			return err
+		}
+	}
+	f.ring = newRing(members, _ringVnodes)
+	if f.isDebug() {
+		log.Printf("debug: placement ring loaded: %s, %d members\n", f.bucket, len(members))
+	}
+	return nil
+}
+
+// Placement - returns data directory new object with specified cid (aka content identifier) is placed on
+func (f *fsObjectStoreService) Placement(c cid.Cid) string {
+	return filepath.Dir(f.stripeDirs()[f.placement(c)])
+}
+
+// PlanMigration - reports objects which would move, if store was striped across given data directories (first
+// one being primary) instead of currently configured ones; nothing is moved. Plan is applied by reopening
+// store with planned data directories (retiring dropped ones via `WithRetiredDataDirs`) and running `Rebalance`.
+func (f *fsObjectStoreService) PlanMigration(ctx context.Context, dirs ...string) (*MigrationPlan, error) {
+	if err := f.authorize(ctx, OpAdmin, cid.Undef); err != nil {
+		return nil, err
+	}
+	if len(dirs) == 0 {
+		return nil, ErrDataDirNotSpecified
+	}
+	members := make([]string, 0, len(dirs))
+	for _, dir := range dirs {
+		members = append(members, filepath.Clean(dir))
+	}
+	planned := newRing(members, _ringVnodes)
+	moves := map[[2]string]*MigrationMove{}
+	plan := &MigrationPlan{}
+	err := f.walkObjects(withSystem(ctx), func(c cid.Cid, path string, info os.FileInfo) error {
+		plan.Checked++
+		from := filepath.Dir(f.stripeOf(path))
+		to := members[planned.locate(ringKey(c))]
+		if from == to {
+			return nil
+		}
+		move, ok := moves[[2]string{from, to}]
+		if !ok {
+			move = &MigrationMove{From: from, To: to}
+			moves[[2]string{from, to}] = move
+		}
+		move.Objects++
+		move.Bytes += info.Size()
+		return nil
+	})
+	if err != nil {
+		if ctxErr := checkContextError(ctx, f.isDebug()); ctxErr != nil {
+			return plan, ctxErr
+		}
+		log.Printf("err: planning migration failed: %s, %v\n", f.bucket, err)
+		return plan, objectstore.ErrObjectReadingFailed
+	}
+	for _, move := range moves {
+		plan.Moves = append(plan.Moves, *move)
+	}
+	sort.Slice(plan.Moves, func(i, j int) bool {
+		if plan.Moves[i].From != plan.Moves[j].From {
+			return plan.Moves[i].From < plan.Moves[j].From
+		}
+		return plan.Moves[i].To < plan.Moves[j].To
+	})
+	return plan, nil
+}
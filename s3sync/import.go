@@ -0,0 +1,149 @@
+package s3sync
+
+import (
+	"context"
+	"io"
+	"log"
+	"sync"
+
+	fsstore "github.com/igumus/go-objectstore-fs"
+	"github.com/ipfs/go-cid"
+)
+
+// ImportReport captures outcome of importing S3 objects, mapping S3 keys to cids
+type ImportReport struct {
+	Mapping  map[string]cid.Cid
+	Imported int
+	Resumed  int
+	Failed   map[string]error
+}
+
+// ImportFromS3 - streams S3 objects under prefix of bucket into objectstore with bounded concurrency; objects are
+// held fully in memory only by stores not offering uploads (see `fsstore.ResumableUploader`).
+// Keys recorded in progress file are skipped, so interrupted imports resume where they left. Progress is
+// reported to observer of ctx (see `fsstore.ContextWithProgress`) as operation `import`.
+func (s *Syncer) ImportFromS3(ctx context.Context, client Client, bucket, prefix string) (*ImportReport, error) {
+	prog, err := openProgress(s.cfg.progressFile)
+	if err != nil {
+		return nil, err
+	}
+	defer prog.close()
+
+	t := fsstore.NewProgressTracker(ctx, "import")
+	defer t.Finish()
+	report := &ImportReport{Mapping: map[string]cid.Cid{}, Failed: map[string]error{}}
+	var mu sync.Mutex
+	keys := make(chan string)
+	wg := sync.WaitGroup{}
+	for i := 0; i < s.cfg.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for key := range keys {
+				c, err := s.importKey(ctx, client, bucket, key, prog)
+				mu.Lock()
+				if err != nil {
+					report.Failed[key] = err
+				} else {
+					report.Mapping[key] = c
+					report.Imported++
+				}
+				mu.Unlock()
+				t.Add(1, 0)
+			}
+		}()
+	}
+
+	listErr := func() error {
+		defer close(keys)
+		token := ""
+		for {
+			page, err := client.ListObjects(ctx, bucket, prefix, token)
+			if err != nil {
+				return err
+			}
+			t.Expect(int64(len(page.Keys)))
+			for _, key := range page.Keys {
+				if value, ok := prog.lookup(key); ok {
+					if c, err := cid.Decode(value); err == nil {
+						mu.Lock()
+						report.Mapping[key] = c
+						report.Resumed++
+						mu.Unlock()
+						t.Add(1, 0)
+						continue
+					}
+				}
+				select {
+				case keys <- key:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+			if len(page.NextToken) == 0 {
+				return nil
+			}
+			token = page.NextToken
+		}
+	}()
+	wg.Wait()
+
+	if s.cfg.debug {
+		log.Printf("debug: imported from s3: %s/%s, %d imported, %d resumed, %d failed\n", bucket, prefix, report.Imported, report.Resumed, len(report.Failed))
+	}
+	if listErr != nil {
+		return report, listErr
+	}
+	if len(report.Failed) > 0 {
+		return report, ErrSyncIncomplete
+	}
+	return report, nil
+}
+
+// importKey - streams S3 object of key into objectstore and records it as completed
+func (s *Syncer) importKey(ctx context.Context, client Client, bucket, key string, prog *progress) (cid.Cid, error) {
+	body, err := client.GetObject(ctx, bucket, key)
+	if err != nil {
+		log.Printf("err: fetching s3 object failed: %s/%s, %v\n", bucket, key, err)
+		return cid.Undef, err
+	}
+	defer body.Close()
+
+	c, err := s.ingest(ctx, body)
+	if err != nil {
+		log.Printf("err: importing s3 object failed: %s/%s, %v\n", bucket, key, err)
+		return cid.Undef, err
+	}
+	if err := prog.record(key, c.String()); err != nil {
+		log.Printf("err: recording progress failed: %s/%s, %v\n", bucket, key, err)
+		return cid.Undef, err
+	}
+	if s.cfg.debug {
+		log.Printf("debug: imported s3 object: %s/%s, %s\n", bucket, key, c)
+	}
+	return c, nil
+}
+
+// ingest - creates object of body, streamed via an upload when store offers them, so it is never held fully in
+// memory; other stores read body fully
+func (s *Syncer) ingest(ctx context.Context, body io.Reader) (cid.Cid, error) {
+	uploader, ok := s.store.(fsstore.ResumableUploader)
+	if !ok {
+		return s.store.CreateObject(ctx, body)
+	}
+	upload, err := uploader.NewUpload(ctx)
+	if err != nil {
+		return cid.Undef, err
+	}
+	if _, err := io.Copy(upload, body); err != nil {
+		upload.Abort()
+		return cid.Undef, err
+	}
+	c, err := upload.Commit()
+	if err != nil {
+		// upload failing before it is placed stays pending otherwise
+		upload.Abort()
+		return cid.Undef, err
+	}
+	return c, nil
+}
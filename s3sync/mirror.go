@@ -0,0 +1,253 @@
+package s3sync
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	fsstore "github.com/igumus/go-objectstore-fs"
+	"github.com/ipfs/go-cid"
+)
+
+// ErrAgeFilterUnsupported is return, when mirroring filters by age but objectstore can not report object modification times.
+var ErrAgeFilterUnsupported = errors.New("s3sync: age filter requires objectstore reporting object stats")
+
+// Uploader defines the S3 operations clients need to provide for mirroring objects.
+type Uploader interface {
+	PutObject(ctx context.Context, bucket, key string, body []byte) error
+}
+
+// MirrorFilter captures which local objects are mirrored. Zero value mirrors every object.
+type MirrorFilter struct {
+	Prefix    string
+	OlderThan time.Duration
+	NewerThan time.Duration
+}
+
+// MirrorReport captures outcome of mirroring objects to S3. Skipped counts objects deleted since last mirroring,
+// which are not uploaded.
+type MirrorReport struct {
+	Uploaded int
+	Skipped  int
+	Filtered int
+	Failed   map[string]error
+}
+
+// mirrorChange captures an object to mirror, with journal sequence of its last change (zero when not journaled)
+type mirrorChange struct {
+	c       cid.Cid
+	seq     uint64
+	deleted bool
+}
+
+// MirrorToS3 - uploads local objects matching filter to bucket, keyed by their cid. Objects are picked up from
+// journal of store (see `fsstore.ChangeLister`), and journal sequence mirrored up to is recorded in progress file,
+// so repeated mirroring only uploads objects changed since; stores without journal are mirrored in full. Progress
+// file of mirroring must not be shared with imports, since it is keyed by bucket instead of S3 key. Progress is
+// reported to observer of ctx (see `fsstore.ContextWithProgress`) as operation `mirror`.
+func (s *Syncer) MirrorToS3(ctx context.Context, client Uploader, bucket string, filter MirrorFilter) (*MirrorReport, error) {
+	var stater fsstore.StatStore
+	if filter.OlderThan > 0 || filter.NewerThan > 0 {
+		st, ok := s.store.(fsstore.StatStore)
+		if !ok {
+			return nil, ErrAgeFilterUnsupported
+		}
+		stater = st
+	}
+
+	prog, err := openProgress(s.cfg.progressFile)
+	if err != nil {
+		return nil, err
+	}
+	defer prog.close()
+	since := uint64(0)
+	if value, ok := prog.lookup(bucket); ok {
+		if since, err = strconv.ParseUint(value, 10, 64); err != nil {
+			log.Printf("err: decoding mirrored sequence failed: %s, %s\n", bucket, value)
+			return nil, ErrProgressFileCorrupted
+		}
+	}
+	changes, journaled, err := s.mirrorChanges(ctx, since)
+	if err != nil {
+		return nil, err
+	}
+
+	t := fsstore.NewProgressTracker(ctx, "mirror")
+	defer t.Finish()
+	report := &MirrorReport{Failed: map[string]error{}}
+	var mu sync.Mutex
+	// lowest sequence not mirrored yet (failed, or too young for filter), so mirroring resumes from it
+	resumeSeq := uint64(0)
+	objects := make(chan mirrorChange)
+	wg := sync.WaitGroup{}
+	for i := 0; i < s.cfg.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for change := range objects {
+				uploaded, deferred, err := s.mirrorObject(ctx, client, bucket, change.c, filter, stater)
+				mu.Lock()
+				if (err != nil || deferred) && (resumeSeq == 0 || change.seq < resumeSeq) {
+					resumeSeq = change.seq
+				}
+				switch {
+				case err != nil:
+					report.Failed[change.c.String()] = err
+				case uploaded:
+					report.Uploaded++
+				default:
+					report.Filtered++
+				}
+				mu.Unlock()
+				t.Add(1, 0)
+			}
+		}()
+	}
+
+	// sequence of last change handed to workers (or skipped), so mirroring never resumes past one not handled
+	lastSeq := since
+	listErr := func() error {
+		defer close(objects)
+		for change := range changes {
+			if change.err != nil {
+				return change.err
+			}
+			if !strings.HasPrefix(change.c.String(), filter.Prefix) {
+				lastSeq = change.seq
+				continue
+			}
+			if change.deleted {
+				mu.Lock()
+				report.Skipped++
+				mu.Unlock()
+				lastSeq = change.seq
+				continue
+			}
+			t.Expect(1)
+			select {
+			case objects <- change.mirrorChange:
+				lastSeq = change.seq
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return nil
+	}()
+	wg.Wait()
+
+	if journaled {
+		mirrored := lastSeq
+		if resumeSeq > 0 && resumeSeq-1 < mirrored {
+			mirrored = resumeSeq - 1
+		}
+		if mirrored > since {
+			if err := prog.record(bucket, strconv.FormatUint(mirrored, 10)); err != nil {
+				log.Printf("err: recording progress failed: %s, %v\n", bucket, err)
+				return report, err
+			}
+		}
+	}
+	if s.cfg.debug {
+		log.Printf("debug: mirrored to s3: %s, %d uploaded, %d skipped, %d failed\n", bucket, report.Uploaded, report.Skipped, len(report.Failed))
+	}
+	if listErr != nil {
+		return report, listErr
+	}
+	if len(report.Failed) > 0 {
+		return report, ErrSyncIncomplete
+	}
+	return report, nil
+}
+
+// listedChange captures a listed object to mirror, or error listing failed with
+type listedChange struct {
+	mirrorChange
+	err error
+}
+
+// mirrorChanges - lists objects changed since journal sequence since, and whether they are listed from journal;
+// objects of stores without journal are listed in full
+func (s *Syncer) mirrorChanges(ctx context.Context, since uint64) (<-chan listedChange, bool, error) {
+	ch := make(chan listedChange)
+	if lister, ok := s.store.(fsstore.ChangeLister); ok {
+		events, err := lister.ListChangesSince(ctx, since)
+		switch {
+		case err == nil:
+			go func() {
+				defer close(ch)
+				for event := range events {
+					change := listedChange{mirrorChange: mirrorChange{c: event.Cid, seq: event.Seq, deleted: event.Deleted}, err: event.Error}
+					if !sendChange(ctx, ch, change) || change.err != nil {
+						return
+					}
+				}
+			}()
+			return ch, true, nil
+		case !errors.Is(err, fsstore.ErrJournalDisabled):
+			return nil, false, err
+		}
+	}
+	go func() {
+		defer close(ch)
+		for event := range s.store.ListObject(ctx) {
+			if event.Error != nil {
+				sendChange(ctx, ch, listedChange{err: event.Error})
+				return
+			}
+			c, err := cid.Decode(event.Object)
+			if err != nil {
+				continue
+			}
+			if !sendChange(ctx, ch, listedChange{mirrorChange: mirrorChange{c: c}}) {
+				return
+			}
+		}
+	}()
+	return ch, false, nil
+}
+
+// sendChange - sends change to ch unless ctx is done first
+func sendChange(ctx context.Context, ch chan<- listedChange, change listedChange) bool {
+	select {
+	case ch <- change:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// mirrorObject - uploads object when it matches filter, reporting whether it is uploaded, or deferred to a later
+// mirroring since it is too young for filter yet
+func (s *Syncer) mirrorObject(ctx context.Context, client Uploader, bucket string, c cid.Cid, filter MirrorFilter, stater fsstore.StatStore) (bool, bool, error) {
+	if stater != nil {
+		stat, err := stater.StatObject(ctx, c)
+		if err != nil {
+			return false, false, err
+		}
+		age := time.Since(stat.ModTime)
+		if filter.OlderThan > 0 && age < filter.OlderThan {
+			return false, true, nil
+		}
+		if filter.NewerThan > 0 && age > filter.NewerThan {
+			return false, false, nil
+		}
+	}
+
+	data, err := s.store.ReadObject(ctx, c)
+	if err != nil {
+		log.Printf("err: reading object failed: %s, %v\n", c, err)
+		return false, false, err
+	}
+	if err := client.PutObject(ctx, bucket, c.String(), data); err != nil {
+		log.Printf("err: uploading object failed: %s/%s, %v\n", bucket, c, err)
+		return false, false, err
+	}
+	if s.cfg.debug {
+		log.Printf("debug: mirrored object: %s/%s, %d bytes\n", bucket, c, len(data))
+	}
+	return true, false, nil
+}
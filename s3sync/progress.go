@@ -0,0 +1,106 @@
+package s3sync
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+)
+
+// progress records completed transfers as `key<TAB>value` lines, so transfers are resumable
+type progress struct {
+	mu   sync.Mutex
+	done map[string]string
+	file *os.File
+}
+
+// openProgress - loads completed transfers from path, and opens it for appending. Empty path
+// returns an in-memory only progress.
+func openProgress(path string) (*progress, error) {
+	p := &progress{done: map[string]string{}}
+	if len(path) == 0 {
+		return p, nil
+	}
+	if err := repairProgress(path); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0666)
+	if err != nil {
+		return nil, err
+	}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if len(line) == 0 {
+			continue
+		}
+		idx := strings.LastIndex(line, "\t")
+		if idx < 0 {
+			file.Close()
+			log.Printf("err: decoding progress line failed: %s, %s\n", path, line)
+			return nil, ErrProgressFileCorrupted
+		}
+		p.done[line[:idx]] = line[idx+1:]
+	}
+	if err := scanner.Err(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	p.file = file
+	return p, nil
+}
+
+// repairProgress - truncates line torn by an interrupted record (one not terminated by newline) off progress
+// file, so transfer it recorded is redone
+func repairProgress(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		log.Printf("err: reading progress file failed: %s, %v\n", path, err)
+		return err
+	}
+	if len(data) == 0 || data[len(data)-1] == '\n' {
+		return nil
+	}
+	size := bytes.LastIndexByte(data, '\n') + 1
+	if err := os.Truncate(path, int64(size)); err != nil {
+		log.Printf("err: truncating torn progress line failed: %s, %v\n", path, err)
+		return err
+	}
+	log.Printf("warn: truncated torn progress line: %s, %d bytes\n", path, len(data)-size)
+	return nil
+}
+
+// lookup - returns recorded value of completed transfer of key
+func (p *progress) lookup(key string) (string, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	value, ok := p.done[key]
+	return value, ok
+}
+
+// record - records completed transfer of key
+func (p *progress) record(key, value string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done[key] = value
+	if p.file == nil {
+		return nil
+	}
+	_, err := fmt.Fprintf(p.file, "%s\t%s\n", key, value)
+	return err
+}
+
+// close - closes underlying progress file
+func (p *progress) close() error {
+	if p.file == nil {
+		return nil
+	}
+	return p.file.Close()
+}
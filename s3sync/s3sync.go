@@ -0,0 +1,85 @@
+// Package s3sync moves objects between S3 compatible buckets and objectstores. It talks to S3 via
+// the small Client interface, so applications adapt the SDK they already use (aws-sdk-go, minio-go)
+// and fsstore itself stays free of S3 dependencies.
+package s3sync
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/igumus/go-objectstore-lib"
+)
+
+// ErrSyncIncomplete is return, when some keys could not be transferred; report lists failed keys.
+var ErrSyncIncomplete = errors.New("s3sync: some keys failed to transfer")
+
+// ErrProgressFileCorrupted is return, when progress file of resumable transfer could not be decoded.
+var ErrProgressFileCorrupted = errors.New("s3sync: progress file corrupted")
+
+// _defConcurrency handles the default count of concurrent transfers
+const _defConcurrency = 4
+
+// ListPage captures one page of S3 key listing
+type ListPage struct {
+	Keys      []string
+	NextToken string
+}
+
+// Client defines the S3 operations clients need to provide for syncing objects.
+type Client interface {
+	ListObjects(ctx context.Context, bucket, prefix, token string) (*ListPage, error)
+	GetObject(ctx context.Context, bucket, key string) (io.ReadCloser, error)
+}
+
+// Captures/Represents syncer configuration information
+type syncerConfig struct {
+	concurrency  int
+	progressFile string
+	debug        bool
+}
+
+// A SyncerOption sets options such as concurrency and progress file.
+type SyncerOption func(*syncerConfig)
+
+// WithConcurrency returns a SyncerOption that specifies count of concurrent transfers.
+// If not set, the default is `4`
+func WithConcurrency(n int) SyncerOption {
+	return func(sc *syncerConfig) {
+		if n > 0 {
+			sc.concurrency = n
+		}
+	}
+}
+
+// WithProgressFile returns a SyncerOption that specifies file recording completed transfers,
+// so interrupted transfers resume where they left. If not set, transfers are not resumable.
+func WithProgressFile(path string) SyncerOption {
+	return func(sc *syncerConfig) {
+		sc.progressFile = strings.TrimSpace(path)
+	}
+}
+
+// WithDebugMode returns a SyncerOption that specifies debug mode.
+// If not set, the default is `false`
+func WithDebugMode(dm bool) SyncerOption {
+	return func(sc *syncerConfig) {
+		sc.debug = dm
+	}
+}
+
+// Syncer transfers objects between S3 buckets and an objectstore
+type Syncer struct {
+	store objectstore.ObjectStore
+	cfg   *syncerConfig
+}
+
+// NewSyncer creates Syncer instance transferring objects of given store via configuration options.
+func NewSyncer(store objectstore.ObjectStore, opts ...SyncerOption) *Syncer {
+	cfg := &syncerConfig{concurrency: _defConcurrency}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return &Syncer{store: store, cfg: cfg}
+}
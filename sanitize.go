@@ -0,0 +1,57 @@
+package fsstore
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+
+	"github.com/igumus/go-objectstore-lib"
+	"github.com/ipfs/go-cid"
+)
+
+// ErrInvalidBucketName is return, when bucket name is not a single plain path component.
+var ErrInvalidBucketName = errors.New("fsobjectstore: invalid bucket name")
+
+// validateBucket - rejects bucket names which would not resolve to a direct child of data directory: names
+// containing separators or NUL bytes, absolute or volume qualified names, and names starting with a dot
+// (`.`, `..` and hidden names, reserved for store internals)
+func validateBucket(name string) error {
+	if len(name) == 0 {
+		return objectstore.ErrBucketNotSpecified
+	}
+	if strings.HasPrefix(name, ".") || strings.ContainsAny(name, "/\\\x00") || filepath.IsAbs(name) ||
+		filepath.VolumeName(name) != "" || filepath.Base(name) != name {
+		return ErrInvalidBucketName
+	}
+	return nil
+}
+
+// objectLink - returns bucket relative link of object with specified cid, or empty link when cid is undefined
+// or its link is not a safe relative path; file operations on an empty path fail as absent
+func objectLink(c cid.Cid) string {
+	if !c.Defined() {
+		return ""
+	}
+	key := c.String()
+	if !validLink(key) || len(key) <= 8 {
+		return ""
+	}
+	link := objectstore.DefaultLinkFunc(key)
+	if !validLink(link) {
+		return ""
+	}
+	return link
+}
+
+// validLink - checks whether link is a relative slash separated path without empty, dot or dot-dot components
+func validLink(link string) bool {
+	if len(link) == 0 || filepath.IsAbs(link) || filepath.VolumeName(link) != "" || strings.ContainsAny(link, "\\\x00") {
+		return false
+	}
+	for _, elem := range strings.Split(link, "/") {
+		if elem == "" || elem == "." || elem == ".." {
+			return false
+		}
+	}
+	return true
+}
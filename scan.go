@@ -0,0 +1,260 @@
+package fsstore
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/ipfs/go-cid"
+)
+
+// ErrObjectRejected is return (wrapped by `*RejectionError`), when a content scanner rejects object before it is
+// committed.
+var ErrObjectRejected = errors.New("fsobjectstore: object rejected")
+
+// ErrScanFailed is return, when a content scanner fails to decide on object (e.g. virus scanner is unreachable),
+// so object is not committed.
+var ErrScanFailed = errors.New("fsobjectstore: scanning object failed")
+
+// _sniffLen handles the count of leading bytes content type of staged objects is sniffed from
+const _sniffLen = 512
+
+// _clamChunkSize handles the size of chunks staged content is streamed to clamd with
+const _clamChunkSize = 64 << 10
+
+// RejectionError captures why a content scanner rejected object; it wraps `ErrObjectRejected`
+type RejectionError struct {
+	Cid    cid.Cid
+	Reason string
+}
+
+// Error - returns error message of rejection, along with its reason
+func (e *RejectionError) Error() string {
+	return ErrObjectRejected.Error() + ": " + e.Reason
+}
+
+// Unwrap - returns `ErrObjectRejected`
+func (e *RejectionError) Unwrap() error {
+	return ErrObjectRejected
+}
+
+// Reject - returns rejection content scanners return for objects they reject, with formatted reason
+func Reject(format string, args ...interface{}) error {
+	return &RejectionError{Reason: fmt.Sprintf(format, args...)}
+}
+
+// StagedObject captures an object about to be committed, as content scanners inspect it. `ContentType` is
+// sniffed from content (so it cannot be spoofed), `Metadata` is declared by call creating object (see
+// `WithMetadata`), nil when none is.
+type StagedObject struct {
+	Cid         cid.Cid
+	Size        int64
+	ContentType string
+	Metadata    *Metadata
+	open        func() (io.ReadCloser, error)
+}
+
+// Open - opens content of staged object for reading from its start; callers must close returned reader
+func (o StagedObject) Open() (io.ReadCloser, error) {
+	return o.open()
+}
+
+// ContentScanner inspects content of objects before they are committed (see `WithContentScanners`): objects
+// created, put, uploaded and adopted. Scanners reject objects via `Reject` (or errors wrapping
+// `ErrObjectRejected`), which fails write with a `*RejectionError`; other errors fail write with
+// `ErrScanFailed`. Nodes, deltas and objects placed into bucket by other processes (see `WithExternalWrites`) are
+// scanned too; chunked objects are scanned as a whole, rather than chunk by chunk. Content stored already is not
+// scanned again, snapshot manifests store creates itself are not scanned, and standby stores do not scan objects
+// they replicate.
+type ContentScanner interface {
+	Scan(ctx context.Context, obj StagedObject) error
+}
+
+// ContentScannerFunc adapts an ordinary function to ContentScanner
+type ContentScannerFunc func(ctx context.Context, obj StagedObject) error
+
+// Scan - calls fn
+func (fn ContentScannerFunc) Scan(ctx context.Context, obj StagedObject) error {
+	return fn(ctx, obj)
+}
+
+// NewSizeLimitScanner - creates scanner rejecting objects larger than max bytes
+func NewSizeLimitScanner(max int64) ContentScanner {
+	return ContentScannerFunc(func(ctx context.Context, obj StagedObject) error {
+		if obj.Size > max {
+			return Reject("object of %d bytes exceeds limit of %d bytes", obj.Size, max)
+		}
+		return nil
+	})
+}
+
+// NewMediaTypeScanner - creates scanner rejecting objects of denied media types, by sniffed content type;
+// types ending with `/` deny every subtype, e.g. `application/x-msdownload` or `video/`
+func NewMediaTypeScanner(denied ...string) ContentScanner {
+	return ContentScannerFunc(func(ctx context.Context, obj StagedObject) error {
+		mediaType, _, err := mime.ParseMediaType(obj.ContentType)
+		if err != nil {
+			mediaType = obj.ContentType
+		}
+		for _, t := range denied {
+			if mediaType == t || (strings.HasSuffix(t, "/") && strings.HasPrefix(mediaType, t)) {
+				return Reject("media type %s not allowed", mediaType)
+			}
+		}
+		return nil
+	})
+}
+
+// NewHashDenylistScanner - creates scanner rejecting banned objects, listed by cid or hex SHA-256 digest of
+// their content
+func NewHashDenylistScanner(banned ...string) ContentScanner {
+	cids, digests := map[string]struct{}{}, map[string]struct{}{}
+	for _, b := range banned {
+		if c, err := cid.Decode(b); err == nil {
+			cids[c.String()] = struct{}{}
+		} else {
+			digests[strings.ToLower(b)] = struct{}{}
+		}
+	}
+	return ContentScannerFunc(func(ctx context.Context, obj StagedObject) error {
+		if _, ok := cids[obj.Cid.String()]; ok {
+			return Reject("object %s is banned", obj.Cid)
+		}
+		if len(digests) == 0 {
+			return nil
+		}
+		content, err := obj.Open()
+		if err != nil {
+			return err
+		}
+		defer content.Close()
+		digest := sha256.New()
+		if _, err := io.Copy(digest, content); err != nil {
+			return err
+		}
+		if _, ok := digests[hex.EncodeToString(digest.Sum(nil))]; ok {
+			return Reject("object %s is banned", obj.Cid)
+		}
+		return nil
+	})
+}
+
+// NewClamAVScanner - creates scanner streaming content of objects to clamd listening at address of network (e.g.
+// `tcp`, `localhost:3310` or `unix`, `/run/clamav/clamd.ctl`), rejecting objects it finds infected
+func NewClamAVScanner(network, address string) ContentScanner {
+	return ContentScannerFunc(func(ctx context.Context, obj StagedObject) error {
+		content, err := obj.Open()
+		if err != nil {
+			return err
+		}
+		defer content.Close()
+		conn, err := (&net.Dialer{}).DialContext(ctx, network, address)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		if deadline, ok := ctx.Deadline(); ok {
+			conn.SetDeadline(deadline)
+		}
+		if _, err := io.WriteString(conn, "zINSTREAM\x00"); err != nil {
+			return err
+		}
+		chunk := make([]byte, 4+_clamChunkSize)
+		for {
+			n, err := io.ReadFull(content, chunk[4:])
+			if n > 0 {
+				binary.BigEndian.PutUint32(chunk[:4], uint32(n))
+				if _, err := conn.Write(chunk[:4+n]); err != nil {
+					return err
+				}
+			}
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+		}
+		if _, err := conn.Write([]byte{0, 0, 0, 0}); err != nil {
+			return err
+		}
+		reply, err := bufio.NewReader(conn).ReadString(0)
+		if err != nil {
+			return err
+		}
+		// replies are `stream: OK`, `stream: <signature> FOUND` or `<reason> ERROR`
+		reply = strings.TrimSpace(strings.TrimRight(reply, "\x00"))
+		switch {
+		case strings.HasSuffix(reply, " OK"):
+			return nil
+		case strings.HasSuffix(reply, " FOUND"):
+			return Reject("malware detected: %s", strings.TrimSuffix(strings.TrimPrefix(reply, "stream: "), " FOUND"))
+		default:
+			return fmt.Errorf("clamd: %s", reply)
+		}
+	})
+}
+
+// unscannedKey is context key marking objects created with context as not scanned on their own
+type unscannedKey struct{}
+
+// withoutScan - marks ctx, so objects created with it are not scanned: their content is scanned as a whole
+// elsewhere (e.g. chunks of chunked objects), or is data of store itself (e.g. snapshot manifests)
+func withoutScan(ctx context.Context) context.Context {
+	return context.WithValue(ctx, unscannedKey{}, true)
+}
+
+// scanSource captures content scanners inspect in place of stored bytes of object, with its size (e.g. content
+// of chunks listed by chunk manifest)
+type scanSource struct {
+	size int64
+	open func() (io.ReadCloser, error)
+}
+
+// scan - runs content scanners on staged object with cid and size, whose content open reads
+func (f *fsObjectStoreService) scan(ctx context.Context, c cid.Cid, size int64, open func() (io.ReadCloser, error)) error {
+	if len(f.scanners) == 0 || f.isStandby() {
+		return nil
+	}
+	if unscanned, _ := ctx.Value(unscannedKey{}).(bool); unscanned {
+		return nil
+	}
+	obj := StagedObject{Cid: c, Size: size, Metadata: callConfigFrom(ctx).metadata, open: open}
+	if content, err := open(); err == nil {
+		head := make([]byte, _sniffLen)
+		n, _ := io.ReadFull(content, head)
+		content.Close()
+		obj.ContentType = http.DetectContentType(head[:n])
+	}
+	for _, scanner := range f.scanners {
+		err := scanner.Scan(ctx, obj)
+		if err == nil {
+			continue
+		}
+		rejection := &RejectionError{}
+		if errors.As(err, &rejection) {
+			log.Printf("warn: content scanner rejected object: %s, %s\n", c, rejection.Reason)
+			return &RejectionError{Cid: c, Reason: rejection.Reason}
+		}
+		if errors.Is(err, ErrObjectRejected) {
+			log.Printf("warn: content scanner rejected object: %s, %v\n", c, err)
+			return &RejectionError{Cid: c, Reason: err.Error()}
+		}
+		if ctxErr := checkContextError(ctx, f.isDebug()); ctxErr != nil {
+			return ctxErr
+		}
+		log.Printf("err: scanning object failed: %s, %v\n", c, err)
+		return ErrScanFailed
+	}
+	return nil
+}
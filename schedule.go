@@ -0,0 +1,121 @@
+package fsstore
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidSchedule is return, when maintenance schedule spec could not be parsed.
+var ErrInvalidSchedule = errors.New("fsobjectstore: invalid schedule spec")
+
+// _scheduleHorizon handles the maximum duration searched for next matching time of cron spec
+const _scheduleHorizon = 366 * 24 * time.Hour
+
+// schedule computes next activation time after a given time
+type schedule interface {
+	next(time.Time) time.Time
+}
+
+// everySchedule activates at fixed intervals
+type everySchedule struct {
+	interval time.Duration
+}
+
+// next - returns time one interval after t
+func (e everySchedule) next(t time.Time) time.Time {
+	return t.Add(e.interval)
+}
+
+// cronSchedule activates on minutes matching cron fields
+type cronSchedule struct {
+	minute, hour, dom, month, dow map[int]bool
+}
+
+// next - returns first minute after t matching every field, zero time when none within horizon
+func (c cronSchedule) next(t time.Time) time.Time {
+	at := t.Truncate(time.Minute).Add(time.Minute)
+	for limit := t.Add(_scheduleHorizon); at.Before(limit); at = at.Add(time.Minute) {
+		if c.minute[at.Minute()] && c.hour[at.Hour()] && c.dom[at.Day()] &&
+			c.month[int(at.Month())] && c.dow[int(at.Weekday())] {
+			return at
+		}
+	}
+	return time.Time{}
+}
+
+// parseSchedule - parses `@every <duration>`, `@hourly`, `@daily`, `@weekly` or five field cron
+// specs (minute hour day-of-month month day-of-week) supporting `*`, `*/n`, ranges and lists
+func parseSchedule(spec string) (schedule, error) {
+	spec = strings.TrimSpace(spec)
+	switch {
+	case strings.HasPrefix(spec, "@every "):
+		d, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(spec, "@every ")))
+		if err != nil || d <= 0 {
+			return nil, ErrInvalidSchedule
+		}
+		return everySchedule{interval: d}, nil
+	case spec == "@hourly":
+		spec = "0 * * * *"
+	case spec == "@daily":
+		spec = "0 0 * * *"
+	case spec == "@weekly":
+		spec = "0 0 * * 0"
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, ErrInvalidSchedule
+	}
+	bounds := [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 6}}
+	sets := [5]map[int]bool{}
+	for i, field := range fields {
+		set, err := parseCronField(field, bounds[i][0], bounds[i][1])
+		if err != nil {
+			return nil, err
+		}
+		sets[i] = set
+	}
+	return cronSchedule{minute: sets[0], hour: sets[1], dom: sets[2], month: sets[3], dow: sets[4]}, nil
+}
+
+// parseCronField - parses comma separated cron field items within bounds
+func parseCronField(field string, min, max int) (map[int]bool, error) {
+	set := map[int]bool{}
+	for _, item := range strings.Split(field, ",") {
+		step := 1
+		if idx := strings.Index(item, "/"); idx >= 0 {
+			n, err := strconv.Atoi(item[idx+1:])
+			if err != nil || n <= 0 {
+				return nil, ErrInvalidSchedule
+			}
+			step = n
+			item = item[:idx]
+		}
+		lo, hi := min, max
+		if item != "*" {
+			if idx := strings.Index(item, "-"); idx >= 0 {
+				a, errA := strconv.Atoi(item[:idx])
+				b, errB := strconv.Atoi(item[idx+1:])
+				if errA != nil || errB != nil {
+					return nil, ErrInvalidSchedule
+				}
+				lo, hi = a, b
+			} else {
+				n, err := strconv.Atoi(item)
+				if err != nil {
+					return nil, ErrInvalidSchedule
+				}
+				lo, hi = n, n
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return nil, ErrInvalidSchedule
+		}
+		for v := lo; v <= hi; v += step {
+			set[v] = true
+		}
+	}
+	return set, nil
+}
@@ -0,0 +1,324 @@
+package fsstore
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/igumus/go-objectstore-lib"
+	"github.com/ipfs/go-cid"
+)
+
+// ErrInvalidSelector is return, when label selector can not be parsed.
+var ErrInvalidSelector = errors.New("fsobjectstore: invalid label selector")
+
+// ErrExportFailed is return, when writing archive of exported objects failed.
+var ErrExportFailed = errors.New("fsobjectstore: export failed")
+
+// _paxContentType, _paxContentEncoding, _paxCacheControl and _paxTags handle the PAX record names of object
+// metadata in exported archives
+const (
+	_paxContentType     = "FSSTORE.contentType"
+	_paxContentEncoding = "FSSTORE.contentEncoding"
+	_paxCacheControl    = "FSSTORE.cacheControl"
+	_paxTags            = "FSSTORE.tags"
+)
+
+// _exportSignatureMember handles the name of archive member ending signed exports, holding signature of export
+// listing, see `VerifyExport`
+const _exportSignatureMember = ".signature"
+
+// requirement captures a single requirement of label selector
+type requirement struct {
+	key    string
+	value  string
+	negate bool
+	exists bool
+}
+
+// Selector matches objects by labels of their metadata tags, see `ParseSelector`
+type Selector struct {
+	reqs []requirement
+}
+
+// Selection defines the functions clients need to delete or export classes of objects selected by their labels.
+type Selection interface {
+	DeleteWhere(ctx context.Context, selector string) (int, error)
+	ExportWhere(ctx context.Context, selector string, w io.Writer) (int, error)
+}
+
+var _ Selection = (*fsObjectStoreService)(nil)
+
+// ParseSelector - parses comma separated label selector requirements: `key=value` (object labeled key with value),
+// `key!=value` (object not labeled key with value), `key` (object labeled key) and `!key` (object not labeled key).
+// Labels of object are its metadata tags (see `Metadata`): tag `key=value` labels key with value, and tag without
+// `=` labels key with an empty value. Empty selector matches every object.
+func ParseSelector(s string) (*Selector, error) {
+	sel := &Selector{}
+	if len(strings.TrimSpace(s)) == 0 {
+		return sel, nil
+	}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		req := requirement{}
+		switch {
+		case strings.Contains(part, "!="):
+			kv := strings.SplitN(part, "!=", 2)
+			req = requirement{key: strings.TrimSpace(kv[0]), value: strings.TrimSpace(kv[1]), negate: true}
+		case strings.Contains(part, "="):
+			kv := strings.SplitN(part, "=", 2)
+			req = requirement{key: strings.TrimSpace(kv[0]), value: strings.TrimSpace(kv[1])}
+		case strings.HasPrefix(part, "!"):
+			req = requirement{key: strings.TrimSpace(part[1:]), exists: true, negate: true}
+		default:
+			req = requirement{key: part, exists: true}
+		}
+		if len(req.key) == 0 || strings.ContainsAny(req.key, "=!") || strings.ContainsAny(req.value, "=!") {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidSelector, part)
+		}
+		sel.reqs = append(sel.reqs, req)
+	}
+	return sel, nil
+}
+
+// Matches - checks whether object with given metadata tags satisfies every requirement of selector
+func (s *Selector) Matches(tags []string) bool {
+	labels := map[string][]string{}
+	for _, tag := range tags {
+		kv := strings.SplitN(tag, "=", 2)
+		value := ""
+		if len(kv) == 2 {
+			value = kv[1]
+		}
+		labels[kv[0]] = append(labels[kv[0]], value)
+	}
+	for _, req := range s.reqs {
+		values, found := labels[req.key]
+		matched := found
+		if !req.exists {
+			matched = false
+			for _, value := range values {
+				if value == req.value {
+					matched = true
+				}
+			}
+		}
+		if matched == req.negate {
+			return false
+		}
+	}
+	return true
+}
+
+// DeleteWhere - moves objects matching label selector (see `ParseSelector`) to trash, as `DeleteObject` does,
+// and returns number of deleted objects. Objects still referenced by other objects are skipped.
+func (f *fsObjectStoreService) DeleteWhere(ctx context.Context, selector string) (int, error) {
+	if err := f.authorize(ctx, OpDelete, cid.Undef); err != nil {
+		return 0, err
+	}
+	defer f.writeDone(ctx)
+	deleted, err := f.deleteWhere(ctx, selector)
+	f.audit(ctx, OpDelete, cid.Undef, err)
+	return deleted, err
+}
+
+// deleteWhere - deletes selected objects, auditing every deletion
+func (f *fsObjectStoreService) deleteWhere(ctx context.Context, selector string) (int, error) {
+	selected, err := f.selectObjects(ctx, selector)
+	if err != nil {
+		return 0, err
+	}
+	deleted := 0
+	for _, c := range selected {
+		err := f.deleteObject(ctx, c)
+		f.audit(ctx, OpDelete, c, err)
+		switch {
+		case err == nil:
+			deleted++
+		case errors.Is(err, ErrObjectReferenced):
+			log.Printf("warn: skipping referenced object of selection: %s\n", c)
+		default:
+			return deleted, err
+		}
+	}
+	if f.isDebug() {
+		log.Printf("debug: deleted selected objects: %s, %q, %d of %d\n", f.bucket, selector, deleted, len(selected))
+	}
+	return deleted, nil
+}
+
+// ExportWhere - writes objects matching label selector (see `ParseSelector`) to w as a tar archive, one member
+// named after cid of every object, with content type and tags of object as PAX records. Exports of stores signing
+// them (see `WithSigner`) end with a signature member, see `VerifyExport`. Returns number of exported objects.
+func (f *fsObjectStoreService) ExportWhere(ctx context.Context, selector string, w io.Writer) (int, error) {
+	if err := f.authorize(ctx, OpRead, cid.Undef); err != nil {
+		return 0, err
+	}
+	exported, err := f.exportWhere(ctx, selector, w)
+	f.audit(ctx, OpRead, cid.Undef, err)
+	return exported, err
+}
+
+// exportWhere - archives selected objects into w
+func (f *fsObjectStoreService) exportWhere(ctx context.Context, selector string, w io.Writer) (int, error) {
+	selected, err := f.selectObjects(ctx, selector)
+	if err != nil {
+		return 0, err
+	}
+	tw := tar.NewWriter(w)
+	listing := sha256.New()
+	exported := 0
+	for _, c := range selected {
+		err := f.exportObject(ctx, tw, c, listing)
+		if errors.Is(err, objectstore.ErrObjectNotExists) {
+			// object deleted since it was selected
+			continue
+		}
+		if err != nil {
+			return exported, err
+		}
+		exported++
+	}
+	signature, err := f.sign(ctx, listing.Sum(nil))
+	if err != nil {
+		return exported, err
+	}
+	if len(signature) > 0 {
+		hdr := &tar.Header{Name: _exportSignatureMember, Mode: 0644, Size: int64(len(signature)), ModTime: f.now()}
+		if err := tw.WriteHeader(hdr); err == nil {
+			_, err = io.WriteString(tw, signature)
+		}
+		if err != nil {
+			log.Printf("err: writing export archive failed: %s, %v\n", f.bucket, err)
+			return exported, ErrExportFailed
+		}
+	}
+	if err := tw.Close(); err != nil {
+		log.Printf("err: closing export archive failed: %s, %v\n", f.bucket, err)
+		return exported, ErrExportFailed
+	}
+	if f.isDebug() {
+		log.Printf("debug: exported selected objects: %s, %q, %d\n", f.bucket, selector, exported)
+	}
+	return exported, nil
+}
+
+// exportObject - writes object as archive member, and its `sha256sum` line to listing
+func (f *fsObjectStoreService) exportObject(ctx context.Context, tw *tar.Writer, c cid.Cid, listing io.Writer) error {
+	info, err := os.Stat(f.objectPath(c))
+	if err != nil {
+		return objectstore.ErrObjectNotExists
+	}
+	obj, err := f.openObject(ctx, c)
+	if err != nil {
+		return err
+	}
+	defer obj.Close()
+	size, err := obj.Seek(0, io.SeekEnd)
+	if err == nil {
+		_, err = obj.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		log.Printf("err: sizing exported object failed: %s, %v\n", c, err)
+		return objectstore.ErrObjectReadingFailed
+	}
+	hdr := &tar.Header{Name: c.String(), Mode: 0644, Size: size, ModTime: info.ModTime()}
+	if meta, err := f.GetMetadata(withSystem(ctx), c); err == nil {
+		if len(meta.ContentType) > 0 || len(meta.ContentEncoding) > 0 || len(meta.CacheControl) > 0 ||
+			len(meta.Tags) > 0 {
+			hdr.Format = tar.FormatPAX
+			hdr.PAXRecords = map[string]string{}
+		}
+		if len(meta.ContentType) > 0 {
+			hdr.PAXRecords[_paxContentType] = meta.ContentType
+		}
+		if len(meta.ContentEncoding) > 0 {
+			hdr.PAXRecords[_paxContentEncoding] = meta.ContentEncoding
+		}
+		if len(meta.CacheControl) > 0 {
+			hdr.PAXRecords[_paxCacheControl] = meta.CacheControl
+		}
+		if len(meta.Tags) > 0 {
+			hdr.PAXRecords[_paxTags] = strings.Join(meta.Tags, ",")
+		}
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		log.Printf("err: writing export archive failed: %s, %v\n", c, err)
+		return ErrExportFailed
+	}
+	digest := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tw, digest), obj); err != nil {
+		if ctxErr := checkContextError(ctx, f.isDebug()); ctxErr != nil {
+			return ctxErr
+		}
+		log.Printf("err: writing export archive failed: %s, %v\n", c, err)
+		return ErrExportFailed
+	}
+	fmt.Fprintf(listing, "%x  %s\n", digest.Sum(nil), c)
+	return nil
+}
+
+// VerifyExport - reads export archive (see `ExportWhere`), checking whether it ends with a valid signature of its
+// members, so importers can authenticate exports before trusting them. Signature covers `sha256sum` listing of
+// member names and content, in archive order. Returns number of verified members.
+func VerifyExport(ctx context.Context, r io.Reader, v SignatureVerifier) (int, error) {
+	archive := tar.NewReader(r)
+	listing := sha256.New()
+	members := 0
+	for {
+		hdr, err := archive.Next()
+		if err == io.EOF {
+			return members, ErrSignatureInvalid
+		}
+		if err != nil {
+			return members, ErrUnsupportedArchive
+		}
+		if hdr.Name == _exportSignatureMember {
+			signature, err := io.ReadAll(io.LimitReader(archive, 4096))
+			if err != nil {
+				return members, ErrUnsupportedArchive
+			}
+			if _, err := archive.Next(); err != io.EOF {
+				// members after signature are not covered by it
+				return members, ErrSignatureInvalid
+			}
+			return members, verifySignature(ctx, v, listing.Sum(nil), string(signature))
+		}
+		digest := sha256.New()
+		if _, err := io.Copy(digest, archive); err != nil {
+			return members, ErrUnsupportedArchive
+		}
+		fmt.Fprintf(listing, "%x  %s\n", digest.Sum(nil), hdr.Name)
+		members++
+	}
+}
+
+// selectObjects - walks bucket for objects whose metadata tags match selector
+func (f *fsObjectStoreService) selectObjects(ctx context.Context, selector string) ([]cid.Cid, error) {
+	sel, err := ParseSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	selected := []cid.Cid{}
+	err = f.walkObjects(ctx, func(c cid.Cid, path string, info os.FileInfo) error {
+		meta, err := f.GetMetadata(withSystem(ctx), c)
+		if err != nil {
+			log.Printf("warn: skipping object of unreadable metadata: %s, %v\n", c, err)
+			return nil
+		}
+		if sel.Matches(meta.Tags) {
+			selected = append(selected, c)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return selected, nil
+}
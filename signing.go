@@ -0,0 +1,200 @@
+package fsstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// ErrSigningFailed is return, when signing export, snapshot or checksum manifest failed.
+var ErrSigningFailed = errors.New("fsobjectstore: signing failed")
+
+// ErrSignatureInvalid is return, when signature of export, snapshot or checksum manifest is missing, or does not
+// verify.
+var ErrSignatureInvalid = errors.New("fsobjectstore: signature invalid")
+
+// _signaturePlaceholder handles the argument of verifying commands replaced by path of signature file
+const _signaturePlaceholder = "{signature}"
+
+// Signer signs SHA-256 digests of artifacts store hands out: bucket exports (see `ExportWhere`), snapshots (see
+// `SnapshotBucket`) and checksum manifests (see `ExportChecksumManifest`), see `WithSigner`. `Algorithm` names
+// scheme of signatures, recorded along them.
+type Signer interface {
+	Algorithm() string
+	Sign(ctx context.Context, digest []byte) ([]byte, error)
+}
+
+// SignatureVerifier verifies signatures of a `Signer` with same algorithm.
+type SignatureVerifier interface {
+	Algorithm() string
+	Verify(ctx context.Context, digest, signature []byte) error
+}
+
+// ed25519Signer signs digests with an ed25519 key
+type ed25519Signer struct {
+	key ed25519.PrivateKey
+}
+
+// NewEd25519Signer - creates signer signing with ed25519 key
+func NewEd25519Signer(key ed25519.PrivateKey) Signer {
+	return &ed25519Signer{key: key}
+}
+
+// Algorithm - returns `ed25519`
+func (s *ed25519Signer) Algorithm() string {
+	return "ed25519"
+}
+
+// Sign - signs digest with key
+func (s *ed25519Signer) Sign(ctx context.Context, digest []byte) ([]byte, error) {
+	if len(s.key) != ed25519.PrivateKeySize {
+		return nil, ErrSigningFailed
+	}
+	return ed25519.Sign(s.key, digest), nil
+}
+
+// ed25519Verifier verifies digests signed with an ed25519 key
+type ed25519Verifier struct {
+	key ed25519.PublicKey
+}
+
+// NewEd25519Verifier - creates verifier of signatures of ed25519 key
+func NewEd25519Verifier(key ed25519.PublicKey) SignatureVerifier {
+	return &ed25519Verifier{key: key}
+}
+
+// Algorithm - returns `ed25519`
+func (v *ed25519Verifier) Algorithm() string {
+	return "ed25519"
+}
+
+// Verify - checks signature of digest against key
+func (v *ed25519Verifier) Verify(ctx context.Context, digest, signature []byte) error {
+	if len(v.key) != ed25519.PublicKeySize || !ed25519.Verify(v.key, digest, signature) {
+		return ErrSignatureInvalid
+	}
+	return nil
+}
+
+// commandSigner signs digests via external command, reading hex digest from stdin and writing signature to stdout
+type commandSigner struct {
+	algorithm string
+	name      string
+	args      []string
+}
+
+// NewCommandSigner - creates signer of given algorithm running external command, which reads hex digest from
+// stdin and writes signature to stdout, e.g. `gpg --detach-sign --armor` or `cosign sign-blob --yes -` (keyless)
+func NewCommandSigner(algorithm, name string, args ...string) Signer {
+	return &commandSigner{algorithm: algorithm, name: name, args: args}
+}
+
+// Algorithm - returns algorithm signer is created with
+func (s *commandSigner) Algorithm() string {
+	return s.algorithm
+}
+
+// Sign - runs command for digest, returning its output
+func (s *commandSigner) Sign(ctx context.Context, digest []byte) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, s.name, s.args...)
+	cmd.Stdin = strings.NewReader(hex.EncodeToString(digest))
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	signature, err := cmd.Output()
+	if err != nil {
+		log.Printf("err: running signing command failed: %s, %v, %s\n", s.name, err, strings.TrimSpace(stderr.String()))
+		return nil, ErrSigningFailed
+	}
+	return signature, nil
+}
+
+// commandVerifier verifies digests via external command, exiting successfully for valid signatures
+type commandVerifier struct {
+	algorithm string
+	name      string
+	args      []string
+}
+
+// NewCommandVerifier - creates verifier of given algorithm running external command, which reads hex digest from
+// stdin and exits successfully when signature is valid; `{signature}` arguments are replaced by path of a file
+// holding signature, e.g. `gpg --verify {signature} -`
+func NewCommandVerifier(algorithm, name string, args ...string) SignatureVerifier {
+	return &commandVerifier{algorithm: algorithm, name: name, args: args}
+}
+
+// Algorithm - returns algorithm verifier is created with
+func (v *commandVerifier) Algorithm() string {
+	return v.algorithm
+}
+
+// Verify - runs command for digest and signature
+func (v *commandVerifier) Verify(ctx context.Context, digest, signature []byte) error {
+	file, err := os.CreateTemp("", "fsstore-signature-*")
+	if err != nil {
+		log.Printf("err: creating signature file failed: %v\n", err)
+		return ErrSignatureInvalid
+	}
+	defer os.Remove(file.Name())
+	_, err = file.Write(signature)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		log.Printf("err: writing signature file failed: %s, %v\n", file.Name(), err)
+		return ErrSignatureInvalid
+	}
+	args := make([]string, len(v.args))
+	for i, arg := range v.args {
+		args[i] = strings.ReplaceAll(arg, _signaturePlaceholder, file.Name())
+	}
+	cmd := exec.CommandContext(ctx, v.name, args...)
+	cmd.Stdin = strings.NewReader(hex.EncodeToString(digest))
+	if err := cmd.Run(); err != nil {
+		return ErrSignatureInvalid
+	}
+	return nil
+}
+
+// sign - returns signature of SHA-256 digest by configured signer, formatted as algorithm and base64 signature
+// separated by `:`; empty when store signs nothing
+func (f *fsObjectStoreService) sign(ctx context.Context, digest []byte) (string, error) {
+	if f.signer == nil {
+		return "", nil
+	}
+	signature, err := f.signer.Sign(ctx, digest)
+	if err != nil {
+		if ctxErr := checkContextError(ctx, f.isDebug()); ctxErr != nil {
+			return "", ctxErr
+		}
+		log.Printf("err: signing failed: %s, %v\n", f.bucket, err)
+		return "", ErrSigningFailed
+	}
+	return f.signer.Algorithm() + ":" + base64.StdEncoding.EncodeToString(signature), nil
+}
+
+// verifySignature - checks formatted signature (see `sign`) of digest via verifier of same algorithm
+func verifySignature(ctx context.Context, v SignatureVerifier, digest []byte, signature string) error {
+	i := strings.Index(signature, ":")
+	if i < 0 || signature[:i] != v.Algorithm() {
+		return ErrSignatureInvalid
+	}
+	decoded, err := base64.StdEncoding.DecodeString(signature[i+1:])
+	if err != nil {
+		return ErrSignatureInvalid
+	}
+	return v.Verify(ctx, digest, decoded)
+}
+
+// digestOf - returns SHA-256 digest of data
+func digestOf(data []byte) []byte {
+	sum := sha256.Sum256(data)
+	return sum[:]
+}
@@ -0,0 +1,224 @@
+package fsstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipld/go-ipld-prime/datamodel"
+	"github.com/ipld/go-ipld-prime/fluent/qp"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+	"github.com/ipld/go-ipld-prime/node/basicnode"
+	"github.com/multiformats/go-multicodec"
+)
+
+// ErrSnapshotFailed is return, when writing bucket snapshot failed.
+var ErrSnapshotFailed = errors.New("fsobjectstore: bucket snapshot failed")
+
+// _snapshotsFile handles the internal file name recording taken bucket snapshots
+const _snapshotsFile = "snapshots"
+
+// Snapshot captures information of a bucket snapshot manifest. `Signature` signs manifest cid for stores signing
+// snapshots (see `WithSigner`), see `VerifySnapshot`.
+type Snapshot struct {
+	Cid       cid.Cid
+	Created   time.Time
+	Signature string
+}
+
+// BucketSnapshotter defines the functions clients need to capture point in time manifests of bucket.
+type BucketSnapshotter interface {
+	SnapshotBucket(context.Context) (cid.Cid, error)
+	Snapshots(context.Context) ([]Snapshot, error)
+}
+
+var _ BucketSnapshotter = (*fsObjectStoreService)(nil)
+
+// snapshotEntry captures an object listed in snapshot manifest
+type snapshotEntry struct {
+	cid  cid.Cid
+	size int64
+}
+
+// SnapshotBucket - writes a dag-cbor manifest object listing every object cid (and size) of bucket,
+// and records it as bucket snapshot. Manifest of previous snapshots are not listed.
+func (f *fsObjectStoreService) SnapshotBucket(ctx context.Context) (cid.Cid, error) {
+	if err := f.authorize(ctx, OpAdmin, cid.Undef); err != nil {
+		return cid.Undef, err
+	}
+	digest, err := f.snapshotBucket(withSystem(ctx))
+	f.audit(ctx, OpAdmin, digest, err)
+	return digest, err
+}
+
+// snapshotBucket - writes manifest of bucket objects, and records it as snapshot
+func (f *fsObjectStoreService) snapshotBucket(ctx context.Context) (cid.Cid, error) {
+	if err := f.ensureBucket(); err != nil {
+		return cid.Undef, err
+	}
+	f.snapMu.Lock()
+	defer f.snapMu.Unlock()
+
+	previous, err := f.readSnapshots()
+	if err != nil {
+		return cid.Undef, err
+	}
+	skip := make(map[string]struct{}, len(previous))
+	for _, snap := range previous {
+		skip[snap.Cid.String()] = struct{}{}
+	}
+
+	entries := []snapshotEntry{}
+	err = f.walkObjects(ctx, func(c cid.Cid, path string, info os.FileInfo) error {
+		if _, ok := skip[c.String()]; !ok {
+			entries = append(entries, snapshotEntry{cid: c, size: info.Size()})
+		}
+		return nil
+	})
+	if err != nil {
+		if ctxErr := checkContextError(ctx, f.isDebug()); ctxErr != nil {
+			return cid.Undef, ctxErr
+		}
+		log.Printf("err: walking bucket for snapshot failed: %s, %v\n", f.bucket, err)
+		return cid.Undef, ErrSnapshotFailed
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].cid.String() < entries[j].cid.String()
+	})
+
+	created := f.now().UTC()
+	manifest, err := qp.BuildMap(basicnode.Prototype.Any, 3, func(ma datamodel.MapAssembler) {
+		qp.MapEntry(ma, "bucket", qp.String(f.bucket))
+		qp.MapEntry(ma, "created", qp.Int(created.Unix()))
+		qp.MapEntry(ma, "objects", qp.List(int64(len(entries)), func(la datamodel.ListAssembler) {
+			for _, entry := range entries {
+				qp.ListEntry(la, qp.Map(2, func(ma datamodel.MapAssembler) {
+					qp.MapEntry(ma, "cid", qp.Link(cidlink.Link{Cid: entry.cid}))
+					qp.MapEntry(ma, "size", qp.Int(entry.size))
+				}))
+			}
+		}))
+	})
+	if err != nil {
+		log.Printf("err: building snapshot manifest failed: %s, %v\n", f.bucket, err)
+		return cid.Undef, ErrSnapshotFailed
+	}
+
+	digest, err := f.CreateNode(withoutScan(ctx), manifest, multicodec.DagCbor)
+	if err != nil {
+		return cid.Undef, err
+	}
+	if n := len(previous); n > 0 && previous[n-1].Cid.Equals(digest) {
+		return digest, nil
+	}
+	signature, err := f.sign(ctx, digestOf(digest.Bytes()))
+	if err != nil {
+		return cid.Undef, err
+	}
+	if err := f.appendSnapshot(Snapshot{Cid: digest, Created: created, Signature: signature}); err != nil {
+		return cid.Undef, err
+	}
+	if f.isDebug() {
+		log.Printf("debug: snapshot bucket: %s, %s, %d objects\n", f.bucket, digest, len(entries))
+	}
+	return digest, nil
+}
+
+// Snapshots - returns recorded bucket snapshots, oldest first
+func (f *fsObjectStoreService) Snapshots(ctx context.Context) ([]Snapshot, error) {
+	if err := f.authorize(ctx, OpAdmin, cid.Undef); err != nil {
+		return nil, err
+	}
+	if ctxErr := checkContextError(ctx, f.isDebug()); ctxErr != nil {
+		return nil, ctxErr
+	}
+	f.snapMu.Lock()
+	defer f.snapMu.Unlock()
+	return f.readSnapshots()
+}
+
+// readSnapshots - reads recorded bucket snapshots, caller must hold snapMu
+func (f *fsObjectStoreService) readSnapshots() ([]Snapshot, error) {
+	snapLink := f.internalPath(_snapshotsFile)
+	if !exists(snapLink) {
+		return nil, nil
+	}
+	data, err := read(snapLink)
+	if err != nil {
+		return nil, err
+	}
+	ret := []Snapshot{}
+	for _, framed := range strings.Split(string(data), "\n") {
+		record, err := unframeLine([]byte(framed), f.legacyFrames)
+		if err != nil {
+			log.Printf("err: snapshot record checksum mismatch: %s\n", framed)
+			continue
+		}
+		line := string(record)
+		fields := strings.Fields(line)
+		if len(fields) != 2 && len(fields) != 3 {
+			continue
+		}
+		c, err := cid.Decode(fields[0])
+		if err != nil {
+			log.Printf("err: decoding snapshot record failed: %s, %v\n", line, err)
+			continue
+		}
+		created, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			log.Printf("err: decoding snapshot record failed: %s, %v\n", line, err)
+			continue
+		}
+		snap := Snapshot{Cid: c, Created: time.Unix(created, 0).UTC()}
+		if len(fields) == 3 {
+			snap.Signature = fields[2]
+		}
+		ret = append(ret, snap)
+	}
+	return ret, nil
+}
+
+// snapshotRecord - returns checksummed record line of snapshot
+func snapshotRecord(snap Snapshot) []byte {
+	record := fmt.Sprintf("%s %d", snap.Cid, snap.Created.Unix())
+	if len(snap.Signature) > 0 {
+		record += " " + snap.Signature
+	}
+	return append(frameLine([]byte(record)), '\n')
+}
+
+// VerifySnapshot - checks whether snapshot is signed (see `WithSigner`) by signer of verifier. Signature covers
+// snapshot cid, which in turn addresses content of manifest
+func VerifySnapshot(ctx context.Context, snap Snapshot, v SignatureVerifier) error {
+	if len(snap.Signature) == 0 {
+		return ErrSignatureInvalid
+	}
+	return verifySignature(ctx, v, digestOf(snap.Cid.Bytes()), snap.Signature)
+}
+
+// appendSnapshot - appends snapshot record, caller must hold snapMu
+func (f *fsObjectStoreService) appendSnapshot(snap Snapshot) error {
+	snapLink := f.internalPath(_snapshotsFile)
+	if err := os.MkdirAll(f.internalPath(), 0777); err != nil {
+		log.Printf("err: creating internal directory failed: %s, %v\n", snapLink, err)
+		return ErrSnapshotFailed
+	}
+	file, err := os.OpenFile(snapLink, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0666)
+	if err != nil {
+		log.Printf("err: opening snapshot records failed: %s, %v\n", snapLink, err)
+		return ErrSnapshotFailed
+	}
+	defer file.Close()
+	if _, err := file.Write(snapshotRecord(snap)); err != nil {
+		log.Printf("err: writing snapshot record failed: %s, %v\n", snapLink, err)
+		return ErrSnapshotFailed
+	}
+	return nil
+}
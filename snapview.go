@@ -0,0 +1,207 @@
+package fsstore
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/igumus/go-objectstore-lib"
+	"github.com/ipfs/go-cid"
+)
+
+// ErrSnapshotClosed is return, when a closed snapshot view is read.
+var ErrSnapshotClosed = errors.New("fsobjectstore: snapshot view closed")
+
+// ReadOnlyStore defines the functions clients need to read a fixed set of objects, such as a snapshot view.
+type ReadOnlyStore interface {
+	HasObject(context.Context, cid.Cid) bool
+	ReadObject(context.Context, cid.Cid) ([]byte, error)
+	ListObject(context.Context) <-chan objectstore.ListObjectEvent
+	Close() error
+}
+
+// SnapshotViewer defines the functions clients need to read bucket as of a point in time.
+type SnapshotViewer interface {
+	Snapshot(context.Context) (ReadOnlyStore, error)
+}
+
+var _ SnapshotViewer = (*fsObjectStoreService)(nil)
+
+// snapshotView serves objects of bucket captured when view is taken. Captured objects are pinned while view
+// is open: garbage collection and trash purge keep them, and objects deleted meanwhile are read from trash.
+type snapshotView struct {
+	f       *fsObjectStoreService
+	mu      sync.RWMutex
+	objects map[string]cid.Cid
+	closed  bool
+}
+
+var _ ReadOnlyStore = (*snapshotView)(nil)
+
+// views tracks open snapshot views of store
+type views struct {
+	mu   sync.Mutex
+	open map[*snapshotView]struct{}
+}
+
+// Snapshot - captures object set of bucket, returning view reading exactly that set however bucket changes
+// afterwards. Views must be closed, since objects they pin are not reclaimed until then.
+func (f *fsObjectStoreService) Snapshot(ctx context.Context) (ReadOnlyStore, error) {
+	if err := f.authorize(ctx, OpList, cid.Undef); err != nil {
+		return nil, err
+	}
+	view, err := f.snapshot(withSystem(ctx))
+	f.audit(ctx, OpList, cid.Undef, err)
+	if err != nil {
+		return nil, err
+	}
+	return view, nil
+}
+
+// snapshot - registers view before walking bucket, so objects are pinned as they are captured
+func (f *fsObjectStoreService) snapshot(ctx context.Context) (*snapshotView, error) {
+	view := &snapshotView{f: f, objects: map[string]cid.Cid{}}
+	f.views.mu.Lock()
+	f.views.open[view] = struct{}{}
+	f.views.mu.Unlock()
+
+	err := f.walkObjects(ctx, func(c cid.Cid, path string, info os.FileInfo) error {
+		view.mu.Lock()
+		view.objects[c.String()] = c
+		view.mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		view.Close()
+		if ctxErr := checkContextError(ctx, f.isDebug()); ctxErr != nil {
+			return nil, ctxErr
+		}
+		log.Printf("err: walking bucket for snapshot view failed: %s, %v\n", f.bucket, err)
+		return nil, ErrSnapshotFailed
+	}
+	if f.isDebug() {
+		log.Printf("debug: snapshot view taken: %s, %d objects\n", f.bucket, len(view.objects))
+	}
+	return view, nil
+}
+
+// pinned - checks whether an open snapshot view captured object with specified cid
+func (f *fsObjectStoreService) pinned(c cid.Cid) bool {
+	f.views.mu.Lock()
+	defer f.views.mu.Unlock()
+	key := c.String()
+	for view := range f.views.open {
+		if view.contains(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// contains - checks whether view captured object with specified key
+func (v *snapshotView) contains(key string) bool {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	_, ok := v.objects[key]
+	return ok && !v.closed
+}
+
+// HasObject - checks whether view captured object with specified cid
+func (v *snapshotView) HasObject(ctx context.Context, c cid.Cid) bool {
+	if v.f.authorize(ctx, OpRead, c) != nil {
+		return false
+	}
+	ret := v.contains(c.String())
+	v.f.audit(ctx, OpRead, c, nil)
+	return ret
+}
+
+// ReadObject - reads captured object with specified cid, from trash when object is deleted from bucket since
+func (v *snapshotView) ReadObject(ctx context.Context, c cid.Cid) ([]byte, error) {
+	if err := v.f.authorize(ctx, OpRead, c); err != nil {
+		return nil, err
+	}
+	data, err := v.read(withSystem(ctx), c)
+	v.f.audit(ctx, OpRead, c, err)
+	return data, err
+}
+
+// read - reads captured object from bucket, falling back to trash of every stripe
+func (v *snapshotView) read(ctx context.Context, c cid.Cid) ([]byte, error) {
+	v.mu.RLock()
+	_, ok := v.objects[c.String()]
+	closed := v.closed
+	v.mu.RUnlock()
+	if closed {
+		return nil, ErrSnapshotClosed
+	}
+	if !ok {
+		return nil, objectstore.ErrObjectNotExists
+	}
+	data, err := v.f.load(ctx, v.f.objectPath(c))
+	if !errors.Is(err, objectstore.ErrObjectNotExists) {
+		return data, err
+	}
+	for _, stripe := range v.f.stripeDirs() {
+		data, err = v.f.load(ctx, v.f.trashPath(stripe, c))
+		if !errors.Is(err, objectstore.ErrObjectNotExists) {
+			return data, err
+		}
+	}
+	log.Printf("err: captured object of snapshot view missing: %s\n", c)
+	return nil, err
+}
+
+// ListObject - lists captured objects, in cid order
+func (v *snapshotView) ListObject(ctx context.Context) <-chan objectstore.ListObjectEvent {
+	ch := make(chan objectstore.ListObjectEvent, v.f.listBuffer)
+
+	go func() {
+		defer close(ch)
+		send := func(event objectstore.ListObjectEvent) bool {
+			select {
+			case ch <- event:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		if err := v.f.authorize(ctx, OpList, cid.Undef); err != nil {
+			send(objectstore.ListObjectEvent{Error: err})
+			return
+		}
+		v.mu.RLock()
+		closed := v.closed
+		keys := make([]string, 0, len(v.objects))
+		for key := range v.objects {
+			keys = append(keys, key)
+		}
+		v.mu.RUnlock()
+		v.f.audit(ctx, OpList, cid.Undef, nil)
+		if closed {
+			send(objectstore.ListObjectEvent{Error: ErrSnapshotClosed})
+			return
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if !send(objectstore.ListObjectEvent{Object: key}) {
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+// Close - releases view, unpinning objects it captured
+func (v *snapshotView) Close() error {
+	v.f.views.mu.Lock()
+	delete(v.f.views.open, v)
+	v.f.views.mu.Unlock()
+	v.mu.Lock()
+	v.closed = true
+	v.mu.Unlock()
+	return nil
+}
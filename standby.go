@@ -0,0 +1,247 @@
+package fsstore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/igumus/go-objectstore-lib"
+	"github.com/ipfs/go-cid"
+)
+
+// ErrStandbyReadOnly is return, when a client writes to a standby store not yet promoted.
+var ErrStandbyReadOnly = errors.New("fsobjectstore: standby store is read only")
+
+// ErrNotStandby is return, when promoting a store not configured as standby (or already promoted).
+var ErrNotStandby = errors.New("fsobjectstore: store is not a standby")
+
+// _standbyFile handles the internal file name persisting sequence of last applied primary journal entry
+const _standbyFile = "standby"
+
+// _defStandbyPoll handles the default interval standby polls primary journal, once caught up
+const _defStandbyPoll = time.Second
+
+// _standbyBatch handles the number of journal entries standby fetches per poll
+const _standbyBatch = 256
+
+// StandbySource defines the functions a primary store (e.g. an `httpstore` client of primary gateway) provides
+// for a standby to follow it: its journal, and content of journaled objects.
+type StandbySource interface {
+	objectstore.ObjectStore
+	JournalReader
+}
+
+// StandbyStatus captures replication state of a standby store
+type StandbyStatus struct {
+	Following bool
+	Applied   uint64
+	Synced    time.Time
+	Err       string
+}
+
+// Standby defines the functions clients need to observe and promote a standby store.
+type Standby interface {
+	StandbyStatus() StandbyStatus
+	Promote(context.Context) error
+}
+
+var _ Standby = (*fsObjectStoreService)(nil)
+
+// standby follows journal of primary store, applying its operations to local store
+type standby struct {
+	source StandbySource
+	poll   time.Duration
+	cancel context.CancelFunc
+	done   chan struct{}
+	mu     sync.Mutex
+	status StandbyStatus
+}
+
+// startStandby - resumes following primary from last applied journal entry in background
+func (f *fsObjectStoreService) startStandby(source StandbySource, poll time.Duration) error {
+	applied := uint64(0)
+	if exists(f.internalPath(_standbyFile)) {
+		data, err := f.readInternal(f.internalPath(_standbyFile))
+		switch {
+		case errors.Is(err, ErrInternalCorrupted):
+			// applying primary journal is idempotent, so position is rebuilt by following it from start
+			log.Printf("warn: standby position corrupted, following primary from start: %s\n", f.bucket)
+		case err != nil:
+			return err
+		default:
+			if applied, err = strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64); err != nil {
+				log.Printf("err: decoding standby position failed: %s, %v\n", f.bucket, err)
+				return ErrJournalReadingFailed
+			}
+		}
+	}
+	ctx, cancel := context.WithCancel(f.bgCtx)
+	s := &standby{source: source, poll: poll, cancel: cancel, done: make(chan struct{})}
+	s.status = StandbyStatus{Following: true, Applied: applied}
+	f.standby = s
+	f.background(func(context.Context) {
+		defer close(s.done)
+		f.follow(ctx, s)
+	})
+	return nil
+}
+
+// follow - polls primary journal and applies new entries, until context is done
+func (f *fsObjectStoreService) follow(ctx context.Context, s *standby) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+		n, err := f.syncStandby(ctx, s)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			log.Printf("err: following primary failed: %s, %v\n", f.bucket, err)
+		}
+		wait := s.poll
+		if n == _standbyBatch {
+			// more entries are pending, keep catching up
+			wait = 0
+		}
+		timer.Reset(wait)
+	}
+}
+
+// syncStandby - fetches one batch of primary journal entries and applies them, returning number applied
+func (f *fsObjectStoreService) syncStandby(ctx context.Context, s *standby) (int, error) {
+	s.mu.Lock()
+	applied := s.status.Applied
+	s.mu.Unlock()
+
+	entries, err := s.source.ReadJournal(ctx, applied, _standbyBatch)
+	count := 0
+	if err == nil {
+		for _, entry := range entries {
+			if err = f.applyEntry(withSystem(ctx), s, entry); err != nil {
+				break
+			}
+			applied = entry.Seq
+			count++
+		}
+		if count > 0 {
+			if werr := f.writeInternal(f.internalPath(_standbyFile), []byte(strconv.FormatUint(applied, 10)+"\n")); werr != nil && err == nil {
+				err = werr
+			}
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status.Applied = applied
+	s.status.Err = ""
+	if err != nil {
+		s.status.Err = err.Error()
+	} else {
+		s.status.Synced = f.now()
+	}
+	if f.isDebug() && count > 0 {
+		log.Printf("debug: standby applied primary journal: %s, %d entries, up to %d\n", f.bucket, count, applied)
+	}
+	return count, err
+}
+
+// applyEntry - applies primary journal entry to local store; created objects are fetched from primary and
+// verified against their cid, deletions (by client or garbage collection) are applied as deletions
+func (f *fsObjectStoreService) applyEntry(ctx context.Context, s *standby, entry JournalEntry) error {
+	switch entry.Op {
+	case JournalCreate:
+		if f.has(entry.Cid) {
+			return nil
+		}
+		data, err := s.source.ReadObject(ctx, entry.Cid)
+		if errors.Is(err, objectstore.ErrObjectNotExists) {
+			// object deleted on primary since, a later entry records deletion
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return f.putObject(ctx, entry.Cid, bytes.NewReader(data))
+	case JournalDelete, JournalGC:
+		err := f.deleteObject(ctx, entry.Cid)
+		if errors.Is(err, objectstore.ErrObjectNotExists) || errors.Is(err, ErrObjectReferenced) {
+			if f.isDebug() {
+				log.Printf("debug: standby skipped deletion: %s, %v\n", entry.Cid, err)
+			}
+			return nil
+		}
+		return err
+	default:
+		return nil
+	}
+}
+
+// isStandby - checks whether store follows a primary, rejecting client writes
+func (f *fsObjectStoreService) isStandby() bool {
+	if f.standby == nil {
+		return false
+	}
+	f.standby.mu.Lock()
+	defer f.standby.mu.Unlock()
+	return f.standby.status.Following
+}
+
+// StandbyStatus - returns replication state of standby store; zero status is returned for other stores
+func (f *fsObjectStoreService) StandbyStatus() StandbyStatus {
+	if f.standby == nil {
+		return StandbyStatus{}
+	}
+	f.standby.mu.Lock()
+	defer f.standby.mu.Unlock()
+	return f.standby.status
+}
+
+// Promote - stops following primary and makes standby store writable. Journal entries primary still serves
+// are applied first, on best effort basis, since primary may be gone. Promotion lasts until store is reopened,
+// so promoted store should be reopened without `WithStandby`.
+func (f *fsObjectStoreService) Promote(ctx context.Context) error {
+	if err := f.authorize(ctx, OpAdmin, cid.Undef); err != nil {
+		return err
+	}
+	err := f.promote(ctx)
+	f.audit(ctx, OpAdmin, cid.Undef, err)
+	return err
+}
+
+// promote - stops background following, catches up with primary as far as it answers, and flips store writable
+func (f *fsObjectStoreService) promote(ctx context.Context) error {
+	if !f.isStandby() {
+		return ErrNotStandby
+	}
+	s := f.standby
+	s.cancel()
+	<-s.done
+	for {
+		n, err := f.syncStandby(ctx, s)
+		if err != nil {
+			log.Printf("warn: promoting without catching up with primary: %s, %v\n", f.bucket, err)
+			break
+		}
+		if n < _standbyBatch {
+			break
+		}
+	}
+	s.mu.Lock()
+	s.status.Following = false
+	applied := s.status.Applied
+	s.mu.Unlock()
+	if f.isDebug() {
+		log.Printf("debug: standby promoted: %s, applied primary journal up to %d\n", f.bucket, applied)
+	}
+	return nil
+}
@@ -0,0 +1,348 @@
+package fsstore
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-cid"
+)
+
+// _sizeClasses handles the exclusive upper bounds of object size histogram classes; objects not below
+// last bound fall in a final unbounded class
+var _sizeClasses = []int64{4 << 10, 64 << 10, 1 << 20, 16 << 20}
+
+// SizeClass captures count and total bytes of objects within a size histogram class. Objects of class
+// are smaller than Below, Below is `0` for the final unbounded class.
+type SizeClass struct {
+	Below int64
+	Count int64
+	Bytes int64
+}
+
+// Stats captures object statistics of bucket, along with count of created objects which already existed and
+// health of data directories (see `DataDirHealth`)
+type Stats struct {
+	Objects   int64
+	Bytes     int64
+	DedupHits int64
+	Sizes     []SizeClass
+	DataDirs  []DataDirHealth
+}
+
+// StatsReporter defines the functions clients need to observe object statistics of bucket.
+type StatsReporter interface {
+	Stats(context.Context) (*Stats, error)
+}
+
+var _ StatsReporter = (*fsObjectStoreService)(nil)
+
+// stats maintains object size histogram (and cid index, see `WithCIDIndex`) incrementally, once loaded from an
+// initial walk of bucket (or from checkpoint, see `WithStatsCheckpointInterval`)
+type stats struct {
+	mu       sync.Mutex
+	loaded   bool
+	dirty    bool
+	seq      uint64
+	dedup    int64
+	counts   []int64
+	bytes    []int64
+	indexing bool
+	indexed  bool
+	index    map[string]cid.Cid
+}
+
+// newStats - creates empty, not yet loaded statistics, maintaining cid index when indexing
+func newStats(indexing bool) *stats {
+	return &stats{
+		counts:   make([]int64, len(_sizeClasses)+1),
+		bytes:    make([]int64, len(_sizeClasses)+1),
+		indexing: indexing,
+	}
+}
+
+// sizeClass - returns histogram class index of object size
+func sizeClass(size int64) int {
+	for i, below := range _sizeClasses {
+		if size < below {
+			return i
+		}
+	}
+	return len(_sizeClasses)
+}
+
+// record - accounts created or deleted object, ignored until statistics (or index) are loaded; requires s.mu held
+func (s *stats) record(op JournalOp, c cid.Cid, size int64) {
+	if s.indexed {
+		if op == JournalCreate {
+			s.index[c.String()] = c
+		} else {
+			delete(s.index, c.String())
+		}
+		s.dirty = true
+	}
+	if !s.loaded {
+		return
+	}
+	delta := int64(1)
+	if op != JournalCreate {
+		delta = -1
+	}
+	class := sizeClass(size)
+	s.counts[class] += delta
+	s.bytes[class] += delta * size
+	s.dirty = true
+}
+
+// deduplicated - accounts created object which already existed
+func (s *stats) deduplicated() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dedup++
+	s.dirty = true
+}
+
+// Stats - returns object count and size histogram of bucket. Unless statistics are recovered from checkpoint
+// when store is opened, first call walks bucket to load them, blocking writers meanwhile; afterwards statistics
+// are maintained as objects are created and deleted.
+func (f *fsObjectStoreService) Stats(ctx context.Context) (*Stats, error) {
+	if err := f.authorize(ctx, OpAdmin, cid.Undef); err != nil {
+		return nil, err
+	}
+	s := f.stats
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.loaded {
+		if err := f.loadWalk(ctx, s); err != nil {
+			return nil, err
+		}
+	}
+
+	report := &Stats{DedupHits: s.dedup, Sizes: make([]SizeClass, len(s.counts)), DataDirs: f.DataDirHealth()}
+	for i := range s.counts {
+		if i < len(_sizeClasses) {
+			report.Sizes[i].Below = _sizeClasses[i]
+		}
+		report.Sizes[i].Count = s.counts[i]
+		report.Sizes[i].Bytes = s.bytes[i]
+		report.Objects += s.counts[i]
+		report.Bytes += s.bytes[i]
+	}
+	return report, nil
+}
+
+// loadWalk - loads statistics (and cid index when indexing but not indexed yet) by walking bucket; requires s.mu held
+func (f *fsObjectStoreService) loadWalk(ctx context.Context, s *stats) error {
+	counting, indexing := !s.loaded, s.indexing && !s.indexed
+	index := map[string]cid.Cid{}
+	err := f.walkObjects(ctx, func(c cid.Cid, path string, info os.FileInfo) error {
+		if counting {
+			size := f.objectSize(path, info.Size())
+			class := sizeClass(size)
+			s.counts[class]++
+			s.bytes[class] += size
+		}
+		if indexing {
+			index[c.String()] = c
+		}
+		return nil
+	})
+	if err != nil {
+		if counting {
+			for i := range s.counts {
+				s.counts[i], s.bytes[i] = 0, 0
+			}
+		}
+		if ctxErr := checkContextError(ctx, f.isDebug()); ctxErr != nil {
+			return ctxErr
+		}
+		log.Printf("err: loading stats failed: %s, %v\n", f.bucket, err)
+		return err
+	}
+	if counting {
+		s.loaded = true
+	}
+	if indexing {
+		s.index, s.indexed = index, true
+	}
+	s.dirty = true
+	return nil
+}
+
+// _statsFile handles the internal file name of statistics checkpoint
+const _statsFile = "stats"
+
+// _defStatsCheckpoint handles the default interval changed statistics are checkpointed at
+const _defStatsCheckpoint = time.Minute
+
+// statsCheckpoint captures persisted statistics, along with journal sequence they account operations up to.
+// Clean checkpoints are written when store is closed; without a journal, only those are trusted when reopened.
+type statsCheckpoint struct {
+	Journal bool     `json:"journal"`
+	Seq     uint64   `json:"seq"`
+	Clean   bool     `json:"clean"`
+	Loaded  bool     `json:"loaded"`
+	Classes []int64  `json:"classes"`
+	Counts  []int64  `json:"counts"`
+	Bytes   []int64  `json:"bytes"`
+	Dedup   int64    `json:"dedup"`
+	Indexed bool     `json:"indexed,omitempty"`
+	Index   []string `json:"index,omitempty"`
+}
+
+// loadStats - recovers statistics from checkpoint, replaying operations journaled after it, so `Stats` needs
+// no walk of bucket. Checkpoints not matching journal (or recorded without journal, and not clean) are ignored.
+func (f *fsObjectStoreService) loadStats() {
+	path := f.internalPath(_statsFile)
+	if !exists(path) {
+		return
+	}
+	data, err := f.readInternal(path)
+	if err != nil {
+		log.Printf("warn: reading stats checkpoint failed, stats are reloaded: %s, %v\n", path, err)
+		return
+	}
+	cp := statsCheckpoint{}
+	if err := json.Unmarshal(data, &cp); err != nil || !cp.valid() {
+		log.Printf("warn: decoding stats checkpoint failed, stats are reloaded: %s\n", path)
+		return
+	}
+
+	s := f.stats
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dedup = cp.Dedup
+	trusted := cp.Clean
+	switch {
+	case f.journal == nil:
+	case !cp.Journal:
+		// entries already journaled predate statistics of checkpoint
+		s.seq = f.journal.seq
+	case f.journal.seq < cp.Seq:
+		log.Printf("warn: journal behind stats checkpoint, stats are reloaded: %s\n", path)
+		trusted = false
+	default:
+		trusted = true
+	}
+	if !trusted {
+		if f.isDebug() {
+			log.Printf("debug: stats checkpoint not trusted, stats are reloaded: %s\n", path)
+		}
+		return
+	}
+	if cp.Loaded {
+		copy(s.counts, cp.Counts)
+		copy(s.bytes, cp.Bytes)
+		s.loaded = true
+	}
+	if s.indexing && cp.Indexed {
+		if s.index, err = decodeIndex(cp.Index); err != nil {
+			log.Printf("warn: decoding cid index failed, index is reloaded: %s, %v\n", path, err)
+		}
+		s.indexed = err == nil
+	}
+	if f.journal != nil && cp.Journal {
+		s.seq = cp.Seq
+		replayed := 0
+		err := scanJournal(f.journal.path, f.journal.legacy, func(rec journalRecord) bool {
+			if rec.Seq > cp.Seq {
+				c, _ := cid.Decode(rec.Cid)
+				s.record(rec.Op, c, rec.Size)
+				s.seq = rec.Seq
+				replayed++
+			}
+			return true
+		})
+		if err != nil {
+			log.Printf("warn: replaying journal into stats failed, stats are reloaded: %s, %v\n", path, err)
+			s.loaded, s.indexed, s.index = false, false, nil
+			for i := range s.counts {
+				s.counts[i], s.bytes[i] = 0, 0
+			}
+			return
+		}
+		if f.isDebug() {
+			log.Printf("debug: stats recovered: %s, %d journal entries replayed\n", path, replayed)
+		}
+	}
+	// checkpoint is marked in use, so a crash before next checkpoint does not leave it trusted
+	if err := f.writeStats(s, false); err != nil && f.isDebug() {
+		log.Printf("debug: marking stats checkpoint in use failed: %s, %v\n", path, err)
+	}
+}
+
+// valid - checks whether checkpoint is recorded with current size classes
+func (cp statsCheckpoint) valid() bool {
+	if len(cp.Classes) != len(_sizeClasses) || len(cp.Counts) != len(_sizeClasses)+1 || len(cp.Bytes) != len(cp.Counts) {
+		return false
+	}
+	for i, below := range _sizeClasses {
+		if cp.Classes[i] != below {
+			return false
+		}
+	}
+	return true
+}
+
+// checkpointStats - persists loaded statistics when they changed since last checkpoint (or unconditionally
+// when clean, as store is closing)
+func (f *fsObjectStoreService) checkpointStats(clean bool) {
+	if !f.isProvisioned() {
+		return
+	}
+	s := f.stats
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.dirty && !(clean && (s.loaded || s.indexed)) {
+		return
+	}
+	if err := f.writeStats(s, clean); err != nil {
+		log.Printf("err: writing stats checkpoint failed: %s, %v\n", f.bucket, err)
+		return
+	}
+	s.dirty = false
+}
+
+// writeStats - writes checkpoint of statistics; requires s.mu held
+func (f *fsObjectStoreService) writeStats(s *stats, clean bool) error {
+	cp := statsCheckpoint{Journal: f.journal != nil, Seq: s.seq, Clean: clean && (s.loaded || s.indexed),
+		Loaded: s.loaded, Classes: _sizeClasses, Counts: s.counts, Bytes: s.bytes, Dedup: s.dedup, Indexed: s.indexed}
+	if !s.loaded {
+		// unloaded statistics carry dedup hits (and index) only, counts are not recovered from them
+		cp.Counts, cp.Bytes = make([]int64, len(s.counts)), make([]int64, len(s.bytes))
+	}
+	if s.indexed {
+		cp.Index = make([]string, 0, len(s.index))
+		for key := range s.index {
+			cp.Index = append(cp.Index, key)
+		}
+		sort.Strings(cp.Index)
+	}
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	return f.writeInternal(f.internalPath(_statsFile), data)
+}
+
+// startStatsCheckpoint - checkpoints statistics in background every interval, until store is closed
+func (f *fsObjectStoreService) startStatsCheckpoint(interval time.Duration) {
+	f.background(func(ctx context.Context) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				f.checkpointStats(false)
+				f.checkpointUsage()
+				f.checkpointNegativeCache()
+			case <-ctx.Done():
+				return
+			}
+		}
+	})
+}
@@ -0,0 +1,101 @@
+package fsstore
+
+import (
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/igumus/go-objectstore-lib"
+	"github.com/ipfs/go-cid"
+)
+
+// ErrDuplicateDataDir is return, when same data directory is specified more than once.
+var ErrDuplicateDataDir = errors.New("fsobjectstore: duplicate data directory")
+
+// stripeDirs - returns bucket directories of every data directory holding objects, primary first and
+// retired ones last
+func (f *fsObjectStoreService) stripeDirs() []string {
+	if len(f.stripes) == 0 {
+		return []string{f.bucketDir()}
+	}
+	return f.stripes
+}
+
+// placement - returns index of (non retired) stripe new object with specified cid is placed on, owner of
+// cid on placement ring
+func (f *fsObjectStoreService) placement(c cid.Cid) int {
+	if f.active < 2 {
+		return 0
+	}
+	return f.ring.locate(ringKey(c))
+}
+
+// objectPath - returns file system path of object with specified cid; stripe holding object is preferred,
+// so objects placed before data directories were added stay readable, otherwise path object is placed at.
+// Empty path is returned for cids without a safe link (see `objectLink`)
+func (f *fsObjectStoreService) objectPath(c cid.Cid) string {
+	objLink := objectLink(c)
+	if len(objLink) == 0 {
+		return ""
+	}
+	if len(f.stripes) < 2 {
+		return f.path(objLink)
+	}
+	home := f.placement(c)
+	placed := filepath.Join(f.stripes[home], objLink)
+	if exists(placed) {
+		return placed
+	}
+	for i, dir := range f.stripes {
+		if i == home {
+			continue
+		}
+		if path := filepath.Join(dir, objLink); exists(path) {
+			return path
+		}
+	}
+	return placed
+}
+
+// stripeOf - returns bucket directory of stripe given path resides in
+func (f *fsObjectStoreService) stripeOf(path string) string {
+	for _, dir := range f.stripeDirs()[1:] {
+		if within(dir, path) {
+			return dir
+		}
+	}
+	return f.bucketDir()
+}
+
+// tempFor - returns staging directory for files committed to given path, which must reside on same
+// device as path for commit rename to succeed
+func (f *fsObjectStoreService) tempFor(path string) string {
+	if dir := f.stripeOf(path); dir != f.bucketDir() {
+		return filepath.Join(dir, _internalDir, _tempDir)
+	}
+	return f.tempDir
+}
+
+// place - commits staged file to objLink; file staged on another device than objLink is copied over
+func (f *fsObjectStoreService) place(file *os.File, objLink string) error {
+	tmpDir := f.tempFor(objLink)
+	if same, err := sameDevice(filepath.Dir(file.Name()), f.stripeOf(objLink)); err == nil && same {
+		return commit(file, objLink)
+	}
+	staged, err := stage(tmpDir)
+	if err != nil {
+		discard(file)
+		return err
+	}
+	if _, err := file.Seek(0, 0); err == nil {
+		_, err = staged.ReadFrom(file)
+	}
+	discard(file)
+	if err != nil {
+		discard(staged)
+		log.Printf("err: copying staged object failed: %s, %v\n", objLink, err)
+		return objectstore.ErrObjectWritingFailed
+	}
+	return commit(staged, objLink)
+}
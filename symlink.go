@@ -0,0 +1,249 @@
+package fsstore
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/igumus/go-objectstore-lib"
+)
+
+// ErrSymlinkNotAllowed is return, when a symbolic link is met in bucket while symlink policy is `SymlinkError`.
+var ErrSymlinkNotAllowed = errors.New("fsobjectstore: symbolic link not allowed")
+
+// ErrSymlinkEscapesRoot is return, when a symbolic link in bucket resolves outside of bucket directory.
+var ErrSymlinkEscapesRoot = errors.New("fsobjectstore: symbolic link escapes bucket")
+
+// SymlinkPolicy defines how symbolic links met in bucket directory are treated
+type SymlinkPolicy int
+
+const (
+	// SymlinkSkip ignores symbolic links; they are neither listed nor read
+	SymlinkSkip SymlinkPolicy = iota
+	// SymlinkFollowWithinRoot follows symbolic links resolving inside bucket directory, ignoring others
+	SymlinkFollowWithinRoot
+	// SymlinkError fails listing, verification and reads meeting a symbolic link
+	SymlinkError
+)
+
+// _defSymlinkPolicy handles the default policy of symbolic links
+const _defSymlinkPolicy = SymlinkSkip
+
+// isSymlink - checks whether file info describes a symbolic link
+func isSymlink(info os.FileInfo) bool {
+	return info.Mode()&os.ModeSymlink != 0
+}
+
+// resolvesWithin - checks whether path (symbolic links resolved) resides inside given root (symbolic links resolved)
+func resolvesWithin(root, path string) bool {
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return false
+	}
+	realPath, err := filepath.EvalSymlinks(path)
+	return err == nil && within(realRoot, realPath)
+}
+
+// walkFiles - walks regular files under bucket directory dir within IO budget of walkers (see `WithIOBudget`), skipping
+// store internals and special files (devices, pipes, sockets). Symbolic links are treated according to symlink policy; directories reached via followed links are
+// walked once, so link cycles terminate.
+func (f *fsObjectStoreService) walkFiles(ctx context.Context, dir string, fn func(path string, info os.FileInfo) error) error {
+	return f.walkFilesWith(ctx, dir, fn, nil)
+}
+
+// walkFilesWith - walks files as `walkFiles` does, passing errors of unreadable entries (and of disallowed links)
+// to onErr unless nil; onErr returns error aborting walk, or nil skipping entry
+func (f *fsObjectStoreService) walkFilesWith(ctx context.Context, dir string, fn func(path string, info os.FileInfo) error, onErr func(path string, err error) error) error {
+	fail := func(path string, err error) error {
+		if onErr == nil {
+			return err
+		}
+		return onErr(path, err)
+	}
+	if !f.isProvisioned() && !exists(dir) {
+		// stripes of lazily initialized store appear once it is provisioned
+		return nil
+	}
+	defer f.io.lower(f.isDebug())()
+	visited := map[string]struct{}{}
+	var walk func(root string) error
+	walk = func(root string) error {
+		if real, err := filepath.EvalSymlinks(root); err == nil {
+			if _, ok := visited[real]; ok {
+				return nil
+			}
+			visited[real] = struct{}{}
+		}
+		return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if ctxErr := checkContextError(ctx, f.isDebug()); ctxErr != nil {
+				return ctxErr
+			}
+			if err := f.io.wait(ctx, 1, 0); err != nil {
+				return err
+			}
+			if err != nil {
+				return fail(path, err)
+			}
+			if info.IsDir() && f.isInternal(path) {
+				return filepath.SkipDir
+			}
+			if isSymlink(info) {
+				target, err := f.followSymlink(dir, path)
+				if err != nil {
+					return fail(path, err)
+				}
+				if target == nil {
+					return nil
+				}
+				if target.IsDir() {
+					// trailing separator makes walk descend into link target
+					return walk(path + string(filepath.Separator))
+				}
+				info = target
+			}
+			if !info.Mode().IsRegular() {
+				if !info.IsDir() && f.isDebug() {
+					log.Printf("debug: skipping special file: %s, %s\n", path, info.Mode())
+				}
+				return nil
+			}
+			return fn(path, info)
+		})
+	}
+	return walk(dir)
+}
+
+// followSymlink - applies symlink policy to symbolic link at path under root, returning info of link target
+// when link is followed, nil when it is skipped
+func (f *fsObjectStoreService) followSymlink(root, path string) (os.FileInfo, error) {
+	switch f.symlinks {
+	case SymlinkError:
+		log.Printf("err: symbolic link in bucket: %s\n", path)
+		return nil, ErrSymlinkNotAllowed
+	case SymlinkFollowWithinRoot:
+		if !resolvesWithin(root, path) {
+			log.Printf("warn: skipping symbolic link escaping bucket: %s\n", path)
+			return nil, nil
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			if f.isDebug() {
+				log.Printf("debug: skipping dangling symbolic link: %s\n", path)
+			}
+			return nil, nil
+		}
+		return info, nil
+	default:
+		if f.isDebug() {
+			log.Printf("debug: skipping symbolic link: %s\n", path)
+		}
+		return nil, nil
+	}
+}
+
+// errLinked is return, when object file can not be opened directly since a symbolic link is met on its path
+var errLinked = errors.New("fsobjectstore: object path linked")
+
+// openObjectFile - opens object file at path for reading, applying symlink policy. Under default policy file is
+// opened directly, not following a link as its final component, and path is checked (see `guardObjectFile`) only
+// when open meets a link; other policies check path before it is opened. Opened file is checked to be a regular
+// one, so special files are never read
+func (f *fsObjectStoreService) openObjectFile(path string) (*os.File, error) {
+	file, err := (*os.File)(nil), errLinked
+	if f.symlinks == _defSymlinkPolicy {
+		file, err = openNoFollow(path)
+	}
+	if errors.Is(err, errLinked) {
+		if err := f.guardObjectFile(path); err != nil {
+			return nil, err
+		}
+		file, err = os.Open(path)
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, objectstore.ErrObjectNotExists
+	}
+	if err != nil {
+		log.Printf("err: opening object failed: %s, %v\n", path, err)
+		return nil, objectstore.ErrObjectReadingFailed
+	}
+	if info, err := file.Stat(); err != nil || !info.Mode().IsRegular() {
+		file.Close()
+		if err == nil {
+			log.Printf("err: object path is not a regular file: %s, %s\n", path, info.Mode())
+		}
+		return nil, objectstore.ErrObjectReadingFailed
+	}
+	return file, nil
+}
+
+// statObjectFile - stats object file at path, applying symlink policy as `openObjectFile` does: under default policy
+// file is stated without following a link as its final component, other policies check path first
+func (f *fsObjectStoreService) statObjectFile(path string) (os.FileInfo, error) {
+	info, err := os.FileInfo(nil), errLinked
+	if f.symlinks == _defSymlinkPolicy {
+		if info, err = os.Lstat(path); err == nil && isSymlink(info) {
+			err = errLinked
+		}
+	}
+	if errors.Is(err, errLinked) {
+		if err := f.guardObjectFile(path); err != nil {
+			return nil, err
+		}
+		info, err = os.Stat(path)
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, objectstore.ErrObjectNotExists
+	}
+	if err != nil {
+		log.Printf("err: stating object failed: %s, %v\n", path, err)
+		return nil, objectstore.ErrObjectReadingFailed
+	}
+	if !info.Mode().IsRegular() {
+		log.Printf("err: object path is not a regular file: %s, %s\n", path, info.Mode())
+		return nil, objectstore.ErrObjectReadingFailed
+	}
+	return info, nil
+}
+
+// guardObjectFile - applies symlink policy to object file at path (and directories leading to it within its
+// stripe) before it is opened, where `openObjectFile` can not open it directly; skipped links read as absent
+// objects, special files are never opened
+func (f *fsObjectStoreService) guardObjectFile(path string) error {
+	root := f.stripeOf(path)
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return nil
+	}
+	current, linked := root, false
+	for _, elem := range strings.Split(rel, string(filepath.Separator)) {
+		current = filepath.Join(current, elem)
+		info, err := os.Lstat(current)
+		if err != nil {
+			// absence is reported once file is opened
+			return nil
+		}
+		linked = linked || isSymlink(info)
+	}
+	if linked {
+		switch f.symlinks {
+		case SymlinkError:
+			log.Printf("err: symbolic link in object path: %s\n", path)
+			return ErrSymlinkNotAllowed
+		case SymlinkFollowWithinRoot:
+			if !resolvesWithin(root, path) {
+				log.Printf("err: object path escapes bucket: %s\n", path)
+				return ErrSymlinkEscapesRoot
+			}
+		default:
+			return objectstore.ErrObjectNotExists
+		}
+	}
+	if info, err := os.Stat(path); err == nil && !info.Mode().IsRegular() {
+		log.Printf("err: object path is not a regular file: %s, %s\n", path, info.Mode())
+		return objectstore.ErrObjectReadingFailed
+	}
+	return nil
+}
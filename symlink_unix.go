@@ -0,0 +1,21 @@
+//go:build !windows
+
+package fsstore
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// openNoFollow - opens file at path for reading without following a symbolic link as its final component, failing
+// with errLinked when it is one (or a file stands in place of a directory leading to it); special files are opened
+// without blocking, so they are told apart before being read
+func openNoFollow(path string) (*os.File, error) {
+	file, err := os.OpenFile(path, os.O_RDONLY|unix.O_NOFOLLOW|unix.O_NONBLOCK, 0)
+	if errors.Is(err, unix.ELOOP) || errors.Is(err, unix.ENOTDIR) {
+		return nil, errLinked
+	}
+	return file, err
+}
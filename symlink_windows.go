@@ -0,0 +1,11 @@
+//go:build windows
+
+package fsstore
+
+import "os"
+
+// openNoFollow - fails with errLinked, since files can not be opened without following links on windows, so
+// object paths are always checked before being opened
+func openNoFollow(path string) (*os.File, error) {
+	return nil, errLinked
+}
@@ -0,0 +1,229 @@
+package fsstore
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/igumus/go-objectstore-lib"
+	"github.com/ipfs/go-cid"
+)
+
+// ErrObjectReferenced is return, when deleting an object other objects still reference.
+var ErrObjectReferenced = errors.New("fsobjectstore: object referenced")
+
+// ErrObjectNotInTrash is return, when restoring an object not in trash (or already purged).
+var ErrObjectNotInTrash = errors.New("fsobjectstore: object not in trash")
+
+// _trashDir handles the internal directory name of deleted objects awaiting purge
+const _trashDir = "trash"
+
+// _defTrashRetention handles the default duration deleted objects stay restorable
+const _defTrashRetention = 7 * 24 * time.Hour
+
+// PurgeReport captures outcome of purging trash
+type PurgeReport struct {
+	Purged      int
+	PurgedBytes int64
+	Sample      []cid.Cid
+	DryRun      bool
+}
+
+// Deleter defines the functions clients need to delete objects, and recover accidental deletions.
+type Deleter interface {
+	DeletableStore
+	RestoreObject(context.Context, cid.Cid) error
+	EmptyTrash(context.Context) (int, error)
+}
+
+var _ Deleter = (*fsObjectStoreService)(nil)
+
+// trashPath - returns file system path of deleted object in trash of given stripe; objects are trashed
+// within stripe holding them, so deletion is a same device rename
+func (f *fsObjectStoreService) trashPath(stripe string, c cid.Cid) string {
+	if len(objectLink(c)) == 0 {
+		return ""
+	}
+	return filepath.Join(stripe, _internalDir, _trashDir, c.String())
+}
+
+// DeleteObject - moves object with specified cid (aka content identifier) to trash, where it stays restorable
+// for trash retention (see `WithTrashRetention`) until garbage collection purges it. Objects still referenced
+// by other objects are not deleted.
+func (f *fsObjectStoreService) DeleteObject(ctx context.Context, c cid.Cid) error {
+	if err := f.authorize(ctx, OpDelete, c); err != nil {
+		return err
+	}
+	defer f.writeDone(ctx)
+	err := f.deleteObject(ctx, c)
+	f.audit(ctx, OpDelete, c, err)
+	return err
+}
+
+// deleteObject - moves object file to trash, stamping it as placed there at deletion time
+func (f *fsObjectStoreService) deleteObject(ctx context.Context, c cid.Cid) error {
+	if ctxErr := checkContextError(ctx, f.isDebug()); ctxErr != nil {
+		return ctxErr
+	}
+	objLink := f.objectPath(c)
+	info, err := os.Stat(objLink)
+	if errors.Is(err, os.ErrNotExist) {
+		return objectstore.ErrObjectNotExists
+	}
+	if err != nil {
+		return objectstore.ErrObjectReadingFailed
+	}
+	count, err := f.readRefCount(c)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrObjectReferenced
+	}
+	size := f.objectSize(objLink, info.Size())
+
+	trashLink := f.trashPath(f.stripeOf(objLink), c)
+	if err := os.MkdirAll(filepath.Dir(trashLink), 0777); err != nil {
+		log.Printf("err: creating trash directory failed: %s, %v\n", trashLink, err)
+		return objectstore.ErrObjectWritingFailed
+	}
+	if err := os.Rename(objLink, trashLink); err != nil {
+		log.Printf("err: moving object to trash failed: %s, %v\n", objLink, err)
+		return objectstore.ErrObjectWritingFailed
+	}
+	f.touch(trashKey(c), trashLink, true)
+	f.rememberAbsent(c.String())
+	f.verified.forget(c.String())
+	if f.isDebug() {
+		log.Printf("debug: deleted object: %s\n", c)
+	}
+	return f.journaled(JournalDelete, c, size)
+}
+
+// RestoreObject - moves deleted object with specified cid back from trash
+func (f *fsObjectStoreService) RestoreObject(ctx context.Context, c cid.Cid) error {
+	if err := f.authorize(ctx, OpWrite, c); err != nil {
+		return err
+	}
+	defer f.writeDone(ctx)
+	err := f.restoreObject(ctx, c)
+	f.audit(ctx, OpWrite, c, err)
+	return err
+}
+
+// restoreObject - moves object file back into bucket, stamped as written now, so garbage collection treats it as
+// newly written instead of by its deletion time; when object was created again meanwhile, trash copy is dropped
+func (f *fsObjectStoreService) restoreObject(ctx context.Context, c cid.Cid) error {
+	if ctxErr := checkContextError(ctx, f.isDebug()); ctxErr != nil {
+		return ctxErr
+	}
+	var stripe, trashLink string
+	var info os.FileInfo
+	for _, stripe = range f.stripeDirs() {
+		trashLink = f.trashPath(stripe, c)
+		var err error
+		if info, err = os.Stat(trashLink); err == nil {
+			break
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return objectstore.ErrObjectReadingFailed
+		}
+	}
+	if info == nil {
+		return ErrObjectNotInTrash
+	}
+	if f.has(c) {
+		os.Remove(trashLink)
+		return nil
+	}
+	objLink := filepath.Join(stripe, objectLink(c))
+	size := f.objectSize(trashLink, info.Size())
+	if err := placeFile(objLink, func() error { return os.Rename(trashLink, objLink) }); err != nil {
+		log.Printf("err: restoring object from trash failed: %s, %v\n", trashLink, err)
+		return objectstore.ErrObjectWritingFailed
+	}
+	f.touch(c.String(), objLink, true)
+	f.negative.remove(c.String())
+	if f.isDebug() {
+		log.Printf("debug: restored object: %s\n", c)
+	}
+	return f.journaled(JournalCreate, c, size)
+}
+
+// EmptyTrash - purges every deleted object regardless of trash retention, returning count of purged objects
+func (f *fsObjectStoreService) EmptyTrash(ctx context.Context) (int, error) {
+	if err := f.authorize(ctx, OpAdmin, cid.Undef); err != nil {
+		return 0, err
+	}
+	report, err := f.purgeTrash(ctx, f.now())
+	f.audit(ctx, OpAdmin, cid.Undef, err)
+	return report.Purged, err
+}
+
+// purgeTrash - permanently removes objects deleted before given time, along with their metadata and references;
+// on dry run (see `WithDryRun`) objects are only reported
+func (f *fsObjectStoreService) purgeTrash(ctx context.Context, before time.Time) (*PurgeReport, error) {
+	report := &PurgeReport{DryRun: isDryRun(ctx)}
+	t := NewProgressTracker(ctx, "purge")
+	defer t.Finish()
+	purged := map[string]struct{}{}
+	for _, stripe := range f.stripeDirs() {
+		if err := f.purgeStripeTrash(ctx, stripe, before, purged, report, t); err != nil {
+			return report, err
+		}
+	}
+	if f.isDebug() && report.Purged > 0 {
+		log.Printf("debug: purged trash: %s, %d objects, dry run %t\n", f.bucket, report.Purged, report.DryRun)
+	}
+	return report, nil
+}
+
+// purgeStripeTrash - removes objects deleted before given time from trash of given stripe, recording them in purged
+func (f *fsObjectStoreService) purgeStripeTrash(ctx context.Context, stripe string, before time.Time, purged map[string]struct{}, report *PurgeReport, t *ProgressTracker) error {
+	entries, err := ioutil.ReadDir(filepath.Join(stripe, _internalDir, _trashDir))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		log.Printf("err: reading trash failed: %s, %v\n", stripe, err)
+		return ErrGarbageCollectionFailed
+	}
+	t.Expect(int64(len(entries)))
+	for _, entry := range entries {
+		if ctxErr := checkContextError(ctx, f.isDebug()); ctxErr != nil {
+			return ctxErr
+		}
+		t.Add(1, entry.Size())
+		c, err := cid.Decode(entry.Name())
+		if err != nil || f.placedAt(trashKey(c), entry).After(before) || f.pinned(c) {
+			// objects pinned by snapshot views are purged once views are closed
+			continue
+		}
+		report.PurgedBytes += entry.Size()
+		if !report.DryRun {
+			if err := os.Remove(f.trashPath(stripe, c)); err != nil && !os.IsNotExist(err) {
+				log.Printf("err: purging trash failed: %s, %v\n", c, err)
+				return ErrGarbageCollectionFailed
+			}
+		}
+		if _, ok := purged[c.String()]; ok {
+			continue
+		}
+		purged[c.String()] = struct{}{}
+		report.Purged++
+		report.Sample = sample(report.Sample, c)
+		// object created again after deletion shares metadata and references with trash copy
+		if report.DryRun || f.has(c) {
+			continue
+		}
+		os.Remove(f.metaPath(c))
+		if err := f.removeRefs(ctx, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package fsstore
+
+import (
+	"context"
+	"errors"
+	"path"
+	"strings"
+
+	"github.com/igumus/go-objectstore-lib"
+	"github.com/ipfs/go-cid"
+	"github.com/ipld/go-ipld-prime/datamodel"
+	cidlink "github.com/ipld/go-ipld-prime/linking/cid"
+)
+
+// ErrPathNotExists is return, when path does not name an entry of tree manifest.
+var ErrPathNotExists = errors.New("fsobjectstore: path not exists")
+
+// ErrNotTreeManifest is return, when object addressed as tree manifest is not a directory node.
+var ErrNotTreeManifest = errors.New("fsobjectstore: not a tree manifest")
+
+// TreeEntry captures a file or directory of tree manifest, see `WithIngestTree`
+type TreeEntry struct {
+	Cid  cid.Cid
+	Size int64
+	Dir  bool
+}
+
+// TreeResolver defines the functions clients need to address files of tree manifests by path, e.g. to serve
+// ingested directories.
+type TreeResolver interface {
+	ResolvePath(context.Context, cid.Cid, string) (TreeEntry, error)
+}
+
+var _ TreeResolver = (*fsObjectStoreService)(nil)
+
+// ResolvePath - returns entry named by `/` separated path within tree manifest with root cid, following links of
+// directory nodes; empty path (or `/`) returns root directory itself. Files are objects as created (raw, or chunk
+// manifests, see `CreateChunked`).
+func (f *fsObjectStoreService) ResolvePath(ctx context.Context, root cid.Cid, p string) (TreeEntry, error) {
+	if err := f.authorize(ctx, OpRead, root); err != nil {
+		return TreeEntry{}, err
+	}
+	entry, err := f.resolvePath(withSystem(ctx), root, p)
+	f.audit(ctx, OpRead, root, err)
+	return entry, err
+}
+
+// resolvePath - walks directory nodes from root along segments of path
+func (f *fsObjectStoreService) resolvePath(ctx context.Context, root cid.Cid, p string) (TreeEntry, error) {
+	entries, size, err := f.readTreeNode(ctx, root)
+	if errors.Is(err, objectstore.ErrObjectNotExists) {
+		return TreeEntry{}, ErrPathNotExists
+	}
+	if err != nil {
+		return TreeEntry{}, err
+	}
+	current := TreeEntry{Cid: root, Size: size, Dir: true}
+	clean := strings.TrimPrefix(path.Clean("/"+p), "/")
+	if len(clean) == 0 {
+		return current, nil
+	}
+	for _, name := range strings.Split(clean, "/") {
+		if !current.Dir {
+			return TreeEntry{}, ErrPathNotExists
+		}
+		next, err := treeChild(entries, name)
+		if err != nil {
+			return TreeEntry{}, err
+		}
+		current, entries = next, nil
+		if kindOf(current.Cid) != KindManifest {
+			continue
+		}
+		// manifests are either directories, or chunked files
+		children, _, err := f.readTreeNode(ctx, current.Cid)
+		switch {
+		case err == nil:
+			current.Dir, entries = true, children
+		case errors.Is(err, ErrNotTreeManifest):
+		case errors.Is(err, objectstore.ErrObjectNotExists):
+			return TreeEntry{}, ErrPathNotExists
+		default:
+			return TreeEntry{}, err
+		}
+	}
+	return current, nil
+}
+
+// readTreeNode - reads directory node, returning its entries map along with total size
+func (f *fsObjectStoreService) readTreeNode(ctx context.Context, c cid.Cid) (datamodel.Node, int64, error) {
+	if kindOf(c) != KindManifest {
+		return nil, 0, ErrNotTreeManifest
+	}
+	node, err := f.ReadNode(ctx, c)
+	if errors.Is(err, ErrUnsupportedCodec) || errors.Is(err, ErrNodeDecodingFailed) {
+		return nil, 0, ErrNotTreeManifest
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	entries, err := node.LookupByString("entries")
+	if err != nil || entries.Kind() != datamodel.Kind_Map {
+		return nil, 0, ErrNotTreeManifest
+	}
+	var size int64
+	if sizeNode, err := node.LookupByString("size"); err == nil {
+		size, _ = sizeNode.AsInt()
+	}
+	return entries, size, nil
+}
+
+// treeChild - returns entry of directory node entries named name
+func treeChild(entries datamodel.Node, name string) (TreeEntry, error) {
+	child, err := entries.LookupByString(name)
+	if err != nil {
+		return TreeEntry{}, ErrPathNotExists
+	}
+	linkNode, err := child.LookupByString("cid")
+	if err != nil {
+		return TreeEntry{}, ErrNotTreeManifest
+	}
+	link, err := linkNode.AsLink()
+	if err != nil {
+		return TreeEntry{}, ErrNotTreeManifest
+	}
+	cl, ok := link.(cidlink.Link)
+	if !ok {
+		return TreeEntry{}, ErrNotTreeManifest
+	}
+	entry := TreeEntry{Cid: cl.Cid}
+	if sizeNode, err := child.LookupByString("size"); err == nil {
+		entry.Size, _ = sizeNode.AsInt()
+	}
+	return entry, nil
+}
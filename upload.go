@@ -0,0 +1,337 @@
+package fsstore
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/igumus/go-objectstore-lib"
+	"github.com/ipfs/go-cid"
+	mh "github.com/multiformats/go-multihash"
+)
+
+// ErrUploadNotExists is return, when resumed upload id does not belong to a pending upload.
+var ErrUploadNotExists = errors.New("fsobjectstore: upload not exists")
+
+// ErrUploadClosed is return, when a committed or aborted upload is used.
+var ErrUploadClosed = errors.New("fsobjectstore: upload closed")
+
+// _uploadsDir handles the internal directory name of pending uploads
+const _uploadsDir = "uploads"
+
+// _defUploadTTL handles the default duration pending uploads may stay idle before they are expired
+const _defUploadTTL = 24 * time.Hour
+
+// Upload feeds an object incrementally. Pending uploads survive process restarts, and can be
+// continued via `ResumeUpload` with their id, until they stay idle for upload TTL (see `WithUploadTTL`).
+type Upload interface {
+	io.Writer
+	// ID - returns identifier of upload, to resume it later
+	ID() string
+	// Size - returns count of bytes written so far
+	Size() int64
+	// Abort - discards upload
+	Abort() error
+	// Commit - stores written content as object, and returns its cid
+	Commit() (cid.Cid, error)
+	// Close - releases upload, keeping it pending to be resumed; closing a committed or aborted upload has
+	// no effect
+	Close() error
+}
+
+// ResumableUploader defines the functions clients need to upload objects incrementally.
+type ResumableUploader interface {
+	NewUpload(context.Context) (Upload, error)
+	ResumeUpload(context.Context, string) (Upload, error)
+}
+
+var _ ResumableUploader = (*fsObjectStoreService)(nil)
+
+// upload is a pending upload staged under internal uploads directory
+type upload struct {
+	f    *fsObjectStoreService
+	ctx  context.Context
+	id   string
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// NewUpload - starts a new pending upload
+func (f *fsObjectStoreService) NewUpload(ctx context.Context) (Upload, error) {
+	if err := f.authorize(ctx, OpWrite, cid.Undef); err != nil {
+		return nil, err
+	}
+	defer f.writeDone(ctx)
+	if ctxErr := checkContextError(ctx, f.isDebug()); ctxErr != nil {
+		return nil, ctxErr
+	}
+	dir := f.internalPath(_uploadsDir)
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		log.Printf("err: creating uploads directory failed: %s, %v\n", dir, err)
+		return nil, objectstore.ErrObjectWritingFailed
+	}
+	suffix := make([]byte, 16)
+	for {
+		if _, err := rand.Read(suffix); err != nil {
+			return nil, objectstore.ErrObjectWritingFailed
+		}
+		id := hex.EncodeToString(suffix)
+		file, err := os.OpenFile(f.internalPath(_uploadsDir, id), os.O_WRONLY|os.O_CREATE|os.O_EXCL|os.O_APPEND, 0666)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			log.Printf("err: creating upload failed: %s, %v\n", id, err)
+			return nil, objectstore.ErrObjectWritingFailed
+		}
+		f.stampUpload(file.Name())
+		if f.isDebug() {
+			log.Printf("debug: upload started: %s\n", id)
+		}
+		return &upload{f: f, ctx: ctx, id: id, file: file}, nil
+	}
+}
+
+// ResumeUpload - continues pending upload with given id, appending after bytes written so far
+func (f *fsObjectStoreService) ResumeUpload(ctx context.Context, id string) (Upload, error) {
+	if err := f.authorize(ctx, OpWrite, cid.Undef); err != nil {
+		return nil, err
+	}
+	defer f.writeDone(ctx)
+	if ctxErr := checkContextError(ctx, f.isDebug()); ctxErr != nil {
+		return nil, ctxErr
+	}
+	if _, err := hex.DecodeString(id); err != nil || len(id) == 0 {
+		return nil, ErrUploadNotExists
+	}
+	file, err := os.OpenFile(f.internalPath(_uploadsDir, id), os.O_WRONLY|os.O_APPEND, 0666)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrUploadNotExists
+	}
+	if err != nil {
+		log.Printf("err: opening upload failed: %s, %v\n", id, err)
+		return nil, objectstore.ErrObjectWritingFailed
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		log.Printf("err: opening upload failed: %s, %v\n", id, err)
+		return nil, objectstore.ErrObjectWritingFailed
+	}
+	if f.uploadExpired(info) {
+		// upload left idle for too long is expired, even when garbage collection did not remove it yet
+		discard(file)
+		return nil, ErrUploadNotExists
+	}
+	f.stampUpload(file.Name())
+	if f.isDebug() {
+		log.Printf("debug: upload resumed: %s, %d bytes\n", id, info.Size())
+	}
+	return &upload{f: f, ctx: ctx, id: id, file: file, size: info.Size()}, nil
+}
+
+// ID - returns identifier of upload
+func (u *upload) ID() string {
+	return u.id
+}
+
+// Size - returns count of bytes written so far
+func (u *upload) Size() int64 {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.size
+}
+
+// Write - appends p to upload
+func (u *upload) Write(p []byte) (int, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.file == nil {
+		return 0, ErrUploadClosed
+	}
+	if ctxErr := checkContextError(u.ctx, u.f.isDebug()); ctxErr != nil {
+		return 0, ctxErr
+	}
+	n, err := u.file.Write(p)
+	u.size += int64(n)
+	if err != nil {
+		log.Printf("err: writing upload failed: %s, %v\n", u.id, err)
+		return n, objectstore.ErrObjectWritingFailed
+	}
+	u.f.stampUpload(u.file.Name())
+	return n, nil
+}
+
+// Abort - discards upload
+func (u *upload) Abort() error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.file == nil {
+		return ErrUploadClosed
+	}
+	discard(u.file)
+	u.file = nil
+	if u.f.isDebug() {
+		log.Printf("debug: upload aborted: %s\n", u.id)
+	}
+	return nil
+}
+
+// Close - releases file of upload, keeping it pending
+func (u *upload) Close() error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.file == nil {
+		return nil
+	}
+	err := u.file.Close()
+	u.file = nil
+	if err != nil {
+		log.Printf("err: closing upload failed: %s, %v\n", u.id, err)
+		return objectstore.ErrObjectWritingFailed
+	}
+	return nil
+}
+
+// Commit - digests written content and renames upload into place as object
+func (u *upload) Commit() (cid.Cid, error) {
+	if err := u.f.admitWrite(u.ctx); err != nil {
+		u.f.audit(u.ctx, OpWrite, cid.Undef, err)
+		return cid.Undef, err
+	}
+	defer u.f.writeDone(u.ctx)
+	digest, err := u.commit()
+	u.f.audit(u.ctx, OpWrite, digest, err)
+	return digest, err
+}
+
+// commit - digests upload content and renames it into place as object
+func (u *upload) commit() (cid.Cid, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.file == nil {
+		return cid.Undef, ErrUploadClosed
+	}
+	if ctxErr := checkContextError(u.ctx, u.f.isDebug()); ctxErr != nil {
+		return cid.Undef, ctxErr
+	}
+	f := u.f
+	start := time.Now()
+	digest, err := u.digest()
+	if err != nil {
+		return cid.Undef, err
+	}
+	defer f.observe(u.ctx, "upload", start, digest, u.size)
+	if err := f.checkBlocked(u.ctx, OpWrite, digest); err != nil {
+		return cid.Undef, err
+	}
+	file := u.file
+	u.file = nil
+	if f.has(digest) {
+		f.stats.deduplicated()
+		discard(file)
+		f.notifyCreated(u.ctx, digest, u.size)
+		return digest, nil
+	}
+	err = f.scan(u.ctx, digest, u.size, func() (io.ReadCloser, error) {
+		return os.Open(file.Name())
+	})
+	if err != nil {
+		discard(file)
+		return cid.Undef, err
+	}
+	if err := f.sealFile(file.Name()); err != nil {
+		discard(file)
+		return cid.Undef, err
+	}
+	objLink := f.objectPath(digest)
+	if err := f.place(file, objLink); err != nil {
+		return cid.Undef, err
+	}
+	f.share(digest, objLink)
+	f.negative.remove(digest.String())
+	if f.isDebug() {
+		log.Printf("debug: upload committed: %s, %s\n", u.id, digest)
+	}
+	if err := f.journaled(JournalCreate, digest, u.size); err != nil {
+		return digest, err
+	}
+	f.notifyCreated(u.ctx, digest, u.size)
+	return digest, nil
+}
+
+// digest - computes cid of upload content, same as `CreateObject` would compute
+func (u *upload) digest() (cid.Cid, error) {
+	content, err := os.Open(u.file.Name())
+	if err != nil {
+		log.Printf("err: reading upload failed: %s, %v\n", u.id, err)
+		return cid.Undef, objectstore.ErrObjectReadingFailed
+	}
+	defer content.Close()
+	prefix := objectstore.DigestPrefix
+	hash, err := mh.SumStream(content, prefix.MhType, prefix.MhLength)
+	if err != nil {
+		log.Printf("err: digesting upload failed: %s, %v\n", u.id, err)
+		return cid.Undef, ErrDataDigestionFailed
+	}
+	return cid.NewCidV1(prefix.Codec, hash), nil
+}
+
+// stampUpload - stamps pending upload at path as used now by store clock, so its idle time agrees with store clock;
+// under wall clock, file system stamps it already as it is written
+func (f *fsObjectStoreService) stampUpload(path string) {
+	if _, ok := f.clock.(systemClock); ok {
+		return
+	}
+	now := f.now()
+	os.Chtimes(path, now, now)
+}
+
+// uploadExpired - checks whether pending upload stated as info stayed idle for upload TTL
+func (f *fsObjectStoreService) uploadExpired(info os.FileInfo) bool {
+	return f.uploadTTL > 0 && f.now().Sub(info.ModTime()) >= f.uploadTTL
+}
+
+// expireUploads - removes pending uploads idle for upload TTL (see `WithUploadTTL`), returning count of them; on
+// dry run they are only counted
+func (f *fsObjectStoreService) expireUploads(ctx context.Context, dryRun bool) (int, error) {
+	if f.uploadTTL == 0 {
+		return 0, nil
+	}
+	entries, err := ioutil.ReadDir(f.internalPath(_uploadsDir))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		log.Printf("err: reading uploads failed: %s, %v\n", f.bucket, err)
+		return 0, ErrGarbageCollectionFailed
+	}
+	expired := 0
+	for _, entry := range entries {
+		if ctxErr := checkContextError(ctx, f.isDebug()); ctxErr != nil {
+			return expired, ctxErr
+		}
+		if !entry.Mode().IsRegular() || !f.uploadExpired(entry) {
+			continue
+		}
+		if !dryRun {
+			if err := os.Remove(f.internalPath(_uploadsDir, entry.Name())); err != nil && !os.IsNotExist(err) {
+				log.Printf("err: expiring upload failed: %s, %v\n", entry.Name(), err)
+				return expired, ErrGarbageCollectionFailed
+			}
+		}
+		expired++
+	}
+	if f.isDebug() && expired > 0 {
+		log.Printf("debug: expired idle uploads: %s, %d uploads, dry run %t\n", f.bucket, expired, dryRun)
+	}
+	return expired, nil
+}
@@ -0,0 +1,270 @@
+package fsstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-cid"
+)
+
+// ErrUsageDisabled is return, when usage is requested but accounting is not enabled.
+var ErrUsageDisabled = errors.New("fsobjectstore: usage accounting disabled")
+
+// _usageFile handles the internal file name of persisted usage counters
+const _usageFile = "usage"
+
+// _usageDay handles the layout of days usage windows are keyed by
+const _usageDay = "2006-01-02"
+
+// _anonymousTenant handles the tenant usage of callers without tenant and principal is accounted to
+const _anonymousTenant = "anonymous"
+
+// TenantUsage captures count of reads and writes performed on behalf of a tenant, and content bytes they
+// transferred
+type TenantUsage struct {
+	Tenant       string `json:"tenant"`
+	Reads        int64  `json:"reads"`
+	Writes       int64  `json:"writes"`
+	BytesRead    int64  `json:"bytesRead"`
+	BytesWritten int64  `json:"bytesWritten"`
+}
+
+// UsageReporter defines the functions clients need to charge back tenants of shared stores for bandwidth they use.
+type UsageReporter interface {
+	Usage(ctx context.Context, from, to time.Time) ([]TenantUsage, error)
+}
+
+var _ UsageReporter = (*fsObjectStoreService)(nil)
+
+// tenantKey is context key of attached tenant
+type tenantKey struct{}
+
+// ContextWithTenant returns copy of ctx carrying tenant, usage of operations is accounted to (see
+// `WithUsageAccounting`)
+func ContextWithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// TenantFromContext returns tenant attached to ctx, and whether one was attached
+func TenantFromContext(ctx context.Context) (string, bool) {
+	t, ok := ctx.Value(tenantKey{}).(string)
+	return t, ok && len(t) > 0
+}
+
+// tenantOf - returns tenant usage of operation with ctx is accounted to: attached tenant, falling back to id of
+// attached principal
+func tenantOf(ctx context.Context) string {
+	if t, ok := TenantFromContext(ctx); ok {
+		return t
+	}
+	if p, ok := PrincipalFromContext(ctx); ok && len(p.ID) > 0 {
+		return p.ID
+	}
+	return _anonymousTenant
+}
+
+// usageWindow captures persisted usage of tenants during a day
+type usageWindow struct {
+	Day     string        `json:"day"`
+	Tenants []TenantUsage `json:"tenants"`
+}
+
+// usage accounts reads and writes of tenants in daily windows, retained for a while; windows are persisted along
+// with statistics checkpoints (see `WithStatsCheckpointInterval`)
+type usage struct {
+	mu        sync.Mutex
+	retention time.Duration
+	windows   map[string]map[string]*TenantUsage
+	opened    map[string]*TenantUsage
+	dirty     bool
+}
+
+// newUsage - creates empty usage accounting, retaining windows for retention
+func newUsage(retention time.Duration) *usage {
+	return &usage{retention: retention, windows: map[string]map[string]*TenantUsage{}, opened: map[string]*TenantUsage{}}
+}
+
+// record - accounts operation observed via `observe` of tenant, transferring size bytes at now
+func (u *usage) record(tenant, op string, size int64, now time.Time) {
+	if u == nil {
+		return
+	}
+	write := false
+	switch op {
+	case "read":
+	case "create", "put", "upload":
+		write = true
+	default:
+		return
+	}
+	day := now.UTC().Format(_usageDay)
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	window, ok := u.windows[day]
+	if !ok {
+		window = map[string]*TenantUsage{}
+		u.windows[day] = window
+		u.prune(now)
+	}
+	for _, counters := range []map[string]*TenantUsage{window, u.opened} {
+		t, ok := counters[tenant]
+		if !ok {
+			t = &TenantUsage{Tenant: tenant}
+			counters[tenant] = t
+		}
+		if write {
+			t.Writes++
+			t.BytesWritten += size
+		} else {
+			t.Reads++
+			t.BytesRead += size
+		}
+	}
+	u.dirty = true
+}
+
+// prune - drops windows of days before retention; requires u.mu held
+func (u *usage) prune(now time.Time) {
+	oldest := now.UTC().Add(-u.retention).Format(_usageDay)
+	for day := range u.windows {
+		if day < oldest {
+			delete(u.windows, day)
+			u.dirty = true
+		}
+	}
+}
+
+// total - sums usage of windows of days from (inclusive) to `to` (exclusive), by tenant
+func (u *usage) total(from, to time.Time) []TenantUsage {
+	first, last := from.UTC().Format(_usageDay), to.UTC().Format(_usageDay)
+	totals := map[string]*TenantUsage{}
+	u.mu.Lock()
+	for day, window := range u.windows {
+		if day < first || day >= last {
+			continue
+		}
+		for tenant, t := range window {
+			sum, ok := totals[tenant]
+			if !ok {
+				sum = &TenantUsage{Tenant: tenant}
+				totals[tenant] = sum
+			}
+			sum.Reads += t.Reads
+			sum.Writes += t.Writes
+			sum.BytesRead += t.BytesRead
+			sum.BytesWritten += t.BytesWritten
+		}
+	}
+	u.mu.Unlock()
+	return sortedUsage(totals)
+}
+
+// sortedUsage - returns usage of tenants, ordered by tenant
+func sortedUsage(counters map[string]*TenantUsage) []TenantUsage {
+	ret := make([]TenantUsage, 0, len(counters))
+	for _, t := range counters {
+		ret = append(ret, *t)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Tenant < ret[j].Tenant })
+	return ret
+}
+
+// Usage - returns reads and writes performed on behalf of each tenant during days from (inclusive) to `to`
+// (exclusive, zero meaning until now), ordered by tenant. Usage is accounted in daily (UTC) windows; see
+// `WithUsageAccounting`.
+func (f *fsObjectStoreService) Usage(ctx context.Context, from, to time.Time) ([]TenantUsage, error) {
+	if err := f.authorize(ctx, OpAdmin, cid.Undef); err != nil {
+		return nil, err
+	}
+	if f.usage == nil {
+		return nil, ErrUsageDisabled
+	}
+	if ctxErr := checkContextError(ctx, f.isDebug()); ctxErr != nil {
+		return nil, ctxErr
+	}
+	if to.IsZero() {
+		to = f.now().Add(24 * time.Hour)
+	}
+	ret := f.usage.total(from, to)
+	f.audit(ctx, OpAdmin, cid.Undef, nil)
+	return ret, nil
+}
+
+// recordUsage - accounts transfer of operation observed via `observe` to tenant of client ctx; operations performed
+// internally are not accounted
+func (f *fsObjectStoreService) recordUsage(ctx context.Context, op string, size int64) {
+	if f.usage == nil {
+		return
+	}
+	if system, _ := ctx.Value(systemKey{}).(bool); system {
+		return
+	}
+	f.usage.record(tenantOf(ctx), op, size, f.now())
+}
+
+// loadUsage - recovers persisted usage windows; unreadable usage is logged, and accounting starts over
+func (f *fsObjectStoreService) loadUsage() {
+	if f.usage == nil {
+		return
+	}
+	path := f.internalPath(_usageFile)
+	if !exists(path) {
+		return
+	}
+	data, err := f.readInternal(path)
+	windows := []usageWindow{}
+	if err == nil {
+		err = json.Unmarshal(data, &windows)
+	}
+	if err != nil {
+		log.Printf("warn: reading usage failed, usage is accounted from scratch: %s, %v\n", path, err)
+		return
+	}
+	u := f.usage
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	for _, w := range windows {
+		window := map[string]*TenantUsage{}
+		for i := range w.Tenants {
+			t := w.Tenants[i]
+			window[t.Tenant] = &t
+		}
+		u.windows[w.Day] = window
+	}
+	u.prune(f.now())
+	if f.isDebug() {
+		log.Printf("debug: usage recovered: %s, %d windows\n", path, len(u.windows))
+	}
+}
+
+// checkpointUsage - persists usage windows when they changed since last checkpoint
+func (f *fsObjectStoreService) checkpointUsage() {
+	if f.usage == nil || !f.isProvisioned() {
+		return
+	}
+	u := f.usage
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if !u.dirty {
+		return
+	}
+	windows := make([]usageWindow, 0, len(u.windows))
+	for day, window := range u.windows {
+		windows = append(windows, usageWindow{Day: day, Tenants: sortedUsage(window)})
+	}
+	sort.Slice(windows, func(i, j int) bool { return windows[i].Day < windows[j].Day })
+	data, err := json.Marshal(windows)
+	if err == nil {
+		err = f.writeInternal(f.internalPath(_usageFile), data)
+	}
+	if err != nil {
+		log.Printf("err: writing usage failed: %s, %v\n", f.bucket, err)
+		return
+	}
+	u.dirty = false
+}
@@ -0,0 +1,229 @@
+package fsstore
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/ipfs/go-cid"
+)
+
+// ErrVerificationFailed is return, when verifying objects of bucket could not complete.
+var ErrVerificationFailed = errors.New("fsobjectstore: verification failed")
+
+// FindingKind classifies problems found by verification
+type FindingKind string
+
+const (
+	// FindingCorrupt marks object whose content does not match its cid
+	FindingCorrupt FindingKind = "corrupt"
+	// FindingMissing marks object referenced by a manifest object, but absent from bucket
+	FindingMissing FindingKind = "missing"
+	// FindingExtra marks file in bucket that is not an object at its expected path
+	FindingExtra FindingKind = "extra"
+)
+
+// VerifyFinding captures a problem found by verification, with a hint how to remediate it
+type VerifyFinding struct {
+	Kind FindingKind
+	Cid  cid.Cid
+	Path string
+	Hint string
+}
+
+// VerifyReport captures outcome of verifying objects against their cids
+type VerifyReport struct {
+	Checked  int
+	Bytes    int64
+	Corrupt  []cid.Cid
+	Findings []VerifyFinding
+}
+
+// verifyRecord captures wire format of verification finding
+type verifyRecord struct {
+	Kind FindingKind `json:"kind"`
+	Cid  string      `json:"cid,omitempty"`
+	Path string      `json:"path,omitempty"`
+	Hint string      `json:"hint,omitempty"`
+}
+
+// verifySummary captures wire format of verification summary, closing JSON Lines report
+type verifySummary struct {
+	Kind     string `json:"kind"`
+	Checked  int    `json:"checked"`
+	Bytes    int64  `json:"bytes"`
+	Findings int    `json:"findings"`
+}
+
+// Verifier defines the functions clients need to detect corrupted objects.
+type Verifier interface {
+	Verify(context.Context) (*VerifyReport, error)
+}
+
+var _ Verifier = (*fsObjectStoreService)(nil)
+
+// Verify - rehashes every object of bucket and reports objects whose content not matches their cid, objects
+// referenced by manifests but missing, and files not being objects at their expected path.
+// Reading is paced to scrub rate (see `WithScrubRate`), so verification does not starve foreground traffic.
+func (f *fsObjectStoreService) Verify(ctx context.Context) (*VerifyReport, error) {
+	if err := f.authorize(ctx, OpAdmin, cid.Undef); err != nil {
+		return nil, err
+	}
+	report := &VerifyReport{}
+	t := NewProgressTracker(ctx, "verify")
+	defer t.Finish()
+	f.countObjects(ctx, t)
+	err := f.verifyObjects(ctx, report, t)
+	if err == nil {
+		err = f.verifyRefs(ctx, report)
+	}
+	if err != nil {
+		if ctxErr := checkContextError(ctx, f.isDebug()); ctxErr != nil {
+			return report, ctxErr
+		}
+		log.Printf("err: verifying bucket failed: %s, %v\n", f.bucket, err)
+		return report, ErrVerificationFailed
+	}
+	if f.isDebug() {
+		log.Printf("debug: verified bucket: %s, %d checked, %d corrupt, %d findings\n", f.bucket, report.Checked, len(report.Corrupt), len(report.Findings))
+	}
+	return report, nil
+}
+
+// verifyObjects - rehashes object files of every stripe, reporting corrupt objects and extra files
+func (f *fsObjectStoreService) verifyObjects(ctx context.Context, report *VerifyReport, t *ProgressTracker) error {
+	for _, stripe := range f.stripeDirs() {
+		err := f.walkFiles(ctx, stripe, func(path string, info os.FileInfo) error {
+			c, err := cid.Decode(filepath.Base(path))
+			if err != nil {
+				report.Findings = append(report.Findings, VerifyFinding{Kind: FindingExtra, Path: path,
+					Hint: "file is not an object of store, move it out of bucket"})
+				return nil
+			}
+			if expected := filepath.Join(stripe, objectLink(c)); filepath.Clean(path) != expected {
+				report.Findings = append(report.Findings, VerifyFinding{Kind: FindingExtra, Cid: c, Path: path,
+					Hint: fmt.Sprintf("object stored at unexpected path, move it to %s", expected)})
+				return nil
+			}
+			if err := f.scrubLimit.wait(ctx, info.Size()); err != nil {
+				return err
+			}
+			if err := f.io.wait(ctx, 0, info.Size()); err != nil {
+				return err
+			}
+			ok, err := f.verifyFile(ctx, c, path)
+			if errors.Is(err, ErrEnvelopeCorrupted) || errors.Is(err, ErrDeltaCorrupted) || errors.Is(err, ErrDecryptionFailed) {
+				ok, err = false, nil
+			}
+			if err != nil {
+				return err
+			}
+			report.Checked++
+			report.Bytes += info.Size()
+			t.Add(1, info.Size())
+			if ok {
+				f.verified.record(c.String(), info)
+			} else {
+				f.verified.forget(c.String())
+				log.Printf("err: object corrupted: %s\n", path)
+				report.Corrupt = append(report.Corrupt, c)
+				report.Findings = append(report.Findings, VerifyFinding{Kind: FindingCorrupt, Cid: c, Path: path,
+					Hint: "content does not match cid, restore object from a replica or backup, or delete it"})
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// verifyRefs - reports objects referenced by manifest objects, but missing from bucket
+func (f *fsObjectStoreService) verifyRefs(ctx context.Context, report *VerifyReport) error {
+	missing := map[string]struct{}{}
+	err := filepath.Walk(f.internalPath(_refsDir, _refsOut), func(path string, info os.FileInfo, err error) error {
+		if ctxErr := checkContextError(ctx, f.isDebug()); ctxErr != nil {
+			return ctxErr
+		}
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		if err != nil || info.IsDir() {
+			return err
+		}
+		parent, err := cid.Decode(info.Name())
+		if err != nil || !f.has(parent) {
+			// references of deleted manifests are dropped when they are purged
+			return nil
+		}
+		f.refMu.Lock()
+		children, err := f.readRefs(parent)
+		f.refMu.Unlock()
+		if err != nil {
+			return err
+		}
+		for _, child := range children {
+			if _, ok := missing[child.String()]; ok || f.has(child) {
+				continue
+			}
+			missing[child.String()] = struct{}{}
+			report.Findings = append(report.Findings, VerifyFinding{Kind: FindingMissing, Cid: child, Path: f.objectPath(child),
+				Hint: fmt.Sprintf("referenced by manifest %s, restore object from a replica or backup", parent)})
+		}
+		return nil
+	})
+	return err
+}
+
+// verifyFile - checks whether content of object file at path matches cid
+func (f *fsObjectStoreService) verifyFile(ctx context.Context, c cid.Cid, path string) (bool, error) {
+	data, err := f.load(ctx, path)
+	if err != nil {
+		return false, err
+	}
+	digest, err := c.Prefix().Sum(data)
+	if err != nil {
+		return false, ErrDataDigestionFailed
+	}
+	return digest.Equals(c), nil
+}
+
+// record - returns wire format of finding
+func (v VerifyFinding) record() verifyRecord {
+	rec := verifyRecord{Kind: v.Kind, Path: v.Path, Hint: v.Hint}
+	if v.Cid.Defined() {
+		rec.Cid = v.Cid.String()
+	}
+	return rec
+}
+
+// WriteJSONLines - prints findings of report to given writer as JSON Lines, one finding per line, closed by a
+// summary line of kind `summary`
+func (r *VerifyReport) WriteJSONLines(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	for _, finding := range r.Findings {
+		if err := encoder.Encode(finding.record()); err != nil {
+			return err
+		}
+	}
+	return encoder.Encode(verifySummary{Kind: "summary", Checked: r.Checked, Bytes: r.Bytes, Findings: len(r.Findings)})
+}
+
+// WriteCSV - prints findings of report to given writer as CSV, with `kind,cid,path,hint` header
+func (r *VerifyReport) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"kind", "cid", "path", "hint"})
+	for _, finding := range r.Findings {
+		rec := finding.record()
+		writer.Write([]string{string(rec.Kind), rec.Cid, rec.Path, rec.Hint})
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,114 @@
+package fsstore
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-cid"
+)
+
+// _defVerifyCacheSize handles the default count of verified objects remembered by verification cache
+const _defVerifyCacheSize = 4096
+
+// _defVerifyCacheTTL handles the default duration verification results are trusted, bounding how long bit rot
+// leaving file size and modification time intact goes unnoticed by verified reads
+const _defVerifyCacheTTL = 10 * time.Minute
+
+// verifyStamp captures stored file an object was verified in, and when result expires
+type verifyStamp struct {
+	size    int64
+	mtime   time.Time
+	expires time.Time
+}
+
+// verifyCache remembers objects recently verified against their cid, keyed by cid along with size and
+// modification time of their stored file, so verified reads (see `WithVerify`) of hot objects skip rehashing;
+// rewritten (or restored) files no longer match their stamp
+type verifyCache struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	entries map[string]verifyStamp
+}
+
+// newVerifyCache - creates verification cache remembering up to size objects for ttl; size below one disables it
+func newVerifyCache(size int, ttl time.Duration) *verifyCache {
+	return &verifyCache{size: size, ttl: ttl, entries: make(map[string]verifyStamp)}
+}
+
+// verified - checks whether object is remembered as verified in stored file of given info
+func (v *verifyCache) verified(key string, info os.FileInfo) bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	stamp, ok := v.entries[key]
+	if !ok {
+		return false
+	}
+	if time.Now().After(stamp.expires) || stamp.size != info.Size() || !stamp.mtime.Equal(info.ModTime()) {
+		delete(v.entries, key)
+		return false
+	}
+	return true
+}
+
+// record - remembers object as verified in stored file of given info, evicting expired (or if none, arbitrary)
+// entries when full
+func (v *verifyCache) record(key string, info os.FileInfo) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.size < 1 {
+		return
+	}
+	if _, ok := v.entries[key]; !ok && len(v.entries) >= v.size {
+		now := time.Now()
+		for k, stamp := range v.entries {
+			if now.After(stamp.expires) {
+				delete(v.entries, k)
+			}
+		}
+		for k := range v.entries {
+			if len(v.entries) < v.size {
+				break
+			}
+			delete(v.entries, k)
+		}
+	}
+	v.entries[key] = verifyStamp{size: info.Size(), mtime: info.ModTime(), expires: time.Now().Add(v.ttl)}
+}
+
+// forget - drops verification result of object, called once object is deleted or found corrupted
+func (v *verifyCache) forget(key string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	delete(v.entries, key)
+}
+
+// verifyRead - reads object and verifies its content against cid, unless verification cache holds a result for
+// its stored file. Stored file is stated before and after read, so results are only trusted (and recorded) when
+// file did not change meanwhile. Stores reading from replicas (see `WithReplica`) cache nothing, as content
+// read may not come from stored file.
+func (f *fsObjectStoreService) verifyRead(read func() ([]byte, error), c cid.Cid) ([]byte, error) {
+	key, path := c.String(), f.objectPath(c)
+	before, statErr := os.Stat(path)
+	data, err := read()
+	if err != nil {
+		return nil, err
+	}
+	after, afterErr := os.Stat(path)
+	stable := len(f.replicas) == 0 && statErr == nil && afterErr == nil && os.SameFile(before, after) &&
+		before.Size() == after.Size() && before.ModTime().Equal(after.ModTime())
+	hit := stable && f.verified.verified(key, before)
+	f.metrics.verifyLookup(hit)
+	if hit {
+		return data, nil
+	}
+	if err := verifyContent(c, data); err != nil {
+		f.verified.forget(key)
+		return nil, ErrVerificationFailed
+	}
+	if stable {
+		f.verified.record(key, before)
+	}
+	return data, nil
+}
@@ -0,0 +1,83 @@
+package fsstore
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ipfs/go-cid"
+)
+
+// walkObjects - walks object files of bucket across every stripe, skipping store internals and files not
+// named after a cid
+func (f *fsObjectStoreService) walkObjects(ctx context.Context, fn func(c cid.Cid, path string, info os.FileInfo) error) error {
+	for _, dir := range f.stripeDirs() {
+		if err := f.walkStripe(ctx, dir, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// walkStripe - walks object files under given bucket directory
+func (f *fsObjectStoreService) walkStripe(ctx context.Context, dir string, fn func(c cid.Cid, path string, info os.FileInfo) error) error {
+	return f.walkFiles(ctx, dir, func(path string, info os.FileInfo) error {
+		c, err := cid.Decode(filepath.Base(path))
+		if err != nil {
+			if f.isDebug() {
+				log.Printf("debug: skipping non object file: %s\n", path)
+			}
+			return nil
+		}
+		return fn(c, path, info)
+	})
+}
+
+// walkCursor captures position of a resumable walk of objects: stripes before Stripe are walked already, and
+// objects of Stripe up to Path (slash separated, relative to stripe) in walk order
+type walkCursor struct {
+	Stripe int    `json:"stripe"`
+	Path   string `json:"path,omitempty"`
+}
+
+// walkObjectsFrom - walks object files as `walkObjects` does, skipping those walked before cursor, and passes fn
+// cursor of every object for walk to be resumed after it
+func (f *fsObjectStoreService) walkObjectsFrom(ctx context.Context, from walkCursor, fn func(c cid.Cid, path string, info os.FileInfo, at walkCursor) error) error {
+	for i, dir := range f.stripeDirs() {
+		if i < from.Stripe {
+			continue
+		}
+		err := f.walkStripe(ctx, dir, func(c cid.Cid, path string, info os.FileInfo) error {
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			at := walkCursor{Stripe: i, Path: filepath.ToSlash(rel)}
+			if i == from.Stripe && !walkedAfter(at.Path, from.Path) {
+				return nil
+			}
+			return fn(c, path, info, at)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// walkedAfter - checks whether path is walked after cursor, comparing components as walk visits sorted directory
+// entries depth first; every path is walked after empty cursor
+func walkedAfter(path, cursor string) bool {
+	if len(cursor) == 0 {
+		return true
+	}
+	a, b := strings.Split(path, "/"), strings.Split(cursor, "/")
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return a[i] > b[i]
+		}
+	}
+	return len(a) > len(b)
+}
@@ -0,0 +1,173 @@
+package fsstore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-cid"
+)
+
+// errWatchUnsupported is return, when file system notifications are not supported on platform
+var errWatchUnsupported = errors.New("fsobjectstore: watching bucket not supported")
+
+// _watchSettle handles the duration files written by other processes must stay unchanged before they are picked
+// up, so files still being written (and objects store places itself) settle first
+const _watchSettle = time.Second
+
+// externalWrites collects object files other processes placed into bucket, until they settled
+type externalWrites struct {
+	mu      sync.Mutex
+	pending map[string]time.Time
+}
+
+// newExternalWrites - creates empty collection of external writes
+func newExternalWrites() *externalWrites {
+	return &externalWrites{pending: map[string]time.Time{}}
+}
+
+// add - records file at path written at now, postponing it when written again
+func (w *externalWrites) add(path string, now time.Time) {
+	w.mu.Lock()
+	w.pending[path] = now
+	w.mu.Unlock()
+}
+
+// settled - removes and returns files not written since before
+func (w *externalWrites) settled(before time.Time) []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	ret := []string{}
+	for path, written := range w.pending {
+		if written.Before(before) {
+			ret = append(ret, path)
+			delete(w.pending, path)
+		}
+	}
+	return ret
+}
+
+// startExternalWatch - watches bucket for object files placed by other processes (e.g. rsync restores or manual
+// copies) in background until store is closed, picking them up once they settled; lazily initialized store is
+// watched once provisioned
+func (f *fsObjectStoreService) startExternalWatch() {
+	writes := newExternalWrites()
+	f.background(func(ctx context.Context) {
+		ticker := time.NewTicker(_watchSettle)
+		defer ticker.Stop()
+		for !f.isProvisioned() {
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+		if err := f.watchBucket(ctx, writes); err != nil {
+			log.Printf("warn: watching bucket for external writes failed: %s, %v\n", f.bucket, err)
+		}
+	})
+	f.background(func(ctx context.Context) {
+		ticker := time.NewTicker(_watchSettle / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				for _, path := range writes.settled(time.Now().Add(-_watchSettle)) {
+					f.pickUp(ctx, path)
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	})
+}
+
+// enqueueDir - records files below dir as written, so files placed before dir was watched are picked up too
+func (f *fsObjectStoreService) enqueueDir(ctx context.Context, dir string, writes *externalWrites) {
+	now := time.Now()
+	f.walkFiles(ctx, dir, func(path string, info os.FileInfo) error {
+		writes.add(path, now)
+		return nil
+	})
+}
+
+// pickUp - validates object file another process placed at path, indexing it as created: file must be named after
+// cid, stored at path of cid, and its content must match cid. Objects store knows already are skipped.
+func (f *fsObjectStoreService) pickUp(ctx context.Context, path string) {
+	c, err := cid.Decode(filepath.Base(path))
+	if err != nil {
+		if f.isDebug() {
+			log.Printf("debug: skipping external non object file: %s\n", path)
+		}
+		return
+	}
+	info, err := os.Stat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return
+	}
+	if expected := filepath.Join(f.stripeOf(path), objectLink(c)); filepath.Clean(path) != expected {
+		log.Printf("warn: skipping external object at unexpected path: %s, expected %s\n", path, expected)
+		return
+	}
+	known, err := f.indexedCID(ctx, c)
+	if err != nil || known {
+		return
+	}
+	ok, err := f.verifyFile(ctx, c, path)
+	if err != nil {
+		log.Printf("err: verifying external object failed: %s, %v\n", path, err)
+		return
+	}
+	if !ok {
+		log.Printf("warn: skipping external object not matching its cid: %s\n", path)
+		return
+	}
+	if f.blocklist.blocked(c) {
+		log.Printf("warn: skipping external object on deny-list: %s\n", path)
+		return
+	}
+	size := f.objectSize(path, info.Size())
+	err = f.scan(ctx, c, size, func() (io.ReadCloser, error) {
+		data, err := f.load(ctx, path)
+		if err != nil {
+			return nil, err
+		}
+		return ioutil.NopCloser(bytes.NewReader(data)), nil
+	})
+	if err != nil {
+		log.Printf("warn: skipping external object refused by content scanner: %s, %v\n", path, err)
+		return
+	}
+	f.verified.record(c.String(), info)
+	f.negative.remove(c.String())
+	if err := f.journaled(JournalCreate, c, size); err != nil {
+		log.Printf("err: indexing external object failed: %s, %v\n", path, err)
+		return
+	}
+	f.notifyCreated(ctx, c, size)
+	if f.isDebug() {
+		log.Printf("debug: picked up external object: %s, %d bytes\n", c, size)
+	}
+}
+
+// indexedCID - checks whether cid index holds cid, loading index by walking bucket when not loaded
+func (f *fsObjectStoreService) indexedCID(ctx context.Context, c cid.Cid) (bool, error) {
+	s := f.stats
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.indexed {
+		s.indexing = true
+		if err := f.loadWalk(ctx, s); err != nil {
+			return false, err
+		}
+	}
+	_, ok := s.index[c.String()]
+	return ok, nil
+}
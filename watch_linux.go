@@ -0,0 +1,106 @@
+package fsstore
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// _watchMask handles the inotify(7) events of bucket directories files are picked up on
+const _watchMask = unix.IN_CLOSE_WRITE | unix.IN_MOVED_TO | unix.IN_CREATE
+
+// _watchPoll handles the milliseconds inotify descriptor is polled for, before context is checked again
+const _watchPoll = 500
+
+// watchBucket - records object files placed into (non internal) directories of every stripe as written, until ctx
+// is done; directories created meanwhile are watched as they appear
+func (f *fsObjectStoreService) watchBucket(ctx context.Context, writes *externalWrites) error {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return err
+	}
+	defer unix.Close(fd)
+	dirs := map[int]string{}
+	watch := func(root string) {
+		filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil || !info.IsDir() {
+				return nil
+			}
+			if f.isInternal(path) {
+				return filepath.SkipDir
+			}
+			wd, err := unix.InotifyAddWatch(fd, path, _watchMask)
+			if err != nil {
+				log.Printf("warn: watching directory failed: %s, %v\n", path, err)
+				return filepath.SkipDir
+			}
+			dirs[wd] = path
+			return nil
+		})
+	}
+	for _, dir := range f.stripeDirs() {
+		watch(dir)
+	}
+
+	buf := make([]byte, 64<<10)
+	fds := []unix.PollFd{{Fd: int32(fd), Events: unix.POLLIN}}
+	for {
+		if ctx.Err() != nil {
+			return nil
+		}
+		n, err := unix.Poll(fds, _watchPoll)
+		if err == unix.EINTR || n == 0 {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		n, err = unix.Read(fd, buf)
+		if err == unix.EAGAIN || err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		for offset := 0; offset+unix.SizeofInotifyEvent <= n; {
+			event := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			name := string(bytes.TrimRight(buf[offset+unix.SizeofInotifyEvent:offset+unix.SizeofInotifyEvent+int(event.Len)], "\x00"))
+			offset += unix.SizeofInotifyEvent + int(event.Len)
+
+			switch {
+			case event.Mask&unix.IN_Q_OVERFLOW != 0:
+				log.Printf("warn: bucket watch overflowed, rescanning: %s\n", f.bucket)
+				for _, dir := range f.stripeDirs() {
+					f.enqueueDir(ctx, dir, writes)
+				}
+				continue
+			case event.Mask&unix.IN_IGNORED != 0:
+				// directory was removed, e.g. pruned
+				delete(dirs, int(event.Wd))
+				continue
+			}
+			dir, ok := dirs[int(event.Wd)]
+			if !ok || len(name) == 0 {
+				continue
+			}
+			path := filepath.Join(dir, name)
+			if event.Mask&unix.IN_ISDIR != 0 {
+				if event.Mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0 && !f.isInternal(path) {
+					watch(path)
+					f.enqueueDir(ctx, path, writes)
+				}
+				continue
+			}
+			if event.Mask&(unix.IN_CLOSE_WRITE|unix.IN_MOVED_TO) != 0 {
+				writes.add(path, now)
+			}
+		}
+	}
+}
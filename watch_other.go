@@ -0,0 +1,10 @@
+//go:build !linux
+
+package fsstore
+
+import "context"
+
+// watchBucket - returns errWatchUnsupported, since file system notifications are only watched on linux
+func (f *fsObjectStoreService) watchBucket(ctx context.Context, writes *externalWrites) error {
+	return errWatchUnsupported
+}
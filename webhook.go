@@ -0,0 +1,173 @@
+package fsstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-cid"
+)
+
+// _webhookQueue handles the count of pending webhook deliveries, beyond which deliveries are dropped
+const _webhookQueue = 1024
+
+// _webhookAttempts handles the count of delivery attempts of a webhook notification
+const _webhookAttempts = 5
+
+// _webhookBackoff handles the initial backoff between delivery attempts, doubled on each retry
+const _webhookBackoff = 500 * time.Millisecond
+
+// _webhookTimeout handles the timeout of a single delivery attempt
+const _webhookTimeout = 10 * time.Second
+
+// SignatureHeader is the request header carrying hex encoded HMAC-SHA256 signature of webhook body,
+// prefixed with `sha256=`
+const SignatureHeader = "X-Fsstore-Signature"
+
+// CreateNotification captures body of webhook notification POSTed after object creation
+type CreateNotification struct {
+	Cid       string    `json:"cid"`
+	Size      int64     `json:"size"`
+	Bucket    string    `json:"bucket"`
+	Timestamp time.Time `json:"timestamp"`
+	RequestID string    `json:"requestId,omitempty"`
+	TraceID   string    `json:"traceId,omitempty"`
+}
+
+// webhook delivers create notifications to an url in background
+type webhook struct {
+	url    string
+	secret []byte
+	client *http.Client
+	queue  chan CreateNotification
+	debug  bool
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// newWebhook - creates webhook delivering to url, and starts its delivery worker
+func newWebhook(url, secret string, debug bool) *webhook {
+	w := &webhook{
+		url:    url,
+		secret: []byte(secret),
+		client: &http.Client{Timeout: _webhookTimeout},
+		queue:  make(chan CreateNotification, _webhookQueue),
+		debug:  debug,
+	}
+	go w.run()
+	return w
+}
+
+// notify - queues create notification, dropping it when queue is full so creation never blocks
+func (w *webhook) notify(c cid.Cid, size int64, bucket string, meta RequestMeta) {
+	n := CreateNotification{Cid: c.String(), Size: size, Bucket: bucket, Timestamp: time.Now().UTC(),
+		RequestID: meta.RequestID, TraceID: meta.TraceID}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return
+	}
+	select {
+	case w.queue <- n:
+	default:
+		log.Printf("err: webhook queue full, dropping notification: %s\n", n.Cid)
+	}
+}
+
+// close - stops accepting notifications, letting worker drain already queued ones
+func (w *webhook) close() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.closed {
+		w.closed = true
+		close(w.queue)
+	}
+}
+
+// run - delivers queued notifications one by one
+func (w *webhook) run() {
+	for n := range w.queue {
+		w.deliver(n)
+	}
+}
+
+// deliver - POSTs signed notification, retrying with exponential backoff
+func (w *webhook) deliver(n CreateNotification) {
+	body, err := json.Marshal(n)
+	if err != nil {
+		return
+	}
+	mac := hmac.New(sha256.New, w.secret)
+	mac.Write(body)
+	signature := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	backoff := _webhookBackoff
+	for attempt := 1; attempt <= _webhookAttempts; attempt++ {
+		err := w.post(body, signature, n)
+		if err == nil {
+			if w.debug {
+				log.Printf("debug: webhook delivered: %s, %s\n", w.url, n.Cid)
+			}
+			return
+		}
+		log.Printf("err: webhook delivery failed: %s, %s, attempt %d, %v\n", w.url, n.Cid, attempt, err)
+		if attempt < _webhookAttempts {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+}
+
+// post - sends single delivery attempt, propagating request metadata of notification as headers
+func (w *webhook) post(body []byte, signature string, n CreateNotification) error {
+	req, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, signature)
+	if len(n.RequestID) > 0 {
+		req.Header.Set(RequestIDHeader, n.RequestID)
+	}
+	if len(n.TraceID) > 0 {
+		req.Header.Set(TraceIDHeader, n.TraceID)
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("fsobjectstore: webhook responded %s", resp.Status)
+	}
+	return nil
+}
+
+// notifyCreated - notifies create webhook (if configured) about successfully created object, on behalf of
+// request of ctx
+func (f *fsObjectStoreService) notifyCreated(ctx context.Context, c cid.Cid, size int64) {
+	if f.webhook != nil {
+		f.webhook.notify(c, size, f.bucket, RequestMetaFromContext(ctx))
+	}
+}
+
+// VerifyWebhookSignature - checks whether signature header value is a valid signature of body with secret,
+// so webhook receivers can authenticate notifications
+func VerifyWebhookSignature(body []byte, signature, secret string) bool {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	expected := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
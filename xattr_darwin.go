@@ -0,0 +1,6 @@
+package fsstore
+
+import "golang.org/x/sys/unix"
+
+// errNoAttr handles the platform errno of absent extended attribute
+const errNoAttr = unix.ENOATTR
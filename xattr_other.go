@@ -0,0 +1,13 @@
+//go:build !linux && !darwin
+
+package fsstore
+
+// getXattr - returns errXattrUnsupported, since extended attributes are not supported on this platform
+func getXattr(path, name string) ([]byte, error) {
+	return nil, errXattrUnsupported
+}
+
+// setXattr - returns errXattrUnsupported, since extended attributes are not supported on this platform
+func setXattr(path, name string, value []byte) error {
+	return errXattrUnsupported
+}
@@ -0,0 +1,44 @@
+//go:build linux || darwin
+
+package fsstore
+
+import (
+	"errors"
+
+	"golang.org/x/sys/unix"
+)
+
+// getXattr - reads extended attribute of path; errXattrNotExists is returned when attribute is absent,
+// errXattrUnsupported when file system does not support extended attributes
+func getXattr(path, name string) ([]byte, error) {
+	size, err := unix.Getxattr(path, name, nil)
+	if err != nil {
+		return nil, xattrError(err)
+	}
+	buf := make([]byte, size)
+	size, err = unix.Getxattr(path, name, buf)
+	if err != nil {
+		return nil, xattrError(err)
+	}
+	return buf[:size], nil
+}
+
+// setXattr - writes extended attribute of path; errXattrUnsupported is returned when file system
+// does not support extended attributes
+func setXattr(path, name string, value []byte) error {
+	return xattrError(unix.Setxattr(path, name, value, 0))
+}
+
+// xattrError - maps platform errors of extended attribute calls
+func xattrError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, errNoAttr):
+		return errXattrNotExists
+	case errors.Is(err, unix.ENOTSUP), errors.Is(err, unix.EOPNOTSUPP):
+		return errXattrUnsupported
+	default:
+		return err
+	}
+}